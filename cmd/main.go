@@ -18,6 +18,8 @@ import (
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
 	"github.com/teslamotors/fleet-telemetry/server/monitoring"
 	"github.com/teslamotors/fleet-telemetry/server/streaming"
+	"github.com/teslamotors/fleet-telemetry/telemetry/alerts"
+	"github.com/teslamotors/fleet-telemetry/telemetry/state"
 )
 
 // shutdownDrainTimeout bounds how long a SIGTERM/SIGINT drain waits for open
@@ -89,9 +91,27 @@ func startServer(ctx context.Context, stopSignal context.CancelFunc, config *con
 	registry := streaming.NewSocketRegistry()
 
 	airbrakeHandler := airbrake.NewAirbrakeHandler(airbrakeNotifier)
+	reloader := streaming.NewReloader(config, airbrakeHandler, logger)
+
+	var stateStore *state.Store
+	if config.State != nil {
+		if stateStore, err = state.NewStore(config.State, config.MetricCollector, logger); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := stateStore.Close(); closeErr != nil {
+				logger.ErrorLog("state_store_close_error", closeErr, nil)
+			}
+		}()
+	}
+
+	var alertTracker *alerts.Tracker
+	if config.AlertLifecycle != nil {
+		alertTracker = alerts.NewTracker(config.AlertLifecycle, config.MetricCollector, logger)
+	}
 
 	if config.StatusPort > 0 {
-		monitoring.StartStatusServer(config, logger, airbrakeHandler)
+		monitoring.StartStatusServer(config, logger, airbrakeHandler, registry, reloader, stateStore, alertTracker)
 	}
 	if config.Monitoring != nil {
 		monitoring.StartServerMetrics(config, logger, registry)
@@ -101,10 +121,29 @@ func startServer(ctx context.Context, stopSignal context.CancelFunc, config *con
 	if err != nil {
 		return err
 	}
-	server, _, err := streaming.InitServer(config, airbrakeHandler, producerRules, logger, registry)
+	server, socketServer, err := streaming.InitServer(config, airbrakeHandler, producerRules, logger, registry)
 	if err != nil {
 		return err
 	}
+	if stateStore != nil {
+		socketServer.SetObserver(stateStore)
+	}
+	if alertTracker != nil {
+		socketServer.AddDeriver(alertTracker)
+	}
+	reloader.Start(socketServer, dispatchers)
+
+	// SIGHUP re-reads the config file and applies it without dropping vehicle connections
+	reloadSignal := make(chan os.Signal, 1)
+	signal.Notify(reloadSignal, syscall.SIGHUP)
+	defer signal.Stop(reloadSignal)
+	go func() {
+		for range reloadSignal {
+			logger.ActivityLog("reload_signal_received", nil)
+			// errors are logged by the reloader, the current configuration is kept
+			_ = reloader.Reload()
+		}
+	}()
 
 	if server.TLSConfig, err = config.ExtractServiceTLSConfig(logger); err != nil {
 		return err
@@ -127,14 +166,20 @@ func startServer(ctx context.Context, stopSignal context.CancelFunc, config *con
 		// so each socket's ProcessTelemetry runs its normal teardown.
 		err = gracefulShutdown(server, registry, logger)
 	}
+	if closeErr := socketServer.Close(); closeErr != nil {
+		logger.ErrorLog("server_close_error", closeErr, nil)
+	}
 
-	for dispatcher, producer := range dispatchers {
+	for dispatcher, producer := range reloader.Producers() {
 		logger.ActivityLog("attempting_to_close", logrus.LogInfo{"dispatcher": dispatcher})
 		// We don't care if this fails. If it does, we'll just continue on.
 		if dispatcherCloseErr := producer.Close(); dispatcherCloseErr != nil {
 			logger.ErrorLog("producer_close_error", dispatcherCloseErr, logrus.LogInfo{"dispatcher": dispatcher})
 		}
 	}
+	if config.StreamHub != nil {
+		config.StreamHub.Close()
+	}
 	logger.ActivityLog("stopped_server", nil)
 	return err
 }
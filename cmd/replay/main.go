@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/teslamotors/fleet-telemetry/config"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/tools/lib"
+	"github.com/teslamotors/fleet-telemetry/tools/replay"
+)
+
+const testCertsDirectory = "test/integration/test-certs/"
+
+var (
+	serverURL          string
+	configFile         string
+	speed              float64
+	ackTimeout         time.Duration
+	clientCACert       string
+	clientCAKey        string
+	serverCA           string
+	insecureSkipVerify bool
+	clientVersion      string
+	networkInterface   string
+)
+
+func main() {
+	loadFlags()
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <capture dir or file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	files, err := captureFiles(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats := replay.NewStats()
+	sink, closeProducers, err := buildSink(stats)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeProducers()
+
+	start := time.Now()
+	log.Printf("replaying %d capture files at speed %v", len(files), speed)
+	if err := replay.Run(ctx, &replay.Config{Files: files, Speed: speed}, sink, stats); err != nil {
+		log.Fatal(err)
+	}
+	stats.Report(time.Since(start)).Print(os.Stdout)
+}
+
+// captureFiles expands the capture directories of the arguments into their files, oldest first
+func captureFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one capture dir or file is required")
+	}
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		dirFiles, err := capture.ListFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// buildSink returns the sink replaying into the server at -url, or into the producers of -config.
+// The returned func closes the producers once the replay is done.
+func buildSink(stats *replay.Stats) (replay.Sink, func(), error) {
+	switch {
+	case serverURL != "" && configFile != "":
+		return nil, nil, errors.New("-url and -config are mutually exclusive")
+	case serverURL != "":
+		dialer, err := buildDialer()
+		if err != nil {
+			return nil, nil, err
+		}
+		return replay.NewServerSink(serverURL, dialer, ackTimeout, stats), func() {}, nil
+	case configFile != "":
+		return buildProducerSink()
+	}
+	return nil, nil, errors.New("either -url or -config is required")
+}
+
+func buildDialer() (*lib.VehicleDialer, error) {
+	clientCA, err := lib.LoadTestCertAndKey(clientCACert, clientCAKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var rootCAs *x509.CertPool
+	if serverCA != "" {
+		pem, err := os.ReadFile(serverCA)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs = x509.NewCertPool()
+		rootCAs.AppendCertsFromPEM(pem)
+	}
+
+	return &lib.VehicleDialer{
+		ClientCA:           clientCA,
+		RootCAs:            rootCAs,
+		InsecureSkipVerify: insecureSkipVerify,
+		ClientVersion:      clientVersion,
+		NetworkInterface:   networkInterface,
+	}, nil
+}
+
+func buildProducerSink() (replay.Sink, func(), error) {
+	logger, err := logrus.NewBasicLogrusLogger("fleet-telemetry-replay")
+	if err != nil {
+		return nil, nil, err
+	}
+	conf, err := config.LoadApplicationConfigurationFile(configFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	producers, producerRules, err := conf.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), logger, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// reliable acks have no vehicle to be sent to
+	go func() {
+		for {
+			<-conf.AckChan
+		}
+	}()
+
+	closeProducers := func() {
+		for dispatcher, producer := range producers {
+			if err := producer.Close(); err != nil {
+				logger.ErrorLog("producer_close_error", err, logrus.LogInfo{"dispatcher": dispatcher})
+			}
+		}
+	}
+	return replay.NewProducerSink(producerRules, conf.TransmitDecodedRecords, logger), closeProducers, nil
+}
+
+func loadFlags() {
+	flag.StringVar(&serverURL, "url", "", "websocket url of the telemetry server to replay into, ex.: wss://localhost:4443")
+	flag.StringVar(&configFile, "config", "", "application configuration file whose producers the records are dispatched to, instead of a server")
+	flag.Float64Var(&speed, "speed", 1, "pace of the replay relative to the capture, 0 replays as fast as possible")
+	flag.DurationVar(&ackTimeout, "ack-timeout", 10*time.Second, "how long to wait for the responses of the server before disconnecting")
+	flag.StringVar(&clientCACert, "client-ca-cert", testCertsDirectory+"vehicle_device.CA.cert", "CA certificate signing the vehicle certificates, must be trusted by the server")
+	flag.StringVar(&clientCAKey, "client-ca-key", testCertsDirectory+"vehicle_device.CA.key", "private key of the client CA")
+	flag.StringVar(&serverCA, "server-ca", "", "CA certificate verifying the server, defaults to the system pool")
+	flag.BoolVar(&insecureSkipVerify, "insecure", false, "skip the verification of the server certificate")
+	flag.StringVar(&clientVersion, "client-version", "replay", "value of the Version header")
+	flag.StringVar(&networkInterface, "network-interface", "wifi", "value of the X-Network-Interface header")
+}
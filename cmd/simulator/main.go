@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/teslamotors/fleet-telemetry/tools/lib"
+	"github.com/teslamotors/fleet-telemetry/tools/simulator"
+)
+
+const testCertsDirectory = "test/integration/test-certs/"
+
+var (
+	serverURL          string
+	vehicles           int
+	connectRate        float64
+	messageInterval    time.Duration
+	duration           time.Duration
+	ackTimeout         time.Duration
+	records            string
+	vinPrefix          string
+	clientCACert       string
+	clientCAKey        string
+	serverCA           string
+	insecureSkipVerify bool
+	clientVersion      string
+	networkInterface   string
+	reportInterval     time.Duration
+)
+
+func main() {
+	loadFlags()
+	flag.Parse()
+
+	config, err := buildConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats := simulator.NewStats()
+	start := time.Now()
+	if reportInterval > 0 {
+		ticker := time.NewTicker(reportInterval)
+		defer ticker.Stop()
+		go func() {
+			for range ticker.C {
+				stats.Report(time.Since(start)).Print(os.Stdout)
+			}
+		}()
+	}
+
+	log.Printf("simulating %d vehicles against %s", config.Vehicles, config.URL)
+	if err := simulator.Run(ctx, config, stats); err != nil {
+		log.Fatal(err)
+	}
+	stats.Report(time.Since(start)).Print(os.Stdout)
+}
+
+func buildConfig() (*simulator.Config, error) {
+	weights, err := simulator.ParseRecordWeights(records)
+	if err != nil {
+		return nil, err
+	}
+	clientCA, err := lib.LoadTestCertAndKey(clientCACert, clientCAKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var rootCAs *x509.CertPool
+	if serverCA != "" {
+		pem, err := os.ReadFile(serverCA)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs = x509.NewCertPool()
+		rootCAs.AppendCertsFromPEM(pem)
+	}
+
+	return &simulator.Config{
+		URL:                serverURL,
+		Vehicles:           vehicles,
+		ConnectRate:        connectRate,
+		MessageInterval:    messageInterval,
+		Duration:           duration,
+		AckTimeout:         ackTimeout,
+		RecordWeights:      weights,
+		VinPrefix:          vinPrefix,
+		ClientCA:           clientCA,
+		RootCAs:            rootCAs,
+		InsecureSkipVerify: insecureSkipVerify,
+		ClientVersion:      clientVersion,
+		NetworkInterface:   networkInterface,
+	}, nil
+}
+
+func loadFlags() {
+	flag.StringVar(&serverURL, "url", "wss://localhost:4443", "websocket url of the telemetry server")
+	flag.IntVar(&vehicles, "vehicles", 10, "number of concurrent vehicle websockets")
+	flag.Float64Var(&connectRate, "connect-rate", 10, "websockets opened per second while ramping up")
+	flag.DurationVar(&messageInterval, "message-interval", time.Second, "time between two messages of a vehicle")
+	flag.DurationVar(&duration, "duration", time.Minute, "duration of the simulation, 0 runs until interrupted")
+	flag.DurationVar(&ackTimeout, "ack-timeout", 10*time.Second, "how long to wait for outstanding responses before disconnecting")
+	flag.StringVar(&records, "records", "V=8,alerts=1,errors=1,connectivity=1", "record types to send with their relative weight")
+	flag.StringVar(&vinPrefix, "vin-prefix", "SIMVIN", "prefix of the simulated VINs, followed by the vehicle number")
+	flag.StringVar(&clientCACert, "client-ca-cert", testCertsDirectory+"vehicle_device.CA.cert", "CA certificate signing the vehicle certificates, must be trusted by the server")
+	flag.StringVar(&clientCAKey, "client-ca-key", testCertsDirectory+"vehicle_device.CA.key", "private key of the client CA")
+	flag.StringVar(&serverCA, "server-ca", "", "CA certificate verifying the server, defaults to the system pool")
+	flag.BoolVar(&insecureSkipVerify, "insecure", false, "skip the verification of the server certificate")
+	flag.StringVar(&clientVersion, "client-version", "simulator", "value of the Version header")
+	flag.StringVar(&networkInterface, "network-interface", "wifi", "value of the X-Network-Interface header")
+	flag.DurationVar(&reportInterval, "report-interval", 0, "interval of intermediate reports, 0 disables them")
+}
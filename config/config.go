@@ -1,14 +1,17 @@
 package config
 
 import (
-	"context"
 	"crypto/tls"
 	"crypto/x509"
 	_ "embed" //Used for default CAs
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,22 +21,41 @@ import (
 	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	githublogrus "github.com/sirupsen/logrus"
 
+	_ "github.com/teslamotors/fleet-telemetry/datastore/file" // registers the file dispatcher
 	"github.com/teslamotors/fleet-telemetry/datastore/googlepubsub"
 	"github.com/teslamotors/fleet-telemetry/datastore/kafka"
 	"github.com/teslamotors/fleet-telemetry/datastore/kinesis"
 	"github.com/teslamotors/fleet-telemetry/datastore/mqtt"
 	"github.com/teslamotors/fleet-telemetry/datastore/nats"
+	_ "github.com/teslamotors/fleet-telemetry/datastore/parquet" // registers the parquet dispatcher
 	"github.com/teslamotors/fleet-telemetry/datastore/simple"
+	"github.com/teslamotors/fleet-telemetry/datastore/spool"
+	"github.com/teslamotors/fleet-telemetry/datastore/stream"
+	_ "github.com/teslamotors/fleet-telemetry/datastore/webhook" // registers the webhook dispatcher
 	"github.com/teslamotors/fleet-telemetry/datastore/zmq"
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/metrics"
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/ackpolicy"
+	"github.com/teslamotors/fleet-telemetry/telemetry/alerts"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/telemetry/deadletter"
+	"github.com/teslamotors/fleet-telemetry/telemetry/dedup"
+	"github.com/teslamotors/fleet-telemetry/telemetry/delta"
+	"github.com/teslamotors/fleet-telemetry/telemetry/derived"
+	"github.com/teslamotors/fleet-telemetry/telemetry/filter"
+	"github.com/teslamotors/fleet-telemetry/telemetry/geofence"
+	"github.com/teslamotors/fleet-telemetry/telemetry/session"
+	"github.com/teslamotors/fleet-telemetry/telemetry/state"
 	"github.com/teslamotors/fleet-telemetry/telemetry/tracing"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 )
 
 const (
 	airbrakeProjectKeyEnv = "AIRBRAKE_PROJECT_KEY"
+	adminTokenEnv         = "ADMIN_TOKEN"
+	streamTokenEnv        = "STREAM_TOKEN"
 )
 
 // Config object for server
@@ -47,6 +69,9 @@ type Config struct {
 	// Status Port is used to check whether service is live or not
 	StatusPort int `json:"status_port,omitempty"`
 
+	// Admin enables the authenticated admin API on the status port
+	Admin *Admin `json:"admin,omitempty"`
+
 	// TLS contains certificates & CA info for the webserver
 	TLS *TLS `json:"tls,omitempty"`
 
@@ -59,11 +84,19 @@ type Config struct {
 	// ReliableAckSources is a mapping of record types to a dispatcher that will be used for reliable ack
 	ReliableAckSources map[string]telemetry.Dispatcher `json:"reliable_ack_sources,omitempty"`
 
+	// ReliableAckPolicies is a mapping of record types to the dispatchers which must confirm their records before
+	// the vehicle gets its ack, any, all or a number of them. A record type uses either a source or a policy.
+	ReliableAckPolicies map[string]*ackpolicy.Policy `json:"reliable_ack_policies,omitempty"`
+
 	// Kafka is a configuration for the standard librdkafka configuration properties
 	// seen here: https://raw.githubusercontent.com/confluentinc/librdkafka/master/CONFIGURATION.md
 	// we extract the "topic" key as the default topic for the producer
 	Kafka *confluent.ConfigMap `json:"kafka,omitempty"`
 
+	// KafkaSerializer encodes kafka records as avro or protobuf in the Confluent wire format, with schemas
+	// registered in a schema registry. Records are produced as is when not set
+	KafkaSerializer *kafka.SerializerConfig `json:"kafka_serializer,omitempty"`
+
 	// Kinesis is a configuration for AWS Kinesis
 	Kinesis *Kinesis `json:"kinesis,omitempty"`
 
@@ -73,6 +106,11 @@ type Config struct {
 	// ZMQ configures a zeromq socket
 	ZMQ *zmq.Config `json:"zmq,omitempty"`
 
+	// Dispatchers is a mapping of the dispatchers registered with telemetry.RegisterProducerFactory without a field
+	// of the config, and of the named dispatcher instances (ex.: "kafka:analytics") to the raw config given to their
+	// factory. The top level settings named after a registered dispatcher (ex.: "webhook") are read into it.
+	Dispatchers map[telemetry.Dispatcher]json.RawMessage `json:"dispatchers,omitempty"`
+
 	// Namespace defines a prefix for dispatcher topics or subjects
 	Namespace string `json:"namespace,omitempty"`
 
@@ -101,7 +139,7 @@ type Config struct {
 	MetricCollector metrics.MetricCollector
 
 	// AckChan is a channel used to push acknowledgment from the datastore to connected clients
-	AckChan chan (*telemetry.Record)
+	AckChan chan (*telemetry.Ack)
 
 	// Airbrake config
 	Airbrake *Airbrake
@@ -111,6 +149,55 @@ type Config struct {
 
 	// NATS config
 	NATS *nats.Config `json:"nats,omitempty"`
+
+	// Stream configures the SSE and websocket endpoints fed by the stream dispatcher
+	Stream *stream.Config `json:"stream,omitempty"`
+
+	// StreamHub fans the records of the stream dispatcher out to the subscribers, set when Stream is configured
+	StreamHub *stream.Hub `json:"-"`
+
+	// Spool configures an on-disk write-ahead spool for records the dispatchers fail to deliver
+	Spool *spool.Config `json:"spool,omitempty"`
+
+	// Capture appends the raw messages received from vehicles to rotating files, for the replay command
+	Capture *capture.Config `json:"capture,omitempty"`
+
+	// DeadLetter keeps the messages which fail to parse in a file, they are also dispatched as "deadletter" records
+	DeadLetter *deadletter.Config `json:"dead_letter,omitempty"`
+
+	// Dedup acks the records a vehicle sends again with the same txid, ex.: after a lost ack, without dispatching them
+	Dedup *dedup.Config `json:"dedup,omitempty"`
+
+	// Units normalizes the values of V records to a unit system and annotates their unit in the logger dispatcher
+	Units *units.Config `json:"units,omitempty"`
+
+	// UnitConverter applies Units, set when Units is configured
+	UnitConverter *units.Converter `json:"-"`
+
+	// Derived computes signals from the V records, dispatched as "derived" records
+	Derived *derived.Config `json:"derived,omitempty"`
+
+	// Geofence emits "geofence" records when the vehicles enter or exit the areas of a GeoJSON file
+	Geofence *geofence.Config `json:"geofence,omitempty"`
+
+	// AlertLifecycle tracks the open alerts of the vehicles, emitting "alert_lifecycle" records when they open and close
+	AlertLifecycle *alerts.Config `json:"alert_lifecycle,omitempty"`
+
+	// Sessions segments the records into trip and charge sessions, summarized as "session" records
+	Sessions *session.Config `json:"sessions,omitempty"`
+
+	// State keeps the last known state of every vehicle, served by the admin API
+	State *state.Config `json:"state,omitempty"`
+
+	// Filters is a mapping of dispatchers to per record type field filters applied before dispatching
+	Filters map[telemetry.Dispatcher]map[string]*filter.Rule `json:"filters,omitempty"`
+
+	// Delta is a mapping of dispatchers to the change-only emission of their V records, which strips the fields
+	// whose value did not change since the last record of the vehicle
+	Delta map[telemetry.Dispatcher]*delta.Config `json:"delta,omitempty"`
+
+	// path is the file the config was loaded from
+	path string
 }
 
 // Airbrake config
@@ -123,6 +210,12 @@ type Airbrake struct {
 	TLS *TLS `json:"tls" yaml:"tls"`
 }
 
+// Admin config for the admin API served on the status port
+type Admin struct {
+	// Token is the bearer token required by the admin API. The ADMIN_TOKEN environment variable takes precedence
+	Token string `json:"token,omitempty"`
+}
+
 // RateLimit config for the service to handle ratelimiting incoming requests
 type RateLimit struct {
 	// MessageRateLimiterEnabled skip messages if it exceeds the limit
@@ -136,6 +229,17 @@ type RateLimit struct {
 
 	// MessageIntervalTimeSecond is the rate limit time interval as a duration in second
 	MessageIntervalTimeSecond time.Duration
+
+	// Action taken on messages over the limit: drop (default), ack or close
+	Action RateLimitAction `json:"action,omitempty"`
+
+	// RecordTypes gives record types their own limit, so a chatty record type cannot starve the others
+	RecordTypes map[string]*RateLimitPolicy `json:"record_types,omitempty"`
+
+	// VinOverridesFile is a json file mapping vins to rate limits replacing the ones above
+	VinOverridesFile string `json:"vin_overrides_file,omitempty"`
+
+	vinOverrides map[string]*RateLimitOverride
 }
 
 // Pubsub config for the Google pubsub
@@ -147,10 +251,31 @@ type Pubsub struct {
 }
 
 // Kinesis is a configuration for aws Kinesis.
-type Kinesis struct {
-	MaxRetries   *int              `json:"max_retries,omitempty"`
-	OverrideHost string            `json:"override_host"`
-	Streams      map[string]string `json:"streams,omitempty"`
+type Kinesis = kinesis.Config
+
+// AdminToken returns the bearer token of the admin API, empty when the admin API is disabled
+func (c *Config) AdminToken() string {
+	if c.Admin == nil {
+		return ""
+	}
+	if token, ok := os.LookupEnv(adminTokenEnv); ok {
+		return token
+	}
+	return c.Admin.Token
+}
+
+// StreamToken returns the bearer token of the stream endpoints, empty when the stream dispatcher is not configured
+func (c *Config) StreamToken() string {
+	if c.Stream == nil {
+		return ""
+	}
+	if token, ok := os.LookupEnv(streamTokenEnv); ok {
+		return token
+	}
+	if c.Stream.Token != "" {
+		return c.Stream.Token
+	}
+	return c.AdminToken()
 }
 
 //go:embed files/eng_ca.crt
@@ -265,6 +390,32 @@ func (c *Config) configureMetricsCollector(logger *logrus.Logger) {
 	c.MetricCollector = metrics.NewCollector(c.Monitoring, logger)
 }
 
+// configureStreamHub creates the hub of the stream dispatcher, which lives as long as the server
+func (c *Config) configureStreamHub(logger *logrus.Logger) error {
+	if c.Stream == nil {
+		return nil
+	}
+	hub, err := stream.NewHub(c.Stream, c.MetricCollector, logger)
+	if err != nil {
+		return err
+	}
+	c.StreamHub = hub
+	return nil
+}
+
+// configureUnitConverter validates the unit normalization, which is bound to the sockets when they connect
+func (c *Config) configureUnitConverter() error {
+	if c.Units == nil {
+		return nil
+	}
+	converter, err := units.NewConverter(c.Units)
+	if err != nil {
+		return err
+	}
+	c.UnitConverter = converter
+	return nil
+}
+
 // ConfigureOTelLogging sets up the OpenTelemetry logging hook if enabled
 // Returns the hook's shutdown function (or nil if not enabled)
 func (c *Config) ConfigureOTelLogging(logger *logrus.Logger) func() error {
@@ -321,132 +472,345 @@ func (c *Config) prometheusEnabled() bool {
 
 // ConfigureProducers validates and establishes connections to the configured producers
 func (c *Config) ConfigureProducers(airbrakeHandler *airbrake.Handler, logger *logrus.Logger, test bool) (map[telemetry.Dispatcher]telemetry.Producer, map[string][]telemetry.Producer, error) {
-	var pubsubTxTypes []string
+	return c.configureProducers(airbrakeHandler, logger, test, nil)
+}
+
+// configureProducers builds the producers of every dispatcher in use, except those found in reused which are kept as is.
+// Producers built before an error are closed.
+func (c *Config) configureProducers(airbrakeHandler *airbrake.Handler, logger *logrus.Logger, test bool, reused map[telemetry.Dispatcher]telemetry.Producer) (_ map[telemetry.Dispatcher]telemetry.Producer, _ map[string][]telemetry.Producer, err error) {
 	reliableAckSources, err := c.configureReliableAckSources()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	producers := make(map[telemetry.Dispatcher]telemetry.Producer)
-	producers[telemetry.Logger] = simple.NewProtoLogger(c.LoggerConfig, logger)
+	defer func() {
+		if err == nil {
+			return
+		}
+		for dispatcher, producer := range producers {
+			if _, ok := reused[dispatcher]; !ok {
+				_ = producer.Close()
+			}
+		}
+	}()
+	for dispatcher, producer := range reused {
+		producers[dispatcher] = producer
+	}
+	if _, ok := producers[telemetry.Logger]; !ok {
+		producers[telemetry.Logger] = simple.NewProtoLogger(c.LoggerConfig, c.UnitConverter, c.AckChan, reliableAckSources[telemetry.Logger], logger)
+	}
+
+	requiredDispatchers := c.requiredDispatchers()
+	if err := c.validateSpool(requiredDispatchers); err != nil {
+		return nil, nil, err
+	}
+	fieldFilters, err := c.configureFieldFilters(requiredDispatchers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.validateDelta(requiredDispatchers); err != nil {
+		return nil, nil, err
+	}
 
-	requiredDispatchers := make(map[telemetry.Dispatcher][]string)
+	if err := c.configureRegisteredProducers(producers, reused, requiredDispatchers, reliableAckSources, airbrakeHandler, logger); err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := requiredDispatchers[telemetry.Stream]; ok && reused[telemetry.Stream] == nil {
+		if c.StreamHub == nil {
+			return nil, nil, errors.New("expected Stream to be configured")
+		}
+		producers[telemetry.Stream] = stream.NewProducer(c.StreamHub)
+	}
+
+	for dispatcher, txTypes := range requiredDispatchers {
+		if test || reused[dispatcher] != nil {
+			continue
+		}
+		switch dispatcher.Type() {
+		case telemetry.Pubsub:
+			if err := producers[dispatcher].(*googlepubsub.Producer).ProvisionTopics(txTypes); err != nil {
+				return nil, nil, err
+			}
+		case telemetry.MQTT:
+			if err := producers[dispatcher].(*mqtt.Producer).Connect(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if err := c.configureSpool(producers, reused, reliableAckSources, airbrakeHandler, logger); err != nil {
+		return nil, nil, err
+	}
+	if err := c.configureDelta(producers, reused, logger); err != nil {
+		return nil, nil, err
+	}
+	for dispatcher, filters := range fieldFilters {
+		if _, ok := reused[dispatcher]; ok {
+			continue
+		}
+		producers[dispatcher] = filter.NewProducer(dispatcher, producers[dispatcher], filters, c.MetricCollector, logger)
+	}
+
+	dispatchProducerRules := make(map[string][]telemetry.Producer)
 	for recordName, dispatchRules := range c.Records {
+		var dispatchFuncs []telemetry.Producer
 		for _, dispatchRule := range dispatchRules {
-			requiredDispatchers[dispatchRule] = append(requiredDispatchers[dispatchRule], recordName)
+			dispatchFuncs = append(dispatchFuncs, producers[dispatchRule])
+		}
+		dispatchProducerRules[recordName] = dispatchFuncs
+
+		if len(dispatchProducerRules[recordName]) == 0 {
+			return nil, nil, fmt.Errorf("unknown_dispatch_rule record: %v, dispatchRule:%v", recordName, dispatchRules)
 		}
 	}
 
-	if _, ok := requiredDispatchers[telemetry.Kafka]; ok {
-		if c.Kafka == nil {
-			return nil, nil, errors.New("expected Kafka to be configured")
+	return producers, dispatchProducerRules, nil
+}
+
+// configureRegisteredProducers builds the producers of the dispatchers in use with the factories registered by their
+// packages, skipping reused producers
+func (c *Config) configureRegisteredProducers(producers, reused map[telemetry.Dispatcher]telemetry.Producer, requiredDispatchers map[telemetry.Dispatcher][]string, reliableAckSources map[telemetry.Dispatcher]map[string]interface{}, airbrakeHandler *airbrake.Handler, logger *logrus.Logger) error {
+	dispatchers := make([]telemetry.Dispatcher, 0, len(requiredDispatchers))
+	for dispatcher := range requiredDispatchers {
+		if err := validateDispatcherName(dispatcher); err != nil {
+			return err
 		}
-		convertKafkaConfig(c.Kafka)
-		kafkaProducer, err := kafka.NewProducer(c.Kafka, c.Namespace, c.prometheusEnabled(), c.MetricCollector, airbrakeHandler, c.AckChan, reliableAckSources[telemetry.Kafka], logger)
-		if err != nil {
-			return nil, nil, err
+		if dispatcher == telemetry.Logger || dispatcher == telemetry.Stream || reused[dispatcher] != nil {
+			continue
 		}
-		producers[telemetry.Kafka] = kafkaProducer
+		dispatchers = append(dispatchers, dispatcher)
 	}
+	sort.Slice(dispatchers, func(i, j int) bool { return dispatchers[i] < dispatchers[j] })
 
-	if _, ok := requiredDispatchers[telemetry.Pubsub]; ok {
-		if c.Pubsub == nil {
-			return nil, nil, errors.New("expected Pubsub to be configured")
+	for _, dispatcher := range dispatchers {
+		factory, ok := telemetry.LookupProducerFactory(dispatcher)
+		if !ok {
+			return fmt.Errorf("unknown dispatcher: %s, registered dispatchers: %v", dispatcher, telemetry.RegisteredDispatchers())
 		}
-		googleProducer, err := googlepubsub.NewProducer(c.prometheusEnabled(), c.Pubsub.ProjectID, c.Namespace, c.MetricCollector, airbrakeHandler, c.AckChan, reliableAckSources[telemetry.Pubsub], logger)
+		rawConfig, err := c.dispatcherConfig(dispatcher)
+		if err != nil {
+			return err
+		}
+		recordTypes := append([]string(nil), requiredDispatchers[dispatcher]...)
+		sort.Strings(recordTypes)
+		reliableAckTxTypes := reliableAckSources[dispatcher]
+		if c.isSpooled(dispatcher) {
+			// the spool acks the records once delivered or spooled, replays must not be acked again
+			reliableAckTxTypes = nil
+		}
+		producer, err := factory(rawConfig, &telemetry.ProducerParams{
+			Dispatcher:         dispatcher,
+			Namespace:          c.Namespace,
+			RecordTypes:        recordTypes,
+			PrometheusEnabled:  c.prometheusEnabled(),
+			MetricsCollector:   c.MetricCollector,
+			AirbrakeHandler:    airbrakeHandler,
+			AckChan:            c.AckChan,
+			ReliableAckTxTypes: reliableAckTxTypes,
+			Logger:             logger,
+		})
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		producers[telemetry.Pubsub] = googleProducer
+		producers[dispatcher] = producer
+	}
+	return nil
+}
+
+// validateDispatcherName ensures named instances have a name, and are only used for dispatchers built by a factory
+func validateDispatcherName(dispatcher telemetry.Dispatcher) error {
+	if dispatcher == dispatcher.Type() {
+		return nil
+	}
+	if dispatcher.Instance() == "" {
+		return fmt.Errorf("dispatcher instance without name: %s", dispatcher)
 	}
+	if dispatcher.Type() == telemetry.Logger || dispatcher.Type() == telemetry.Stream {
+		return fmt.Errorf("%s cannot have named instances: %s", dispatcher.Type(), dispatcher)
+	}
+	return nil
+}
 
-	if recordNames, ok := requiredDispatchers[telemetry.Kinesis]; ok {
-		if c.Kinesis == nil {
-			return nil, nil, errors.New("expected Kinesis to be configured")
+// dispatcherConfig returns the raw JSON config given to the producer factory of a dispatcher, nil when it is not
+// configured. The upstream dispatchers keep their typed settings, while their named instances (ex.:
+// "kafka:analytics") and the other dispatchers are configured under "dispatchers". The kafka config map is converted
+// in place, see kafka.ConvertConfigMap.
+func (c *Config) dispatcherConfig(dispatcher telemetry.Dispatcher) (json.RawMessage, error) {
+	if dispatcher != dispatcher.Type() {
+		return c.Dispatchers[dispatcher], nil
+	}
+	var typed interface{}
+	switch dispatcher {
+	case telemetry.Kafka:
+		if c.Kafka != nil {
+			kafka.ConvertConfigMap(c.Kafka)
+			typed = &kafka.Config{Producer: c.Kafka, Serializer: c.KafkaSerializer}
+		}
+	case telemetry.Kinesis:
+		if c.Kinesis != nil {
+			typed = c.Kinesis
 		}
-		maxRetries := 1
-		if c.Kinesis.MaxRetries != nil {
-			maxRetries = *c.Kinesis.MaxRetries
+	case telemetry.Pubsub:
+		if c.Pubsub != nil {
+			typed = &googlepubsub.Config{ProjectID: c.Pubsub.ProjectID}
 		}
-		streamMapping := c.CreateKinesisStreamMapping(recordNames)
-		kinesis, err := kinesis.NewProducer(maxRetries, streamMapping, c.Kinesis.OverrideHost, c.prometheusEnabled(), c.MetricCollector, airbrakeHandler, c.AckChan, reliableAckSources[telemetry.Kinesis], logger)
-		if err != nil {
-			return nil, nil, err
+	case telemetry.ZMQ:
+		if c.ZMQ != nil {
+			typed = c.ZMQ
+		}
+	case telemetry.MQTT:
+		if c.MQTT != nil {
+			typed = c.MQTT
 		}
-		producers[telemetry.Kinesis] = kinesis
+	case telemetry.NATS:
+		if c.NATS != nil {
+			typed = c.NATS
+		}
+	default:
+		return c.Dispatchers[dispatcher], nil
+	}
+	if typed == nil {
+		return nil, nil
 	}
+	return json.Marshal(typed)
+}
 
-	if _, ok := requiredDispatchers[telemetry.ZMQ]; ok {
-		if c.ZMQ == nil {
-			return nil, nil, errors.New("expected ZMQ to be configured")
+// readDispatcherConfigs reads the top level settings named after a registered dispatcher without a field of the
+// config (ex.: "webhook") into Dispatchers
+func (c *Config) readDispatcherConfigs(data []byte) error {
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+	for _, dispatcher := range telemetry.RegisteredDispatchers() {
+		rawConfig, ok := settings[string(dispatcher)]
+		if !ok || string(rawConfig) == "null" || configKeys[strings.ToLower(string(dispatcher))] {
+			continue
 		}
-		zmqProducer, err := zmq.NewProducer(context.Background(), c.ZMQ, c.MetricCollector, c.Namespace, airbrakeHandler, c.AckChan, reliableAckSources[telemetry.ZMQ], logger)
-		if err != nil {
-			return nil, nil, err
+		if _, ok := c.Dispatchers[dispatcher]; ok {
+			return fmt.Errorf("%s is configured both at the top level and under dispatchers", dispatcher)
+		}
+		if c.Dispatchers == nil {
+			c.Dispatchers = make(map[telemetry.Dispatcher]json.RawMessage)
 		}
-		producers[telemetry.ZMQ] = zmqProducer
+		c.Dispatchers[dispatcher] = rawConfig
 	}
+	return nil
+}
 
-	if _, ok := requiredDispatchers[telemetry.MQTT]; ok {
-		if c.MQTT == nil {
-			return nil, nil, errors.New("expected MQTT to be configured")
+// configKeys are the top level settings decoded into a field of the config
+var configKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = configType.Field(i).Name
 		}
-		mqttProducer, err := mqtt.NewProducer(context.Background(), c.MQTT, c.MetricCollector, c.Namespace, airbrakeHandler, c.AckChan, reliableAckSources[telemetry.MQTT], logger)
-		if err != nil {
-			return nil, nil, err
+		keys[strings.ToLower(name)] = true
+	}
+	return keys
+}()
+
+// validateSpool ensures only dispatchers in use are spooled
+func (c *Config) validateSpool(requiredDispatchers map[telemetry.Dispatcher][]string) error {
+	if c.Spool == nil {
+		return nil
+	}
+	for _, dispatcher := range c.Spool.Dispatchers {
+		if dispatcher == telemetry.Logger || dispatcher == telemetry.Stream {
+			return fmt.Errorf("%s cannot be configured for spool", dispatcher)
+		}
+		if _, ok := requiredDispatchers[dispatcher]; !ok {
+			return fmt.Errorf("%s cannot be configured for spool since no record is dispatched to it", dispatcher)
 		}
-		producers[telemetry.MQTT] = mqttProducer
 	}
+	return nil
+}
 
-	if _, ok := requiredDispatchers[telemetry.NATS]; ok {
-		if c.NATS == nil {
-			return nil, nil, errors.New("expected NATS to be configured")
+// configureFieldFilters validates the field filters of each dispatcher
+func (c *Config) configureFieldFilters(requiredDispatchers map[telemetry.Dispatcher][]string) (map[telemetry.Dispatcher]filter.FieldFilters, error) {
+	fieldFilters := make(map[telemetry.Dispatcher]filter.FieldFilters, len(c.Filters))
+	for dispatcher, rules := range c.Filters {
+		if _, ok := requiredDispatchers[dispatcher]; !ok {
+			return nil, fmt.Errorf("%s cannot be configured for field filters since no record is dispatched to it", dispatcher)
 		}
-		natsProducer, err := nats.NewProducer(c.NATS, c.Namespace, c.prometheusEnabled(), c.MetricCollector, airbrakeHandler, c.AckChan, reliableAckSources[telemetry.NATS], logger)
+		filters, err := filter.NewFieldFilters(rules)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		producers[telemetry.NATS] = natsProducer
+		fieldFilters[dispatcher] = filters
 	}
+	return fieldFilters, nil
+}
 
-	dispatchProducerRules := make(map[string][]telemetry.Producer)
-	for recordName, dispatchRules := range c.Records {
-		var dispatchFuncs []telemetry.Producer
-		for _, dispatchRule := range dispatchRules {
-			if dispatchRule == telemetry.Pubsub {
-				pubsubTxTypes = append(pubsubTxTypes, recordName)
-			}
-			dispatchFuncs = append(dispatchFuncs, producers[dispatchRule])
+// validateDelta ensures change-only emission is only configured for dispatchers in use
+func (c *Config) validateDelta(requiredDispatchers map[telemetry.Dispatcher][]string) error {
+	for dispatcher := range c.Delta {
+		if _, ok := requiredDispatchers[dispatcher]; !ok {
+			return fmt.Errorf("%s cannot be configured for delta since no record is dispatched to it", dispatcher)
 		}
-		dispatchProducerRules[recordName] = dispatchFuncs
+	}
+	return nil
+}
 
-		if len(dispatchProducerRules[recordName]) == 0 {
-			return nil, nil, fmt.Errorf("unknown_dispatch_rule record: %v, dispatchRule:%v", recordName, dispatchRules)
+// configureDelta wraps the producers of the dispatchers with change-only emission, skipping reused producers
+func (c *Config) configureDelta(producers, reused map[telemetry.Dispatcher]telemetry.Producer, logger *logrus.Logger) error {
+	for dispatcher, deltaConfig := range c.Delta {
+		if _, ok := reused[dispatcher]; ok {
+			continue
+		}
+		if deltaConfig == nil {
+			deltaConfig = &delta.Config{}
 		}
+		deltaProducer, err := delta.NewProducer(dispatcher, producers[dispatcher], deltaConfig, c.MetricCollector, logger)
+		if err != nil {
+			return err
+		}
+		producers[dispatcher] = deltaProducer
 	}
+	return nil
+}
 
-	if !test && len(pubsubTxTypes) > 0 {
-		if err := producers[telemetry.Pubsub].(*googlepubsub.Producer).ProvisionTopics(pubsubTxTypes); err != nil {
-			return nil, nil, err
-		}
+// configureSpool wraps the producers of the spooled dispatchers with an on-disk spool, skipping reused producers
+func (c *Config) configureSpool(producers, reused map[telemetry.Dispatcher]telemetry.Producer, reliableAckSources map[telemetry.Dispatcher]map[string]interface{}, airbrakeHandler *airbrake.Handler, logger *logrus.Logger) error {
+	if c.Spool == nil {
+		return nil
 	}
-	if !test && producers[telemetry.MQTT] != nil {
-		if err := producers[telemetry.MQTT].(*mqtt.Producer).Connect(); err != nil {
-			return nil, nil, err
+	for _, dispatcher := range c.Spool.Dispatchers {
+		if _, ok := reused[dispatcher]; ok {
+			continue
 		}
+		spoolProducer, err := spool.NewProducer(c.Spool, dispatcher, producers[dispatcher], c.TransmitDecodedRecords, c.MetricCollector, airbrakeHandler, c.AckChan, reliableAckSources[dispatcher], logger)
+		if err != nil {
+			return err
+		}
+		producers[dispatcher] = spoolProducer
 	}
+	return nil
+}
 
-	return producers, dispatchProducerRules, nil
+// serverRecordTypes are the records produced by the server rather than sent by the vehicles, so there is no vehicle
+// message to ack
+var serverRecordTypes = map[string]struct{}{
+	"connectivity":        {},
+	derived.RecordType:    {},
+	geofence.RecordType:   {},
+	session.RecordType:    {},
+	deadletter.RecordType: {},
+	alerts.RecordType:     {},
 }
 
 func (c *Config) configureReliableAckSources() (map[telemetry.Dispatcher]map[string]interface{}, error) {
 	reliableAckSources := make(map[telemetry.Dispatcher]map[string]interface{}, 0)
 	for txType, dispatchRule := range c.ReliableAckSources {
-		if txType == "connectivity" {
+		if _, ok := serverRecordTypes[txType]; ok {
 			return nil, fmt.Errorf("reliable ack not needed for txType: %s", txType)
 		}
-		if dispatchRule == telemetry.Logger {
-			return nil, fmt.Errorf("logger cannot be configured as reliable ack for record: %s", txType)
+		if dispatchRule == telemetry.Stream {
+			return nil, fmt.Errorf("%s cannot be configured as reliable ack for record: %s", dispatchRule, txType)
 		}
 		dispatchers, ok := c.Records[txType]
 		if !ok {
@@ -468,46 +832,60 @@ func (c *Config) configureReliableAckSources() (map[telemetry.Dispatcher]map[str
 			return nil, fmt.Errorf("%s cannot be configured as reliable ack for record: %s. Valid datastores configured %v", dispatchRule, txType, validDispatchers)
 		}
 	}
+	if err := c.configureReliableAckPolicies(reliableAckSources); err != nil {
+		return nil, err
+	}
 	return reliableAckSources, nil
 }
 
-// parseValidDispatchers removes no-op dispatcher from the input i.e. Logger
+// configureReliableAckPolicies validates the policies and adds their record types to the reliable acks of their
+// dispatchers
+func (c *Config) configureReliableAckPolicies(reliableAckSources map[telemetry.Dispatcher]map[string]interface{}) error {
+	for txType, policy := range c.ReliableAckPolicies {
+		if _, ok := serverRecordTypes[txType]; ok {
+			return fmt.Errorf("reliable ack not needed for txType: %s", txType)
+		}
+		if _, ok := c.ReliableAckSources[txType]; ok {
+			return fmt.Errorf("record: %s cannot have both a reliable ack source and policy", txType)
+		}
+		if policy == nil {
+			return fmt.Errorf("empty reliable ack policy for record: %s", txType)
+		}
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("record: %s: %w", txType, err)
+		}
+		validDispatchers := parseValidDispatchers(c.Records[txType])
+		for _, dispatcher := range policy.Dispatchers {
+			if !slices.Contains(validDispatchers, dispatcher) {
+				return fmt.Errorf("%s cannot be configured as reliable ack for record: %s. Valid datastores configured %v", dispatcher, txType, validDispatchers)
+			}
+			if _, ok := reliableAckSources[dispatcher]; !ok {
+				reliableAckSources[dispatcher] = make(map[string]interface{}, 1)
+			}
+			reliableAckSources[dispatcher][txType] = true
+		}
+	}
+	return nil
+}
+
+// parseValidDispatchers removes the dispatchers which cannot ack from the input i.e. Stream
 func parseValidDispatchers(input []telemetry.Dispatcher) []telemetry.Dispatcher {
 	var result []telemetry.Dispatcher
 	for _, v := range input {
-		if v != telemetry.Logger {
+		if v != telemetry.Stream {
 			result = append(result, v)
 		}
 	}
 	return result
 }
 
-// convertKafkaConfig will prioritize int over float
-// see: https://github.com/confluentinc/confluent-kafka-go/blob/cde2827bc49655eca0f9ce3fc1cda13cb6cdabc9/kafka/config.go#L108-L125
-func convertKafkaConfig(input *confluent.ConfigMap) {
-	for key, val := range *input {
-		if i, ok := val.(float64); ok {
-			(*input)[key] = int(i)
-		}
-	}
-}
-
 // CreateKinesisStreamMapping uses the config, overrides with ENV variable names, and finally falls back to namespace based names
 func (c *Config) CreateKinesisStreamMapping(recordNames []string) map[string]string {
-	streamMapping := make(map[string]string)
-	for _, recordName := range recordNames {
-		if c.Kinesis != nil {
-			streamMapping[recordName] = c.Kinesis.Streams[recordName]
-		}
-		envVarStreamName := os.Getenv(fmt.Sprintf("KINESIS_STREAM_%s", strings.ToUpper(recordName)))
-		if envVarStreamName != "" {
-			streamMapping[recordName] = envVarStreamName
-		}
-		if streamMapping[recordName] == "" {
-			streamMapping[recordName] = telemetry.BuildTopicName(c.Namespace, recordName)
-		}
+	var streams map[string]string
+	if c.Kinesis != nil {
+		streams = c.Kinesis.Streams
 	}
-	return streamMapping
+	return kinesis.StreamMapping(streams, c.Namespace, recordNames)
 }
 
 // CreateAirbrakeNotifier intializes an airbrake notifier with standard configs
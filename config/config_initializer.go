@@ -30,14 +30,11 @@ func LoadApplicationConfiguration() (config *Config, logger *logrus.Logger, shut
 
 	configFilePath := loadConfigFlags()
 
-	config, err = loadApplicationConfig(configFilePath)
+	config, err = LoadApplicationConfigurationFile(configFilePath, logger)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	config.configureLogger(logger)
-	config.configureMetricsCollector(logger)
-
 	// Configure OTel logging if enabled
 	if otelLogShutdown := config.ConfigureOTelLogging(logger); otelLogShutdown != nil {
 		shutdownFuncs = append(shutdownFuncs, otelLogShutdown)
@@ -51,16 +48,27 @@ func LoadApplicationConfiguration() (config *Config, logger *logrus.Logger, shut
 	return config, logger, shutdownFuncs, nil
 }
 
-func loadApplicationConfig(configFilePath string) (*Config, error) {
-	configFile, err := os.Open(configFilePath)
+// LoadApplicationConfigurationFile loads the configuration from a file, for tools running the producers
+// outside of the server
+func LoadApplicationConfigurationFile(configFilePath string, logger *logrus.Logger) (*Config, error) {
+	config, err := loadApplicationConfig(configFilePath)
 	if err != nil {
 		return nil, err
 	}
 
-	config := &Config{
-		LoggerConfig: &simple.Config{},
+	config.configureLogger(logger)
+	config.configureMetricsCollector(logger)
+	if err := config.configureUnitConverter(); err != nil {
+		return nil, err
 	}
-	err = json.NewDecoder(configFile).Decode(&config)
+	if err := config.configureStreamHub(logger); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func loadApplicationConfig(configFilePath string) (*Config, error) {
+	config, err := readApplicationConfig(configFilePath)
 	if err != nil {
 		return nil, err
 	}
@@ -71,18 +79,44 @@ func loadApplicationConfig(configFilePath string) (*Config, error) {
 		return nil, err
 	}
 
+	config.MetricCollector = metrics.NewCollector(config.Monitoring, logger)
+	config.AckChan = make(chan *telemetry.Ack)
+	return config, err
+}
+
+// readApplicationConfig decodes and validates a config file
+func readApplicationConfig(configFilePath string) (*Config, error) {
+	data, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		LoggerConfig: &simple.Config{},
+		path:         configFilePath,
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := config.readDispatcherConfigs(data); err != nil {
+		return nil, err
+	}
+
 	if err := validateConfig(config); err != nil {
 		return nil, err
 	}
-	config.MetricCollector = metrics.NewCollector(config.Monitoring, logger)
-	config.AckChan = make(chan *telemetry.Record)
-	return config, err
+	return config, nil
 }
 
 func validateConfig(config *Config) error {
 	if len(config.VinsToTrack()) > maxVinsToTrack {
 		return fmt.Errorf("set the value of `vins_signal_tracking_enabled` less than %d unique vins", maxVinsToTrack)
 	}
+	if config.RateLimit != nil {
+		if err := config.RateLimit.validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
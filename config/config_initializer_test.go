@@ -44,6 +44,7 @@ var _ = Describe("Test application config initialization", func() {
 		expectedConfig.MetricCollector = loadedConfig.MetricCollector
 		expectedConfig.LoggerConfig = loadedConfig.LoggerConfig
 		expectedConfig.AckChan = loadedConfig.AckChan
+		expectedConfig.path = loadedConfig.path
 		Expect(loadedConfig).To(Equal(expectedConfig))
 	})
 
@@ -72,6 +73,7 @@ var _ = Describe("Test application config initialization", func() {
 		expectedConfig.LoggerConfig = loadedConfig.LoggerConfig
 		expectedConfig.MetricCollector = loadedConfig.MetricCollector
 		expectedConfig.AckChan = loadedConfig.AckChan
+		expectedConfig.path = loadedConfig.path
 		Expect(loadedConfig).To(Equal(expectedConfig))
 	})
 
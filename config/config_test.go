@@ -1,8 +1,15 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -10,12 +17,30 @@ import (
 	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	githublogrus "github.com/sirupsen/logrus"
 
+	"github.com/teslamotors/fleet-telemetry/datastore/kafka"
+	"github.com/teslamotors/fleet-telemetry/datastore/spool"
+	"github.com/teslamotors/fleet-telemetry/datastore/stream"
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/metrics"
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/ackpolicy"
+	"github.com/teslamotors/fleet-telemetry/telemetry/delta"
+	"github.com/teslamotors/fleet-telemetry/telemetry/filter"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 )
 
+// registerTestFactoryOnce registers the factory of the registered_test dispatcher, which can only be registered once
+var registerTestFactoryOnce sync.Once
+
+// registeredProducer is the producer of the registered_test dispatcher
+type registeredProducer struct{}
+
+func (p *registeredProducer) Close() error                                    { return nil }
+func (p *registeredProducer) Produce(_ *telemetry.Record)                     {}
+func (p *registeredProducer) ProcessReliableAck(_ *telemetry.Record)          {}
+func (p *registeredProducer) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
 var _ = Describe("Test full application config", func() {
 
 	var (
@@ -141,6 +166,51 @@ var _ = Describe("Test full application config", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(value.(int)).To(Equal(1000000))
 		})
+
+		It("configures the serializer, registering the schemas of the records", func() {
+			var subjects []string
+			registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				subjects = append(subjects, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/versions"))
+				_, _ = w.Write([]byte(`{"id": 1}`))
+			}))
+			defer registry.Close()
+			config.KafkaSerializer = &kafka.SerializerConfig{Format: kafka.FormatAvro, SchemaRegistry: &kafka.SchemaRegistryConfig{URL: registry.URL}}
+
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producers["V"]).To(HaveLen(1))
+			Expect(subjects).To(ConsistOf(telemetry.BuildTopicName(config.Namespace, "V") + "-value"))
+		})
+
+		It("returns an error when the schemas cannot be registered", func() {
+			registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"error_code": 50001, "message": "store unavailable"}`))
+			}))
+			defer registry.Close()
+			config.KafkaSerializer = &kafka.SerializerConfig{Format: kafka.FormatAvro, SchemaRegistry: &kafka.SchemaRegistryConfig{URL: registry.URL}}
+
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError(ContainSubstring("schema registry responded 500")))
+		})
+
+		It("returns an error for an unknown serializer format", func() {
+			config.KafkaSerializer = &kafka.SerializerConfig{Format: "json", SchemaRegistry: &kafka.SchemaRegistryConfig{URL: "http://127.0.0.1:8081"}}
+
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("unknown kafka serializer format: json"))
+		})
+
+		It("returns an error without a schema registry", func() {
+			config.KafkaSerializer = &kafka.SerializerConfig{Format: kafka.FormatProtobuf}
+
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("schema registry url is required"))
+		})
 	})
 
 	Context("configure airbrake", func() {
@@ -166,6 +236,86 @@ var _ = Describe("Test full application config", func() {
 		})
 	})
 
+	Context("configure admin", func() {
+		AfterEach(func() {
+			_ = os.Unsetenv("ADMIN_TOKEN")
+		})
+
+		It("is disabled by default", func() {
+			config, err := loadTestApplicationConfig(TestSmallConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.AdminToken()).To(BeEmpty())
+		})
+
+		It("gets token from file", func() {
+			config, err := loadTestApplicationConfig(TestAdminConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.AdminToken()).To(Equal("test-admin-token"))
+		})
+
+		It("gets token from env variable", func() {
+			err := os.Setenv("ADMIN_TOKEN", "environmentAdminToken")
+			Expect(err).NotTo(HaveOccurred())
+			config, err := loadTestApplicationConfig(TestAdminConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.AdminToken()).To(Equal("environmentAdminToken"))
+		})
+	})
+
+	Context("configure rate limit", func() {
+		loadRateLimitConfig := func(configInput string, overrides string) (*Config, error) {
+			overridesFile, err := os.CreateTemp(GinkgoT().TempDir(), "overrides")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = overridesFile.WriteString(overrides)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(overridesFile.Close()).To(Succeed())
+			return loadTestApplicationConfig(strings.Replace(configInput, "VIN_OVERRIDES_FILE", overridesFile.Name(), 1))
+		}
+
+		It("applies the default rate limit when not configured", func() {
+			config, err := loadTestApplicationConfig(TestSmallConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.RateLimitRules("VIN1")).To(Equal(&RateLimitRules{
+				Default: &RateLimitRule{Limit: 100, Interval: 60 * time.Second, Action: RateLimitDrop},
+			}))
+		})
+
+		It("is unlimited when disabled", func() {
+			config.RateLimit = &RateLimit{Enabled: false}
+			Expect(config.RateLimitRules("VIN1")).To(BeNil())
+		})
+
+		It("resolves record type limits and vin overrides", func() {
+			config, err := loadRateLimitConfig(TestRateLimitConfig, TestRateLimitVinOverrides)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.RateLimitRules("VIN0")).To(Equal(&RateLimitRules{
+				Default:     &RateLimitRule{Limit: 1000, Interval: 30 * time.Second, Action: RateLimitAck},
+				RecordTypes: map[string]*RateLimitRule{"alerts": {Limit: 10, Interval: 60 * time.Second, Action: RateLimitClose}},
+			}))
+			Expect(config.RateLimitRules("VIN1")).To(Equal(&RateLimitRules{
+				Default:     &RateLimitRule{Limit: 5000, Interval: 10 * time.Second, Action: RateLimitAck},
+				RecordTypes: map[string]*RateLimitRule{"alerts": {Limit: 10, Interval: 60 * time.Second, Action: RateLimitClose}},
+			}))
+			Expect(config.RateLimitRules("VIN2")).To(Equal(&RateLimitRules{
+				Default:     &RateLimitRule{Limit: 1000, Interval: 30 * time.Second, Action: RateLimitAck},
+				RecordTypes: map[string]*RateLimitRule{"alerts": {Limit: 100, Interval: 60 * time.Second, Action: RateLimitAck}},
+			}))
+		})
+
+		DescribeTable("fails",
+			func(configInput string, overrides string, errMessage string) {
+				_, err := loadRateLimitConfig(configInput, overrides)
+				Expect(err).To(MatchError(errMessage))
+			},
+			Entry("with an unknown action", strings.Replace(TestRateLimitConfig, `"action": "ack"`, `"action": "block"`, 1), TestRateLimitVinOverrides, "unknown rate limit action: block"),
+			Entry("with an incomplete record type limit", strings.Replace(TestRateLimitConfig, `"message_limit": 10,`, "", 1), TestRateLimitVinOverrides, "rate limit for record alerts must set a positive message_limit and message_interval_time"),
+			Entry("without a message limit", strings.Replace(TestRateLimitConfig, `"message_limit": 1000,`, "", 1), TestRateLimitVinOverrides, "enabled rate limit must set a positive message_limit and message_interval_time"),
+			Entry("without a message interval", strings.Replace(TestRateLimitConfig, `"message_interval_time": 30,`, "", 1), TestRateLimitVinOverrides, "enabled rate limit must set a positive message_limit and message_interval_time"),
+			Entry("with an incomplete vin override", TestRateLimitConfig, `{"VIN1": {"message_limit": 10}}`, "rate limit for vin VIN1 must set a positive message_limit and message_interval_time"),
+		)
+	})
+
 	Context("configure reliable acks", func() {
 		It("configures each datasource", func() {
 			config, err := loadTestApplicationConfig(TestMultipleTxTypeReliableAckConfig)
@@ -180,6 +330,16 @@ var _ = Describe("Test full application config", func() {
 			Expect(reliableAcks["mqtt"]["alerts"]).To(BeTrue())
 		})
 
+		It("configures the logger", func() {
+			config, err := loadTestApplicationConfig(TestLoggerAsReliableAckConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			reliableAcks, err := config.configureReliableAckSources()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reliableAcks["logger"]).To(HaveLen(1))
+			Expect(reliableAcks["logger"]["V"]).To(BeTrue())
+		})
+
 		DescribeTable("fails",
 			func(configInput string, errMessage string) {
 
@@ -191,11 +351,86 @@ var _ = Describe("Test full application config", func() {
 				Expect(producers).To(BeNil())
 			},
 			Entry("when reliable ack is mapped incorrectly", TestBadReliableAckConfig, "pubsub cannot be configured as reliable ack for record: V. Valid datastores configured [kafka]"),
-			Entry("when logger is configured as reliable ack", TestLoggerAsReliableAckConfig, "logger cannot be configured as reliable ack for record: V"),
 			Entry("when reliable ack is configured for unmapped txtype", TestUnusedTxTypeAsReliableAckConfig, "kafka cannot be configured as reliable ack for record: error since no record mapping exists"),
 			Entry("when reliable ack is mapped with unsupported txtype", TestBadTxTypeReliableAckConfig, "reliable ack not needed for txType: connectivity"),
 		)
 
+		It("rejects derived records", func() {
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"derived": telemetry.Kafka}
+			_, err := config.configureReliableAckSources()
+			Expect(err).To(MatchError("reliable ack not needed for txType: derived"))
+		})
+
+		It("rejects geofence records", func() {
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"geofence": telemetry.Kafka}
+			_, err := config.configureReliableAckSources()
+			Expect(err).To(MatchError("reliable ack not needed for txType: geofence"))
+		})
+
+		It("rejects session records", func() {
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"session": telemetry.Kafka}
+			_, err := config.configureReliableAckSources()
+			Expect(err).To(MatchError("reliable ack not needed for txType: session"))
+		})
+
+		It("rejects dead letter records", func() {
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"deadletter": telemetry.Kafka}
+			_, err := config.configureReliableAckSources()
+			Expect(err).To(MatchError("reliable ack not needed for txType: deadletter"))
+		})
+
+		It("rejects alert lifecycle records", func() {
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"alert_lifecycle": telemetry.Kafka}
+			_, err := config.configureReliableAckSources()
+			Expect(err).To(MatchError("reliable ack not needed for txType: alert_lifecycle"))
+		})
+
+		It("gives the record types of a policy to each of its dispatchers", func() {
+			config, err := loadTestApplicationConfig(TestMultipleTxTypeReliableAckConfig)
+			Expect(err).NotTo(HaveOccurred())
+			delete(config.ReliableAckSources, "errors")
+			config.ReliableAckPolicies = map[string]*ackpolicy.Policy{
+				"errors": {Dispatchers: []telemetry.Dispatcher{telemetry.Kafka, telemetry.MQTT}, Require: ackpolicy.RequireAll},
+			}
+
+			reliableAcks, err := config.configureReliableAckSources()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reliableAcks["kafka"]).To(HaveLen(2))
+			Expect(reliableAcks["kafka"]["errors"]).To(BeTrue())
+			Expect(reliableAcks["mqtt"]).To(HaveLen(2))
+			Expect(reliableAcks["mqtt"]["errors"]).To(BeTrue())
+		})
+
+		DescribeTable("rejects invalid policies",
+			func(policy *ackpolicy.Policy, errMessage string) {
+				config, err := loadTestApplicationConfig(TestMultipleTxTypeReliableAckConfig)
+				Expect(err).NotTo(HaveOccurred())
+				delete(config.ReliableAckSources, "errors")
+				config.ReliableAckPolicies = map[string]*ackpolicy.Policy{"errors": policy}
+
+				_, err = config.configureReliableAckSources()
+				Expect(err).To(MatchError(errMessage))
+			},
+			Entry("when empty", nil, "empty reliable ack policy for record: errors"),
+			Entry("without dispatchers", &ackpolicy.Policy{}, "record: errors: reliable ack policy without dispatchers"),
+			Entry("requiring too many dispatchers", &ackpolicy.Policy{Dispatchers: []telemetry.Dispatcher{telemetry.Kafka}, Require: "2"}, "record: errors: invalid reliable ack requirement: 2, expected any, all or 1 to 1"),
+			Entry("with a dispatcher not mapped to the record", &ackpolicy.Policy{Dispatchers: []telemetry.Dispatcher{telemetry.Kafka, telemetry.Kinesis}}, "kinesis cannot be configured as reliable ack for record: errors. Valid datastores configured [kafka mqtt]"),
+		)
+
+		It("rejects a record type with both a source and a policy", func() {
+			config, err := loadTestApplicationConfig(TestMultipleTxTypeReliableAckConfig)
+			Expect(err).NotTo(HaveOccurred())
+			config.ReliableAckPolicies = map[string]*ackpolicy.Policy{"V": {Dispatchers: []telemetry.Dispatcher{telemetry.Kafka}}}
+
+			_, err = config.configureReliableAckSources()
+			Expect(err).To(MatchError("record: V cannot have both a reliable ack source and policy"))
+		})
+
+		It("rejects policies for server records", func() {
+			config.ReliableAckPolicies = map[string]*ackpolicy.Policy{"geofence": {Dispatchers: []telemetry.Dispatcher{telemetry.Kafka}}}
+			_, err := config.configureReliableAckSources()
+			Expect(err).To(MatchError("reliable ack not needed for txType: geofence"))
+		})
 	})
 
 	Context("configure kinesis", func() {
@@ -308,6 +543,413 @@ var _ = Describe("Test full application config", func() {
 		})
 	})
 
+	Context("configure spool", func() {
+		var spoolConfig *Config
+
+		BeforeEach(func() {
+			var err error
+			spoolConfig, err = loadTestApplicationConfig(TestSpoolConfig)
+			Expect(err).NotTo(HaveOccurred())
+			spoolConfig.Spool.Dir = GinkgoT().TempDir()
+		})
+
+		It("wraps the spooled dispatchers", func() {
+			log, _ := logrus.NoOpLogger()
+			var err error
+			var dispatchers map[telemetry.Dispatcher]telemetry.Producer
+			dispatchers, producers, err = spoolConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dispatchers[telemetry.ZMQ]).To(BeAssignableToTypeOf(&spool.Producer{}))
+			Expect(dispatchers[telemetry.Logger]).NotTo(BeAssignableToTypeOf(&spool.Producer{}))
+			Expect(producers["V"]).To(ContainElement(dispatchers[telemetry.ZMQ]))
+		})
+
+		It("leaves the reliable acks of a spooled dispatcher to the spool", func() {
+			log, _ := logrus.NoOpLogger()
+			spoolConfig.ReliableAckSources = map[string]telemetry.Dispatcher{"V": telemetry.ZMQ}
+			spoolConfig.AckChan = make(chan *telemetry.Ack, 1)
+			var err error
+			var dispatchers map[telemetry.Dispatcher]telemetry.Producer
+			dispatchers, producers, err = spoolConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			spoolProducer := dispatchers[telemetry.ZMQ].(*spool.Producer)
+			record := &telemetry.Record{TxType: "V", Txid: "1"}
+			spoolProducer.Unwrap().ProcessReliableAck(record)
+			Expect(spoolConfig.AckChan).To(BeEmpty())
+			spoolProducer.ProcessReliableAck(record)
+			Expect(spoolConfig.AckChan).To(Receive(Equal(&telemetry.Ack{Record: record, Dispatcher: telemetry.ZMQ})))
+		})
+
+		DescribeTable("fails",
+			func(dispatcher telemetry.Dispatcher, errMessage string) {
+				log, _ := logrus.NoOpLogger()
+				spoolConfig.Spool.Dispatchers = []telemetry.Dispatcher{dispatcher}
+
+				_, spoolProducers, err := spoolConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+				Expect(err).To(MatchError(errMessage))
+				Expect(spoolProducers).To(BeNil())
+			},
+			Entry("when logger is spooled", telemetry.Logger, "logger cannot be configured for spool"),
+			Entry("when the dispatcher is unused", telemetry.Kafka, "kafka cannot be configured for spool since no record is dispatched to it"),
+		)
+	})
+
+	Context("configure webhook", func() {
+		It("returns an error if webhook isn't included", func() {
+			log, _ := logrus.NoOpLogger()
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"webhook"}}
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("expected Webhook to be configured"))
+			Expect(producers).To(BeNil())
+		})
+
+		It("webhook config works", func() {
+			webhookConfig, err := loadTestApplicationConfig(TestWebhookConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			log, _ := logrus.NoOpLogger()
+			_, producers, err = webhookConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producers["V"]).NotTo(BeNil())
+			Expect(producers["alerts"]).NotTo(BeNil())
+		})
+	})
+
+	Context("configure file", func() {
+		It("returns an error if file isn't included", func() {
+			log, _ := logrus.NoOpLogger()
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"file"}}
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("expected File to be configured"))
+			Expect(producers).To(BeNil())
+		})
+
+		It("file config works", func() {
+			fileConfig, err := loadTestApplicationConfig(strings.Replace(TestFileConfig, "/tmp/fleet-telemetry", GinkgoT().TempDir(), 1))
+			Expect(err).NotTo(HaveOccurred())
+
+			log, _ := logrus.NoOpLogger()
+			_, producers, err = fileConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producers["V"]).NotTo(BeNil())
+			Expect(producers["alerts"]).NotTo(BeNil())
+		})
+	})
+
+	Context("configure parquet", func() {
+		It("returns an error if parquet isn't included", func() {
+			log, _ := logrus.NoOpLogger()
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"parquet"}}
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("expected Parquet to be configured"))
+			Expect(producers).To(BeNil())
+		})
+
+		It("parquet config works", func() {
+			parquetConfig, err := loadTestApplicationConfig(strings.Replace(TestParquetConfig, "/tmp/fleet-telemetry-parquet", GinkgoT().TempDir(), 1))
+			Expect(err).NotTo(HaveOccurred())
+
+			log, _ := logrus.NoOpLogger()
+			_, producers, err = parquetConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(producers["V"]).NotTo(BeNil())
+		})
+
+		It("rejects records other than V", func() {
+			parquetConfig, err := loadTestApplicationConfig(strings.Replace(TestParquetConfig, "/tmp/fleet-telemetry-parquet", GinkgoT().TempDir(), 1))
+			Expect(err).NotTo(HaveOccurred())
+			parquetConfig.Records["alerts"] = []telemetry.Dispatcher{telemetry.Parquet}
+
+			log, _ := logrus.NoOpLogger()
+			_, producers, err = parquetConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("parquet cannot be configured for record: alerts, only V records are written as parquet"))
+			Expect(producers).To(BeNil())
+		})
+	})
+
+	Context("configure registered dispatchers", func() {
+		const registered telemetry.Dispatcher = "registered_test"
+
+		var (
+			rawConfigs []json.RawMessage
+			params     []*telemetry.ProducerParams
+		)
+
+		BeforeEach(func() {
+			rawConfigs, params = nil, nil
+			registerTestFactoryOnce.Do(func() {
+				telemetry.RegisterProducerFactory(registered, func(rawConfig json.RawMessage, producerParams *telemetry.ProducerParams) (telemetry.Producer, error) {
+					if rawConfig == nil {
+						return nil, errors.New("expected registered_test to be configured")
+					}
+					rawConfigs = append(rawConfigs, rawConfig)
+					params = append(params, producerParams)
+					return &registeredProducer{}, nil
+				})
+			})
+		})
+
+		It("builds the producer with the factory", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {registered}, "alerts": {registered, "kafka"}}
+			config.Dispatchers = map[telemetry.Dispatcher]json.RawMessage{registered: json.RawMessage(`{"url":"http://127.0.0.1"}`)}
+
+			dispatchers, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			producers = producerRules
+			Expect(dispatchers[registered]).To(BeAssignableToTypeOf(&registeredProducer{}))
+			Expect(producers["V"]).To(ConsistOf(dispatchers[registered]))
+			Expect(producers["alerts"]).To(ConsistOf(dispatchers[registered], dispatchers[telemetry.Kafka]))
+
+			Expect(rawConfigs).To(HaveLen(1))
+			Expect(string(rawConfigs[0])).To(MatchJSON(`{"url":"http://127.0.0.1"}`))
+			Expect(params[0].Namespace).To(Equal("tesla_telemetry"))
+			Expect(params[0].RecordTypes).To(Equal([]string{"V", "alerts"}))
+			Expect(params[0].PrometheusEnabled).To(BeTrue())
+			Expect(params[0].Logger).To(Equal(log))
+		})
+
+		It("gives the reliable ack records to the factory", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {registered}}
+			config.Dispatchers = map[telemetry.Dispatcher]json.RawMessage{registered: json.RawMessage(`{}`)}
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"V": registered}
+
+			_, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			producers = producerRules
+			Expect(params[0].ReliableAckTxTypes).To(Equal(map[string]interface{}{"V": true}))
+		})
+
+		It("returns the error of the factory", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {registered}}
+
+			_, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("expected registered_test to be configured"))
+			Expect(producerRules).To(BeNil())
+		})
+
+		It("returns an error for an unregistered dispatcher", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"unregistered"}}
+
+			_, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError(ContainSubstring("unknown dispatcher: unregistered")))
+			Expect(producerRules).To(BeNil())
+		})
+
+		It("builds named instances with the factory of their type", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {registered, "registered_test:partner"}}
+			config.Dispatchers = map[telemetry.Dispatcher]json.RawMessage{
+				registered:                json.RawMessage(`{"url":"http://127.0.0.1"}`),
+				"registered_test:partner": json.RawMessage(`{"url":"http://127.0.0.2"}`),
+			}
+
+			dispatchers, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			producers = producerRules
+			Expect(producers["V"]).To(HaveLen(2))
+			Expect(dispatchers[registered]).NotTo(BeIdenticalTo(dispatchers["registered_test:partner"]))
+
+			Expect(params).To(HaveLen(2))
+			Expect(params[0].Dispatcher).To(Equal(registered))
+			Expect(string(rawConfigs[0])).To(MatchJSON(`{"url":"http://127.0.0.1"}`))
+			Expect(params[1].Dispatcher).To(Equal(telemetry.Dispatcher("registered_test:partner")))
+			Expect(string(rawConfigs[1])).To(MatchJSON(`{"url":"http://127.0.0.2"}`))
+		})
+
+		It("loads the config of the dispatchers", func() {
+			loadedConfig, err := loadTestApplicationConfig(TestRegisteredDispatcherConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(loadedConfig.Dispatchers[registered])).To(MatchJSON(`{"url": "http://127.0.0.1", "batch_size": 10}`))
+		})
+
+		It("loads the top level config of the dispatchers without a config field", func() {
+			loadedConfig, err := loadTestApplicationConfig(strings.Replace(TestRegisteredDispatcherConfig, `"dispatchers": {`, `"registered_test": {"url": "http://127.0.0.2"}, "dispatchers": {`, 1))
+			Expect(err).To(MatchError("registered_test is configured both at the top level and under dispatchers"))
+			Expect(loadedConfig).To(BeNil())
+
+			loadedConfig, err = loadTestApplicationConfig(TestWebhookConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.Dispatchers).To(HaveKey(telemetry.Webhook))
+			Expect(loadedConfig.Dispatchers).NotTo(HaveKey(telemetry.Kafka))
+		})
+	})
+
+	Context("configure named dispatcher instances", func() {
+		It("builds an instance of a built-in dispatcher from its own config", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"kafka", "kafka:analytics"}}
+			config.Dispatchers = map[telemetry.Dispatcher]json.RawMessage{
+				"kafka:analytics": json.RawMessage(`{"producer": {"bootstrap.servers": "analytics.broker:9093", "queue.buffering.max.messages": 1000}}`),
+			}
+			config.ReliableAckSources = map[string]telemetry.Dispatcher{"V": "kafka:analytics"}
+
+			dispatchers, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			producers = producerRules
+			Expect(dispatchers["kafka:analytics"]).To(BeAssignableToTypeOf(&kafka.Producer{}))
+			Expect(dispatchers["kafka:analytics"]).NotTo(BeIdenticalTo(dispatchers[telemetry.Kafka]))
+			Expect(producers["V"]).To(ConsistOf(dispatchers[telemetry.Kafka], dispatchers["kafka:analytics"]))
+		})
+
+		It("returns an error when an instance is not configured", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"kafka:analytics"}}
+
+			_, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("expected Kafka to be configured"))
+			Expect(producerRules).To(BeNil())
+		})
+
+		DescribeTable("rejects invalid instances",
+			func(dispatcher telemetry.Dispatcher, expectedErr string) {
+				config.Records = map[string][]telemetry.Dispatcher{"V": {dispatcher}}
+
+				_, producerRules, err := config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+				Expect(err).To(MatchError(expectedErr))
+				Expect(producerRules).To(BeNil())
+			},
+			Entry("without name", telemetry.Dispatcher("kafka:"), "dispatcher instance without name: kafka:"),
+			Entry("of the logger", telemetry.Dispatcher("logger:debug"), "logger cannot have named instances: logger:debug"),
+			Entry("of the stream", telemetry.Dispatcher("stream:partner"), "stream cannot have named instances: stream:partner"),
+		)
+	})
+
+	Context("configure stream", func() {
+		var streamConfig *Config
+
+		BeforeEach(func() {
+			var err error
+			streamConfig, err = loadTestApplicationConfig(TestStreamConfig)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			_ = os.Unsetenv("STREAM_TOKEN")
+		})
+
+		It("returns an error if stream isn't included", func() {
+			config.Records = map[string][]telemetry.Dispatcher{"V": {"stream"}}
+			var err error
+			_, producers, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("expected Stream to be configured"))
+			Expect(producers).To(BeNil())
+		})
+
+		It("stream config works", func() {
+			Expect(streamConfig.configureStreamHub(log)).To(Succeed())
+			var err error
+			var dispatchers map[telemetry.Dispatcher]telemetry.Producer
+			dispatchers, producers, err = streamConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dispatchers[telemetry.Stream]).To(BeAssignableToTypeOf(&stream.Producer{}))
+			Expect(producers["V"]).To(ConsistOf(dispatchers[telemetry.Stream]))
+		})
+
+		It("rejects an invalid slow consumer action", func() {
+			streamConfig.Stream.SlowConsumer = "block"
+			Expect(streamConfig.configureStreamHub(log)).To(MatchError("invalid stream slow_consumer: block"))
+		})
+
+		It("rejects stream as reliable ack source", func() {
+			Expect(streamConfig.configureStreamHub(log)).To(Succeed())
+			streamConfig.ReliableAckSources = map[string]telemetry.Dispatcher{"V": telemetry.Stream}
+			_, _, err := streamConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).To(MatchError("stream cannot be configured as reliable ack for record: V"))
+		})
+
+		It("gets the token from the stream config, the env variable or the admin config", func() {
+			Expect(streamConfig.StreamToken()).To(Equal("test-admin-token"))
+
+			streamConfig.Stream.Token = "test-stream-token"
+			Expect(streamConfig.StreamToken()).To(Equal("test-stream-token"))
+
+			Expect(os.Setenv("STREAM_TOKEN", "environmentStreamToken")).To(Succeed())
+			Expect(streamConfig.StreamToken()).To(Equal("environmentStreamToken"))
+		})
+
+		It("has no token without stream", func() {
+			Expect(config.StreamToken()).To(BeEmpty())
+		})
+	})
+
+	Context("configure field filters", func() {
+		var filterConfig *Config
+
+		BeforeEach(func() {
+			var err error
+			filterConfig, err = loadTestApplicationConfig(TestFieldFilterConfig)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("wraps the filtered dispatchers", func() {
+			log, _ := logrus.NoOpLogger()
+			var err error
+			var dispatchers map[telemetry.Dispatcher]telemetry.Producer
+			dispatchers, producers, err = filterConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dispatchers[telemetry.Logger]).To(BeAssignableToTypeOf(&filter.Producer{}))
+			Expect(producers["V"]).To(ContainElement(dispatchers[telemetry.Logger]))
+		})
+
+		DescribeTable("fails",
+			func(filters map[telemetry.Dispatcher]map[string]*filter.Rule, errMessage string) {
+				log, _ := logrus.NoOpLogger()
+				filterConfig.Filters = filters
+
+				_, filterProducers, err := filterConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+				Expect(err).To(MatchError(errMessage))
+				Expect(filterProducers).To(BeNil())
+			},
+			Entry("when the dispatcher is unused", map[telemetry.Dispatcher]map[string]*filter.Rule{"kafka": {"V": {Include: []string{"Soc"}}}}, "kafka cannot be configured for field filters since no record is dispatched to it"),
+			Entry("when a field is unknown", map[telemetry.Dispatcher]map[string]*filter.Rule{"logger": {"V": {Include: []string{"Speed"}}}}, "unknown field in filter for record V: Speed"),
+		)
+	})
+
+	Context("configure units", func() {
+		It("creates the unit converter", func() {
+			config.Units = &units.Config{System: units.SI}
+			Expect(config.configureUnitConverter()).To(Succeed())
+			Expect(config.UnitConverter).NotTo(BeNil())
+		})
+
+		It("rejects an invalid system", func() {
+			config.Units = &units.Config{System: "metric"}
+			Expect(config.configureUnitConverter()).To(MatchError("invalid units system: metric"))
+		})
+	})
+
+	Context("configure delta", func() {
+		var deltaConfig *Config
+
+		BeforeEach(func() {
+			var err error
+			deltaConfig, err = loadTestApplicationConfig(TestDeltaConfig)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("wraps the dispatchers inside their field filters", func() {
+			var err error
+			var dispatchers map[telemetry.Dispatcher]telemetry.Producer
+			dispatchers, producers, err = deltaConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dispatchers[telemetry.Logger]).To(BeAssignableToTypeOf(&filter.Producer{}))
+			Expect(dispatchers[telemetry.Logger].(*filter.Producer).Unwrap()).To(BeAssignableToTypeOf(&delta.Producer{}))
+		})
+
+		DescribeTable("fails",
+			func(deltas map[telemetry.Dispatcher]*delta.Config, errMessage string) {
+				deltaConfig.Delta = deltas
+
+				_, deltaProducers, err := deltaConfig.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+				Expect(err).To(MatchError(errMessage))
+				Expect(deltaProducers).To(BeNil())
+			},
+			Entry("when the dispatcher is unused", map[telemetry.Dispatcher]*delta.Config{"kafka": {}}, "kafka cannot be configured for delta since no record is dispatched to it"),
+			Entry("when a field is unknown", map[telemetry.Dispatcher]*delta.Config{"logger": {AlwaysSend: []string{"Speed"}}}, "unknown field in delta always_send of logger: Speed"),
+		)
+	})
+
 	Context("configureMetricsCollector", func() {
 		It("does not fail when TLS is nil ", func() {
 			log, _ := logrus.NoOpLogger()
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// RateLimitAction is the action taken on a message over its rate limit
+type RateLimitAction string
+
+const (
+	// RateLimitDrop drops the message without acknowledging it, so the vehicle sends it again later
+	RateLimitDrop RateLimitAction = "drop"
+
+	// RateLimitAck acknowledges the message without dispatching it
+	RateLimitAck RateLimitAction = "ack"
+
+	// RateLimitClose closes the socket
+	RateLimitClose RateLimitAction = "close"
+)
+
+// defaultRateLimitRule applies when no rate limit is configured
+var defaultRateLimitRule = RateLimitRule{Limit: 100, Interval: 60 * time.Second, Action: RateLimitDrop}
+
+// RateLimitPolicy limits the messages of a record type
+type RateLimitPolicy struct {
+	// MessageLimit is the number of messages allowed per interval
+	MessageLimit int `json:"message_limit,omitempty"`
+
+	// MessageInterval is the rate limit time interval in seconds
+	MessageInterval int `json:"message_interval_time,omitempty"`
+
+	// Action taken on messages over the limit, defaults to the action of the rate limit config
+	Action RateLimitAction `json:"action,omitempty"`
+}
+
+// RateLimitOverride replaces the rate limits of a single vin
+type RateLimitOverride struct {
+	// RateLimitPolicy replaces the limit of record types without their own policy, when MessageLimit is set
+	RateLimitPolicy
+
+	// RecordTypes replaces the limits of the listed record types
+	RecordTypes map[string]*RateLimitPolicy `json:"record_types,omitempty"`
+}
+
+// RateLimitRule is a rate limit resolved for a socket
+type RateLimitRule struct {
+	Limit    int
+	Interval time.Duration
+	Action   RateLimitAction
+}
+
+// RateLimitRules are the rate limits of a socket. Record types listed in RecordTypes have their own limit,
+// all others share Default. A nil rule means unlimited.
+type RateLimitRules struct {
+	Default     *RateLimitRule
+	RecordTypes map[string]*RateLimitRule
+}
+
+// RateLimitRules returns the rate limits applied to the socket of a vin, nil when rate limiting is disabled
+func (c *Config) RateLimitRules(vin string) *RateLimitRules {
+	if c.RateLimit == nil {
+		rule := defaultRateLimitRule
+		return &RateLimitRules{Default: &rule}
+	}
+	if !c.RateLimit.Enabled {
+		return nil
+	}
+
+	action := c.RateLimit.action()
+	interval := c.RateLimit.MessageIntervalTimeSecond
+	if interval == 0 {
+		interval = time.Duration(c.RateLimit.MessageInterval) * time.Second
+	}
+	rules := &RateLimitRules{
+		Default:     &RateLimitRule{Limit: c.RateLimit.MessageLimit, Interval: interval, Action: action},
+		RecordTypes: make(map[string]*RateLimitRule, len(c.RateLimit.RecordTypes)),
+	}
+	for recordType, policy := range c.RateLimit.RecordTypes {
+		rules.RecordTypes[recordType] = policy.rule(action)
+	}
+
+	override, ok := c.RateLimit.vinOverrides[vin]
+	if !ok {
+		return rules
+	}
+	if override.MessageLimit > 0 {
+		rules.Default = override.rule(action)
+	}
+	for recordType, policy := range override.RecordTypes {
+		rules.RecordTypes[recordType] = policy.rule(action)
+	}
+	return rules
+}
+
+func (p *RateLimitPolicy) rule(defaultAction RateLimitAction) *RateLimitRule {
+	action := p.Action
+	if action == "" {
+		action = defaultAction
+	}
+	return &RateLimitRule{Limit: p.MessageLimit, Interval: time.Duration(p.MessageInterval) * time.Second, Action: action}
+}
+
+func (p *RateLimitPolicy) validate(name string) error {
+	if p == nil || p.MessageLimit <= 0 || p.MessageInterval <= 0 {
+		return fmt.Errorf("rate limit for %s must set a positive message_limit and message_interval_time", name)
+	}
+	return validateRateLimitAction(p.Action)
+}
+
+func (r *RateLimit) action() RateLimitAction {
+	if r.Action == "" {
+		return RateLimitDrop
+	}
+	return r.Action
+}
+
+// validate checks the limit of an enabled rate limit and its policies, and loads the vin overrides file
+func (r *RateLimit) validate() error {
+	if err := validateRateLimitAction(r.Action); err != nil {
+		return err
+	}
+	if r.Enabled && (r.MessageLimit <= 0 || (r.MessageInterval <= 0 && r.MessageIntervalTimeSecond <= 0)) {
+		return errors.New("enabled rate limit must set a positive message_limit and message_interval_time")
+	}
+	for recordType, policy := range r.RecordTypes {
+		if err := policy.validate("record " + recordType); err != nil {
+			return err
+		}
+	}
+	if r.VinOverridesFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.VinOverridesFile)
+	if err != nil {
+		return err
+	}
+	var overrides map[string]*RateLimitOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("invalid rate limit vin overrides file %s: %w", r.VinOverridesFile, err)
+	}
+	for vin, override := range overrides {
+		if override == nil {
+			return fmt.Errorf("rate limit override for vin %s is empty", vin)
+		}
+		if override.MessageLimit > 0 || override.MessageInterval > 0 {
+			if err := override.RateLimitPolicy.validate("vin " + vin); err != nil {
+				return err
+			}
+		}
+		for recordType, policy := range override.RecordTypes {
+			if err := policy.validate(fmt.Sprintf("vin %s record %s", vin, recordType)); err != nil {
+				return err
+			}
+		}
+	}
+	r.vinOverrides = overrides
+	return nil
+}
+
+func validateRateLimitAction(action RateLimitAction) error {
+	switch action {
+	case "", RateLimitDrop, RateLimitAck, RateLimitClose:
+		return nil
+	default:
+		return fmt.Errorf("unknown rate limit action: %s", action)
+	}
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// ReloadApplicationConfiguration re-reads and validates the file the config was loaded from.
+// Settings bound when the server starts (listeners, TLS, monitoring, logging, airbrake, capture, dead letters,
+// dedup, units, derived signals, geofences, sessions, alert lifecycle, state, stream and the admin API) are kept from
+// the current config, so changing them still requires a restart.
+func (c *Config) ReloadApplicationConfiguration() (*Config, error) {
+	if c.path == "" {
+		return nil, errors.New("config was not loaded from a file")
+	}
+	reloaded, err := readApplicationConfig(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	reloaded.Host = c.Host
+	reloaded.Port = c.Port
+	reloaded.StatusPort = c.StatusPort
+	reloaded.TLS = c.TLS
+	reloaded.UseDefaultEngCA = c.UseDefaultEngCA
+	reloaded.Admin = c.Admin
+	reloaded.Monitoring = c.Monitoring
+	reloaded.LogLevel = c.LogLevel
+	reloaded.JSONLogEnable = c.JSONLogEnable
+	reloaded.Airbrake = c.Airbrake
+	reloaded.Capture = c.Capture
+	reloaded.DeadLetter = c.DeadLetter
+	reloaded.Dedup = c.Dedup
+	reloaded.Derived = c.Derived
+	reloaded.Geofence = c.Geofence
+	reloaded.Sessions = c.Sessions
+	reloaded.AlertLifecycle = c.AlertLifecycle
+	reloaded.State = c.State
+	reloaded.Units = c.Units
+	reloaded.UnitConverter = c.UnitConverter
+	reloaded.Stream = c.Stream
+	reloaded.StreamHub = c.StreamHub
+	reloaded.MetricCollector = c.MetricCollector
+	reloaded.AckChan = c.AckChan
+	return reloaded, nil
+}
+
+// ReconfigureProducers builds the producers of a reloaded config. The producers of dispatchers whose settings
+// are unchanged are reused from the previous config, the others are rebuilt and the previous ones are returned
+// as retired, for the caller to close once records stop flowing to them.
+func (c *Config) ReconfigureProducers(previous *Config, previousProducers map[telemetry.Dispatcher]telemetry.Producer, airbrakeHandler *airbrake.Handler, logger *logrus.Logger) (map[telemetry.Dispatcher]telemetry.Producer, map[string][]telemetry.Producer, []telemetry.Producer, error) {
+	requiredDispatchers := c.requiredDispatchers()
+	reused := make(map[telemetry.Dispatcher]telemetry.Producer, len(previousProducers))
+	for dispatcher, producer := range previousProducers {
+		if _, ok := requiredDispatchers[dispatcher]; !ok && dispatcher != telemetry.Logger {
+			continue
+		}
+		previousSettings, err := previous.dispatcherSettings(dispatcher)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		settings, err := c.dispatcherSettings(dispatcher)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if string(previousSettings) == string(settings) {
+			reused[dispatcher] = producer
+			continue
+		}
+		if previous.isSpooled(dispatcher) && c.isSpooled(dispatcher) && previous.Spool.Dir == c.Spool.Dir {
+			return nil, nil, nil, fmt.Errorf("spooled dispatcher %s cannot be reconfigured without a restart", dispatcher)
+		}
+	}
+
+	producers, producerRules, err := c.configureProducers(airbrakeHandler, logger, false, reused)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var retired []telemetry.Producer
+	for dispatcher, producer := range previousProducers {
+		if _, ok := reused[dispatcher]; !ok {
+			retired = append(retired, producer)
+		}
+	}
+	return producers, producerRules, retired, nil
+}
+
+// dispatcherSettings returns everything the producer of a dispatcher is built from: the config and parameters given
+// to its factory, and the wrappers of the config
+func (c *Config) dispatcherSettings(dispatcher telemetry.Dispatcher) ([]byte, error) {
+	settings := struct {
+		Backend                interface{} `json:"backend"`
+		Namespace              string      `json:"namespace"`
+		Prometheus             bool        `json:"prometheus"`
+		TransmitDecodedRecords bool        `json:"transmit_decoded_records"`
+		ReliableAckRecords     []string    `json:"reliable_ack_records"`
+		Records                []string    `json:"records"`
+		Spool                  interface{} `json:"spool"`
+		Filters                interface{} `json:"filters"`
+		Delta                  interface{} `json:"delta"`
+	}{
+		Namespace:              c.Namespace,
+		Prometheus:             c.prometheusEnabled(),
+		TransmitDecodedRecords: c.TransmitDecodedRecords,
+		Filters:                c.Filters[dispatcher],
+		Delta:                  c.Delta[dispatcher],
+	}
+
+	switch dispatcher {
+	case telemetry.Logger:
+		settings.Backend = c.LoggerConfig
+	case telemetry.Stream:
+		settings.Backend = c.Stream
+	default:
+		rawConfig, err := c.dispatcherConfig(dispatcher)
+		if err != nil {
+			return nil, err
+		}
+		settings.Backend = rawConfig
+		if !telemetry.ProducerIgnoresRecordTypes(dispatcher, rawConfig) {
+			settings.Records = c.requiredDispatchers()[dispatcher]
+		}
+	}
+	sort.Strings(settings.Records)
+
+	for txType, reliableAckDispatcher := range c.ReliableAckSources {
+		if reliableAckDispatcher == dispatcher {
+			settings.ReliableAckRecords = append(settings.ReliableAckRecords, txType)
+		}
+	}
+	for txType, policy := range c.ReliableAckPolicies {
+		if policy != nil && slices.Contains(policy.Dispatchers, dispatcher) {
+			settings.ReliableAckRecords = append(settings.ReliableAckRecords, txType)
+		}
+	}
+	sort.Strings(settings.ReliableAckRecords)
+
+	if c.isSpooled(dispatcher) {
+		spoolConfig := *c.Spool
+		spoolConfig.Dispatchers = nil
+		settings.Spool = spoolConfig
+	}
+	return json.Marshal(settings)
+}
+
+// requiredDispatchers maps each dispatcher in use to the records dispatched to it
+func (c *Config) requiredDispatchers() map[telemetry.Dispatcher][]string {
+	requiredDispatchers := make(map[telemetry.Dispatcher][]string)
+	for recordName, dispatchRules := range c.Records {
+		for _, dispatchRule := range dispatchRules {
+			requiredDispatchers[dispatchRule] = append(requiredDispatchers[dispatchRule], recordName)
+		}
+	}
+	return requiredDispatchers
+}
+
+// isSpooled returns whether the producer of a dispatcher is wrapped with a spool
+func (c *Config) isSpooled(dispatcher telemetry.Dispatcher) bool {
+	if c.Spool == nil {
+		return false
+	}
+	for _, spooled := range c.Spool.Dispatchers {
+		if spooled == dispatcher {
+			return true
+		}
+	}
+	return false
+}
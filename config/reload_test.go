@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Reload application config", func() {
+	var (
+		config    *Config
+		producers map[telemetry.Dispatcher]telemetry.Producer
+		log       *logrus.Logger
+	)
+
+	rewrite := func(replacements ...string) {
+		Expect(os.WriteFile(config.path, []byte(strings.NewReplacer(replacements...).Replace(TestSmallConfig)), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		log, _ = logrus.NoOpLogger()
+		var err error
+		config, err = loadTestApplicationConfig(TestSmallConfig)
+		Expect(err).NotTo(HaveOccurred())
+		producers, _, err = config.ConfigureProducers(airbrake.NewAirbrakeHandler(nil), log, true)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		for _, producer := range producers {
+			_ = producer.Close()
+		}
+	})
+
+	It("reuses the producers of unchanged dispatchers", func() {
+		rewrite(`"V": ["kafka"]`, `"V": ["kafka", "logger"], "errors": ["kafka"]`)
+
+		reloaded, err := config.ReloadApplicationConfiguration()
+		Expect(err).NotTo(HaveOccurred())
+		reloadedProducers, producerRules, retired, err := reloaded.ReconfigureProducers(config, producers, airbrake.NewAirbrakeHandler(nil), log)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(retired).To(BeEmpty())
+		Expect(reloadedProducers[telemetry.Kafka]).To(BeIdenticalTo(producers[telemetry.Kafka]))
+		Expect(producerRules["V"]).To(HaveLen(2))
+		Expect(producerRules["errors"]).To(ConsistOf(BeIdenticalTo(producers[telemetry.Kafka])))
+		producers = reloadedProducers
+	})
+
+	It("rebuilds the producers of changed dispatchers", func() {
+		rewrite("some.broker1:9093,some.broker1:9093", "some.broker2:9093")
+
+		reloaded, err := config.ReloadApplicationConfiguration()
+		Expect(err).NotTo(HaveOccurred())
+		reloadedProducers, producerRules, retired, err := reloaded.ReconfigureProducers(config, producers, airbrake.NewAirbrakeHandler(nil), log)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(retired).To(ConsistOf(BeIdenticalTo(producers[telemetry.Kafka])))
+		Expect(reloadedProducers[telemetry.Kafka]).NotTo(BeIdenticalTo(producers[telemetry.Kafka]))
+		Expect(reloadedProducers[telemetry.Logger]).To(BeIdenticalTo(producers[telemetry.Logger]))
+		Expect(producerRules["V"]).To(ConsistOf(BeIdenticalTo(reloadedProducers[telemetry.Kafka])))
+
+		for _, producer := range retired {
+			Expect(producer.Close()).To(Succeed())
+		}
+		producers = reloadedProducers
+	})
+
+	It("retires the producers of dispatchers no longer in use", func() {
+		rewrite(`"V": ["kafka"]`, `"V": ["logger"]`)
+
+		reloaded, err := config.ReloadApplicationConfiguration()
+		Expect(err).NotTo(HaveOccurred())
+		reloadedProducers, _, retired, err := reloaded.ReconfigureProducers(config, producers, airbrake.NewAirbrakeHandler(nil), log)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(retired).To(ConsistOf(BeIdenticalTo(producers[telemetry.Kafka])))
+		Expect(reloadedProducers).NotTo(HaveKey(telemetry.Kafka))
+
+		for _, producer := range retired {
+			Expect(producer.Close()).To(Succeed())
+		}
+		producers = reloadedProducers
+	})
+
+	It("keeps the settings bound at startup", func() {
+		rewrite(`"port": 443`, `"port": 8443`, `"status_port": 8080`, `"status_port": 9090, "capture": {"dir": "/tmp/capture"}, "stream": {"buffer_size": 10}, "dedup": {"ttl_seconds": 60}, "units": {"system": "si"}, "derived": {"signals": [{"name": "soc", "expression": "Soc"}]}, "geofence": {"file": "/tmp/geofences.json"}, "sessions": {}, "dead_letter": {"file": "/tmp/deadletter.jsonl"}, "alert_lifecycle": {}`)
+
+		reloaded, err := config.ReloadApplicationConfiguration()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reloaded.Port).To(Equal(443))
+		Expect(reloaded.StatusPort).To(Equal(8080))
+		Expect(reloaded.Capture).To(BeNil())
+		Expect(reloaded.Stream).To(BeNil())
+		Expect(reloaded.Dedup).To(BeNil())
+		Expect(reloaded.Units).To(BeNil())
+		Expect(reloaded.Derived).To(BeNil())
+		Expect(reloaded.Geofence).To(BeNil())
+		Expect(reloaded.Sessions).To(BeNil())
+		Expect(reloaded.DeadLetter).To(BeNil())
+		Expect(reloaded.AlertLifecycle).To(BeNil())
+		Expect(reloaded.StreamHub).To(BeNil())
+		Expect(reloaded.AckChan).To(Equal(config.AckChan))
+		Expect(reloaded.MetricCollector).To(Equal(config.MetricCollector))
+	})
+
+	It("compares the config given to the factory of every dispatcher", func() {
+		webhookConfig, err := loadTestApplicationConfig(TestWebhookConfig)
+		Expect(err).NotTo(HaveOccurred())
+		changedConfig, err := loadTestApplicationConfig(strings.Replace(TestWebhookConfig, "http://127.0.0.1:8090/telemetry", "http://127.0.0.2:8090/telemetry", 1))
+		Expect(err).NotTo(HaveOccurred())
+
+		settings, err := webhookConfig.dispatcherSettings(telemetry.Webhook)
+		Expect(err).NotTo(HaveOccurred())
+		changedSettings, err := changedConfig.dispatcherSettings(telemetry.Webhook)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changedSettings).NotTo(Equal(settings))
+
+		// webhook producers ignore their record types, unlike kinesis producers which map them to streams
+		changedConfig, err = loadTestApplicationConfig(TestWebhookConfig)
+		Expect(err).NotTo(HaveOccurred())
+		changedConfig.Records["errors"] = []telemetry.Dispatcher{telemetry.Webhook, telemetry.Kinesis}
+		for dispatcher, changed := range map[telemetry.Dispatcher]bool{telemetry.Webhook: false, telemetry.Kinesis: true} {
+			settings, err = webhookConfig.dispatcherSettings(dispatcher)
+			Expect(err).NotTo(HaveOccurred())
+			changedSettings, err = changedConfig.dispatcherSettings(dispatcher)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(changedSettings) != string(settings)).To(Equal(changed), string(dispatcher))
+		}
+	})
+
+	It("fails on an invalid file", func() {
+		rewrite(`"records": {`, `"records": {{`)
+
+		_, err := config.ReloadApplicationConfiguration()
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -202,6 +202,166 @@ const TestZMQConfig = `
 }
 `
 
+const TestSpoolConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "zmq": {
+    "addr": "tcp://127.0.0.1:5289"
+  },
+  "spool": {
+    "dir": "/tmp/fleet-telemetry/spool",
+    "dispatchers": ["zmq"],
+    "max_bytes": 1073741824,
+    "max_age_seconds": 86400
+  },
+  "records": {
+    "V": ["zmq", "logger"]
+  }
+}
+`
+
+const TestFieldFilterConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "filters": {
+    "logger": {
+      "V": {
+        "include": ["VehicleSpeed", "Soc"]
+      }
+    }
+  },
+  "records": {
+    "V": ["logger"],
+    "alerts": ["logger"]
+  }
+}
+`
+
+const TestDeltaConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "filters": {
+    "logger": {
+      "V": {
+        "include": ["VehicleSpeed", "Soc"]
+      }
+    }
+  },
+  "delta": {
+    "logger": {
+      "always_send": ["VehicleSpeed"],
+      "keyframe_interval_minutes": 10
+    }
+  },
+  "records": {
+    "V": ["logger"]
+  }
+}
+`
+
+const TestFileConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "file": {
+    "dir": "/tmp/fleet-telemetry",
+    "format": "json",
+    "compression": "gzip",
+    "fsync_interval_ms": 500
+  },
+  "reliable_ack_sources": {
+    "V": "file"
+  },
+  "records": {
+    "V": ["file"],
+    "alerts": ["file"]
+  }
+}
+`
+
+const TestParquetConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "parquet": {
+    "dir": "/tmp/fleet-telemetry-parquet",
+    "row_group_size": 1000,
+    "flush_interval_ms": 30000
+  },
+  "records": {
+    "V": ["parquet"]
+  }
+}
+`
+
+const TestWebhookConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "webhook": {
+    "url": "http://127.0.0.1:8090/telemetry",
+    "urls": {
+      "alerts": "http://127.0.0.1:8090/alerts"
+    },
+    "batch_size": 50,
+    "linger_ms": 500,
+    "secret": "s3cr3t"
+  },
+  "reliable_ack_sources": {
+    "V": "webhook"
+  },
+  "records": {
+    "V": ["webhook"],
+    "alerts": ["webhook"]
+  }
+}
+`
+
+const TestStreamConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "admin": {
+    "token": "test-admin-token"
+  },
+  "stream": {
+    "buffer_size": 10,
+    "slow_consumer": "disconnect"
+  },
+  "records": {
+    "V": ["stream"],
+    "alerts": ["stream", "logger"]
+  }
+}
+`
+
+const TestRegisteredDispatcherConfig = `
+{
+  "host": "127.0.0.1",
+  "port": 443,
+  "status_port": 8080,
+  "dispatchers": {
+    "registered_test": {
+      "url": "http://127.0.0.1",
+      "batch_size": 10
+    }
+  },
+  "records": {
+    "V": ["registered_test"]
+  }
+}
+`
+
 const TestTransmitDecodedRecords = `
 {
 	"host": "127.0.0.1",
@@ -257,6 +417,73 @@ const TestAirbrakeConfig = `
 }
 `
 
+const TestAdminConfig = `
+{
+	"host": "127.0.0.1",
+	"port": 443,
+	"status_port": 8080,
+	"records": {
+		"V": ["logger"]
+	},
+	"tls": {
+		"ca_file": "tesla.ca",
+		"server_cert": "your_own_cert.crt",
+		"server_key": "your_own_key.key"
+	},
+	"admin": {
+		"token": "test-admin-token"
+	}
+}
+`
+
+const TestRateLimitConfig = `
+{
+	"host": "127.0.0.1",
+	"port": 443,
+	"status_port": 8080,
+	"records": {
+		"V": ["logger"],
+		"alerts": ["logger"]
+	},
+	"tls": {
+		"ca_file": "tesla.ca",
+		"server_cert": "your_own_cert.crt",
+		"server_key": "your_own_key.key"
+	},
+	"rate_limit": {
+		"enabled": true,
+		"message_limit": 1000,
+		"message_interval_time": 30,
+		"action": "ack",
+		"record_types": {
+			"alerts": {
+				"message_limit": 10,
+				"message_interval_time": 60,
+				"action": "close"
+			}
+		},
+		"vin_overrides_file": "VIN_OVERRIDES_FILE"
+	}
+}
+`
+
+const TestRateLimitVinOverrides = `
+{
+	"VIN1": {
+		"message_limit": 5000,
+		"message_interval_time": 10
+	},
+	"VIN2": {
+		"record_types": {
+			"alerts": {
+				"message_limit": 100,
+				"message_interval_time": 60
+			}
+		}
+	}
+}
+`
+
 const TestBadTxTypeReliableAckConfig = `
 {
 	"host": "127.0.0.1",
@@ -0,0 +1,503 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// DefaultMaxFileBytes is the size on disk at which a new file is started
+	DefaultMaxFileBytes = 64 << 20
+	// DefaultFsyncIntervalMs is how often the files are synced to disk
+	DefaultFsyncIntervalMs = 1000
+
+	// FormatJSON writes one JSON record per line (NDJSON)
+	FormatJSON = "json"
+	// FormatProtobuf writes length-delimited protobuf messages
+	FormatProtobuf = "protobuf"
+
+	// CompressionGzip compresses the files with gzip
+	CompressionGzip = "gzip"
+	// CompressionNone writes the files uncompressed
+	CompressionNone = "none"
+
+	partitionLayout = "2006-01-02/15"
+	bufferSize      = 64 << 10
+)
+
+// Config for the file producer
+type Config struct {
+	// Dir is the directory the files are written to, under <record_type>/<YYYY-MM-DD>/<HH>/
+	Dir string `json:"dir"`
+
+	// Format of the files, "json" (NDJSON) or "protobuf" (length-delimited). Default: json
+	Format string `json:"format,omitempty"`
+
+	// Compression of the files, "gzip" or "none". Default: gzip
+	Compression string `json:"compression,omitempty"`
+
+	// MaxFileBytes is the size on disk at which a new file is started. Default: 64MB
+	MaxFileBytes int64 `json:"max_file_bytes,omitempty"`
+
+	// FsyncIntervalMs is how often the files are flushed and synced to disk, records being acked once synced.
+	// Default: 1000
+	FsyncIntervalMs int `json:"fsync_interval_ms,omitempty"`
+}
+
+// jsonRecord is the JSON representation of a record in a file
+type jsonRecord struct {
+	Vin        string          `json:"vin"`
+	Txid       string          `json:"txid"`
+	RecordType string          `json:"record_type"`
+	ReceivedAt int64           `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Producer writes records to rotating files partitioned by record type, date and hour
+type Producer struct {
+	dispatcher         telemetry.Dispatcher
+	config             *Config
+	extension          string
+	logger             *logrus.Logger
+	airbrakeHandler    *airbrake.Handler
+	ackChan            chan (*telemetry.Ack)
+	reliableAckTxTypes map[string]interface{}
+
+	mutex    sync.Mutex
+	closed   bool
+	segments map[string]*segment
+	lastName int64
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	telemetry.DeliveryNotifier
+}
+
+// segment is the file being written for a record type, and the records written to it since the last sync
+type segment struct {
+	partition string
+	path      string
+	file      *os.File
+	counter   *countingWriter
+	buffer    *bufio.Writer
+	gzip      *gzip.Writer
+	writer    io.Writer
+	pending   []*telemetry.Record
+}
+
+// countingWriter counts the bytes written to the file
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	writeCount       adapter.Counter
+	bytesTotal       adapter.Counter
+	fileCount        adapter.Counter
+	errorCount       adapter.Counter
+	reliableAckCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.File, newProducerFromConfig, telemetry.IgnoresRecordTypes())
+}
+
+// newProducerFromConfig is the producer factory of the file dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected File to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid file config: %w", err)
+	}
+	return NewProducer(config, params.Dispatcher, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
+// NewProducer validates the file configuration, creates its directory and starts syncing the files on schedule
+func NewProducer(config *Config, dispatcher telemetry.Dispatcher, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+	registerMetricsOnce(metricsCollector)
+
+	if config.Dir == "" {
+		return nil, errors.New("file dir is required")
+	}
+	if config.Format == "" {
+		config.Format = FormatJSON
+	}
+	if config.Compression == "" {
+		config.Compression = CompressionGzip
+	}
+	extension := ".ndjson"
+	switch config.Format {
+	case FormatJSON:
+	case FormatProtobuf:
+		extension = ".pb"
+	default:
+		return nil, fmt.Errorf("unsupported file format: %s", config.Format)
+	}
+	switch config.Compression {
+	case CompressionNone:
+	case CompressionGzip:
+		extension += ".gz"
+	default:
+		return nil, fmt.Errorf("unsupported file compression: %s", config.Compression)
+	}
+	if config.MaxFileBytes <= 0 {
+		config.MaxFileBytes = DefaultMaxFileBytes
+	}
+	if config.FsyncIntervalMs <= 0 {
+		config.FsyncIntervalMs = DefaultFsyncIntervalMs
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	producer := &Producer{
+		dispatcher:         dispatcher,
+		config:             config,
+		extension:          extension,
+		logger:             logger,
+		airbrakeHandler:    airbrakeHandler,
+		ackChan:            ackChan,
+		reliableAckTxTypes: reliableAckTxTypes,
+		segments:           make(map[string]*segment),
+		stopChan:           make(chan struct{}),
+	}
+	producer.wg.Add(1)
+	go producer.runSync()
+	producer.logger.ActivityLog("file_registered", logrus.LogInfo{"dir": config.Dir, "format": config.Format, "compression": config.Compression, "fsync_interval_ms": config.FsyncIntervalMs})
+	return producer, nil
+}
+
+// Produce appends the record to the file of its record type and hour. It is acked once the file is synced.
+func (p *Producer) Produce(entry *telemetry.Record) {
+	data, err := p.encode(entry)
+	if err != nil {
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+		p.ReportError("file_encode_error", err, logrus.LogInfo{"record_type": entry.TxType, "txid": entry.Txid})
+		p.NotifyDelivery(entry, telemetry.ErrRecordRejected)
+		return
+	}
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		p.NotifyDelivery(entry, errors.New("file producer closed"))
+		return
+	}
+	var acks []*telemetry.Record
+	current, err := p.segmentFor(entry, int64(len(data)), &acks)
+	if err == nil {
+		_, err = current.writer.Write(data)
+	}
+	if err == nil {
+		current.pending = append(current.pending, entry)
+	}
+	p.mutex.Unlock()
+
+	p.acknowledge(acks)
+	if err != nil {
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+		p.ReportError("file_write_error", err, logrus.LogInfo{"record_type": entry.TxType, "txid": entry.Txid})
+		p.NotifyDelivery(entry, err)
+		return
+	}
+	metricsRegistry.writeCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	metricsRegistry.bytesTotal.Add(int64(len(data)), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+}
+
+// Close syncs and closes the files being written and stops the sync schedule
+func (p *Producer) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.stopChan)
+	p.mutex.Unlock()
+	p.wg.Wait()
+
+	p.mutex.Lock()
+	var acks []*telemetry.Record
+	var errs []error
+	for txType, current := range p.segments {
+		errs = append(errs, p.closeSegment(current, &acks))
+		delete(p.segments, txType)
+	}
+	p.mutex.Unlock()
+
+	p.acknowledge(acks)
+	return errors.Join(errs...)
+}
+
+// ProcessReliableAck sends to ackChan if reliable ack is configured
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	_, ok := p.reliableAckTxTypes[entry.TxType]
+	if ok {
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	}
+}
+
+// ReportError to airbrake and logger
+func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo) {
+	p.airbrakeHandler.ReportLogMessage(logrus.ERROR, message, err, logInfo)
+	p.logger.ErrorLog(message, err, logInfo)
+}
+
+// Sync flushes the files being written to disk and acks their records. It runs every FsyncIntervalMs, files of a
+// past hour are closed.
+func (p *Producer) Sync() error {
+	p.mutex.Lock()
+	var acks []*telemetry.Record
+	var errs []error
+	currentPartition := time.Now().UTC().Format(partitionLayout)
+	for txType, current := range p.segments {
+		if current.partition != currentPartition {
+			errs = append(errs, p.closeSegment(current, &acks))
+			delete(p.segments, txType)
+			continue
+		}
+		errs = append(errs, p.syncSegment(current, &acks))
+	}
+	p.mutex.Unlock()
+
+	p.acknowledge(acks)
+	return errors.Join(errs...)
+}
+
+func (p *Producer) runSync() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(time.Duration(p.config.FsyncIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+			if err := p.Sync(); err != nil {
+				metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+				p.ReportError("file_sync_error", err, nil)
+			}
+		}
+	}
+}
+
+// segmentFor returns the file the record is written to, starting a new one when the record belongs to another hour
+// or the file is full. The records of a closed file are added to acks.
+func (p *Producer) segmentFor(entry *telemetry.Record, size int64, acks *[]*telemetry.Record) (*segment, error) {
+	receivedAt := time.Now()
+	if entry.ReceivedTimestamp > 0 {
+		receivedAt = time.UnixMilli(entry.ReceivedTimestamp)
+	}
+	partition := receivedAt.UTC().Format(partitionLayout)
+
+	current, ok := p.segments[entry.TxType]
+	if ok && current.partition == partition && current.size()+size <= p.config.MaxFileBytes {
+		return current, nil
+	}
+	if ok {
+		delete(p.segments, entry.TxType)
+		if err := p.closeSegment(current, acks); err != nil {
+			return nil, err
+		}
+	}
+	current, err := p.openSegment(entry.TxType, partition)
+	if err != nil {
+		return nil, err
+	}
+	p.segments[entry.TxType] = current
+	return current, nil
+}
+
+// openSegment creates a new file in the partition of a record type
+func (p *Producer) openSegment(txType string, partition string) (*segment, error) {
+	dir := filepath.Join(p.config.Dir, txType, filepath.FromSlash(partition))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// file names sort in creation order, even when two files are created within the same nanosecond
+	name := max(time.Now().UnixNano(), p.lastName+1)
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", name, p.extension))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	p.lastName = name
+
+	current := &segment{partition: partition, path: path, file: file, counter: &countingWriter{writer: file}}
+	current.buffer = bufio.NewWriterSize(current.counter, bufferSize)
+	current.writer = current.buffer
+	if p.config.Compression == CompressionGzip {
+		current.gzip = gzip.NewWriter(current.buffer)
+		current.writer = current.gzip
+	}
+	metricsRegistry.fileCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": txType})
+	return current, nil
+}
+
+// syncSegment flushes a file to disk, its records are added to acks, or failed when the sync fails
+func (p *Producer) syncSegment(current *segment, acks *[]*telemetry.Record) error {
+	if len(current.pending) == 0 {
+		return nil
+	}
+	err := current.flush()
+	if err == nil {
+		err = current.file.Sync()
+	}
+	p.settle(current, err, acks)
+	return err
+}
+
+// closeSegment syncs and closes a file
+func (p *Producer) closeSegment(current *segment, acks *[]*telemetry.Record) error {
+	var err error
+	if current.gzip != nil {
+		err = current.gzip.Close()
+	}
+	if err == nil {
+		err = current.buffer.Flush()
+	}
+	if err == nil {
+		err = current.file.Sync()
+	}
+	p.settle(current, err, acks)
+	if closeErr := current.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// settle moves the records of a segment to acks once synced, or reports their failure
+func (p *Producer) settle(current *segment, err error, acks *[]*telemetry.Record) {
+	if err != nil {
+		for _, entry := range current.pending {
+			p.NotifyDelivery(entry, err)
+		}
+	} else {
+		*acks = append(*acks, current.pending...)
+	}
+	current.pending = nil
+}
+
+// acknowledge sends the reliable acks of synced records, outside of the lock of the producer
+func (p *Producer) acknowledge(acks []*telemetry.Record) {
+	for _, entry := range acks {
+		p.ProcessReliableAck(entry)
+		p.NotifyDelivery(entry, nil)
+	}
+}
+
+func (s *segment) flush() error {
+	if s.gzip != nil {
+		if err := s.gzip.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.buffer.Flush()
+}
+
+// size returns the bytes of the file, including its buffered bytes
+func (s *segment) size() int64 {
+	return s.counter.count + int64(s.buffer.Buffered())
+}
+
+// encode serializes a record in the configured format
+func (p *Producer) encode(entry *telemetry.Record) ([]byte, error) {
+	if p.config.Format == FormatProtobuf {
+		payload := entry.Payload()
+		if message := entry.GetProtoMessage(); message != nil {
+			var err error
+			if payload, err = proto.Marshal(message); err != nil {
+				return nil, err
+			}
+		}
+		return protowire.AppendBytes(nil, payload), nil
+	}
+
+	payload, err := entry.GetJSONPayload()
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(jsonRecord{
+		Vin:        entry.Vin,
+		Txid:       entry.Txid,
+		RecordType: entry.TxType,
+		ReceivedAt: entry.ReceivedTimestamp,
+		Payload:    payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.writeCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "file_write_total",
+		Help:   "The number of records written to files.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.bytesTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "file_write_total_bytes",
+		Help:   "The number of bytes written to files, before compression.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.fileCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "file_created_total",
+		Help:   "The number of files created.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "file_err",
+		Help:   "The number of errors while writing or syncing files.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "file_reliable_ack_total",
+		Help:   "The number of records written to files for which we sent a reliable ACK.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+}
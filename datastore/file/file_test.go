@@ -0,0 +1,194 @@
+package file_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/file"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("File producer", func() {
+	var (
+		logger     *logrus.Logger
+		serializer *telemetry.BinarySerializer
+		dir        string
+		ackChan    chan *telemetry.Ack
+		receivedAt time.Time
+	)
+
+	newRecord := func(txid string) *telemetry.Record {
+		payload, err := proto.Marshal(&protos.Payload{
+			Vin:  "42",
+			Data: []*protos.Datum{{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: "D"}}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		message := messages.StreamMessage{TXID: []byte(txid), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte("V"), Payload: payload}
+		recordMsg, err := message.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		record.ReceivedTimestamp = receivedAt.UnixMilli()
+		return record
+	}
+
+	newProducer := func(config *file.Config) *file.Producer {
+		config.Dir = dir
+		config.FsyncIntervalMs = 60000
+		producer, err := file.NewProducer(config, telemetry.File, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, map[string]interface{}{"V": true}, logger)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(producer.Close)
+		return producer.(*file.Producer)
+	}
+
+	partitionFiles := func(at time.Time) []string {
+		files, err := filepath.Glob(filepath.Join(dir, "V", at.UTC().Format("2006-01-02"), at.UTC().Format("15"), "*"))
+		Expect(err).NotTo(HaveOccurred())
+		return files
+	}
+
+	readLines := func(path string) []map[string]interface{} {
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = f.Close() }()
+		reader, err := gzip.NewReader(f)
+		Expect(err).NotTo(HaveOccurred())
+
+		var lines []map[string]interface{}
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := map[string]interface{}{}
+			Expect(json.Unmarshal(scanner.Bytes(), &line)).To(Succeed())
+			lines = append(lines, line)
+		}
+		Expect(scanner.Err()).NotTo(HaveOccurred())
+		return lines
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		serializer = telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{}, logger)
+		dir = GinkgoT().TempDir()
+		ackChan = make(chan *telemetry.Ack, 10)
+		receivedAt = time.Date(2024, 5, 1, 13, 30, 0, 0, time.UTC)
+	})
+
+	It("writes gzipped NDJSON partitioned by record type, date and hour", func() {
+		producer := newProducer(&file.Config{})
+		producer.Produce(newRecord("1"))
+		producer.Produce(newRecord("2"))
+		Expect(producer.Close()).To(Succeed())
+
+		files := partitionFiles(receivedAt)
+		Expect(files).To(HaveLen(1))
+		Expect(files[0]).To(HaveSuffix(".ndjson.gz"))
+		lines := readLines(files[0])
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(HaveKeyWithValue("vin", "42"))
+		Expect(lines[0]).To(HaveKeyWithValue("txid", "1"))
+		Expect(lines[0]).To(HaveKeyWithValue("record_type", "V"))
+		Expect(lines[0]).To(HaveKeyWithValue("received_at", BeNumerically("==", receivedAt.UnixMilli())))
+		Expect(lines[0]).To(HaveKey("payload"))
+		Expect(lines[1]).To(HaveKeyWithValue("txid", "2"))
+	})
+
+	It("acks records once their file is synced", func() {
+		producer := newProducer(&file.Config{})
+		record := newRecord("1")
+		producer.Produce(record)
+		Consistently(ackChan).ShouldNot(Receive())
+
+		Expect(producer.Sync()).To(Succeed())
+		Expect(ackChan).To(Receive(Equal(&telemetry.Ack{Record: record, Dispatcher: telemetry.File})))
+
+		Expect(producer.Sync()).To(Succeed())
+		Consistently(ackChan).ShouldNot(Receive())
+	})
+
+	It("reports the delivery of records once synced", func() {
+		producer := newProducer(&file.Config{})
+		delivered := make(chan error, 1)
+		producer.SetDeliveryHandler(func(_ *telemetry.Record, err error) { delivered <- err })
+
+		producer.Produce(newRecord("1"))
+		Consistently(delivered).ShouldNot(Receive())
+		Expect(producer.Sync()).To(Succeed())
+		Expect(delivered).To(Receive(BeNil()))
+	})
+
+	It("writes uncompressed length-delimited protobuf", func() {
+		producer := newProducer(&file.Config{Format: file.FormatProtobuf, Compression: file.CompressionNone})
+		producer.Produce(newRecord("1"))
+		Expect(producer.Close()).To(Succeed())
+
+		files := partitionFiles(receivedAt)
+		Expect(files).To(HaveLen(1))
+		Expect(files[0]).To(HaveSuffix(".pb"))
+		body, err := os.ReadFile(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		message, n := protowire.ConsumeBytes(body)
+		Expect(n).To(Equal(len(body)))
+		payload := &protos.Payload{}
+		Expect(proto.Unmarshal(message, payload)).To(Succeed())
+		Expect(payload.GetVin()).To(Equal("42"))
+	})
+
+	It("starts a new file for each hour", func() {
+		producer := newProducer(&file.Config{})
+		producer.Produce(newRecord("1"))
+		first := receivedAt
+		receivedAt = receivedAt.Add(time.Hour)
+		producer.Produce(newRecord("2"))
+		Expect(ackChan).To(Receive())
+		Expect(producer.Close()).To(Succeed())
+
+		Expect(partitionFiles(first)).To(HaveLen(1))
+		Expect(partitionFiles(receivedAt)).To(HaveLen(1))
+	})
+
+	It("starts a new file past the max file size", func() {
+		producer := newProducer(&file.Config{Compression: file.CompressionNone, MaxFileBytes: 10})
+		producer.Produce(newRecord("1"))
+		producer.Produce(newRecord("2"))
+		producer.Produce(newRecord("3"))
+		Expect(producer.Close()).To(Succeed())
+
+		Expect(partitionFiles(receivedAt)).To(HaveLen(3))
+		Expect(ackChan).To(HaveLen(3))
+	})
+
+	It("rejects records once closed", func() {
+		producer := newProducer(&file.Config{})
+		Expect(producer.Close()).To(Succeed())
+
+		delivered := make(chan error, 1)
+		producer.SetDeliveryHandler(func(_ *telemetry.Record, err error) { delivered <- err })
+		producer.Produce(newRecord("1"))
+		Expect(delivered).To(Receive(MatchError("file producer closed")))
+	})
+
+	DescribeTable("rejects invalid configs",
+		func(config *file.Config, expectedErr string) {
+			_, err := file.NewProducer(config, telemetry.File, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, nil, logger)
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("without dir", &file.Config{}, "file dir is required"),
+		Entry("with an unknown format", &file.Config{Dir: os.TempDir(), Format: "csv"}, "unsupported file format: csv"),
+		Entry("with an unknown compression", &file.Config{Dir: os.TempDir(), Compression: "zstd"}, "unsupported file compression: zstd"),
+	)
+})
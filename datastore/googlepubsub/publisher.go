@@ -2,6 +2,7 @@ package googlepubsub
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -19,6 +20,7 @@ import (
 
 // Producer client to handle google pubsub interactions
 type Producer struct {
+	dispatcher         telemetry.Dispatcher
 	pubsubClient       *pubsub.Client
 	projectID          string
 	namespace          string
@@ -26,8 +28,10 @@ type Producer struct {
 	prometheusEnabled  bool
 	logger             *logrus.Logger
 	airbrakeHandler    *airbrake.Handler
-	ackChan            chan (*telemetry.Record)
+	ackChan            chan (*telemetry.Ack)
 	reliableAckTxTypes map[string]interface{}
+
+	telemetry.DeliveryNotifier
 }
 
 // Metrics stores metrics reported from this package
@@ -56,8 +60,30 @@ func configurePubsub(projectID string) (*pubsub.Client, error) {
 	return pubsub.NewClient(context.Background(), projectID)
 }
 
+// Config of the pubsub dispatcher
+type Config struct {
+	// GCP Project ID
+	ProjectID string `json:"gcp_project_id,omitempty"`
+}
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.Pubsub, newProducerFromConfig)
+}
+
+// newProducerFromConfig is the producer factory of the pubsub dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected Pubsub to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid pubsub config: %w", err)
+	}
+	return NewProducer(params.PrometheusEnabled, config.ProjectID, params.Dispatcher, params.Namespace, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
 // NewProducer establishes the pubsub connection and define the dispatch method
-func NewProducer(prometheusEnabled bool, projectID string, namespace string, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+func NewProducer(prometheusEnabled bool, projectID string, dispatcher telemetry.Dispatcher, namespace string, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
 	registerMetricsOnce(metricsCollector)
 	pubsubClient, err := configurePubsub(projectID)
 	if err != nil {
@@ -65,6 +91,7 @@ func NewProducer(prometheusEnabled bool, projectID string, namespace string, met
 	}
 
 	p := &Producer{
+		dispatcher:         dispatcher,
 		projectID:          projectID,
 		namespace:          namespace,
 		pubsubClient:       pubsubClient,
@@ -100,12 +127,14 @@ func (p *Producer) Produce(entry *telemetry.Record) {
 
 	if _, err := result.Get(ctx); err != nil {
 		p.ReportError("pubsub_err", err, logInfo)
-		metricsRegistry.errorCount.Inc(map[string]string{"record_type": entry.TxType})
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+		p.NotifyDelivery(entry, err)
 		return
 	}
 	p.ProcessReliableAck(entry)
-	metricsRegistry.publishBytesTotal.Add(int64(entry.Length()), map[string]string{"record_type": entry.TxType})
-	metricsRegistry.publishCount.Inc(map[string]string{"record_type": entry.TxType})
+	p.NotifyDelivery(entry, nil)
+	metricsRegistry.publishBytesTotal.Add(int64(entry.Length()), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	metricsRegistry.publishCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 
 }
 
@@ -118,8 +147,8 @@ func (p *Producer) Close() error {
 func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
 	_, ok := p.reliableAckTxTypes[entry.TxType]
 	if ok {
-		p.ackChan <- entry
-		metricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": entry.TxType})
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 	}
 }
 
@@ -137,30 +166,30 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 	metricsRegistry.notConnectedTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "pubsub_not_connected_total",
 		Help:   "The number of times pubsub has not been connected when attempting to produce.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.publishCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "pubsub_publish_total",
 		Help:   "The number of messages published to pubsub.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.publishBytesTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "pubsub_publish_total_bytes",
 		Help:   "The number of bytes published to pubsub.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "pubsub_err",
 		Help:   "The number of errors while publishing to pubsub.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "pubsub_reliable_ack_total",
 		Help:   "The number of records produced to pubsub for which we sent a reliable ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 }
@@ -0,0 +1,361 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// Proto messages map to avro records named after their full proto name, scalars to their avro counterpart,
+// enums to their value name and timestamps to timestamp-millis. Message, oneof and optional fields are nullable.
+//
+// Payload is flattened into a row holding one nullable column per protos.Field, named after the field.
+// A column is a union of the types a Value can hold, so a signal keeps the type the vehicle sent it with.
+
+const timestampFullName protoreflect.FullName = "google.protobuf.Timestamp"
+
+var (
+	payloadFullName       = (&protos.Payload{}).ProtoReflect().Descriptor().FullName()
+	payloadValueOneof     = (&protos.Value{}).ProtoReflect().Descriptor().Oneofs().ByName("value")
+	payloadColumnBranches = newPayloadColumnBranches()
+	avroNull              = json.RawMessage("null")
+)
+
+type avroRecord struct {
+	Type   string      `json:"type"`
+	Name   string      `json:"name"`
+	Fields []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// columnBranches are the branches of the union type of a payload column
+type columnBranches struct {
+	// types lists the branches, the first one being null
+	types []string
+	// messages maps the branches holding a message to its descriptor
+	messages map[string]protoreflect.MessageDescriptor
+	// byField maps the fields of the Value oneof to the index of their branch
+	byField map[protoreflect.FieldNumber]int64
+}
+
+func newPayloadColumnBranches() *columnBranches {
+	branches := &columnBranches{
+		types:    []string{"null"},
+		messages: make(map[string]protoreflect.MessageDescriptor),
+		byField:  make(map[protoreflect.FieldNumber]int64),
+	}
+	fields := payloadValueOneof.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		branch := payloadColumnBranch(fd)
+		if fd.Kind() == protoreflect.MessageKind {
+			branches.messages[branch] = fd.Message()
+		}
+		index := -1
+		for j, existing := range branches.types {
+			if existing == branch {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			index = len(branches.types)
+			branches.types = append(branches.types, branch)
+		}
+		branches.byField[fd.Number()] = int64(index)
+	}
+	return branches
+}
+
+// payloadColumnBranch returns the union branch of a Value field. Integers widen to long, floats to double
+// and enums are written as their value name. The invalid marker is written as null.
+func payloadColumnBranch(fd protoreflect.FieldDescriptor) string {
+	if fd.Name() == "invalid" {
+		return "null"
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return "boolean"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return "double"
+	case protoreflect.StringKind, protoreflect.EnumKind:
+		return "string"
+	case protoreflect.MessageKind:
+		return string(fd.Message().FullName())
+	default:
+		return "long"
+	}
+}
+
+// avroSchema returns the avro schema of a proto message
+func avroSchema(desc protoreflect.MessageDescriptor) (string, error) {
+	builder := &avroSchemaBuilder{defined: make(map[protoreflect.FullName]bool)}
+	schema, err := json.Marshal(builder.record(desc))
+	return string(schema), err
+}
+
+// avroSchemaBuilder defines each named type once, later uses refer to it by name
+type avroSchemaBuilder struct {
+	defined map[protoreflect.FullName]bool
+}
+
+func (b *avroSchemaBuilder) record(desc protoreflect.MessageDescriptor) interface{} {
+	if desc.FullName() == timestampFullName {
+		return map[string]string{"type": "long", "logicalType": "timestamp-millis"}
+	}
+	if b.defined[desc.FullName()] {
+		return string(desc.FullName())
+	}
+	b.defined[desc.FullName()] = true
+
+	record := avroRecord{Type: "record", Name: string(desc.FullName())}
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if desc.FullName() == payloadFullName && fd.IsList() {
+			record.Fields = append(record.Fields, b.payloadColumns()...)
+			continue
+		}
+		record.Fields = append(record.Fields, b.field(fd))
+	}
+	return record
+}
+
+func (b *avroSchemaBuilder) field(fd protoreflect.FieldDescriptor) avroField {
+	field := avroField{Name: string(fd.Name())}
+	switch {
+	case fd.IsMap():
+		field.Type = map[string]interface{}{"type": "map", "values": b.value(fd.MapValue())}
+	case fd.IsList():
+		field.Type = map[string]interface{}{"type": "array", "items": b.value(fd)}
+	case fd.HasPresence():
+		field.Type = []interface{}{"null", b.value(fd)}
+		field.Default = avroNull
+	default:
+		field.Type = b.value(fd)
+	}
+	return field
+}
+
+func (b *avroSchemaBuilder) value(fd protoreflect.FieldDescriptor) interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return "boolean"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return "int"
+	case protoreflect.FloatKind:
+		return "float"
+	case protoreflect.DoubleKind:
+		return "double"
+	case protoreflect.StringKind, protoreflect.EnumKind:
+		return "string"
+	case protoreflect.BytesKind:
+		return "bytes"
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.record(fd.Message())
+	default:
+		return "long"
+	}
+}
+
+// payloadColumns returns one nullable column per protos.Field, Unknown excepted
+func (b *avroSchemaBuilder) payloadColumns() []avroField {
+	values := protos.Field(0).Descriptor().Values()
+	columns := make([]avroField, 0, values.Len())
+	for i := 0; i < values.Len(); i++ {
+		value := values.Get(i)
+		if value.Number() == 0 {
+			continue
+		}
+		union := make([]interface{}, 0, len(payloadColumnBranches.types))
+		for _, branch := range payloadColumnBranches.types {
+			if desc, ok := payloadColumnBranches.messages[branch]; ok {
+				union = append(union, b.record(desc))
+				continue
+			}
+			union = append(union, branch)
+		}
+		columns = append(columns, avroField{Name: string(value.Name()), Type: union, Default: avroNull})
+	}
+	return columns
+}
+
+// appendAvroMessage appends the avro binary encoding of a message, following the schema returned by avroSchema
+func appendAvroMessage(buf []byte, message protoreflect.Message) []byte {
+	desc := message.Descriptor()
+	if desc.FullName() == timestampFullName {
+		ts, _ := message.Interface().(*timestamppb.Timestamp)
+		return appendAvroLong(buf, ts.AsTime().UnixMilli())
+	}
+
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case desc.FullName() == payloadFullName && fd.IsList():
+			buf = appendAvroPayloadColumns(buf, message.Get(fd).List())
+		case fd.IsMap():
+			buf = appendAvroMap(buf, fd, message.Get(fd).Map())
+		case fd.IsList():
+			list := message.Get(fd).List()
+			if list.Len() > 0 {
+				buf = appendAvroLong(buf, int64(list.Len()))
+				for j := 0; j < list.Len(); j++ {
+					buf = appendAvroValue(buf, fd, list.Get(j))
+				}
+			}
+			buf = appendAvroLong(buf, 0)
+		case fd.HasPresence():
+			if !message.Has(fd) {
+				buf = appendAvroLong(buf, 0)
+				continue
+			}
+			buf = appendAvroLong(buf, 1)
+			buf = appendAvroValue(buf, fd, message.Get(fd))
+		default:
+			buf = appendAvroValue(buf, fd, message.Get(fd))
+		}
+	}
+	return buf
+}
+
+// appendAvroMap writes the entries sorted by key, so equal messages encode to equal bytes
+func appendAvroMap(buf []byte, fd protoreflect.FieldDescriptor, entries protoreflect.Map) []byte {
+	if entries.Len() > 0 {
+		keys := make([]string, 0, entries.Len())
+		values := make(map[string]protoreflect.Value, entries.Len())
+		entries.Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			keys = append(keys, key.String())
+			values[key.String()] = value
+			return true
+		})
+		sort.Strings(keys)
+
+		buf = appendAvroLong(buf, int64(len(keys)))
+		for _, key := range keys {
+			buf = appendAvroString(buf, key)
+			buf = appendAvroValue(buf, fd.MapValue(), values[key])
+		}
+	}
+	return appendAvroLong(buf, 0)
+}
+
+func appendAvroValue(buf []byte, fd protoreflect.FieldDescriptor, value protoreflect.Value) []byte {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return appendAvroBool(buf, value.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return appendAvroLong(buf, value.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return appendAvroLong(buf, int64(value.Uint()))
+	case protoreflect.FloatKind:
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(value.Float())))
+	case protoreflect.DoubleKind:
+		return appendAvroDouble(buf, value.Float())
+	case protoreflect.StringKind:
+		return appendAvroString(buf, value.String())
+	case protoreflect.BytesKind:
+		buf = appendAvroLong(buf, int64(len(value.Bytes())))
+		return append(buf, value.Bytes()...)
+	case protoreflect.EnumKind:
+		return appendAvroString(buf, enumValueName(fd, value.Enum()))
+	default:
+		return appendAvroMessage(buf, value.Message())
+	}
+}
+
+// appendAvroPayloadColumns writes every column of the payload row, null for the fields the payload does not hold
+func appendAvroPayloadColumns(buf []byte, data protoreflect.List) []byte {
+	values := make(map[protoreflect.EnumNumber]protoreflect.Message, data.Len())
+	for i := 0; i < data.Len(); i++ {
+		datum, ok := data.Get(i).Message().Interface().(*protos.Datum)
+		if ok && datum.GetValue() != nil {
+			values[protoreflect.EnumNumber(datum.GetKey())] = datum.GetValue().ProtoReflect()
+		}
+	}
+
+	columns := protos.Field(0).Descriptor().Values()
+	for i := 0; i < columns.Len(); i++ {
+		number := columns.Get(i).Number()
+		if number == 0 {
+			continue
+		}
+		value, ok := values[number]
+		if !ok {
+			buf = appendAvroLong(buf, 0)
+			continue
+		}
+		buf = appendAvroColumn(buf, value)
+	}
+	return buf
+}
+
+func appendAvroColumn(buf []byte, value protoreflect.Message) []byte {
+	fd := value.WhichOneof(payloadValueOneof)
+	if fd == nil {
+		return appendAvroLong(buf, 0)
+	}
+	index := payloadColumnBranches.byField[fd.Number()]
+	buf = appendAvroLong(buf, index)
+
+	field := value.Get(fd)
+	switch payloadColumnBranches.types[index] {
+	case "null":
+		return buf
+	case "long":
+		return appendAvroLong(buf, field.Int())
+	case "double":
+		return appendAvroDouble(buf, field.Float())
+	case "boolean":
+		return appendAvroBool(buf, field.Bool())
+	case "string":
+		if fd.Kind() == protoreflect.EnumKind {
+			return appendAvroString(buf, enumValueName(fd, field.Enum()))
+		}
+		return appendAvroString(buf, field.String())
+	default:
+		return appendAvroMessage(buf, field.Message())
+	}
+}
+
+// enumValueName returns the name of an enum value, its number if the value is unknown to this build
+func enumValueName(fd protoreflect.FieldDescriptor, number protoreflect.EnumNumber) string {
+	if value := fd.Enum().Values().ByNumber(number); value != nil {
+		return string(value.Name())
+	}
+	return strconv.Itoa(int(number))
+}
+
+func appendAvroLong(buf []byte, value int64) []byte {
+	// avro longs are zig-zag encoded varints, like binary.AppendVarint
+	return binary.AppendVarint(buf, value)
+}
+
+func appendAvroDouble(buf []byte, value float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(value))
+}
+
+func appendAvroBool(buf []byte, value bool) []byte {
+	if value {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+func appendAvroString(buf []byte, value string) []byte {
+	buf = appendAvroLong(buf, int64(len(value)))
+	return append(buf, value...)
+}
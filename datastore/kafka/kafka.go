@@ -1,6 +1,8 @@
 package kafka
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -16,15 +18,19 @@ import (
 
 // Producer client to handle kafka interactions
 type Producer struct {
+	dispatcher         telemetry.Dispatcher
 	kafkaProducer      *kafka.Producer
+	serializer         *Serializer
 	namespace          string
 	prometheusEnabled  bool
 	metricsCollector   metrics.MetricCollector
 	logger             *logrus.Logger
 	airbrakeHandler    *airbrake.Handler
 	deliveryChan       chan kafka.Event
-	ackChan            chan (*telemetry.Record)
+	ackChan            chan (*telemetry.Ack)
 	reliableAckTxTypes map[string]interface{}
+
+	telemetry.DeliveryNotifier
 }
 
 // Metrics stores metrics reported from this package
@@ -43,17 +49,70 @@ var (
 	metricsOnce     sync.Once
 )
 
-// NewProducer establishes the kafka connection and define the dispatch method
-func NewProducer(config *kafka.ConfigMap, namespace string, prometheusEnabled bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+// Config of the kafka dispatcher, as given to its producer factory
+type Config struct {
+	// Producer holds the standard librdkafka configuration properties, the "topic" key being the default topic
+	Producer *kafka.ConfigMap `json:"producer"`
+
+	// Serializer encodes the records in the Confluent wire format, records are produced as is when not set
+	Serializer *SerializerConfig `json:"serializer,omitempty"`
+}
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.Kafka, newProducerFromConfig, telemetry.IgnoresRecordTypesUnless(usesSerializer))
+}
+
+// usesSerializer returns whether the records are serialized, the producer then registers the schemas of its record
+// types
+func usesSerializer(rawConfig json.RawMessage) bool {
+	config := &Config{}
+	return len(rawConfig) > 0 && json.Unmarshal(rawConfig, config) == nil && config.Serializer != nil
+}
+
+// newProducerFromConfig is the producer factory of the kafka dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	config := &Config{}
+	if len(rawConfig) > 0 {
+		if err := json.Unmarshal(rawConfig, config); err != nil {
+			return nil, fmt.Errorf("invalid kafka config: %w", err)
+		}
+	}
+	if config.Producer == nil {
+		return nil, errors.New("expected Kafka to be configured")
+	}
+	ConvertConfigMap(config.Producer)
+	return NewProducer(config.Producer, params.Dispatcher, config.Serializer, params.Namespace, params.RecordTypes, params.PrometheusEnabled, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
+// ConvertConfigMap will prioritize int over float, since numbers decoded from JSON are floats
+// see: https://github.com/confluentinc/confluent-kafka-go/blob/cde2827bc49655eca0f9ce3fc1cda13cb6cdabc9/kafka/config.go#L108-L125
+func ConvertConfigMap(input *kafka.ConfigMap) {
+	for key, val := range *input {
+		if i, ok := val.(float64); ok {
+			(*input)[key] = int(i)
+		}
+	}
+}
+
+// NewProducer registers the schemas of the record types when records are serialized, establishes the kafka connection
+// and define the dispatch method
+func NewProducer(config *kafka.ConfigMap, dispatcher telemetry.Dispatcher, serializerConfig *SerializerConfig, namespace string, recordTypes []string, prometheusEnabled bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
 	registerMetricsOnce(metricsCollector)
 
+	serializer, err := newConfiguredSerializer(serializerConfig, namespace, recordTypes)
+	if err != nil {
+		return nil, err
+	}
+
 	kafkaProducer, err := kafka.NewProducer(config)
 	if err != nil {
 		return nil, err
 	}
 
 	producer := &Producer{
+		dispatcher:         dispatcher,
 		kafkaProducer:      kafkaProducer,
+		serializer:         serializer,
 		namespace:          namespace,
 		metricsCollector:   metricsCollector,
 		prometheusEnabled:  prometheusEnabled,
@@ -66,17 +125,31 @@ func NewProducer(config *kafka.ConfigMap, namespace string, prometheusEnabled bo
 
 	go producer.handleProducerEvents()
 	go producer.reportProducerMetrics()
-	producer.logger.ActivityLog("kafka_registered", logrus.LogInfo{"namespace": namespace})
+	logInfo := logrus.LogInfo{"namespace": namespace}
+	if serializerConfig != nil {
+		logInfo["format"] = serializerConfig.Format
+	}
+	producer.logger.ActivityLog("kafka_registered", logInfo)
 	return producer, nil
 }
 
-// Produce asynchronously sends the record payload to kafka
+// Produce asynchronously sends the record payload to kafka, encoded by the serializer if one is configured
 func (p *Producer) Produce(entry *telemetry.Record) {
 	topic := telemetry.BuildTopicName(p.namespace, entry.TxType)
 
+	value := entry.Payload()
+	if p.serializer != nil {
+		var err error
+		if value, err = p.serializer.Serialize(topic, entry); err != nil {
+			p.logError(err)
+			p.NotifyDelivery(entry, err)
+			return
+		}
+	}
+
 	msg := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-		Value:          entry.Payload(),
+		Value:          value,
 		Key:            []byte(entry.Vin),
 		Headers:        headersFromRecord(entry),
 		Timestamp:      time.Now(),
@@ -88,10 +161,11 @@ func (p *Producer) Produce(entry *telemetry.Record) {
 	entry.ProduceTime = time.Now()
 	if err := p.kafkaProducer.Produce(msg, p.deliveryChan); err != nil {
 		p.logError(err)
+		p.NotifyDelivery(entry, err)
 		return
 	}
-	metricsRegistry.producerCount.Inc(map[string]string{"record_type": entry.TxType})
-	metricsRegistry.bytesTotal.Add(int64(entry.Length()), map[string]string{"record_type": entry.TxType})
+	metricsRegistry.producerCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	metricsRegistry.bytesTotal.Add(int64(entry.Length()), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 }
 
 // ReportError to airbrake and logger
@@ -116,18 +190,22 @@ func (p *Producer) handleProducerEvents() {
 		case kafka.Error:
 			p.logError(fmt.Errorf("producer_error %v", ev))
 		case *kafka.Message:
+			entry, ok := ev.Opaque.(*telemetry.Record)
 			if ev.TopicPartition.Error != nil {
 				p.logError(fmt.Errorf("topic_partition_error %v", ev))
+				if ok {
+					p.NotifyDelivery(entry, ev.TopicPartition.Error)
+				}
 				continue
 			}
-			entry, ok := ev.Opaque.(*telemetry.Record)
 			if !ok {
 				p.logError(fmt.Errorf("opaque_record_missing %v", ev))
 				continue
 			}
 			p.ProcessReliableAck(entry)
-			metricsRegistry.producerAckCount.Inc(map[string]string{"record_type": entry.TxType})
-			metricsRegistry.bytesAckTotal.Add(int64(entry.Length()), map[string]string{"record_type": entry.TxType})
+			p.NotifyDelivery(entry, nil)
+			metricsRegistry.producerAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+			metricsRegistry.bytesAckTotal.Add(int64(entry.Length()), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 		default:
 			p.logger.ActivityLog("kafka_event_ignored", logrus.LogInfo{"event": ev.String()})
 		}
@@ -144,14 +222,14 @@ func (p *Producer) Close() error {
 func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
 	_, ok := p.reliableAckTxTypes[entry.TxType]
 	if ok {
-		p.ackChan <- entry
-		metricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": entry.TxType})
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 	}
 }
 
 func (p *Producer) logError(err error) {
 	p.ReportError("kafka_err", err, nil)
-	metricsRegistry.errorCount.Inc(map[string]string{})
+	metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
 }
 
 func (p *Producer) reportProducerMetrics() {
@@ -160,9 +238,9 @@ func (p *Producer) reportProducerMetrics() {
 	for range t.C {
 		total := p.kafkaProducer.Len()
 		eventsCount := len(p.kafkaProducer.Events())
-		metricsRegistry.producerQueueSize.Set(int64(total), map[string]string{"type": "total"})
-		metricsRegistry.producerQueueSize.Set(int64(eventsCount), map[string]string{"type": "events"})
-		metricsRegistry.producerQueueSize.Set(int64(total-eventsCount), map[string]string{"type": "buffer"})
+		metricsRegistry.producerQueueSize.Set(int64(total), map[string]string{"dispatcher": string(p.dispatcher), "type": "total"})
+		metricsRegistry.producerQueueSize.Set(int64(eventsCount), map[string]string{"dispatcher": string(p.dispatcher), "type": "events"})
+		metricsRegistry.producerQueueSize.Set(int64(total-eventsCount), map[string]string{"dispatcher": string(p.dispatcher), "type": "buffer"})
 	}
 }
 
@@ -174,42 +252,42 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 	metricsRegistry.producerCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kafka_produce_total",
 		Help:   "The number of records produced to Kafka.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.bytesTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kafka_produce_total_bytes",
 		Help:   "The number of bytes produced to Kafka.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.producerAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kafka_produce_ack_total",
 		Help:   "The number of records produced to Kafka for which we got an ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kafka_reliable_ack_total",
 		Help:   "The number of records produced to Kafka for which we sent a reliable ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.bytesAckTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kafka_produce_ack_total_bytes",
 		Help:   "The number of bytes produced to Kafka for which we got an ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kafka_err",
 		Help:   "The number of errors while producing to Kafka.",
-		Labels: []string{},
+		Labels: []string{"dispatcher"},
 	})
 
 	metricsRegistry.producerQueueSize = metricsCollector.RegisterGauge(adapter.CollectorOptions{
 		Name:   "kafka_produce_queue_size",
 		Help:   "Total pending messages to produce",
-		Labels: []string{"type"},
+		Labels: []string{"dispatcher", "type"},
 	})
 }
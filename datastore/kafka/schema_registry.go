@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSchemaRegistryTimeoutMs is the default timeout of a schema registry request
+	DefaultSchemaRegistryTimeoutMs = 10000
+
+	// SchemaTypeAvro registers Avro schemas
+	SchemaTypeAvro = "AVRO"
+	// SchemaTypeProtobuf registers proto definitions
+	SchemaTypeProtobuf = "PROTOBUF"
+
+	schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+	schemaRegistryPasswordEnv = "SCHEMA_REGISTRY_PASSWORD"
+)
+
+// SchemaRegistryConfig locates a Confluent compatible schema registry
+type SchemaRegistryConfig struct {
+	// URL of the schema registry, ex.: http://schema-registry:8081
+	URL string `json:"url"`
+
+	// Username for basic authentication, optional
+	Username string `json:"username,omitempty"`
+
+	// Password for basic authentication, the SCHEMA_REGISTRY_PASSWORD env variable takes precedence
+	Password string `json:"password,omitempty"`
+
+	// TimeoutMs is the timeout of a single request. Default: 10000
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+}
+
+// SchemaRegistry registers the schemas of the records produced to kafka
+type SchemaRegistry interface {
+	// Register adds a schema to a subject and returns its id. Registering a schema the subject
+	// already has returns the existing id.
+	Register(subject string, schemaType string, schema string) (int, error)
+}
+
+// schemaRegistryClient talks to the REST API of a Confluent compatible schema registry
+type schemaRegistryClient struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewSchemaRegistryClient returns a client of the schema registry API
+func NewSchemaRegistryClient(config *SchemaRegistryConfig) (SchemaRegistry, error) {
+	if config == nil || config.URL == "" {
+		return nil, errors.New("schema registry url is required")
+	}
+	if _, err := url.ParseRequestURI(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid schema registry url %s: %w", config.URL, err)
+	}
+
+	timeoutMs := config.TimeoutMs
+	if timeoutMs <= 0 {
+		timeoutMs = DefaultSchemaRegistryTimeoutMs
+	}
+	password := config.Password
+	if env := os.Getenv(schemaRegistryPasswordEnv); env != "" {
+		password = env
+	}
+
+	return &schemaRegistryClient{
+		url:      strings.TrimSuffix(config.URL, "/"),
+		username: config.Username,
+		password: password,
+		client:   &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond},
+	}, nil
+}
+
+// Register posts the schema to the versions of the subject
+func (c *schemaRegistryClient) Register(subject string, schemaType string, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schemaType": schemaType, "schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/subjects/%s/versions", c.url, url.PathEscape(subject)), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", schemaRegistryContentType)
+	req.Header.Set("Accept", schemaRegistryContentType)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var registryErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &registryErr)
+		return 0, fmt.Errorf("schema registry responded %d to the registration of %s: %s", resp.StatusCode, subject, registryErr.Message)
+	}
+
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &registered); err != nil {
+		return 0, fmt.Errorf("invalid schema registry response for %s: %w", subject, err)
+	}
+	return registered.ID, nil
+}
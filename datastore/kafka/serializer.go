@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// FormatAvro encodes records as avro, Payload being flattened into one nullable column per field
+	FormatAvro = "avro"
+	// FormatProtobuf encodes records as their proto message
+	FormatProtobuf = "protobuf"
+
+	// magicByte starts every message in the Confluent wire format, followed by the schema id
+	magicByte byte = 0
+)
+
+// SerializerConfig encodes the records produced to kafka in the Confluent wire format
+type SerializerConfig struct {
+	// Format of the messages, "avro" or "protobuf"
+	Format string `json:"format"`
+
+	// SchemaRegistry registers the schemas of the records
+	SchemaRegistry *SchemaRegistryConfig `json:"schema_registry"`
+}
+
+// Serializer encodes records in the Confluent wire format: a magic byte, the big endian schema id and,
+// for protobuf, the index of the message in its proto file, followed by the encoded record.
+// Schemas are registered under the "<topic>-value" subject by Register, before the first record is serialized, so
+// serializing never waits on the schema registry.
+type Serializer struct {
+	format   string
+	registry SchemaRegistry
+
+	schemas map[string]*registeredSchema
+}
+
+// registeredSchema is the prefix of the messages of a subject
+type registeredSchema struct {
+	header []byte
+}
+
+// NewSerializer returns a serializer registering its schemas with the registry
+func NewSerializer(format string, registry SchemaRegistry) (*Serializer, error) {
+	if format != FormatAvro && format != FormatProtobuf {
+		return nil, fmt.Errorf("unknown kafka serializer format: %s", format)
+	}
+	return &Serializer{
+		format:   format,
+		registry: registry,
+		schemas:  make(map[string]*registeredSchema),
+	}, nil
+}
+
+// newConfiguredSerializer returns the serializer of the config with the schemas of the record types produced to the
+// topics of the namespace registered, nil if records are produced as is
+func newConfiguredSerializer(config *SerializerConfig, namespace string, recordTypes []string) (*Serializer, error) {
+	if config == nil {
+		return nil, nil
+	}
+	registry, err := NewSchemaRegistryClient(config.SchemaRegistry)
+	if err != nil {
+		return nil, err
+	}
+	serializer, err := NewSerializer(config.Format, registry)
+	if err != nil {
+		return nil, err
+	}
+	for _, recordType := range recordTypes {
+		if err := serializer.Register(telemetry.BuildTopicName(namespace, recordType), recordType); err != nil {
+			return nil, err
+		}
+	}
+	return serializer, nil
+}
+
+// Register registers the schema of the records of a type produced to a topic. It must be called for every topic
+// before the serializer is used.
+func (s *Serializer) Register(topic string, recordType string) error {
+	message := telemetry.NewProtoMessage(recordType)
+	if message == nil {
+		return fmt.Errorf("record type %s has no schema", recordType)
+	}
+	desc := message.ProtoReflect().Descriptor()
+	schemaType, definition, err := s.definition(desc)
+	if err != nil {
+		return err
+	}
+	subject := topic + "-value"
+	id, err := s.registry.Register(subject, schemaType, definition)
+	if err != nil {
+		return err
+	}
+
+	header := binary.BigEndian.AppendUint32([]byte{magicByte}, uint32(id))
+	if s.format == FormatProtobuf {
+		header = appendMessageIndexes(header, desc)
+	}
+	s.schemas[subject] = &registeredSchema{header: header}
+	return nil
+}
+
+// Serialize encodes the record produced to a topic
+func (s *Serializer) Serialize(topic string, record *telemetry.Record) ([]byte, error) {
+	message := record.GetProtoMessage()
+	if message == nil {
+		return nil, fmt.Errorf("%w: record type %s has no schema", telemetry.ErrRecordRejected, record.TxType)
+	}
+	schema, ok := s.schemas[topic+"-value"]
+	if !ok {
+		return nil, fmt.Errorf("%w: no schema registered for topic %s", telemetry.ErrRecordRejected, topic)
+	}
+
+	buf := append([]byte{}, schema.header...)
+	if s.format == FormatAvro {
+		return appendAvroMessage(buf, message.ProtoReflect()), nil
+	}
+	return proto.MarshalOptions{}.MarshalAppend(buf, message)
+}
+
+// definition returns the schema type and the schema registered for a message
+func (s *Serializer) definition(desc protoreflect.MessageDescriptor) (string, string, error) {
+	if s.format == FormatAvro {
+		schema, err := avroSchema(desc)
+		return SchemaTypeAvro, schema, err
+	}
+	definition, err := protos.Schemas.ReadFile(path.Base(desc.ParentFile().Path()))
+	if err != nil {
+		return "", "", fmt.Errorf("no proto definition for %s: %w", desc.FullName(), err)
+	}
+	return SchemaTypeProtobuf, string(definition), nil
+}
+
+// appendMessageIndexes appends the path of the message in its proto file, as zig-zag varints prefixed by
+// the path length. The first message of a file, the most common case, is written as a single 0.
+func appendMessageIndexes(buf []byte, desc protoreflect.MessageDescriptor) []byte {
+	var indexes []int64
+	for d := protoreflect.Descriptor(desc); d != nil; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		indexes = append([]int64{int64(d.Index())}, indexes...)
+	}
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, int64(len(indexes)))
+	for _, index := range indexes {
+		buf = binary.AppendVarint(buf, index)
+	}
+	return buf
+}
@@ -0,0 +1,277 @@
+package kafka_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/kafka"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+type registration struct {
+	subject    string
+	schemaType string
+	schema     string
+}
+
+// avroReader decodes the avro primitives of a message
+type avroReader struct {
+	buf []byte
+}
+
+func (r *avroReader) long() int64 {
+	value, n := binary.Varint(r.buf)
+	Expect(n).To(BeNumerically(">", 0))
+	r.buf = r.buf[n:]
+	return value
+}
+
+func (r *avroReader) text() string {
+	length := r.long()
+	value := string(r.buf[:length])
+	r.buf = r.buf[length:]
+	return value
+}
+
+func (r *avroReader) double() float64 {
+	value := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return value
+}
+
+var _ = Describe("Kafka serializer", func() {
+	var (
+		logger        *logrus.Logger
+		serializer    *telemetry.BinarySerializer
+		registry      *httptest.Server
+		mu            sync.Mutex
+		registrations []registration
+		registryDown  bool
+	)
+
+	newRecord := func(txType string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1"), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newSerializer := func(format string) *kafka.Serializer {
+		client, err := kafka.NewSchemaRegistryClient(&kafka.SchemaRegistryConfig{URL: registry.URL, Username: "user", Password: "secret"})
+		Expect(err).NotTo(HaveOccurred())
+		s, err := kafka.NewSerializer(format, client)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	registeredSerializer := func(format string, recordTypes ...string) *kafka.Serializer {
+		s := newSerializer(format)
+		for _, recordType := range recordTypes {
+			Expect(s.Register("tesla_"+recordType, recordType)).To(Succeed())
+		}
+		return s
+	}
+
+	registered := func() []registration {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]registration{}, registrations...)
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		serializer = telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{}, logger)
+		registrations = nil
+		registryDown = false
+		registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if registryDown {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"error_code": 50001, "message": "store unavailable"}`))
+				return
+			}
+			var body map[string]string
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			Expect(r.Method).To(Equal(http.MethodPost))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/vnd.schemaregistry.v1+json"))
+			subject := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/versions")
+			registrations = append(registrations, registration{subject: subject, schemaType: body["schemaType"], schema: body["schema"]})
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		}))
+	})
+
+	AfterEach(func() {
+		registry.Close()
+	})
+
+	It("rejects unknown formats", func() {
+		_, err := kafka.NewSerializer("json", nil)
+		Expect(err).To(MatchError("unknown kafka serializer format: json"))
+	})
+
+	It("encodes a payload as an avro row with one column per field", func() {
+		createdAt := time.UnixMilli(1700000000123)
+		record := newRecord("V", &protos.Payload{
+			CreatedAt: timestamppb.New(createdAt),
+			Data: []*protos.Datum{
+				{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 42.5}}},
+				{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: protos.ShiftState_ShiftStateD}}},
+				{Key: protos.Field_Location, Value: &protos.Value{Value: &protos.Value_LocationValue{LocationValue: &protos.LocationValue{Latitude: 37.5, Longitude: -122.25}}}},
+				{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_Invalid{Invalid: true}}},
+			},
+		})
+
+		value, err := registeredSerializer(kafka.FormatAvro, "V").Serialize("tesla_V", record)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(registered()).To(HaveLen(1))
+		Expect(registered()[0].subject).To(Equal("tesla_V-value"))
+		Expect(registered()[0].schemaType).To(Equal(kafka.SchemaTypeAvro))
+		var schema struct {
+			Name   string `json:"name"`
+			Fields []struct {
+				Name    string          `json:"name"`
+				Type    json.RawMessage `json:"type"`
+				Default json.RawMessage `json:"default"`
+			} `json:"fields"`
+		}
+		Expect(json.Unmarshal([]byte(registered()[0].schema), &schema)).To(Succeed())
+		Expect(schema.Name).To(Equal("telemetry.vehicle_data.Payload"))
+		fieldValues := protos.Field(0).Descriptor().Values()
+		Expect(schema.Fields).To(HaveLen(fieldValues.Len() - 1 + 3))
+		Expect(schema.Fields[0].Name).To(Equal(string(fieldValues.Get(1).Name())))
+		Expect(string(schema.Fields[0].Default)).To(Equal("null"))
+		Expect(string(schema.Fields[1].Type)).To(Equal(`["null","string","long","double","boolean","telemetry.vehicle_data.LocationValue","telemetry.vehicle_data.Doors","telemetry.vehicle_data.Time","telemetry.vehicle_data.TireLocation"]`))
+
+		Expect(value[:5]).To(Equal([]byte{0, 0, 0, 0, 7}))
+		reader := &avroReader{buf: value[5:]}
+		for i := 0; i < fieldValues.Len(); i++ {
+			field := protos.Field(fieldValues.Get(i).Number())
+			switch field {
+			case protos.Field_Unknown:
+				continue
+			case protos.Field_VehicleSpeed:
+				Expect(reader.long()).To(BeEquivalentTo(3))
+				Expect(reader.double()).To(Equal(42.5))
+			case protos.Field_Gear:
+				Expect(reader.long()).To(BeEquivalentTo(1))
+				Expect(reader.text()).To(Equal("ShiftStateD"))
+			case protos.Field_Location:
+				Expect(reader.long()).To(BeEquivalentTo(5))
+				Expect(reader.double()).To(Equal(37.5))
+				Expect(reader.double()).To(Equal(-122.25))
+			default:
+				Expect(reader.long()).To(BeEquivalentTo(0), field.String())
+			}
+		}
+		Expect(reader.long()).To(BeEquivalentTo(1))
+		Expect(reader.long()).To(Equal(createdAt.UnixMilli()))
+		Expect(reader.text()).To(Equal("42"))
+		Expect(reader.long()).To(BeEquivalentTo(0))
+		Expect(reader.buf).To(BeEmpty())
+	})
+
+	It("encodes alerts as an avro record", func() {
+		record := newRecord("alerts", &protos.VehicleAlerts{
+			Alerts: []*protos.VehicleAlert{{Name: "alert1", Audiences: []protos.Audience{protos.Audience_Customer}}},
+		})
+
+		value, err := registeredSerializer(kafka.FormatAvro, "alerts").Serialize("tesla_alerts", record)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registered()[0].schema).To(ContainSubstring(`"name":"telemetry.vehicle_alerts.VehicleAlert"`))
+
+		reader := &avroReader{buf: value[5:]}
+		Expect(reader.long()).To(BeEquivalentTo(1))
+		Expect(reader.text()).To(Equal("alert1"))
+		Expect(reader.long()).To(BeEquivalentTo(1))
+		Expect(reader.text()).To(Equal("Customer"))
+		Expect(reader.long()).To(BeEquivalentTo(0))
+		Expect(reader.long()).To(BeEquivalentTo(0))
+		Expect(reader.long()).To(BeEquivalentTo(0))
+		Expect(reader.long()).To(BeEquivalentTo(0))
+		Expect(reader.long()).To(BeEquivalentTo(0))
+		Expect(reader.text()).To(Equal("42"))
+		Expect(reader.buf).To(BeEmpty())
+	})
+
+	It("encodes protobuf with the index of the message", func() {
+		s := registeredSerializer(kafka.FormatProtobuf, "alerts", "V")
+
+		alerts, err := s.Serialize("tesla_alerts", newRecord("alerts", &protos.VehicleAlerts{Alerts: []*protos.VehicleAlert{{Name: "alert1"}}}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(alerts[:6]).To(Equal([]byte{0, 0, 0, 0, 7, 0}))
+		decodedAlerts := &protos.VehicleAlerts{}
+		Expect(proto.Unmarshal(alerts[6:], decodedAlerts)).To(Succeed())
+		Expect(decodedAlerts.GetAlerts()[0].GetName()).To(Equal("alert1"))
+
+		payload, err := s.Serialize("tesla_V", newRecord("V", &protos.Payload{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(payload[:7]).To(Equal([]byte{0, 0, 0, 0, 7, 2, 12}))
+		decodedPayload := &protos.Payload{}
+		Expect(proto.Unmarshal(payload[7:], decodedPayload)).To(Succeed())
+		Expect(decodedPayload.GetVin()).To(Equal("42"))
+
+		Expect(registered()).To(HaveLen(2))
+		Expect(registered()[0].schemaType).To(Equal(kafka.SchemaTypeProtobuf))
+		Expect(registered()[0].schema).To(ContainSubstring("message VehicleAlerts {"))
+		Expect(registered()[1].schema).To(ContainSubstring("message Payload {"))
+	})
+
+	It("serializes without registering again", func() {
+		s := registeredSerializer(kafka.FormatAvro, "connectivity")
+		for i := 0; i < 3; i++ {
+			_, err := s.Serialize("tesla_connectivity", newRecord("connectivity", &protos.VehicleConnectivity{Status: protos.ConnectivityEvent_CONNECTED}))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(registered()).To(HaveLen(1))
+	})
+
+	It("fails to register when the registry is down", func() {
+		mu.Lock()
+		registryDown = true
+		mu.Unlock()
+		Expect(newSerializer(kafka.FormatAvro).Register("tesla_errors", "errors")).To(MatchError("schema registry responded 500 to the registration of tesla_errors-value: store unavailable"))
+	})
+
+	It("rejects the record types without a schema", func() {
+		Expect(newSerializer(kafka.FormatAvro).Register("tesla_unknown", "unknown")).To(MatchError("record type unknown has no schema"))
+		Expect(registered()).To(BeEmpty())
+	})
+
+	It("rejects records without a schema", func() {
+		_, err := newSerializer(kafka.FormatAvro).Serialize("tesla_unknown", &telemetry.Record{TxType: "unknown"})
+		Expect(err).To(MatchError(telemetry.ErrRecordRejected))
+		Expect(registered()).To(BeEmpty())
+	})
+
+	It("rejects records of a topic without registered schema", func() {
+		s := registeredSerializer(kafka.FormatAvro, "alerts")
+		_, err := s.Serialize("tesla_errors", newRecord("errors", &protos.VehicleErrors{}))
+		Expect(err).To(MatchError(telemetry.ErrRecordRejected))
+		Expect(registered()).To(HaveLen(1))
+	})
+})
@@ -1,7 +1,11 @@
 package kinesis
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,14 +22,17 @@ import (
 
 // Producer client to handle kinesis interactions
 type Producer struct {
+	dispatcher         telemetry.Dispatcher
 	kinesis            *kinesis.Kinesis
 	logger             *logrus.Logger
 	prometheusEnabled  bool
 	metricsCollector   metrics.MetricCollector
 	streams            map[string]string
 	airbrakeHandler    *airbrake.Handler
-	ackChan            chan (*telemetry.Record)
+	ackChan            chan (*telemetry.Ack)
 	reliableAckTxTypes map[string]interface{}
+
+	telemetry.DeliveryNotifier
 }
 
 // Metrics stores metrics reported from this package
@@ -41,8 +48,52 @@ var (
 	metricsOnce     sync.Once
 )
 
+// Config of the kinesis dispatcher
+type Config struct {
+	MaxRetries   *int              `json:"max_retries,omitempty"`
+	OverrideHost string            `json:"override_host"`
+	Streams      map[string]string `json:"streams,omitempty"`
+}
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.Kinesis, newProducerFromConfig)
+}
+
+// newProducerFromConfig is the producer factory of the kinesis dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected Kinesis to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid kinesis config: %w", err)
+	}
+	maxRetries := 1
+	if config.MaxRetries != nil {
+		maxRetries = *config.MaxRetries
+	}
+	return NewProducer(maxRetries, StreamMapping(config.Streams, params.Namespace, params.RecordTypes), config.OverrideHost, params.Dispatcher, params.PrometheusEnabled, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
+// StreamMapping uses the configured streams, overrides with ENV variable names, and finally falls back to namespace
+// based names
+func StreamMapping(streams map[string]string, namespace string, recordNames []string) map[string]string {
+	streamMapping := make(map[string]string)
+	for _, recordName := range recordNames {
+		streamMapping[recordName] = streams[recordName]
+		envVarStreamName := os.Getenv(fmt.Sprintf("KINESIS_STREAM_%s", strings.ToUpper(recordName)))
+		if envVarStreamName != "" {
+			streamMapping[recordName] = envVarStreamName
+		}
+		if streamMapping[recordName] == "" {
+			streamMapping[recordName] = telemetry.BuildTopicName(namespace, recordName)
+		}
+	}
+	return streamMapping
+}
+
 // NewProducer configures and tests the kinesis connection
-func NewProducer(maxRetries int, streams map[string]string, overrideHost string, prometheusEnabled bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+func NewProducer(maxRetries int, streams map[string]string, overrideHost string, dispatcher telemetry.Dispatcher, prometheusEnabled bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
 	registerMetricsOnce(metricsCollector)
 
 	config := &aws.Config{
@@ -66,6 +117,7 @@ func NewProducer(maxRetries int, streams map[string]string, overrideHost string,
 	}
 
 	return &Producer{
+		dispatcher:         dispatcher,
 		kinesis:            service,
 		logger:             logger,
 		prometheusEnabled:  prometheusEnabled,
@@ -83,6 +135,7 @@ func (p *Producer) Produce(entry *telemetry.Record) {
 	stream, ok := p.streams[entry.TxType]
 	if !ok {
 		p.ReportError("kinesis_produce_stream_not_configured", nil, logrus.LogInfo{"record_type": entry.TxType})
+		p.NotifyDelivery(entry, telemetry.ErrRecordRejected)
 		return
 	}
 	kinesisRecord := &kinesis.PutRecordInput{
@@ -94,13 +147,15 @@ func (p *Producer) Produce(entry *telemetry.Record) {
 	kinesisRecordOutput, err := p.kinesis.PutRecord(kinesisRecord)
 	if err != nil {
 		p.ReportError("kinesis_err", err, nil)
-		metricsRegistry.errorCount.Inc(map[string]string{"record_type": entry.TxType})
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+		p.NotifyDelivery(entry, err)
 		return
 	}
 	p.ProcessReliableAck(entry)
+	p.NotifyDelivery(entry, nil)
 	p.logger.Log(logrus.DEBUG, "kinesis_message_dispatched", logrus.LogInfo{"vin": entry.Vin, "record_type": entry.TxType, "txid": entry.Txid, "shard_id": *kinesisRecordOutput.ShardId, "sequence_number": *kinesisRecordOutput.SequenceNumber})
-	metricsRegistry.publishCount.Inc(map[string]string{"record_type": entry.TxType})
-	metricsRegistry.byteTotal.Add(int64(entry.Length()), map[string]string{"record_type": entry.TxType})
+	metricsRegistry.publishCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	metricsRegistry.byteTotal.Add(int64(entry.Length()), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 }
 
 // Close the producer
@@ -112,8 +167,8 @@ func (p *Producer) Close() error {
 func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
 	_, ok := p.reliableAckTxTypes[entry.TxType]
 	if ok {
-		p.ackChan <- entry
-		metricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": entry.TxType})
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 	}
 }
 
@@ -131,24 +186,24 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kinesis_err",
 		Help:   "The number of errors while producing to Kinesis.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.publishCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kinesis_publish_total",
 		Help:   "The number of messages published to Kinesis.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.byteTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kinesis_publish_total_bytes",
 		Help:   "The number of bytes published to Kinesis.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "kinesis_reliable_ack_total",
 		Help:   "The number of records produced to Kinesis for which we sent a reliable ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 }
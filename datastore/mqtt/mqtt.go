@@ -2,6 +2,8 @@ package mqtt
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -21,14 +23,17 @@ var (
 
 // Producer is a telemetry.Producer that sends records to an MQTT broker.
 type Producer struct {
+	dispatcher         telemetry.Dispatcher
 	client             pahomqtt.Client
 	config             *Config
 	logger             *logrus.Logger
 	airbrakeHandler    *airbrake.Handler
 	namespace          string
 	ctx                context.Context
-	ackChan            chan (*telemetry.Record)
+	ackChan            chan (*telemetry.Ack)
 	reliableAckTxTypes map[string]interface{}
+
+	telemetry.DeliveryNotifier
 }
 
 // Config holds the configuration for the MQTT producer.
@@ -73,8 +78,24 @@ var (
 // PahoNewClient allows mocking the mqtt.NewClient function for testing
 var PahoNewClient = pahomqtt.NewClient
 
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.MQTT, newProducerFromConfig, telemetry.IgnoresRecordTypes())
+}
+
+// newProducerFromConfig is the producer factory of the MQTT dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected MQTT to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid MQTT config: %w", err)
+	}
+	return NewProducer(context.Background(), config, params.Dispatcher, params.MetricsCollector, params.Namespace, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
 // NewProducer creates a new MQTT producer.
-func NewProducer(ctx context.Context, config *Config, metrics metrics.MetricCollector, namespace string, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+func NewProducer(ctx context.Context, config *Config, dispatcher telemetry.Dispatcher, metrics metrics.MetricCollector, namespace string, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
 	registerMetricsOnce(metrics)
 
 	// Set default values
@@ -112,6 +133,7 @@ func NewProducer(ctx context.Context, config *Config, metrics metrics.MetricColl
 	client := PahoNewClient(opts)
 
 	return &Producer{
+		dispatcher:         dispatcher,
 		client:             client,
 		config:             config,
 		logger:             logger,
@@ -138,6 +160,7 @@ func (p *Producer) Connect() error {
 // Produce sends a record to the MQTT broker.
 func (p *Producer) Produce(rec *telemetry.Record) {
 	if p.ctx.Err() != nil {
+		p.NotifyDelivery(rec, p.ctx.Err())
 		return
 	}
 
@@ -153,20 +176,34 @@ func (p *Producer) Produce(rec *telemetry.Record) {
 		tokens, err = p.processVehicleAlerts(rec, payload)
 	case *protos.VehicleErrors:
 		tokens, err = p.processVehicleErrors(rec, payload)
+	case *protos.VehicleMetrics:
+		tokens, err = p.processVehicleMetrics(rec, payload)
 	case *protos.VehicleConnectivity:
 		tokens, err = p.processVehicleConnectivity(rec, payload)
+	case *protos.VehicleDerivedSignals:
+		tokens, err = p.processVehicleDerivedSignals(rec, payload)
+	case *protos.VehicleGeofenceEvents:
+		tokens, err = p.processVehicleGeofenceEvents(rec, payload)
+	case *protos.VehicleSession:
+		tokens, err = p.processVehicleSession(rec, payload)
+	case *protos.VehicleDeadLetter:
+		tokens, err = p.processVehicleDeadLetter(rec, payload)
+	case *protos.VehicleAlertLifecycle:
+		tokens, err = p.processVehicleAlertLifecycle(rec, payload)
 	default:
 		p.ReportError("mqtt_unknown_payload_type", nil, p.createLogInfo(rec))
+		p.NotifyDelivery(rec, telemetry.ErrRecordRejected)
 		return
 	}
 	if err != nil {
-		metricsRegistry.errorCount.Inc(map[string]string{"record_type": rec.TxType})
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": rec.TxType})
 		p.ReportError("mqtt_process_payload_error", err, p.createLogInfo(rec))
+		p.NotifyDelivery(rec, telemetry.ErrRecordRejected)
 		return
 	}
 
 	// Wait for all topics to be published
-	var publishError bool
+	var publishError error
 	startTime := time.Now()
 	timeout := time.Duration(p.config.PublishTimeout) * time.Millisecond
 	for _, token := range tokens {
@@ -175,16 +212,17 @@ func (p *Producer) Produce(rec *telemetry.Record) {
 			remainingTimeout = 0
 		}
 		if err := waitTokenTimeout(token, remainingTimeout); err != nil {
-			metricsRegistry.errorCount.Inc(map[string]string{"record_type": rec.TxType})
+			metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": rec.TxType})
 			p.ReportError("mqtt_publish_error", err, p.createLogInfo(rec))
-			publishError = true
+			publishError = err
 		}
 	}
 
 	// Only process reliable ACK if no token errors were reported
-	if !publishError {
+	if publishError == nil {
 		p.ProcessReliableAck(rec)
 	}
+	p.NotifyDelivery(rec, publishError)
 }
 
 // waitTokenTimeout waits for a token to complete or timeout.
@@ -205,8 +243,8 @@ func waitTokenTimeout(t pahomqtt.Token, d time.Duration) error {
 }
 
 func (p *Producer) updateMetrics(txType string, byteCount int) {
-	metricsRegistry.byteTotal.Add(int64(byteCount), map[string]string{"record_type": txType})
-	metricsRegistry.publishCount.Inc(map[string]string{"record_type": txType})
+	metricsRegistry.byteTotal.Add(int64(byteCount), map[string]string{"dispatcher": string(p.dispatcher), "record_type": txType})
+	metricsRegistry.publishCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": txType})
 }
 
 func (p *Producer) createLogInfo(rec *telemetry.Record) logrus.LogInfo {
@@ -222,8 +260,8 @@ func (p *Producer) createLogInfo(rec *telemetry.Record) logrus.LogInfo {
 func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
 	_, ok := p.reliableAckTxTypes[entry.TxType]
 	if ok {
-		p.ackChan <- entry
-		metricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": entry.TxType})
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 	}
 }
 
@@ -243,25 +281,25 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "mqtt_err",
 		Help:   "The number of errors while publishing to MQTT.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.publishCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "mqtt_publish_total",
 		Help:   "The number of values published to MQTT.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.byteTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "mqtt_publish_total_bytes",
 		Help:   "The number of JSON bytes published to MQTT.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "mqtt_reliable_ack_total",
 		Help:   "The number of records published to MQTT topics for which we sent a reliable ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 }
 
@@ -3,6 +3,7 @@ package mqtt
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	pahomqtt "github.com/eclipse/paho.mqtt.golang"
@@ -99,6 +100,76 @@ func (p *Producer) processVehicleErrors(rec *telemetry.Record, payload *protos.V
 	return tokens, nil
 }
 
+func (p *Producer) processVehicleMetrics(rec *telemetry.Record, payload *protos.VehicleMetrics) ([]pahomqtt.Token, error) {
+	var tokens []pahomqtt.Token
+
+	for _, metric := range payload.Metrics {
+		topicName := fmt.Sprintf("%s/%s/metrics/%s", p.config.TopicBase, rec.Vin, metric.Name)
+		metricMap := vehicleMetricToMqttMap(metric, payload)
+		jsonValue, err := json.Marshal(metricMap)
+		if err != nil {
+			return tokens, fmt.Errorf("failed to marshal JSON for MQTT topic %s: %v", topicName, err)
+		}
+
+		token := p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)
+		tokens = append(tokens, token)
+		p.updateMetrics(rec.TxType, len(jsonValue))
+	}
+
+	return tokens, nil
+}
+
+func (p *Producer) processVehicleDerivedSignals(rec *telemetry.Record, payload *protos.VehicleDerivedSignals) ([]pahomqtt.Token, error) {
+	var tokens []pahomqtt.Token
+
+	for _, signal := range payload.Signals {
+		topicName := fmt.Sprintf("%s/%s/derived/%s", p.config.TopicBase, rec.Vin, signal.Name)
+		signalMap := map[string]interface{}{"Value": signal.Value}
+		if payload.CreatedAt != nil {
+			signalMap["CreatedAt"] = payload.CreatedAt.AsTime().Format(time.RFC3339)
+		}
+		jsonValue, err := json.Marshal(signalMap)
+		if err != nil {
+			return tokens, fmt.Errorf("failed to marshal JSON for MQTT topic %s: %v", topicName, err)
+		}
+
+		token := p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)
+		tokens = append(tokens, token)
+		p.updateMetrics(rec.TxType, len(jsonValue))
+	}
+
+	return tokens, nil
+}
+
+func (p *Producer) processVehicleGeofenceEvents(rec *telemetry.Record, payload *protos.VehicleGeofenceEvents) ([]pahomqtt.Token, error) {
+	var tokens []pahomqtt.Token
+
+	for _, event := range payload.Events {
+		topicName := fmt.Sprintf("%s/%s/geofence/%s", p.config.TopicBase, rec.Vin, event.Geofence)
+		eventMap := map[string]interface{}{
+			"Transition": event.Transition.String(),
+			"Latitude":   event.Latitude,
+			"Longitude":  event.Longitude,
+		}
+		if event.EnteredAt != nil {
+			eventMap["EnteredAt"] = event.EnteredAt.AsTime().Format(time.RFC3339)
+		}
+		if event.ExitedAt != nil {
+			eventMap["ExitedAt"] = event.ExitedAt.AsTime().Format(time.RFC3339)
+		}
+		jsonValue, err := json.Marshal(eventMap)
+		if err != nil {
+			return tokens, fmt.Errorf("failed to marshal JSON for MQTT topic %s: %v", topicName, err)
+		}
+
+		token := p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)
+		tokens = append(tokens, token)
+		p.updateMetrics(rec.TxType, len(jsonValue))
+	}
+
+	return tokens, nil
+}
+
 func (p *Producer) processVehicleConnectivity(rec *telemetry.Record, payload *protos.VehicleConnectivity) ([]pahomqtt.Token, error) {
 	topicName := fmt.Sprintf("%s/%s/connectivity", p.config.TopicBase, rec.Vin)
 	value := map[string]interface{}{
@@ -113,6 +184,85 @@ func (p *Producer) processVehicleConnectivity(rec *telemetry.Record, payload *pr
 	return []pahomqtt.Token{p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)}, nil
 }
 
+func (p *Producer) processVehicleSession(rec *telemetry.Record, payload *protos.VehicleSession) ([]pahomqtt.Token, error) {
+	topicName := fmt.Sprintf("%s/%s/session/%s", p.config.TopicBase, rec.Vin, strings.ToLower(payload.GetKind().String()))
+	value := map[string]interface{}{
+		"SessionId":       payload.GetSessionId(),
+		"StartedAt":       payload.GetStartedAt().AsTime().Format(time.RFC3339),
+		"EndedAt":         payload.GetEndedAt().AsTime().Format(time.RFC3339),
+		"DurationSeconds": payload.GetDurationSeconds(),
+	}
+	if payload.Distance != nil {
+		value["Distance"] = payload.GetDistance()
+	}
+	if payload.Energy != nil {
+		value["Energy"] = payload.GetEnergy()
+	}
+	if payload.StartLocation != nil {
+		value["StartLocation"] = map[string]float64{"latitude": payload.StartLocation.Latitude, "longitude": payload.StartLocation.Longitude}
+	}
+	if payload.EndLocation != nil {
+		value["EndLocation"] = map[string]float64{"latitude": payload.EndLocation.Latitude, "longitude": payload.EndLocation.Longitude}
+	}
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON for MQTT topic %s: %v", topicName, err)
+	}
+	p.updateMetrics(rec.TxType, len(jsonValue))
+	return []pahomqtt.Token{p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)}, nil
+}
+
+func (p *Producer) processVehicleDeadLetter(rec *telemetry.Record, payload *protos.VehicleDeadLetter) ([]pahomqtt.Token, error) {
+	topicName := fmt.Sprintf("%s/%s/deadletter", p.config.TopicBase, rec.Vin)
+	value := map[string]interface{}{
+		"Reason":     payload.GetReason().String(),
+		"Error":      payload.GetError(),
+		"ReceivedAt": payload.GetReceivedAt().AsTime().Format(time.RFC3339),
+		"Txid":       payload.GetTxid(),
+		"RecordType": payload.GetRecordType(),
+		"RawBytes":   payload.GetRawBytes(),
+	}
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON for MQTT topic %s: %v", topicName, err)
+	}
+	p.updateMetrics(rec.TxType, len(jsonValue))
+	return []pahomqtt.Token{p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)}, nil
+}
+
+func (p *Producer) processVehicleAlertLifecycle(rec *telemetry.Record, payload *protos.VehicleAlertLifecycle) ([]pahomqtt.Token, error) {
+	var tokens []pahomqtt.Token
+
+	for _, event := range payload.Events {
+		topicName := fmt.Sprintf("%s/%s/alert_lifecycle/%s", p.config.TopicBase, rec.Vin, event.Name)
+		audiences := make([]string, len(event.Audiences))
+		for i, audience := range event.Audiences {
+			audiences[i] = audience.String()
+		}
+		eventMap := map[string]interface{}{
+			"Event":     event.Event.String(),
+			"Audiences": audiences,
+		}
+		if event.StartedAt != nil {
+			eventMap["StartedAt"] = event.StartedAt.AsTime().Format(time.RFC3339)
+		}
+		if event.EndedAt != nil {
+			eventMap["EndedAt"] = event.EndedAt.AsTime().Format(time.RFC3339)
+			eventMap["DurationSeconds"] = event.DurationSeconds
+		}
+		jsonValue, err := json.Marshal(eventMap)
+		if err != nil {
+			return tokens, fmt.Errorf("failed to marshal JSON for MQTT topic %s: %v", topicName, err)
+		}
+
+		token := p.client.Publish(topicName, p.config.QoS, p.config.Retained, jsonValue)
+		tokens = append(tokens, token)
+		p.updateMetrics(rec.TxType, len(jsonValue))
+	}
+
+	return tokens, nil
+}
+
 func vehicleAlertToMqttMap(alert *protos.VehicleAlert) map[string]interface{} {
 	alertMap := make(map[string]interface{}, 3)
 	if alert.StartedAt != nil {
@@ -148,6 +298,20 @@ func vehicleErrorToMqttMap(vehicleError *protos.VehicleError) map[string]interfa
 	return errorMap
 }
 
+// vehicleMetricToMqttMap flattens the metric tags into the payload alongside the value.
+// Value and CreatedAt take precedence over tags with the same name.
+func vehicleMetricToMqttMap(metric *protos.Metric, payload *protos.VehicleMetrics) map[string]interface{} {
+	metricMap := make(map[string]interface{}, len(metric.Tags)+2)
+	for key, value := range metric.Tags {
+		metricMap[key] = value
+	}
+	metricMap["Value"] = metric.Value
+	if payload.CreatedAt != nil {
+		metricMap["CreatedAt"] = payload.CreatedAt.AsTime().Format(time.RFC3339)
+	}
+	return metricMap
+}
+
 // PayloadToMap transforms a Payload into a map for mqtt purposes
 func (p *Producer) payloadToMap(payload *protos.Payload) map[string]interface{} {
 	convertedPayload := make(map[string]interface{}, len(payload.Data))
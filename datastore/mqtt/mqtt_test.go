@@ -177,6 +177,7 @@ var _ = Describe("MQTTProducer", func() {
 			producer, err := mqtt.NewProducer(
 				context.Background(),
 				mockConfig,
+				telemetry.MQTT,
 				mockCollector,
 				"test_namespace",
 				mockAirbrake,
@@ -268,6 +269,7 @@ var _ = Describe("MQTTProducer", func() {
 			producer, err := mqtt.NewProducer(
 				context.Background(),
 				mockConfig,
+				telemetry.MQTT,
 				mockCollector,
 				"test_namespace",
 				mockAirbrake,
@@ -350,6 +352,7 @@ var _ = Describe("MQTTProducer", func() {
 			producer, err := mqtt.NewProducer(
 				context.Background(),
 				mockConfig,
+				telemetry.MQTT,
 				mockCollector,
 				"test_namespace",
 				nil,
@@ -420,6 +423,274 @@ var _ = Describe("MQTTProducer", func() {
 			Expect(error2).To(HaveKey("CreatedAt"))
 		})
 
+		It("should publish MQTT messages for vehicle metrics", func() {
+			producer, err := mqtt.NewProducer(
+				context.Background(),
+				mockConfig,
+				telemetry.MQTT,
+				mockCollector,
+				"test_namespace",
+				mockAirbrake,
+				nil,
+				nil,
+				mockLogger,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			vehicleMetrics := &protos.VehicleMetrics{
+				Vin: "TEST123",
+				Metrics: []*protos.Metric{
+					{
+						Name:  "TestMetric1",
+						Tags:  map[string]string{"tag1": "value1", "tag2": "value2"},
+						Value: 12.5,
+					},
+					{
+						Name:  "TestMetric2",
+						Value: 3,
+					},
+				},
+				CreatedAt: timestamppb.Now(),
+			}
+
+			metricsBytes, err := proto.Marshal(vehicleMetrics)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := messages.StreamMessage{
+				TXID:         []byte("1234"),
+				SenderID:     []byte("vehicle_device.TEST123"),
+				MessageTopic: []byte("metrics"),
+				Payload:      metricsBytes,
+			}
+			msgBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+
+			record, err := telemetry.NewRecord(serializer, msgBytes, "1", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			producer.Produce(record)
+
+			Expect(publishedTopics).To(HaveLen(2))
+
+			metric1Topic := "test/topic/TEST123/metrics/TestMetric1"
+			metric2Topic := "test/topic/TEST123/metrics/TestMetric2"
+
+			Expect(publishedTopics).To(HaveKey(metric1Topic))
+			Expect(publishedTopics).To(HaveKey(metric2Topic))
+
+			var metric1, metric2 map[string]interface{}
+			Expect(json.Unmarshal(publishedTopics[metric1Topic], &metric1)).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(publishedTopics[metric2Topic], &metric2)).NotTo(HaveOccurred())
+
+			Expect(metric1).To(HaveKeyWithValue("Value", 12.5))
+			Expect(metric1).To(HaveKeyWithValue("tag1", "value1"))
+			Expect(metric1).To(HaveKeyWithValue("tag2", "value2"))
+			Expect(metric1).To(HaveKey("CreatedAt"))
+
+			Expect(metric2).To(HaveKeyWithValue("Value", 3.0))
+			Expect(metric2).To(HaveKey("CreatedAt"))
+		})
+
+		It("should publish MQTT messages for derived signals", func() {
+			producer, err := mqtt.NewProducer(
+				context.Background(),
+				mockConfig,
+				telemetry.MQTT,
+				mockCollector,
+				"test_namespace",
+				mockAirbrake,
+				nil,
+				nil,
+				mockLogger,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := messages.StreamMessage{
+				TXID:         []byte("1234"),
+				SenderID:     []byte("vehicle_device.TEST123"),
+				MessageTopic: []byte("V"),
+				Payload:      []byte{},
+			}
+			msgBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+			record, err := telemetry.NewRecord(serializer, msgBytes, "1", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			derived, err := record.WithProtoMessage(&protos.VehicleDerivedSignals{
+				Vin:       "TEST123",
+				Signals:   []*protos.DerivedSignal{{Name: "power", Value: 21.5}},
+				CreatedAt: timestamppb.Now(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			derived.TxType = "derived"
+
+			producer.Produce(derived)
+
+			Expect(publishedTopics).To(HaveLen(1))
+			signalTopic := "test/topic/TEST123/derived/power"
+			Expect(publishedTopics).To(HaveKey(signalTopic))
+
+			var signal map[string]interface{}
+			Expect(json.Unmarshal(publishedTopics[signalTopic], &signal)).NotTo(HaveOccurred())
+			Expect(signal).To(HaveKeyWithValue("Value", 21.5))
+			Expect(signal).To(HaveKey("CreatedAt"))
+		})
+
+		It("should publish MQTT messages for geofence events", func() {
+			producer, err := mqtt.NewProducer(
+				context.Background(),
+				mockConfig,
+				telemetry.MQTT,
+				mockCollector,
+				"test_namespace",
+				mockAirbrake,
+				nil,
+				nil,
+				mockLogger,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := messages.StreamMessage{
+				TXID:         []byte("1234"),
+				SenderID:     []byte("vehicle_device.TEST123"),
+				MessageTopic: []byte("V"),
+				Payload:      []byte{},
+			}
+			msgBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+			record, err := telemetry.NewRecord(serializer, msgBytes, "1", true)
+			Expect(err).NotTo(HaveOccurred())
+			geofence, err := record.WithProtoMessage(&protos.VehicleGeofenceEvents{
+				Vin: "TEST123",
+				Events: []*protos.GeofenceEvent{{
+					Geofence:   "depot",
+					Transition: protos.GeofenceTransition_EXIT,
+					EnteredAt:  timestamppb.Now(),
+					ExitedAt:   timestamppb.Now(),
+					Latitude:   37.41,
+					Longitude:  -122.14,
+				}},
+				CreatedAt: timestamppb.Now(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			geofence.TxType = "geofence"
+
+			producer.Produce(geofence)
+
+			Expect(publishedTopics).To(HaveLen(1))
+			eventTopic := "test/topic/TEST123/geofence/depot"
+			Expect(publishedTopics).To(HaveKey(eventTopic))
+
+			var event map[string]interface{}
+			Expect(json.Unmarshal(publishedTopics[eventTopic], &event)).NotTo(HaveOccurred())
+			Expect(event).To(HaveKeyWithValue("Transition", "EXIT"))
+			Expect(event).To(HaveKeyWithValue("Latitude", 37.41))
+			Expect(event).To(HaveKeyWithValue("Longitude", -122.14))
+			Expect(event).To(HaveKey("EnteredAt"))
+			Expect(event).To(HaveKey("ExitedAt"))
+		})
+
+		It("should publish MQTT messages for sessions", func() {
+			producer, err := mqtt.NewProducer(
+				context.Background(),
+				mockConfig,
+				telemetry.MQTT,
+				mockCollector,
+				"test_namespace",
+				mockAirbrake,
+				nil,
+				nil,
+				mockLogger,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := messages.StreamMessage{
+				TXID:         []byte("1234"),
+				SenderID:     []byte("vehicle_device.TEST123"),
+				MessageTopic: []byte("V"),
+				Payload:      []byte{},
+			}
+			msgBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+			record, err := telemetry.NewRecord(serializer, msgBytes, "1", true)
+			Expect(err).NotTo(HaveOccurred())
+			summary, err := record.WithProtoMessage(&protos.VehicleSession{
+				Vin:             "TEST123",
+				SessionId:       "trip-TEST123-1700000000000",
+				Kind:            protos.SessionKind_TRIP,
+				StartedAt:       timestamppb.Now(),
+				EndedAt:         timestamppb.Now(),
+				DurationSeconds: 1200,
+				Distance:        proto.Float64(12.5),
+				EndLocation:     &protos.SessionLocation{Latitude: 37.42, Longitude: -122.14},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			summary.TxType = "session"
+
+			producer.Produce(summary)
+
+			Expect(publishedTopics).To(HaveLen(1))
+			sessionTopic := "test/topic/TEST123/session/trip"
+			Expect(publishedTopics).To(HaveKey(sessionTopic))
+
+			var trip map[string]interface{}
+			Expect(json.Unmarshal(publishedTopics[sessionTopic], &trip)).NotTo(HaveOccurred())
+			Expect(trip).To(HaveKeyWithValue("SessionId", "trip-TEST123-1700000000000"))
+			Expect(trip).To(HaveKeyWithValue("DurationSeconds", 1200.0))
+			Expect(trip).To(HaveKeyWithValue("Distance", 12.5))
+			Expect(trip).NotTo(HaveKey("Energy"))
+			Expect(trip).NotTo(HaveKey("StartLocation"))
+			Expect(trip).To(HaveKeyWithValue("EndLocation", map[string]interface{}{"latitude": 37.42, "longitude": -122.14}))
+		})
+
+		It("should publish MQTT messages for dead letters", func() {
+			producer, err := mqtt.NewProducer(
+				context.Background(),
+				mockConfig,
+				telemetry.MQTT,
+				mockCollector,
+				"test_namespace",
+				mockAirbrake,
+				nil,
+				nil,
+				mockLogger,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := messages.StreamMessage{
+				TXID:         []byte("1234"),
+				SenderID:     []byte("vehicle_device.TEST123"),
+				MessageTopic: []byte("V"),
+				Payload:      []byte{},
+			}
+			msgBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+			record, err := telemetry.NewRecord(serializer, msgBytes, "1", true)
+			Expect(err).NotTo(HaveOccurred())
+			deadLetter, err := record.WithProtoMessage(&protos.VehicleDeadLetter{
+				Vin:        "TEST123",
+				Reason:     protos.DeadLetterReason_INVALID_MESSAGE,
+				ReceivedAt: timestamppb.Now(),
+				Txid:       "1234",
+				RecordType: "V",
+				RawBytes:   []byte{0x00, 0xff},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			deadLetter.TxType = "deadletter"
+
+			producer.Produce(deadLetter)
+
+			Expect(publishedTopics).To(HaveLen(1))
+			letterTopic := "test/topic/TEST123/deadletter"
+			Expect(publishedTopics).To(HaveKey(letterTopic))
+
+			var letter map[string]interface{}
+			Expect(json.Unmarshal(publishedTopics[letterTopic], &letter)).NotTo(HaveOccurred())
+			Expect(letter).To(HaveKeyWithValue("Reason", "INVALID_MESSAGE"))
+			Expect(letter).To(HaveKeyWithValue("Txid", "1234"))
+			Expect(letter).To(HaveKeyWithValue("RawBytes", "AP8="))
+		})
+
 		It("should handle timeouts when publishing MQTT messages", func() {
 			// Mock a slow publish function that always times out
 			mqtt.PahoNewClient = func(_ *pahomqtt.ClientOptions) pahomqtt.Client {
@@ -446,6 +717,7 @@ var _ = Describe("MQTTProducer", func() {
 			producer, err := mqtt.NewProducer(
 				context.Background(),
 				mockConfig,
+				telemetry.MQTT,
 				mockCollector,
 				"test_namespace",
 				mockAirbrake,
@@ -2,6 +2,8 @@ package nats
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -50,17 +52,20 @@ func (c natsHeaderCarrier) Keys() []string {
 
 // Producer client to handle NATS interactions
 type Producer struct {
+	dispatcher         telemetry.Dispatcher
 	natsConn           *nats.Conn
 	namespace          string
 	metricsCollector   metrics.MetricCollector
 	logger             *logrus.Logger
 	airbrakeHandler    *airbrake.Handler
-	ackChan            chan (*telemetry.Record)
+	ackChan            chan (*telemetry.Ack)
 	reliableAckTxTypes map[string]interface{}
 	// closing is set before Close() tears down natsConn, so the async
 	// ClosedHandler callback (see NewProducer) can tell an intentional,
 	// user-driven shutdown apart from a fatal, unrecoverable connection loss.
 	closing *atomic.Bool
+
+	telemetry.DeliveryNotifier
 }
 
 // Metrics stores metrics reported from this package
@@ -85,8 +90,24 @@ type Config struct {
 	Name string `json:"name"`
 }
 
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.NATS, newProducerFromConfig, telemetry.IgnoresRecordTypes())
+}
+
+// newProducerFromConfig is the producer factory of the NATS dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected NATS to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid NATS config: %w", err)
+	}
+	return NewProducer(config, params.Dispatcher, params.Namespace, params.PrometheusEnabled, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
 // NewProducer establishes the NATS connection and define the dispatch method
-func NewProducer(config *Config, namespace string, _ bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+func NewProducer(config *Config, dispatcher telemetry.Dispatcher, namespace string, _ bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
 	registerMetricsOnce(metricsCollector)
 
 	// Created before the connection so the ClosedHandler closure below can
@@ -125,6 +146,7 @@ func NewProducer(config *Config, namespace string, _ bool, metricsCollector metr
 	}
 
 	producer := &Producer{
+		dispatcher:         dispatcher,
 		natsConn:           natsConn,
 		namespace:          namespace,
 		metricsCollector:   metricsCollector,
@@ -187,11 +209,13 @@ func (p *Producer) Produce(entry *telemetry.Record) {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		p.logError(err)
+		p.NotifyDelivery(entry, err)
 		return
 	}
 	p.ProcessReliableAck(entry)
-	metricsRegistry.producerCount.Inc(map[string]string{"record_type": entry.TxType})
-	metricsRegistry.bytesTotal.Add(int64(entry.Length()), map[string]string{"record_type": entry.TxType})
+	p.NotifyDelivery(entry, nil)
+	metricsRegistry.producerCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	metricsRegistry.bytesTotal.Add(int64(entry.Length()), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 }
 
 // Close intentionally closes the producer without treating the NATS CLOSED
@@ -206,8 +230,8 @@ func (p *Producer) Close() error {
 func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
 	_, ok := p.reliableAckTxTypes[entry.TxType]
 	if ok {
-		p.ackChan <- entry
-		metricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": entry.TxType})
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
 	}
 }
 
@@ -219,7 +243,7 @@ func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo
 
 func (p *Producer) logError(err error) {
 	p.ReportError("nats_err", err, nil)
-	metricsRegistry.errorCount.Inc(map[string]string{})
+	metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
 }
 
 func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
@@ -230,42 +254,42 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 	metricsRegistry.producerCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "nats_produce_total",
 		Help:   "The number of records produced to NATS.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.bytesTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "nats_produce_total_bytes",
 		Help:   "The number of bytes produced to NATS.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.producerAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "nats_produce_ack_total",
 		Help:   "The number of records produced to NATS for which we got an ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "nats_reliable_ack_total",
 		Help:   "The number of records produced to NATS for which we sent a reliable ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.bytesAckTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "nats_produce_ack_total_bytes",
 		Help:   "The number of bytes produced to NATS for which we got an ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "nats_err",
 		Help:   "The number of errors while producing to NATS.",
-		Labels: []string{},
+		Labels: []string{"dispatcher"},
 	})
 
 	metricsRegistry.producerQueueSize = metricsCollector.RegisterGauge(adapter.CollectorOptions{
 		Name:   "nats_produce_queue_size",
 		Help:   "Total pending messages to produce",
-		Labels: []string{"type"},
+		Labels: []string{"dispatcher", "type"},
 	})
 }
@@ -14,6 +14,7 @@ import (
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/metrics"
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
 )
 
 // closeSubprocessEnvVar re-execs this same test binary to run only
@@ -56,7 +57,7 @@ func runCloseSubprocessBody(t *testing.T) {
 	collector := metrics.NewCollector(nil, logger)
 	airbrakeHandler := airbrake.NewAirbrakeHandler(nil)
 
-	producer, err := fleetnats.NewProducer(&fleetnats.Config{URL: srv.ClientURL(), Name: "close-repro"}, "telemetry", false, collector, airbrakeHandler, nil, nil, logger)
+	producer, err := fleetnats.NewProducer(&fleetnats.Config{URL: srv.ClientURL(), Name: "close-repro"}, telemetry.NATS, "telemetry", false, collector, airbrakeHandler, nil, nil, logger)
 	if err != nil {
 		t.Fatalf("failed to create producer: %v", err)
 	}
@@ -56,11 +56,11 @@ func startNatsServer(opts *natsserver.Options) *natsserver.Server {
 
 // newTestProducer wires up a nats.Producer against the given server URL using
 // the same construction path config.go uses in production.
-func newTestProducer(url, namespace string, logger *logrus.Logger, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}) (telemetry.Producer, error) {
+func newTestProducer(url, namespace string, logger *logrus.Logger, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}) (telemetry.Producer, error) {
 	cfg := &fleetnats.Config{URL: url, Name: "fleet-telemetry-test"}
 	collector := metrics.NewCollector(nil, logger)
 	airbrakeHandler := airbrake.NewAirbrakeHandler(nil)
-	return fleetnats.NewProducer(cfg, namespace, false, collector, airbrakeHandler, ackChan, reliableAckTxTypes, logger)
+	return fleetnats.NewProducer(cfg, telemetry.NATS, namespace, false, collector, airbrakeHandler, ackChan, reliableAckTxTypes, logger)
 }
 
 // buildRecord constructs a *telemetry.Record the same way the real ingest path
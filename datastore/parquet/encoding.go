@@ -0,0 +1,363 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+)
+
+// A file is the magic number, the column chunks of each row group, the thrift compact encoded FileMetaData, its
+// 4 byte little endian length and the magic number again. Each column chunk is a single v1 data page of PLAIN
+// encoded values, preceded by the RLE encoded definition levels of optional columns.
+// See https://github.com/apache/parquet-format
+
+const (
+	magic     = "PAR1"
+	createdBy = "fleet-telemetry"
+)
+
+// physical types
+const (
+	typeBoolean   int32 = 0
+	typeInt64     int32 = 2
+	typeDouble    int32 = 5
+	typeByteArray int32 = 6
+)
+
+// converted types
+const (
+	convertedNone            int32 = -1
+	convertedUTF8            int32 = 0
+	convertedTimestampMillis int32 = 9
+)
+
+// repetition types
+const (
+	repetitionRequired int32 = 0
+	repetitionOptional int32 = 1
+)
+
+const (
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+
+	codecUncompressed int32 = 0
+	codecGzip         int32 = 2
+
+	pageTypeData int32 = 0
+)
+
+// thrift compact protocol types
+const (
+	compactI32    byte = 5
+	compactI64    byte = 6
+	compactBinary byte = 8
+	compactList   byte = 9
+	compactStruct byte = 12
+)
+
+// leaf is a primitive column of the schema, the path of a nested column holding its group
+type leaf struct {
+	path     []string
+	physical int32
+	maxDef   int
+	value    func(*row) (cell, bool)
+}
+
+// chunk is the encoded column chunk of a leaf in a row group
+type chunk struct {
+	leaf              *leaf
+	offset            int64
+	numValues         int64
+	uncompressedBytes int64
+	compressedBytes   int64
+}
+
+// encodeFile writes the rows as a parquet file of the columns, split into row groups of rowGroupSize rows
+func encodeFile(columns []*column, rows []*row, rowGroupSize int, codec int32) ([]byte, error) {
+	var leaves []*leaf
+	for _, column := range columns {
+		leaves = append(leaves, column.leaves()...)
+	}
+
+	buf := bytes.NewBufferString(magic)
+	var rowGroups [][]*chunk
+	for start := 0; start < len(rows); start += rowGroupSize {
+		group := rows[start:min(start+rowGroupSize, len(rows))]
+		chunks := make([]*chunk, 0, len(leaves))
+		for _, l := range leaves {
+			encoded, err := encodeChunk(l, group, codec, int64(buf.Len()))
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, encoded.chunk)
+			buf.Write(encoded.data)
+		}
+		rowGroups = append(rowGroups, chunks)
+	}
+
+	footer := encodeFileMetaData(columns, rowGroups, rows, rowGroupSize, codec)
+	buf.Write(footer)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	buf.WriteString(magic)
+	return buf.Bytes(), nil
+}
+
+type encodedChunk struct {
+	chunk *chunk
+	data  []byte
+}
+
+// encodeChunk encodes the values of a leaf as a single data page
+func encodeChunk(l *leaf, rows []*row, codec int32, offset int64) (*encodedChunk, error) {
+	var levels []byte
+	var values []byte
+	var bools []bool
+	for _, r := range rows {
+		value, ok := l.value(r)
+		if l.maxDef > 0 {
+			if ok {
+				levels = append(levels, 1)
+			} else {
+				levels = append(levels, 0)
+			}
+		}
+		if !ok {
+			continue
+		}
+		switch l.physical {
+		case typeBoolean:
+			bools = append(bools, value.b)
+		case typeInt64:
+			values = binary.LittleEndian.AppendUint64(values, uint64(value.l))
+		case typeDouble:
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(value.d))
+		case typeByteArray:
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(value.s)))
+			values = append(values, value.s...)
+		}
+	}
+	if l.physical == typeBoolean {
+		values = appendBitPacked(values, bools)
+	}
+
+	var page []byte
+	if l.maxDef > 0 {
+		encodedLevels := appendRLE(nil, levels)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(encodedLevels)))
+		page = append(page, encodedLevels...)
+	}
+	page = append(page, values...)
+
+	compressed := page
+	if codec == codecGzip {
+		var err error
+		if compressed, err = gzipBytes(page); err != nil {
+			return nil, err
+		}
+	}
+
+	header := &compactWriter{}
+	header.i32(1, pageTypeData)
+	header.i32(2, int32(len(page)))
+	header.i32(3, int32(len(compressed)))
+	header.beginStruct(5)
+	header.i32(1, int32(len(rows)))
+	header.i32(2, encodingPlain)
+	header.i32(3, encodingRLE)
+	header.i32(4, encodingRLE)
+	header.endStruct()
+	header.endStruct()
+
+	return &encodedChunk{
+		chunk: &chunk{
+			leaf:              l,
+			offset:            offset,
+			numValues:         int64(len(rows)),
+			uncompressedBytes: int64(len(header.buf) + len(page)),
+			compressedBytes:   int64(len(header.buf) + len(compressed)),
+		},
+		data: append(header.buf, compressed...),
+	}, nil
+}
+
+// encodeFileMetaData encodes the footer of the file: its schema and the location of its column chunks
+func encodeFileMetaData(columns []*column, rowGroups [][]*chunk, rows []*row, rowGroupSize int, codec int32) []byte {
+	w := &compactWriter{}
+	w.i32(1, 1)
+
+	elements := 1
+	for _, column := range columns {
+		elements += len(column.schema())
+	}
+	w.listHeader(2, compactStruct, elements)
+	w.beginListStruct()
+	w.string(4, "schema")
+	w.i32(5, int32(len(columns)))
+	w.endStruct()
+	for _, column := range columns {
+		for _, element := range column.schema() {
+			w.beginListStruct()
+			if element.children == 0 {
+				w.i32(1, element.physical)
+			}
+			w.i32(3, element.repetition)
+			w.string(4, element.name)
+			if element.children > 0 {
+				w.i32(5, int32(element.children))
+			}
+			if element.converted != convertedNone {
+				w.i32(6, element.converted)
+			}
+			w.endStruct()
+		}
+	}
+
+	w.i64(3, int64(len(rows)))
+	w.listHeader(4, compactStruct, len(rowGroups))
+	for i, chunks := range rowGroups {
+		numRows := min(rowGroupSize, len(rows)-i*rowGroupSize)
+		var totalBytes int64
+		w.beginListStruct()
+		w.listHeader(1, compactStruct, len(chunks))
+		for _, c := range chunks {
+			totalBytes += c.uncompressedBytes
+			w.beginListStruct()
+			w.i64(2, c.offset)
+			w.beginStruct(3)
+			w.i32(1, c.leaf.physical)
+			w.listHeader(2, compactI32, 2)
+			w.appendI32(encodingPlain)
+			w.appendI32(encodingRLE)
+			w.listHeader(3, compactBinary, len(c.leaf.path))
+			for _, name := range c.leaf.path {
+				w.appendString(name)
+			}
+			w.i32(4, codec)
+			w.i64(5, c.numValues)
+			w.i64(6, c.uncompressedBytes)
+			w.i64(7, c.compressedBytes)
+			w.i64(9, c.offset)
+			w.endStruct()
+			w.endStruct()
+		}
+		w.i64(2, totalBytes)
+		w.i64(3, int64(numRows))
+		w.endStruct()
+	}
+	w.string(6, createdBy)
+	w.endStruct()
+	return w.buf
+}
+
+// appendRLE encodes levels of bit width 1 with the RLE/bit-packing hybrid, as RLE runs only
+func appendRLE(buf []byte, levels []byte) []byte {
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		buf = binary.AppendUvarint(buf, uint64(end-start)<<1)
+		buf = append(buf, levels[start])
+		start = end
+	}
+	return buf
+}
+
+// appendBitPacked encodes booleans one bit each, least significant bit first
+func appendBitPacked(buf []byte, values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, value := range values {
+		if value {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(buf, packed...)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compactWriter encodes thrift structs with the compact protocol. The top level struct needs no begin, only an end.
+type compactWriter struct {
+	buf       []byte
+	lastField int16
+	stack     []int16
+}
+
+func (w *compactWriter) fieldHeader(id int16, fieldType byte) {
+	if delta := id - w.lastField; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|fieldType)
+	} else {
+		w.buf = append(w.buf, fieldType)
+		w.buf = binary.AppendUvarint(w.buf, zigzag(int64(id)))
+	}
+	w.lastField = id
+}
+
+func (w *compactWriter) i32(id int16, value int32) {
+	w.fieldHeader(id, compactI32)
+	w.appendI32(value)
+}
+
+func (w *compactWriter) i64(id int16, value int64) {
+	w.fieldHeader(id, compactI64)
+	w.buf = binary.AppendUvarint(w.buf, zigzag(value))
+}
+
+func (w *compactWriter) string(id int16, value string) {
+	w.fieldHeader(id, compactBinary)
+	w.appendString(value)
+}
+
+func (w *compactWriter) beginStruct(id int16) {
+	w.fieldHeader(id, compactStruct)
+	w.beginListStruct()
+}
+
+// beginListStruct starts a struct element of a list
+func (w *compactWriter) beginListStruct() {
+	w.stack = append(w.stack, w.lastField)
+	w.lastField = 0
+}
+
+func (w *compactWriter) endStruct() {
+	w.buf = append(w.buf, 0)
+	if len(w.stack) > 0 {
+		w.lastField = w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+	}
+}
+
+func (w *compactWriter) listHeader(id int16, elementType byte, size int) {
+	w.fieldHeader(id, compactList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elementType)
+		return
+	}
+	w.buf = append(w.buf, 0xf0|elementType)
+	w.buf = binary.AppendUvarint(w.buf, uint64(size))
+}
+
+func (w *compactWriter) appendI32(value int32) {
+	w.buf = binary.AppendUvarint(w.buf, zigzag(int64(value)))
+}
+
+func (w *compactWriter) appendString(value string) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(value)))
+	w.buf = append(w.buf, value...)
+}
+
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
@@ -0,0 +1,521 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// DefaultRowGroupSize is the maximum number of rows of a row group
+	DefaultRowGroupSize = 10000
+	// DefaultFlushIntervalMs is how often the buffered records are written
+	DefaultFlushIntervalMs = 60000
+	// DefaultMaxBufferedRows is the number of rows buffered across partitions before records are failed
+	DefaultMaxBufferedRows = 100000
+
+	// CompressionGzip compresses the pages of the files with gzip
+	CompressionGzip = "gzip"
+	// CompressionNone writes the pages uncompressed
+	CompressionNone = "none"
+
+	fileExtension = ".parquet"
+	defaultRegion = "us-east-1"
+)
+
+// Config for the parquet producer, writing to a local directory or an S3 bucket
+type Config struct {
+	// Dir is the local directory the files are written to
+	Dir string `json:"dir,omitempty"`
+
+	// S3 is the bucket the files are uploaded to, instead of a local directory
+	S3 *S3Config `json:"s3,omitempty"`
+
+	// RowGroupSize is the maximum number of rows of a row group. A partition is written as a file as soon as it
+	// buffers that many rows. Default: 10000
+	RowGroupSize int `json:"row_group_size,omitempty"`
+
+	// FlushIntervalMs is how often the buffered records are written as a file per partition, records being acked
+	// once written. Default: 60000
+	FlushIntervalMs int `json:"flush_interval_ms,omitempty"`
+
+	// MaxBufferedRows caps the rows buffered or being written across partitions, records are failed while it is
+	// reached so a slow store does not exhaust the memory. Default: 100000, at least RowGroupSize
+	MaxBufferedRows int `json:"max_buffered_rows,omitempty"`
+
+	// Compression of the pages, "gzip" or "none". Default: gzip
+	Compression string `json:"compression,omitempty"`
+
+	// PartitionByVin adds the vin to the partitions, under the hour
+	PartitionByVin bool `json:"partition_by_vin,omitempty"`
+}
+
+// S3Config is an S3 bucket, or a bucket of an S3-compatible endpoint such as MinIO. Credentials come from the
+// standard AWS env variables and config files.
+type S3Config struct {
+	// Bucket the files are uploaded to
+	Bucket string `json:"bucket"`
+
+	// Prefix of the keys of the files
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region of the bucket. Default: us-east-1
+	Region string `json:"region,omitempty"`
+
+	// Endpoint of an S3-compatible service, ex.: http://minio:9000
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ForcePathStyle addresses the bucket in the path instead of the host, as required by most S3-compatible services
+	ForcePathStyle bool `json:"force_path_style,omitempty"`
+}
+
+// store persists the files
+type store interface {
+	put(key string, data []byte) error
+}
+
+// Producer buffers V records and writes them as parquet files partitioned by record type, date and hour
+type Producer struct {
+	dispatcher         telemetry.Dispatcher
+	config             *Config
+	codec              int32
+	store              store
+	logger             *logrus.Logger
+	airbrakeHandler    *airbrake.Handler
+	ackChan            chan (*telemetry.Ack)
+	reliableAckTxTypes map[string]interface{}
+
+	mutex    sync.Mutex
+	closed   bool
+	batches  map[string]*batch
+	full     []*batch
+	buffered int
+	lastName int64
+
+	// flushMutex serializes the flushes of the schedule, of the full batches and of Close
+	flushMutex  sync.Mutex
+	flushSignal chan struct{}
+	stopChan    chan struct{}
+	wg          sync.WaitGroup
+
+	telemetry.DeliveryNotifier
+}
+
+// batch is the records buffered for a partition
+type batch struct {
+	partition string
+	txType    string
+	rows      []*row
+	records   []*telemetry.Record
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	rowCount         adapter.Counter
+	fileCount        adapter.Counter
+	bytesTotal       adapter.Counter
+	errorCount       adapter.Counter
+	bufferFullCount  adapter.Counter
+	reliableAckCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.Parquet, newProducerFromConfig)
+}
+
+// newProducerFromConfig is the producer factory of the parquet dispatcher, which only writes V records
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected Parquet to be configured")
+	}
+	for _, recordType := range params.RecordTypes {
+		if recordType != "V" {
+			return nil, fmt.Errorf("%s cannot be configured for record: %s, only V records are written as parquet", params.Dispatcher, recordType)
+		}
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid parquet config: %w", err)
+	}
+	return NewProducer(config, params.Dispatcher, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
+// NewProducer validates the parquet configuration, connects to its store and starts flushing on schedule
+func NewProducer(config *Config, dispatcher telemetry.Dispatcher, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+	registerMetricsOnce(metricsCollector)
+
+	if (config.Dir == "") == (config.S3 == nil) {
+		return nil, errors.New("parquet requires either a dir or s3")
+	}
+	if config.Compression == "" {
+		config.Compression = CompressionGzip
+	}
+	codec := codecGzip
+	switch config.Compression {
+	case CompressionGzip:
+	case CompressionNone:
+		codec = codecUncompressed
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression: %s", config.Compression)
+	}
+	if config.RowGroupSize <= 0 {
+		config.RowGroupSize = DefaultRowGroupSize
+	}
+	if config.FlushIntervalMs <= 0 {
+		config.FlushIntervalMs = DefaultFlushIntervalMs
+	}
+	if config.MaxBufferedRows <= 0 {
+		config.MaxBufferedRows = DefaultMaxBufferedRows
+	}
+	config.MaxBufferedRows = max(config.MaxBufferedRows, config.RowGroupSize)
+
+	var fileStore store
+	var err error
+	if config.S3 != nil {
+		fileStore, err = newS3Store(config.S3)
+	} else {
+		fileStore, err = newDirStore(config.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	producer := &Producer{
+		dispatcher:         dispatcher,
+		config:             config,
+		codec:              codec,
+		store:              fileStore,
+		logger:             logger,
+		airbrakeHandler:    airbrakeHandler,
+		ackChan:            ackChan,
+		reliableAckTxTypes: reliableAckTxTypes,
+		batches:            make(map[string]*batch),
+		flushSignal:        make(chan struct{}, 1),
+		stopChan:           make(chan struct{}),
+	}
+	producer.wg.Add(1)
+	go producer.runFlush()
+	producer.logger.ActivityLog("parquet_registered", logrus.LogInfo{"dir": config.Dir, "s3": config.S3 != nil, "row_group_size": config.RowGroupSize, "flush_interval_ms": config.FlushIntervalMs, "max_buffered_rows": config.MaxBufferedRows})
+	return producer, nil
+}
+
+// Produce buffers the payload of a V record in its partition, which is written as soon as it holds a row group. It
+// is acked once its file is written, and failed while MaxBufferedRows are buffered.
+func (p *Producer) Produce(entry *telemetry.Record) {
+	payload, ok := entry.GetProtoMessage().(*protos.Payload)
+	if !ok {
+		p.ReportError("parquet_unsupported_record_type", nil, logrus.LogInfo{"record_type": entry.TxType})
+		p.NotifyDelivery(entry, telemetry.ErrRecordRejected)
+		return
+	}
+	receivedAt := time.Now()
+	if entry.ReceivedTimestamp > 0 {
+		receivedAt = time.UnixMilli(entry.ReceivedTimestamp)
+	}
+	partition := p.partition(entry, receivedAt)
+	r := newRow(entry.Vin, entry.Txid, receivedAt, payload)
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		p.NotifyDelivery(entry, errors.New("parquet producer closed"))
+		return
+	}
+	if p.buffered >= p.config.MaxBufferedRows {
+		p.mutex.Unlock()
+		metricsRegistry.bufferFullCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+		p.NotifyDelivery(entry, errors.New("parquet buffer full"))
+		return
+	}
+	current, ok := p.batches[partition]
+	if !ok {
+		current = &batch{partition: partition, txType: entry.TxType}
+		p.batches[partition] = current
+	}
+	current.rows = append(current.rows, r)
+	current.records = append(current.records, entry)
+	p.buffered++
+	if len(current.rows) >= p.config.RowGroupSize {
+		delete(p.batches, partition)
+		p.full = append(p.full, current)
+		select {
+		case p.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+	p.mutex.Unlock()
+
+	metricsRegistry.rowCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+}
+
+// Close writes the buffered records and stops the flush schedule
+func (p *Producer) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.stopChan)
+	p.mutex.Unlock()
+	p.wg.Wait()
+
+	return p.Flush()
+}
+
+// ProcessReliableAck sends to ackChan if reliable ack is configured
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	_, ok := p.reliableAckTxTypes[entry.TxType]
+	if ok {
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	}
+}
+
+// ReportError to airbrake and logger
+func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo) {
+	p.airbrakeHandler.ReportLogMessage(logrus.ERROR, message, err, logInfo)
+	p.logger.ErrorLog(message, err, logInfo)
+}
+
+// Flush writes a file per partition of the buffered records, and acks them. It runs every FlushIntervalMs.
+func (p *Producer) Flush() error {
+	return p.flush(true)
+}
+
+// flush writes the partitions which hold a row group, and the other ones too when all is set
+func (p *Producer) flush(all bool) error {
+	p.flushMutex.Lock()
+	defer p.flushMutex.Unlock()
+
+	p.mutex.Lock()
+	batches := p.full
+	p.full = nil
+	if all {
+		for _, current := range p.batches {
+			batches = append(batches, current)
+		}
+		p.batches = make(map[string]*batch)
+	}
+	p.mutex.Unlock()
+
+	var errs []error
+	for _, current := range batches {
+		err := p.write(current)
+		p.mutex.Lock()
+		p.buffered -= len(current.rows)
+		p.mutex.Unlock()
+		for _, entry := range current.records {
+			if err == nil {
+				p.ProcessReliableAck(entry)
+			}
+			p.NotifyDelivery(entry, err)
+		}
+		if err != nil {
+			metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+			errs = append(errs, fmt.Errorf("partition %s: %w", current.partition, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Producer) runFlush() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(time.Duration(p.config.FlushIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-p.flushSignal:
+			if err := p.flush(false); err != nil {
+				p.ReportError("parquet_flush_error", err, nil)
+			}
+		case <-ticker.C:
+			if err := p.Flush(); err != nil {
+				p.ReportError("parquet_flush_error", err, nil)
+			}
+		}
+	}
+}
+
+// write encodes the rows of a batch as a file of its partition
+func (p *Producer) write(current *batch) error {
+	data, err := encodeFile(inferColumns(current.rows), current.rows, p.config.RowGroupSize, p.codec)
+	if err != nil {
+		return err
+	}
+
+	// file names sort in creation order, even when two files are created within the same nanosecond
+	name := max(time.Now().UnixNano(), p.lastName+1)
+	p.lastName = name
+	if err := p.store.put(path.Join(current.partition, fmt.Sprintf("%020d%s", name, fileExtension)), data); err != nil {
+		return err
+	}
+	metricsRegistry.fileCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": current.txType})
+	metricsRegistry.bytesTotal.Add(int64(len(data)), map[string]string{"dispatcher": string(p.dispatcher), "record_type": current.txType})
+	return nil
+}
+
+// partition returns the path of the partition of a record: <record_type>/date=<YYYY-MM-DD>/hour=<HH>, followed by
+// /vin=<vin> when partitioned by vin
+func (p *Producer) partition(entry *telemetry.Record, receivedAt time.Time) string {
+	receivedAt = receivedAt.UTC()
+	partition := path.Join(entry.TxType, "date="+receivedAt.Format("2006-01-02"), "hour="+receivedAt.Format("15"))
+	if p.config.PartitionByVin {
+		partition = path.Join(partition, "vin="+entry.Vin)
+	}
+	return partition
+}
+
+// dirStore writes the files to a local directory
+type dirStore struct {
+	dir string
+}
+
+func newDirStore(dir string) (*dirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirStore{dir: dir}, nil
+}
+
+// put writes a temporary file and renames it once synced, so that complete files only are visible
+func (s *dirStore) put(key string, data []byte) error {
+	target := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	temporary := target + ".tmp"
+	file, err := os.OpenFile(temporary, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(temporary)
+		return err
+	}
+	return os.Rename(temporary, target)
+}
+
+// s3Store uploads the files to a bucket
+type s3Store struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+// newS3Store connects to the bucket and tests the connection
+func newS3Store(config *S3Config) (*s3Store, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("parquet s3 bucket is required")
+	}
+	region := config.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	awsConfig := &aws.Config{
+		Region:                        aws.String(region),
+		S3ForcePathStyle:              aws.Bool(config.ForcePathStyle),
+		CredentialsChainVerboseErrors: aws.Bool(true),
+	}
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.New(sess, awsConfig)
+	if _, err := client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(config.Bucket)}); err != nil {
+		return nil, fmt.Errorf("failed to find bucket %s (test connection): %v", config.Bucket, err)
+	}
+	return &s3Store{client: client, bucket: config.Bucket, prefix: config.Prefix}, nil
+}
+
+func (s *s3Store) put(key string, data []byte) error {
+	_, err := s.client.PutObjectWithContext(context.Background(), &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(path.Join(s.prefix, key)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/vnd.apache.parquet"),
+	})
+	return err
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.rowCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "parquet_row_total",
+		Help:   "The number of records buffered for parquet files.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.fileCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "parquet_file_total",
+		Help:   "The number of parquet files written.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.bytesTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "parquet_file_total_bytes",
+		Help:   "The number of bytes of the parquet files written.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "parquet_err",
+		Help:   "The number of parquet files which could not be written.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.bufferFullCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "parquet_buffer_full_total",
+		Help:   "The number of records failed because the parquet buffer was full.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "parquet_reliable_ack_total",
+		Help:   "The number of records written to parquet files for which we sent a reliable ACK.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+}
@@ -0,0 +1,13 @@
+package parquet
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestParquet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Parquet Suite")
+}
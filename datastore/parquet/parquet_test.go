@@ -0,0 +1,525 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Parquet producer", func() {
+	var (
+		logger     *logrus.Logger
+		serializer *telemetry.BinarySerializer
+		dir        string
+		ackChan    chan *telemetry.Ack
+		receivedAt time.Time
+		createdAt  time.Time
+	)
+
+	newRecord := func(txType string, vin string, txid string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte(txid), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		record.ReceivedTimestamp = receivedAt.UnixMilli()
+		return record
+	}
+
+	newPayloadRecord := func(txid string, data ...*protos.Datum) *telemetry.Record {
+		return newRecord("V", "42", txid, &protos.Payload{Vin: "42", CreatedAt: timestamppb.New(createdAt), Data: data})
+	}
+
+	newProducer := func(config *Config) *Producer {
+		if config.S3 == nil {
+			config.Dir = dir
+		}
+		config.FlushIntervalMs = 60000
+		producer, err := NewProducer(config, telemetry.Parquet, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, map[string]interface{}{"V": true}, logger)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(producer.Close)
+		return producer.(*Producer)
+	}
+
+	partitionFiles := func(partition string) []*parquetFile {
+		paths, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(partition), "*.parquet"))
+		Expect(err).NotTo(HaveOccurred())
+		var files []*parquetFile
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			file, err := readFile(data)
+			Expect(err).NotTo(HaveOccurred())
+			files = append(files, file)
+		}
+		return files
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		serializer = telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{}, logger)
+		dir = GinkgoT().TempDir()
+		ackChan = make(chan *telemetry.Ack, 10)
+		receivedAt = time.Date(2024, 5, 1, 13, 30, 0, 0, time.UTC)
+		createdAt = receivedAt.Add(-time.Second)
+	})
+
+	It("writes a column per field with the type it was sent with", func() {
+		producer := newProducer(&Config{})
+		producer.Produce(newPayloadRecord("1",
+			&protos.Datum{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 80.5}}},
+			&protos.Datum{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: protos.ShiftState_ShiftStateD}}},
+			&protos.Datum{Key: protos.Field_Location, Value: &protos.Value{Value: &protos.Value_LocationValue{LocationValue: &protos.LocationValue{Latitude: 37.5, Longitude: -122.25}}}},
+			&protos.Datum{Key: protos.Field_Odometer, Value: &protos.Value{Value: &protos.Value_Invalid{Invalid: true}}},
+		))
+		producer.Produce(newPayloadRecord("2",
+			&protos.Datum{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_IntValue{IntValue: 65}}},
+			&protos.Datum{Key: protos.Field_Locked, Value: &protos.Value{Value: &protos.Value_BooleanValue{BooleanValue: true}}},
+		))
+		Expect(producer.Flush()).To(Succeed())
+
+		files := partitionFiles("V/date=2024-05-01/hour=13")
+		Expect(files).To(HaveLen(1))
+		file := files[0]
+		Expect(file.numRows).To(Equal(int64(2)))
+		Expect(file.rowGroups).To(Equal(1))
+		Expect(file.names).To(Equal([]string{"vin", "txid", "created_at", "received_at", "is_resend", "VehicleSpeed", "Soc", "Gear", "Location.latitude", "Location.longitude", "Locked"}))
+
+		Expect(file.columns["vin"]).To(Equal([]interface{}{"42", "42"}))
+		Expect(file.columns["txid"]).To(Equal([]interface{}{"1", "2"}))
+		Expect(file.columns["created_at"]).To(Equal([]interface{}{createdAt.UnixMilli(), createdAt.UnixMilli()}))
+		Expect(file.columns["received_at"]).To(Equal([]interface{}{receivedAt.UnixMilli(), receivedAt.UnixMilli()}))
+		Expect(file.columns["is_resend"]).To(Equal([]interface{}{false, false}))
+		Expect(file.columns["Soc"]).To(Equal([]interface{}{80.5, nil}))
+		Expect(file.columns["Gear"]).To(Equal([]interface{}{"ShiftStateD", nil}))
+		Expect(file.columns["Location.latitude"]).To(Equal([]interface{}{37.5, nil}))
+		Expect(file.columns["Location.longitude"]).To(Equal([]interface{}{-122.25, nil}))
+		Expect(file.columns["VehicleSpeed"]).To(Equal([]interface{}{nil, int64(65)}))
+		Expect(file.columns["Locked"]).To(Equal([]interface{}{nil, true}))
+
+		Expect(file.types["Soc"]).To(Equal([2]int64{int64(typeDouble), int64(convertedNone)}))
+		Expect(file.types["Gear"]).To(Equal([2]int64{int64(typeByteArray), int64(convertedUTF8)}))
+		Expect(file.types["VehicleSpeed"]).To(Equal([2]int64{int64(typeInt64), int64(convertedNone)}))
+		Expect(file.types["received_at"]).To(Equal([2]int64{int64(typeInt64), int64(convertedTimestampMillis)}))
+	})
+
+	It("acks records once their file is written", func() {
+		producer := newProducer(&Config{})
+		record := newPayloadRecord("1")
+		producer.Produce(record)
+		Consistently(ackChan).ShouldNot(Receive())
+
+		Expect(producer.Flush()).To(Succeed())
+		Expect(ackChan).To(Receive(Equal(&telemetry.Ack{Record: record, Dispatcher: telemetry.Parquet})))
+
+		Expect(producer.Flush()).To(Succeed())
+		Consistently(ackChan).ShouldNot(Receive())
+	})
+
+	It("writes the buffered records on close", func() {
+		producer := newProducer(&Config{Compression: CompressionNone})
+		producer.Produce(newPayloadRecord("1"))
+		Expect(producer.Close()).To(Succeed())
+
+		Expect(partitionFiles("V/date=2024-05-01/hour=13")).To(HaveLen(1))
+		Expect(ackChan).To(Receive())
+
+		delivered := make(chan error, 1)
+		producer.SetDeliveryHandler(func(_ *telemetry.Record, err error) { delivered <- err })
+		producer.Produce(newPayloadRecord("2"))
+		Expect(delivered).To(Receive(MatchError("parquet producer closed")))
+	})
+
+	It("writes a partition once it buffers row_group_size rows", func() {
+		producer := newProducer(&Config{RowGroupSize: 2})
+		for _, txid := range []string{"1", "2", "3", "4", "5"} {
+			producer.Produce(newPayloadRecord(txid, &protos.Datum{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 50}}}))
+		}
+		Eventually(func() []*parquetFile { return partitionFiles("V/date=2024-05-01/hour=13") }).Should(HaveLen(2))
+		Expect(producer.Flush()).To(Succeed())
+
+		var txids []interface{}
+		for _, file := range partitionFiles("V/date=2024-05-01/hour=13") {
+			Expect(file.rowGroups).To(Equal(1))
+			txids = append(txids, file.columns["txid"]...)
+		}
+		Expect(txids).To(Equal([]interface{}{"1", "2", "3", "4", "5"}))
+	})
+
+	It("fails records while max_buffered_rows are waiting to be written", func() {
+		producer := newProducer(&Config{RowGroupSize: 2, MaxBufferedRows: 2})
+		store := &blockingStore{store: producer.store, release: make(chan struct{})}
+		producer.store = store
+		delivered := make(chan error, 1)
+		producer.SetDeliveryHandler(func(_ *telemetry.Record, err error) { delivered <- err })
+
+		producer.Produce(newPayloadRecord("1"))
+		producer.Produce(newPayloadRecord("2"))
+		producer.Produce(newPayloadRecord("3"))
+		Expect(delivered).To(Receive(MatchError("parquet buffer full")))
+
+		close(store.release)
+		Eventually(ackChan).Should(Receive())
+		Eventually(ackChan).Should(Receive())
+		producer.Produce(newPayloadRecord("4"))
+		Expect(producer.Flush()).To(Succeed())
+		Expect(ackChan).To(Receive())
+	})
+
+	It("partitions the records by hour and vin", func() {
+		producer := newProducer(&Config{PartitionByVin: true})
+		producer.Produce(newPayloadRecord("1"))
+		receivedAt = receivedAt.Add(time.Hour)
+		producer.Produce(newPayloadRecord("2"))
+		Expect(producer.Flush()).To(Succeed())
+
+		Expect(partitionFiles("V/date=2024-05-01/hour=13/vin=42")).To(HaveLen(1))
+		Expect(partitionFiles("V/date=2024-05-01/hour=14/vin=42")).To(HaveLen(1))
+	})
+
+	It("rejects records without a payload", func() {
+		producer := newProducer(&Config{})
+		delivered := make(chan error, 1)
+		producer.SetDeliveryHandler(func(_ *telemetry.Record, err error) { delivered <- err })
+
+		producer.Produce(newRecord("alerts", "42", "1", &protos.VehicleAlerts{Vin: "42"}))
+		Expect(delivered).To(Receive(MatchError(telemetry.ErrRecordRejected)))
+	})
+
+	It("uploads the files to an S3-compatible endpoint", func() {
+		var (
+			mu      sync.Mutex
+			objects = make(map[string][]byte)
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				objects[r.URL.Path] = body
+				mu.Unlock()
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(server.Close)
+		for key, value := range map[string]string{"AWS_ACCESS_KEY_ID": "minio", "AWS_SECRET_ACCESS_KEY": "minio123"} {
+			GinkgoT().Setenv(key, value)
+		}
+
+		producer := newProducer(&Config{S3: &S3Config{Bucket: "telemetry", Prefix: "lake", Endpoint: server.URL, ForcePathStyle: true}})
+		producer.Produce(newPayloadRecord("1"))
+		Expect(producer.Flush()).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(objects).To(HaveLen(1))
+		for key, body := range objects {
+			Expect(key).To(HavePrefix("/telemetry/lake/V/date=2024-05-01/hour=13/"))
+			Expect(key).To(HaveSuffix(".parquet"))
+			file, err := readFile(body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.columns["txid"]).To(Equal([]interface{}{"1"}))
+		}
+	})
+
+	It("encodes the file read by another parquet implementation", func() {
+		// testdata/interop.parquet was read back with github.com/xitongsys/parquet-go, every column matching the rows
+		rows := []*row{
+			newRow("42", "1", receivedAt, &protos.Payload{CreatedAt: timestamppb.New(createdAt), Data: []*protos.Datum{
+				{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 80.5}}},
+				{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: protos.ShiftState_ShiftStateD}}},
+				{Key: protos.Field_Location, Value: &protos.Value{Value: &protos.Value_LocationValue{LocationValue: &protos.LocationValue{Latitude: 37.5, Longitude: -122.25}}}},
+			}}),
+			newRow("42", "2", receivedAt, &protos.Payload{CreatedAt: timestamppb.New(createdAt), IsResend: true, Data: []*protos.Datum{
+				{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_IntValue{IntValue: 65}}},
+				{Key: protos.Field_Locked, Value: &protos.Value{Value: &protos.Value_BooleanValue{BooleanValue: true}}},
+			}}),
+			newRow("43", "3", receivedAt, &protos.Payload{Data: []*protos.Datum{
+				{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 79}}},
+				{Key: protos.Field_Locked, Value: &protos.Value{Value: &protos.Value_BooleanValue{BooleanValue: false}}},
+			}}),
+		}
+		data, err := encodeFile(inferColumns(rows), rows, 2, codecUncompressed)
+		Expect(err).NotTo(HaveOccurred())
+
+		expected, err := os.ReadFile(filepath.Join("testdata", "interop.parquet"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(expected))
+	})
+
+	DescribeTable("rejects invalid configs",
+		func(config *Config, expectedErr string) {
+			_, err := NewProducer(config, telemetry.Parquet, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, nil, logger)
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("without dir or s3", &Config{}, "parquet requires either a dir or s3"),
+		Entry("with both dir and s3", &Config{Dir: os.TempDir(), S3: &S3Config{Bucket: "telemetry"}}, "parquet requires either a dir or s3"),
+		Entry("without bucket", &Config{S3: &S3Config{}}, "parquet s3 bucket is required"),
+		Entry("with an unknown compression", &Config{Dir: os.TempDir(), Compression: "snappy"}, "unsupported parquet compression: snappy"),
+	)
+})
+
+var _ = Describe("Parquet columns", func() {
+	It("widens numbers and falls back to strings for fields sent with several types", func() {
+		rows := []*row{
+			{values: map[protos.Field]cell{protos.Field_Soc: {kind: kindLong, l: 80}, protos.Field_Gear: {kind: kindString, s: "D"}}},
+			{values: map[protos.Field]cell{protos.Field_Soc: {kind: kindDouble, d: 80.5}, protos.Field_Gear: {kind: kindBool, b: true}}},
+		}
+		columns := inferColumns(rows)
+		Expect(columns).To(HaveLen(7))
+
+		soc, gear := columns[5], columns[6]
+		Expect(soc.name).To(Equal("Soc"))
+		Expect(soc.kind).To(Equal(kindDouble))
+		value, ok := soc.value(rows[0])
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal(cell{kind: kindDouble, d: 80}))
+		Expect(gear.name).To(Equal("Gear"))
+		Expect(gear.kind).To(Equal(kindString))
+		value, ok = gear.value(rows[1])
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal(cell{kind: kindString, s: "true"}))
+	})
+})
+
+// blockingStore holds the files until released
+type blockingStore struct {
+	store   store
+	release chan struct{}
+}
+
+func (s *blockingStore) put(key string, data []byte) error {
+	<-s.release
+	return s.store.put(key, data)
+}
+
+// thriftReader decodes thrift compact structs into maps of field ids to values
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) zigzag() int64 {
+	value := r.uvarint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		r.pos++
+		return int64(r.data[r.pos-1])
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos-8:]))
+	case 8:
+		size := int(r.uvarint())
+		r.pos += size
+		return string(r.data[r.pos-size : r.pos])
+	case 9, 10:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", fieldType))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+// parquetFile is a decoded parquet file
+type parquetFile struct {
+	numRows   int64
+	rowGroups int
+	// names are the dotted paths of the leaves, in schema order
+	names []string
+	// columns are the values of each leaf, nulls being nil
+	columns map[string][]interface{}
+	// types are the physical and converted types of each leaf
+	types map[string][2]int64
+}
+
+// readFile decodes the columns of a parquet file, written with a single data page per column chunk
+func readFile(data []byte) (*parquetFile, error) {
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		return nil, errors.New("not a parquet file")
+	}
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerSize : len(data)-8]}
+	metadata := footer.readStruct()
+	if footer.pos != footerSize {
+		return nil, fmt.Errorf("footer decoded %d of %d bytes", footer.pos, footerSize)
+	}
+
+	// max definition level of each leaf, from the repetition of the leaf and its group
+	maxDefs := make(map[string]int)
+	file := &parquetFile{columns: make(map[string][]interface{}), types: make(map[string][2]int64)}
+	schema := metadata[2].([]interface{})
+	var walk func(index int, prefix string, def int) int
+	walk = func(index int, prefix string, def int) int {
+		element := schema[index].(map[int16]interface{})
+		name := prefix + element[4].(string)
+		if element[3] == int64(1) {
+			def++
+		}
+		children, ok := element[5].(int64)
+		if !ok {
+			converted, ok := element[6].(int64)
+			if !ok {
+				converted = -1
+			}
+			maxDefs[name] = def
+			file.names = append(file.names, name)
+			file.types[name] = [2]int64{element[1].(int64), converted}
+			return index + 1
+		}
+		next := index + 1
+		for i := 0; i < int(children); i++ {
+			next = walk(next, name+".", def)
+		}
+		return next
+	}
+	root := schema[0].(map[int16]interface{})
+	next := 1
+	for i := 0; i < int(root[5].(int64)); i++ {
+		next = walk(next, "", 0)
+	}
+
+	columns := file.columns
+	for _, rowGroup := range metadata[4].([]interface{}) {
+		file.rowGroups++
+		for _, columnChunk := range rowGroup.(map[int16]interface{})[1].([]interface{}) {
+			meta := columnChunk.(map[int16]interface{})[3].(map[int16]interface{})
+			var path []string
+			for _, name := range meta[3].([]interface{}) {
+				path = append(path, name.(string))
+			}
+			name := strings.Join(path, ".")
+			offset := int(meta[9].(int64))
+			header := &thriftReader{data: data[offset:]}
+			pageHeader := header.readStruct()
+			compressedSize := int(pageHeader[3].(int64))
+			page := data[offset+header.pos : offset+header.pos+compressedSize]
+			if meta[4] == int64(2) {
+				reader, err := gzip.NewReader(bytes.NewReader(page))
+				if err != nil {
+					return nil, err
+				}
+				if page, err = io.ReadAll(reader); err != nil {
+					return nil, err
+				}
+			}
+			numValues := int(pageHeader[5].(map[int16]interface{})[1].(int64))
+
+			defined := make([]bool, numValues)
+			for i := range defined {
+				defined[i] = true
+			}
+			if maxDefs[name] > 0 {
+				size := int(binary.LittleEndian.Uint32(page))
+				levels := &thriftReader{data: page[4 : 4+size]}
+				for i := 0; i < numValues; {
+					run := levels.uvarint()
+					if run&1 == 1 {
+						return nil, errors.New("bit-packed levels are not supported")
+					}
+					level := levels.data[levels.pos]
+					levels.pos++
+					for j := 0; j < int(run>>1); j++ {
+						defined[i] = level == 1
+						i++
+					}
+				}
+				page = page[4+size:]
+			}
+
+			physical := meta[1].(int64)
+			bit := 0
+			for _, isDefined := range defined {
+				if !isDefined {
+					columns[name] = append(columns[name], nil)
+					continue
+				}
+				switch physical {
+				case 0:
+					columns[name] = append(columns[name], page[bit/8]&(1<<(bit%8)) != 0)
+					bit++
+				case 2:
+					columns[name] = append(columns[name], int64(binary.LittleEndian.Uint64(page)))
+					page = page[8:]
+				case 5:
+					columns[name] = append(columns[name], math.Float64frombits(binary.LittleEndian.Uint64(page)))
+					page = page[8:]
+				case 6:
+					size := int(binary.LittleEndian.Uint32(page))
+					columns[name] = append(columns[name], string(page[4:4+size]))
+					page = page[4+size:]
+				}
+			}
+		}
+	}
+	file.numRows = metadata[3].(int64)
+	return file, nil
+}
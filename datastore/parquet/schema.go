@@ -0,0 +1,286 @@
+package parquet
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// Each file holds a row per V record: its vin, txid, created_at, received_at and is_resend, then one optional
+// column per protos.Field present in the file, named after the field. The type of a column is the one the vehicle
+// sent the field with: strings, longs for int and long values, doubles for float and double values, booleans, and
+// a group of latitude and longitude for locations. Enums are written as their name and other messages as JSON.
+// A field sent with several types in a file is a double column when they are all numbers, a string column otherwise.
+
+var payloadValueOneof = (&protos.Value{}).ProtoReflect().Descriptor().Oneofs().ByName("value")
+
+// kind is the type of a column
+type kind int
+
+const (
+	kindString kind = iota
+	kindLong
+	kindDouble
+	kindBool
+	kindTimestamp
+	kindLocation
+)
+
+// cell is a value of a column
+type cell struct {
+	kind     kind
+	s        string
+	l        int64
+	d        float64
+	b        bool
+	location *protos.LocationValue
+}
+
+// row is the decoded payload of a record
+type row struct {
+	vin        string
+	txid       string
+	createdAt  *time.Time
+	receivedAt time.Time
+	isResend   bool
+	values     map[protos.Field]cell
+}
+
+// column is a top level column of the schema
+type column struct {
+	name     string
+	kind     kind
+	optional bool
+	value    func(*row) (cell, bool)
+}
+
+// schemaElement is a node of the schema, groups having children
+type schemaElement struct {
+	name       string
+	physical   int32
+	converted  int32
+	repetition int32
+	children   int
+}
+
+// newRow decodes the values of a payload
+func newRow(vin string, txid string, receivedAt time.Time, payload *protos.Payload) *row {
+	r := &row{
+		vin:        vin,
+		txid:       txid,
+		receivedAt: receivedAt,
+		isResend:   payload.GetIsResend(),
+		values:     make(map[protos.Field]cell, len(payload.GetData())),
+	}
+	if payload.GetCreatedAt() != nil {
+		createdAt := payload.GetCreatedAt().AsTime()
+		r.createdAt = &createdAt
+	}
+	for _, datum := range payload.GetData() {
+		if value, ok := cellOf(datum.GetValue()); ok {
+			r.values[datum.GetKey()] = value
+		}
+	}
+	return r
+}
+
+// cellOf returns the value of the oneof of a Value, false when it is unset or invalid
+func cellOf(value *protos.Value) (cell, bool) {
+	if value == nil {
+		return cell{}, false
+	}
+	message := value.ProtoReflect()
+	fd := message.WhichOneof(payloadValueOneof)
+	if fd == nil {
+		return cell{}, false
+	}
+	v := message.Get(fd)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return cell{kind: kindString, s: v.String()}, true
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		return cell{kind: kindLong, l: v.Int()}, true
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return cell{kind: kindDouble, d: v.Float()}, true
+	case protoreflect.BoolKind:
+		if fd.Name() == "invalid" {
+			return cell{}, false
+		}
+		return cell{kind: kindBool, b: v.Bool()}, true
+	case protoreflect.EnumKind:
+		return cell{kind: kindString, s: enumValueName(fd, v.Enum())}, true
+	case protoreflect.MessageKind:
+		if location, ok := v.Message().Interface().(*protos.LocationValue); ok {
+			return cell{kind: kindLocation, location: location}, true
+		}
+		data, err := protojson.Marshal(v.Message().Interface())
+		if err != nil {
+			return cell{}, false
+		}
+		return cell{kind: kindString, s: string(data)}, true
+	}
+	return cell{}, false
+}
+
+func enumValueName(fd protoreflect.FieldDescriptor, number protoreflect.EnumNumber) string {
+	if value := fd.Enum().Values().ByNumber(number); value != nil {
+		return string(value.Name())
+	}
+	return strconv.Itoa(int(number))
+}
+
+// as converts a cell to the kind of its column
+func (c cell) as(k kind) cell {
+	if c.kind == k {
+		return c
+	}
+	switch k {
+	case kindDouble:
+		return cell{kind: kindDouble, d: float64(c.l)}
+	case kindString:
+		return cell{kind: kindString, s: c.String()}
+	}
+	return c
+}
+
+// String formats the value of a cell
+func (c cell) String() string {
+	switch c.kind {
+	case kindLong:
+		return strconv.FormatInt(c.l, 10)
+	case kindDouble:
+		return strconv.FormatFloat(c.d, 'g', -1, 64)
+	case kindBool:
+		return strconv.FormatBool(c.b)
+	case kindLocation:
+		data, _ := protojson.Marshal(c.location)
+		return string(data)
+	}
+	return c.s
+}
+
+// mergeKinds returns the kind of a column holding values of two kinds
+func mergeKinds(a, b kind) kind {
+	switch {
+	case a == b:
+		return a
+	case (a == kindLong || a == kindDouble) && (b == kindLong || b == kindDouble):
+		return kindDouble
+	default:
+		return kindString
+	}
+}
+
+// inferColumns returns the columns of the rows of a file
+func inferColumns(rows []*row) []*column {
+	columns := []*column{
+		{name: "vin", kind: kindString, value: func(r *row) (cell, bool) { return cell{kind: kindString, s: r.vin}, true }},
+		{name: "txid", kind: kindString, value: func(r *row) (cell, bool) { return cell{kind: kindString, s: r.txid}, true }},
+		{name: "created_at", kind: kindTimestamp, optional: true, value: func(r *row) (cell, bool) {
+			if r.createdAt == nil {
+				return cell{}, false
+			}
+			return cell{kind: kindTimestamp, l: r.createdAt.UnixMilli()}, true
+		}},
+		{name: "received_at", kind: kindTimestamp, value: func(r *row) (cell, bool) {
+			return cell{kind: kindTimestamp, l: r.receivedAt.UnixMilli()}, true
+		}},
+		{name: "is_resend", kind: kindBool, value: func(r *row) (cell, bool) { return cell{kind: kindBool, b: r.isResend}, true }},
+	}
+
+	kinds := make(map[protos.Field]kind)
+	for _, r := range rows {
+		for field, value := range r.values {
+			if existing, ok := kinds[field]; ok {
+				kinds[field] = mergeKinds(existing, value.kind)
+			} else {
+				kinds[field] = value.kind
+			}
+		}
+	}
+	fields := make([]protos.Field, 0, len(kinds))
+	for field := range kinds {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i] < fields[j] })
+
+	for _, field := range fields {
+		columnKind := kinds[field]
+		columns = append(columns, &column{
+			name:     field.String(),
+			kind:     columnKind,
+			optional: true,
+			value: func(r *row) (cell, bool) {
+				value, ok := r.values[field]
+				if !ok {
+					return cell{}, false
+				}
+				return value.as(columnKind), true
+			},
+		})
+	}
+	return columns
+}
+
+// leaves returns the primitive columns of a column, the latitude and longitude of a location
+func (c *column) leaves() []*leaf {
+	maxDef := 0
+	if c.optional {
+		maxDef = 1
+	}
+	if c.kind != kindLocation {
+		physical, _ := c.kind.types()
+		return []*leaf{{path: []string{c.name}, physical: physical, maxDef: maxDef, value: c.value}}
+	}
+	coordinate := func(get func(*protos.LocationValue) float64) func(*row) (cell, bool) {
+		return func(r *row) (cell, bool) {
+			value, ok := c.value(r)
+			if !ok || value.kind != kindLocation {
+				return cell{}, false
+			}
+			return cell{kind: kindDouble, d: get(value.location)}, true
+		}
+	}
+	return []*leaf{
+		{path: []string{c.name, "latitude"}, physical: typeDouble, maxDef: maxDef, value: coordinate((*protos.LocationValue).GetLatitude)},
+		{path: []string{c.name, "longitude"}, physical: typeDouble, maxDef: maxDef, value: coordinate((*protos.LocationValue).GetLongitude)},
+	}
+}
+
+// schema returns the schema elements of a column, depth first
+func (c *column) schema() []schemaElement {
+	repetition := repetitionRequired
+	if c.optional {
+		repetition = repetitionOptional
+	}
+	if c.kind != kindLocation {
+		physical, converted := c.kind.types()
+		return []schemaElement{{name: c.name, physical: physical, converted: converted, repetition: repetition}}
+	}
+	return []schemaElement{
+		{name: c.name, converted: convertedNone, repetition: repetition, children: 2},
+		{name: "latitude", physical: typeDouble, converted: convertedNone, repetition: repetitionRequired},
+		{name: "longitude", physical: typeDouble, converted: convertedNone, repetition: repetitionRequired},
+	}
+}
+
+// types returns the physical and converted types of a kind of column
+func (k kind) types() (int32, int32) {
+	switch k {
+	case kindLong:
+		return typeInt64, convertedNone
+	case kindDouble:
+		return typeDouble, convertedNone
+	case kindBool:
+		return typeBoolean, convertedNone
+	case kindTimestamp:
+		return typeInt64, convertedTimestampMillis
+	default:
+		return typeByteArray, convertedUTF8
+	}
+}
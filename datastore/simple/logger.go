@@ -7,6 +7,7 @@ import (
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/protos"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 )
 
 // Config for the protobuf logger
@@ -17,13 +18,18 @@ type Config struct {
 
 // Producer is a simple protobuf logger
 type Producer struct {
-	Config *Config
-	logger *logrus.Logger
+	Config             *Config
+	unitConverter      *units.Converter
+	ackChan            chan (*telemetry.Ack)
+	reliableAckTxTypes map[string]interface{}
+	logger             *logrus.Logger
 }
 
-// NewProtoLogger initializes the parameters for protobuf payload logging
-func NewProtoLogger(config *Config, logger *logrus.Logger) telemetry.Producer {
-	return &Producer{Config: config, logger: logger}
+// NewProtoLogger initializes the parameters for protobuf payload logging. The unit converter, if any, annotates the
+// fields of V records with their unit when verbose or when its config requests it. Records of reliableAckTxTypes are
+// acked once logged.
+func NewProtoLogger(config *Config, unitConverter *units.Converter, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) telemetry.Producer {
+	return &Producer{Config: config, unitConverter: unitConverter, ackChan: ackChan, reliableAckTxTypes: reliableAckTxTypes, logger: logger}
 }
 
 // Close the producer
@@ -31,11 +37,15 @@ func (p *Producer) Close() error {
 	return nil
 }
 
-// ProcessReliableAck noop method
-func (p *Producer) ProcessReliableAck(_ *telemetry.Record) {
+// ProcessReliableAck sends to ackChan if reliable ack is configured
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	_, ok := p.reliableAckTxTypes[entry.TxType]
+	if ok {
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: telemetry.Logger}
+	}
 }
 
-// Produce sends the data to the logger
+// Produce sends the data to the logger, and acks the record once logged
 func (p *Producer) Produce(entry *telemetry.Record) {
 	data, err := p.recordToLogMap(entry, entry.Vin)
 	if err != nil {
@@ -43,6 +53,7 @@ func (p *Producer) Produce(entry *telemetry.Record) {
 		return
 	}
 	p.logger.ActivityLog("record_payload", logrus.LogInfo{"vin": entry.Vin, "metadata": entry.Metadata(), "data": data})
+	p.ProcessReliableAck(entry)
 }
 
 // ReportError noop method
@@ -51,24 +62,16 @@ func (p *Producer) ReportError(_ string, _ error, _ logrus.LogInfo) {
 
 // recordToLogMap converts the data of a record to a map or slice of maps
 func (p *Producer) recordToLogMap(record *telemetry.Record, vin string) (interface{}, error) {
-	switch payload := record.GetProtoMessage().(type) {
-	case *protos.Payload:
-		return transformers.PayloadToMap(payload, p.Config.Verbose, vin, p.logger), nil
-	case *protos.VehicleAlerts:
-		alertMaps := make([]map[string]interface{}, len(payload.Alerts))
-		for i, alert := range payload.Alerts {
-			alertMaps[i] = transformers.VehicleAlertToMap(alert)
-		}
-		return alertMaps, nil
-	case *protos.VehicleErrors:
-		errorMaps := make([]map[string]interface{}, len(payload.Errors))
-		for i, vehicleError := range payload.Errors {
-			errorMaps[i] = transformers.VehicleErrorToMap(vehicleError)
-		}
-		return errorMaps, nil
-	case *protos.VehicleConnectivity:
-		return transformers.VehicleConnectivityToMap(payload), nil
-	default:
+	data, ok := transformers.MessageToMap(record.GetProtoMessage(), p.Config.Verbose, vin, p.logger)
+	if !ok {
 		return nil, fmt.Errorf("unknown txType: %s", record.TxType)
 	}
+	if payload, ok := record.GetProtoMessage().(*protos.Payload); ok && p.annotateUnits() {
+		transformers.AnnotateUnits(data.(map[string]interface{}), payload, p.unitConverter)
+	}
+	return data, nil
+}
+
+func (p *Producer) annotateUnits() bool {
+	return p.unitConverter != nil && (p.Config.Verbose || p.unitConverter.Annotate())
 }
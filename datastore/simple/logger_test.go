@@ -11,6 +11,7 @@ import (
 	"github.com/teslamotors/fleet-telemetry/messages"
 	"github.com/teslamotors/fleet-telemetry/protos"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 
 	"github.com/sirupsen/logrus/hooks/test"
 	"google.golang.org/protobuf/proto"
@@ -28,7 +29,7 @@ var _ = Describe("ProtoLogger", func() {
 	BeforeEach(func() {
 		testLogger, hook = logrus.NoOpLogger()
 		config = &simple.Config{Verbose: false}
-		protoLogger = simple.NewProtoLogger(config, testLogger).(*simple.Producer)
+		protoLogger = simple.NewProtoLogger(config, nil, nil, nil, testLogger).(*simple.Producer)
 	})
 
 	Describe("NewProtoLogger", func() {
@@ -39,10 +40,21 @@ var _ = Describe("ProtoLogger", func() {
 	})
 
 	Describe("ProcessReliableAck", func() {
-		It("does not panic", func() {
+		It("does not ack without reliable ack", func() {
 			entry := &telemetry.Record{}
 			Expect(func() { protoLogger.ProcessReliableAck(entry) }).NotTo(Panic())
 		})
+
+		It("acks the records configured for reliable ack", func() {
+			ackChan := make(chan *telemetry.Ack, 1)
+			protoLogger = simple.NewProtoLogger(config, nil, ackChan, map[string]interface{}{"V": true}, testLogger).(*simple.Producer)
+			entry := &telemetry.Record{TxType: "V"}
+			protoLogger.ProcessReliableAck(entry)
+			Expect(ackChan).To(Receive(Equal(&telemetry.Ack{Record: entry, Dispatcher: telemetry.Logger})))
+
+			protoLogger.ProcessReliableAck(&telemetry.Record{TxType: "alerts"})
+			Expect(ackChan).NotTo(Receive())
+		})
 	})
 
 	Describe("Produce", func() {
@@ -114,10 +126,21 @@ var _ = Describe("ProtoLogger", func() {
 			Entry("decoded record", false),
 		)
 
+		It("acks the record once logged", func() {
+			ackChan := make(chan *telemetry.Ack, 1)
+			protoLogger = simple.NewProtoLogger(config, nil, ackChan, map[string]interface{}{"V": true}, testLogger).(*simple.Producer)
+			record, err := telemetry.NewRecord(serializer, streamMessageBytes, "1", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			protoLogger.Produce(record)
+			Expect(hook.LastEntry().Message).To(Equal("record_payload"))
+			Expect(ackChan).To(Receive(Equal(&telemetry.Ack{Record: record, Dispatcher: telemetry.Logger})))
+		})
+
 		Context("when verbose set to true", func() {
 			BeforeEach(func() {
 				config.Verbose = true
-				protoLogger = simple.NewProtoLogger(config, testLogger).(*simple.Producer)
+				protoLogger = simple.NewProtoLogger(config, nil, nil, nil, testLogger).(*simple.Producer)
 			})
 
 			It("does not include types in the data", func() {
@@ -140,6 +163,114 @@ var _ = Describe("ProtoLogger", func() {
 		})
 	})
 
+	Describe("Produce with units", func() {
+		var record *telemetry.Record
+
+		BeforeEach(func() {
+			payloadBytes, err := proto.Marshal(&protos.Payload{
+				Vin:       "TEST123",
+				CreatedAt: timestamppb.New(time.Unix(0, 0)),
+				Data: []*protos.Datum{
+					{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: "10"}}},
+					{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: protos.ShiftState_ShiftStateD}}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			logger, _ := logrus.NoOpLogger()
+			serializer := telemetry.NewBinarySerializer(
+				&telemetry.RequestIdentity{
+					DeviceID: "TEST123",
+					SenderID: "vehicle_device.TEST123",
+				},
+				map[string][]telemetry.Producer{},
+				logger,
+			)
+			message := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.TEST123"), MessageTopic: []byte("V"), Payload: payloadBytes}
+			streamMessageBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+			record, err = telemetry.NewRecord(serializer, streamMessageBytes, "1", false)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not annotate the units unless requested", func() {
+			converter, err := units.NewConverter(&units.Config{})
+			Expect(err).NotTo(HaveOccurred())
+			protoLogger = simple.NewProtoLogger(config, converter, nil, nil, testLogger).(*simple.Producer)
+
+			protoLogger.Produce(record)
+
+			data, ok := hook.LastEntry().Data["data"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(data).To(HaveKeyWithValue("VehicleSpeed", "10"))
+		})
+
+		It("annotates the units when requested", func() {
+			converter, err := units.NewConverter(&units.Config{Annotate: true})
+			Expect(err).NotTo(HaveOccurred())
+			protoLogger = simple.NewProtoLogger(config, converter, nil, nil, testLogger).(*simple.Producer)
+
+			protoLogger.Produce(record)
+
+			data, ok := hook.LastEntry().Data["data"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(data).To(HaveKeyWithValue("VehicleSpeed", map[string]interface{}{"value": "10", "unit": units.MilesPerHour}))
+			Expect(data).To(HaveKeyWithValue("Gear", "ShiftStateD"))
+		})
+
+		It("annotates the units when verbose", func() {
+			converter, err := units.NewConverter(&units.Config{})
+			Expect(err).NotTo(HaveOccurred())
+			config.Verbose = true
+			protoLogger = simple.NewProtoLogger(config, converter, nil, nil, testLogger).(*simple.Producer)
+
+			protoLogger.Produce(record)
+
+			data, ok := hook.LastEntry().Data["data"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(data).To(HaveKeyWithValue("VehicleSpeed", map[string]interface{}{"stringValue": "10", "unit": units.MilesPerHour}))
+			Expect(data).To(HaveKeyWithValue("Gear", map[string]interface{}{"shiftStateValue": "ShiftStateD"}))
+		})
+	})
+
+	Describe("Produce metrics", func() {
+		It("logs each metric", func() {
+			metricsBytes, err := proto.Marshal(&protos.VehicleMetrics{
+				Vin: "TEST123",
+				Metrics: []*protos.Metric{
+					{Name: "TestMetric", Tags: map[string]string{"tag1": "value1"}, Value: 1.5},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			logger, _ := logrus.NoOpLogger()
+			serializer := telemetry.NewBinarySerializer(
+				&telemetry.RequestIdentity{
+					DeviceID: "TEST123",
+					SenderID: "vehicle_device.TEST123",
+				},
+				map[string][]telemetry.Producer{},
+				logger,
+			)
+			message := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.TEST123"), MessageTopic: []byte("metrics"), Payload: metricsBytes}
+			streamMessageBytes, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+
+			record, err := telemetry.NewRecord(serializer, streamMessageBytes, "1", false)
+			Expect(err).NotTo(HaveOccurred())
+
+			protoLogger.Produce(record)
+
+			lastLog := hook.LastEntry()
+			Expect(lastLog.Message).To(Equal("record_payload"))
+			data, ok := lastLog.Data["data"].([]map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(data).To(HaveLen(1))
+			Expect(data[0]).To(HaveKeyWithValue("Name", "TestMetric"))
+			Expect(data[0]).To(HaveKeyWithValue("Value", 1.5))
+		})
+	})
+
 	Describe("ReportError", func() {
 		It("succeeds", func() {
 			Expect(func() {
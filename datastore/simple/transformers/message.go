@@ -0,0 +1,61 @@
+package transformers
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// MessageToMap transforms the message of a record into a human readable map, or a slice of maps for the record types
+// carrying several items. It returns false for unknown message types.
+func MessageToMap(message proto.Message, includeTypes bool, vin string, logger *logrus.Logger) (interface{}, bool) {
+	switch payload := message.(type) {
+	case *protos.Payload:
+		return PayloadToMap(payload, includeTypes, vin, logger), true
+	case *protos.VehicleAlerts:
+		alertMaps := make([]map[string]interface{}, len(payload.Alerts))
+		for i, alert := range payload.Alerts {
+			alertMaps[i] = VehicleAlertToMap(alert)
+		}
+		return alertMaps, true
+	case *protos.VehicleErrors:
+		errorMaps := make([]map[string]interface{}, len(payload.Errors))
+		for i, vehicleError := range payload.Errors {
+			errorMaps[i] = VehicleErrorToMap(vehicleError)
+		}
+		return errorMaps, true
+	case *protos.VehicleMetrics:
+		metricMaps := make([]map[string]interface{}, len(payload.Metrics))
+		for i, metric := range payload.Metrics {
+			metricMaps[i] = VehicleMetricsToMap(metric)
+		}
+		return metricMaps, true
+	case *protos.VehicleConnectivity:
+		return VehicleConnectivityToMap(payload), true
+	case *protos.VehicleDerivedSignals:
+		signalMaps := make([]map[string]interface{}, len(payload.Signals))
+		for i, signal := range payload.Signals {
+			signalMaps[i] = DerivedSignalToMap(signal)
+		}
+		return signalMaps, true
+	case *protos.VehicleGeofenceEvents:
+		eventMaps := make([]map[string]interface{}, len(payload.Events))
+		for i, event := range payload.Events {
+			eventMaps[i] = GeofenceEventToMap(event)
+		}
+		return eventMaps, true
+	case *protos.VehicleSession:
+		return VehicleSessionToMap(payload), true
+	case *protos.VehicleDeadLetter:
+		return VehicleDeadLetterToMap(payload), true
+	case *protos.VehicleAlertLifecycle:
+		eventMaps := make([]map[string]interface{}, len(payload.Events))
+		for i, event := range payload.Events {
+			eventMaps[i] = AlertLifecycleEventToMap(event)
+		}
+		return eventMaps, true
+	default:
+		return nil, false
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 )
 
 const (
@@ -37,6 +38,27 @@ func PayloadToMap(payload *protos.Payload, includeTypes bool, vin string, logger
 	return convertedPayload
 }
 
+// AnnotateUnits adds the unit of the fields of a payload to the map built by PayloadToMap. Typed values get a "unit"
+// key, the others are replaced by a map of their "value" and "unit".
+func AnnotateUnits(convertedPayload map[string]interface{}, payload *protos.Payload, converter *units.Converter) {
+	for _, datum := range payload.Data {
+		unit, ok := converter.Unit(datum.GetKey())
+		if !ok {
+			continue
+		}
+		name := protos.Field_name[int32(datum.Key.Number())]
+		value, ok := convertedPayload[name]
+		if !ok {
+			continue
+		}
+		if typedValue, ok := value.(map[string]interface{}); ok {
+			typedValue["unit"] = unit
+			continue
+		}
+		convertedPayload[name] = map[string]interface{}{"value": value, "unit": unit}
+	}
+}
+
 func transformValue(value interface{}, includeTypes bool, vin string) (interface{}, bool) {
 	var outputValue interface{}
 	var outputType string
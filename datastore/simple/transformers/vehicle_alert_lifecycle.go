@@ -0,0 +1,30 @@
+package transformers
+
+import (
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// AlertLifecycleEventToMap converts an AlertLifecycleEvent proto message to a map representation
+func AlertLifecycleEventToMap(event *protos.AlertLifecycleEvent) map[string]interface{} {
+	audiences := make([]string, len(event.Audiences))
+	for i, audience := range event.Audiences {
+		audiences[i] = audience.String()
+	}
+
+	eventMap := map[string]interface{}{
+		"Name":      event.GetName(),
+		"Event":     event.GetEvent().String(),
+		"Audiences": audiences,
+	}
+
+	if event.StartedAt != nil {
+		eventMap["StartedAt"] = event.StartedAt.AsTime().Unix()
+	}
+
+	if event.EndedAt != nil {
+		eventMap["EndedAt"] = event.EndedAt.AsTime().Unix()
+		eventMap["DurationSeconds"] = event.GetDurationSeconds()
+	}
+
+	return eventMap
+}
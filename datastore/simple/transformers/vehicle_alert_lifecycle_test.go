@@ -0,0 +1,48 @@
+package transformers_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("VehicleAlertLifecycle", func() {
+	Describe("AlertLifecycleEventToMap", func() {
+		startedAt := time.Unix(1700000000, 0)
+
+		It("includes the start of an opened alert", func() {
+			result := transformers.AlertLifecycleEventToMap(&protos.AlertLifecycleEvent{
+				Name:      "ChargePortFault",
+				Event:     protos.AlertEvent_OPENED,
+				Audiences: []protos.AlertAudience{protos.AlertAudience_Customer, protos.AlertAudience_Service},
+				StartedAt: timestamppb.New(startedAt),
+			})
+
+			Expect(result).To(HaveLen(4))
+			Expect(result["Name"]).To(Equal("ChargePortFault"))
+			Expect(result["Event"]).To(Equal("OPENED"))
+			Expect(result["Audiences"]).To(Equal([]string{"Customer", "Service"}))
+			Expect(result["StartedAt"]).To(Equal(startedAt.Unix()))
+		})
+
+		It("includes the end and duration of a closed alert", func() {
+			result := transformers.AlertLifecycleEventToMap(&protos.AlertLifecycleEvent{
+				Name:            "ChargePortFault",
+				Event:           protos.AlertEvent_CLOSED,
+				StartedAt:       timestamppb.New(startedAt),
+				EndedAt:         timestamppb.New(startedAt.Add(90 * time.Second)),
+				DurationSeconds: 90,
+			})
+
+			Expect(result["Event"]).To(Equal("CLOSED"))
+			Expect(result["EndedAt"]).To(Equal(startedAt.Add(90 * time.Second).Unix()))
+			Expect(result["DurationSeconds"]).To(Equal(90.0))
+		})
+	})
+})
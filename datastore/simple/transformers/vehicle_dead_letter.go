@@ -0,0 +1,22 @@
+package transformers
+
+import (
+	"encoding/base64"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// VehicleDeadLetterToMap converts a VehicleDeadLetter proto message to a map representation
+func VehicleDeadLetterToMap(deadLetter *protos.VehicleDeadLetter) map[string]interface{} {
+	return map[string]interface{}{
+		"Vin":           deadLetter.GetVin(),
+		"Reason":        deadLetter.GetReason().String(),
+		"Error":         deadLetter.GetError(),
+		"ReceivedAt":    deadLetter.GetReceivedAt().AsTime().Unix(),
+		"SocketID":      deadLetter.GetSocketId(),
+		"ClientVersion": deadLetter.GetClientVersion(),
+		"Txid":          deadLetter.GetTxid(),
+		"RecordType":    deadLetter.GetRecordType(),
+		"RawBytes":      base64.StdEncoding.EncodeToString(deadLetter.GetRawBytes()),
+	}
+}
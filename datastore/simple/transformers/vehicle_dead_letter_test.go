@@ -0,0 +1,43 @@
+package transformers_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("VehicleDeadLetter", func() {
+	Describe("VehicleDeadLetterToMap", func() {
+		It("includes all expected data", func() {
+			receivedAt := time.Unix(1700000000, 0)
+			result := transformers.VehicleDeadLetterToMap(&protos.VehicleDeadLetter{
+				Vin:           "Vin1",
+				Reason:        protos.DeadLetterReason_INVALID_MESSAGE,
+				Error:         "proto: cannot parse invalid wire-format data",
+				ReceivedAt:    timestamppb.New(receivedAt),
+				SocketId:      "socket-1",
+				ClientVersion: "2024.1",
+				Txid:          "txid-1",
+				RecordType:    "V",
+				RawBytes:      []byte{0x00, 0xff},
+			})
+
+			Expect(result).To(HaveLen(9))
+			Expect(result["Vin"]).To(Equal("Vin1"))
+			Expect(result["Reason"]).To(Equal("INVALID_MESSAGE"))
+			Expect(result["Error"]).To(Equal("proto: cannot parse invalid wire-format data"))
+			Expect(result["ReceivedAt"]).To(Equal(receivedAt.Unix()))
+			Expect(result["SocketID"]).To(Equal("socket-1"))
+			Expect(result["ClientVersion"]).To(Equal("2024.1"))
+			Expect(result["Txid"]).To(Equal("txid-1"))
+			Expect(result["RecordType"]).To(Equal("V"))
+			Expect(result["RawBytes"]).To(Equal("AP8="))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package transformers
+
+import (
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// DerivedSignalToMap converts a DerivedSignal proto message from a VehicleDerivedSignals batch to a map representation
+func DerivedSignalToMap(signal *protos.DerivedSignal) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":  signal.GetName(),
+		"Value": signal.GetValue(),
+	}
+}
@@ -0,0 +1,21 @@
+package transformers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("VehicleDerived", func() {
+	Describe("DerivedSignalToMap", func() {
+		It("includes all expected data", func() {
+			result := transformers.DerivedSignalToMap(&protos.DerivedSignal{Name: "power", Value: 21.5})
+
+			Expect(result).To(HaveLen(2))
+			Expect(result["Name"]).To(Equal("power"))
+			Expect(result["Value"]).To(Equal(21.5))
+		})
+	})
+})
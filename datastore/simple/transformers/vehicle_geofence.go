@@ -0,0 +1,25 @@
+package transformers
+
+import (
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// GeofenceEventToMap converts a GeofenceEvent proto message to a map representation
+func GeofenceEventToMap(event *protos.GeofenceEvent) map[string]interface{} {
+	eventMap := map[string]interface{}{
+		"Geofence":   event.GetGeofence(),
+		"Transition": event.GetTransition().String(),
+		"Latitude":   event.GetLatitude(),
+		"Longitude":  event.GetLongitude(),
+	}
+
+	if event.EnteredAt != nil {
+		eventMap["EnteredAt"] = event.EnteredAt.AsTime().Unix()
+	}
+
+	if event.ExitedAt != nil {
+		eventMap["ExitedAt"] = event.ExitedAt.AsTime().Unix()
+	}
+
+	return eventMap
+}
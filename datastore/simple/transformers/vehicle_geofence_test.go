@@ -0,0 +1,48 @@
+package transformers_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("VehicleGeofence", func() {
+	Describe("GeofenceEventToMap", func() {
+		enteredAt := time.Unix(1700000000, 0)
+
+		It("includes the entered time of an enter", func() {
+			result := transformers.GeofenceEventToMap(&protos.GeofenceEvent{
+				Geofence:   "depot",
+				Transition: protos.GeofenceTransition_ENTER,
+				EnteredAt:  timestamppb.New(enteredAt),
+				Latitude:   37.41,
+				Longitude:  -122.14,
+			})
+
+			Expect(result).To(HaveLen(5))
+			Expect(result["Geofence"]).To(Equal("depot"))
+			Expect(result["Transition"]).To(Equal("ENTER"))
+			Expect(result["EnteredAt"]).To(Equal(enteredAt.Unix()))
+			Expect(result["Latitude"]).To(Equal(37.41))
+			Expect(result["Longitude"]).To(Equal(-122.14))
+		})
+
+		It("includes the exited time of an exit", func() {
+			result := transformers.GeofenceEventToMap(&protos.GeofenceEvent{
+				Geofence:   "depot",
+				Transition: protos.GeofenceTransition_EXIT,
+				EnteredAt:  timestamppb.New(enteredAt),
+				ExitedAt:   timestamppb.New(enteredAt.Add(time.Minute)),
+			})
+
+			Expect(result["Transition"]).To(Equal("EXIT"))
+			Expect(result["ExitedAt"]).To(Equal(enteredAt.Add(time.Minute).Unix()))
+		})
+	})
+})
@@ -0,0 +1,14 @@
+package transformers
+
+import (
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// VehicleMetricsToMap converts a Metric proto message from a VehicleMetrics batch to a map representation
+func VehicleMetricsToMap(metric *protos.Metric) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":  metric.GetName(),
+		"Tags":  metric.GetTags(),
+		"Value": metric.GetValue(),
+	}
+}
@@ -0,0 +1,47 @@
+package transformers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("VehicleMetric", func() {
+	Describe("VehicleMetricsToMap", func() {
+		var (
+			metric *protos.Metric
+		)
+
+		BeforeEach(func() {
+			metric = &protos.Metric{
+				Name:  "TestMetric",
+				Tags:  map[string]string{"tag1": "value1", "tag2": "value2"},
+				Value: 42.5,
+			}
+		})
+
+		It("includes all expected data", func() {
+			result := transformers.VehicleMetricsToMap(metric)
+
+			Expect(result).To(HaveLen(3))
+			Expect(result["Name"]).To(Equal("TestMetric"))
+			Expect(result["Tags"]).To(HaveKeyWithValue("tag1", "value1"))
+			Expect(result["Tags"]).To(HaveKeyWithValue("tag2", "value2"))
+			Expect(result["Value"]).To(Equal(42.5))
+		})
+
+		It("handles missing fields", func() {
+			metric.Tags = nil
+			metric.Value = 0
+
+			result := transformers.VehicleMetricsToMap(metric)
+
+			Expect(result).To(HaveLen(3))
+			Expect(result["Name"]).To(Equal("TestMetric"))
+			Expect(result["Tags"]).To(BeEmpty())
+			Expect(result["Value"]).To(Equal(0.0))
+		})
+	})
+})
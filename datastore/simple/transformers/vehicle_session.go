@@ -0,0 +1,48 @@
+package transformers
+
+import (
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// VehicleSessionToMap converts a VehicleSession proto message to a map representation
+func VehicleSessionToMap(vehicleSession *protos.VehicleSession) map[string]interface{} {
+	sessionMap := map[string]interface{}{
+		"Vin":             vehicleSession.GetVin(),
+		"SessionID":       vehicleSession.GetSessionId(),
+		"Kind":            vehicleSession.GetKind().String(),
+		"DurationSeconds": vehicleSession.GetDurationSeconds(),
+	}
+
+	if vehicleSession.StartedAt != nil {
+		sessionMap["StartedAt"] = vehicleSession.StartedAt.AsTime().Unix()
+	}
+
+	if vehicleSession.EndedAt != nil {
+		sessionMap["EndedAt"] = vehicleSession.EndedAt.AsTime().Unix()
+	}
+
+	if vehicleSession.Distance != nil {
+		sessionMap["Distance"] = vehicleSession.GetDistance()
+	}
+
+	if vehicleSession.Energy != nil {
+		sessionMap["Energy"] = vehicleSession.GetEnergy()
+	}
+
+	if vehicleSession.StartLocation != nil {
+		sessionMap["StartLocation"] = sessionLocationToMap(vehicleSession.StartLocation)
+	}
+
+	if vehicleSession.EndLocation != nil {
+		sessionMap["EndLocation"] = sessionLocationToMap(vehicleSession.EndLocation)
+	}
+
+	return sessionMap
+}
+
+func sessionLocationToMap(location *protos.SessionLocation) map[string]interface{} {
+	return map[string]interface{}{
+		"latitude":  location.GetLatitude(),
+		"longitude": location.GetLongitude(),
+	}
+}
@@ -0,0 +1,60 @@
+package transformers_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("VehicleSession", func() {
+	Describe("VehicleSessionToMap", func() {
+		startedAt := time.Unix(1700000000, 0)
+
+		It("includes all expected data", func() {
+			result := transformers.VehicleSessionToMap(&protos.VehicleSession{
+				Vin:             "Vin1",
+				SessionId:       "trip-Vin1-1700000000000",
+				Kind:            protos.SessionKind_TRIP,
+				StartedAt:       timestamppb.New(startedAt),
+				EndedAt:         timestamppb.New(startedAt.Add(20 * time.Minute)),
+				DurationSeconds: 1200,
+				Distance:        proto.Float64(12.5),
+				Energy:          proto.Float64(2),
+				StartLocation:   &protos.SessionLocation{Latitude: 37.41, Longitude: -122.15},
+				EndLocation:     &protos.SessionLocation{Latitude: 37.42, Longitude: -122.14},
+			})
+
+			Expect(result).To(HaveLen(10))
+			Expect(result["Vin"]).To(Equal("Vin1"))
+			Expect(result["SessionID"]).To(Equal("trip-Vin1-1700000000000"))
+			Expect(result["Kind"]).To(Equal("TRIP"))
+			Expect(result["StartedAt"]).To(Equal(startedAt.Unix()))
+			Expect(result["EndedAt"]).To(Equal(startedAt.Add(20 * time.Minute).Unix()))
+			Expect(result["DurationSeconds"]).To(Equal(1200.0))
+			Expect(result["Distance"]).To(Equal(12.5))
+			Expect(result["Energy"]).To(Equal(2.0))
+			Expect(result["StartLocation"]).To(Equal(map[string]interface{}{"latitude": 37.41, "longitude": -122.15}))
+			Expect(result["EndLocation"]).To(Equal(map[string]interface{}{"latitude": 37.42, "longitude": -122.14}))
+		})
+
+		It("omits the values not received", func() {
+			result := transformers.VehicleSessionToMap(&protos.VehicleSession{
+				Vin:             "Vin1",
+				Kind:            protos.SessionKind_CHARGE,
+				DurationSeconds: 60,
+			})
+
+			Expect(result).NotTo(HaveKey("Distance"))
+			Expect(result).NotTo(HaveKey("Energy"))
+			Expect(result).NotTo(HaveKey("StartLocation"))
+			Expect(result).NotTo(HaveKey("EndLocation"))
+		})
+	})
+})
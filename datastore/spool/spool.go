@@ -0,0 +1,556 @@
+package spool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	defaultSegmentBytes    = 16 << 20
+	defaultRetryIntervalMs = 5000
+	replayTimeout          = 30 * time.Second
+
+	// replayBatchSize is the number of spooled records in flight while replaying
+	replayBatchSize = 500
+)
+
+// Config for the on-disk spool of failed records
+type Config struct {
+	// Dir is the directory holding one write-ahead log per spooled dispatcher
+	Dir string `json:"dir"`
+
+	// Dispatchers is the list of dispatchers wrapped by the spool
+	Dispatchers []telemetry.Dispatcher `json:"dispatchers"`
+
+	// SegmentBytes is the size at which a new segment file is started. Default: 16MB
+	SegmentBytes int64 `json:"segment_bytes,omitempty"`
+
+	// MaxBytes caps the disk usage of a single dispatcher spool, oldest records are dropped past it. Default: unlimited
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+
+	// MaxAgeSeconds drops spooled records older than this. Default: unlimited
+	MaxAgeSeconds int `json:"max_age_seconds,omitempty"`
+
+	// RetryIntervalMs is the interval between replay attempts while the backend is unavailable. Default: 5000
+	RetryIntervalMs int `json:"retry_interval_ms,omitempty"`
+}
+
+// spooledRecord is the on-disk representation of a record
+type spooledRecord struct {
+	TxType              string `json:"tx_type"`
+	Txid                string `json:"txid"`
+	TripID              string `json:"trip_id,omitempty"`
+	Vin                 string `json:"vin"`
+	SocketID            string `json:"socket_id"`
+	DeviceClientVersion string `json:"device_client_version"`
+	ReceivedTimestamp   int64  `json:"received_timestamp"`
+	Timestamp           int64  `json:"timestamp"`
+	Version             int    `json:"version"`
+	PayloadBytes        []byte `json:"payload_bytes"`
+	RawBytes            []byte `json:"raw_bytes"`
+	ProtoBytes          []byte `json:"proto_bytes,omitempty"`
+}
+
+// Producer wraps a producer with a write-ahead spool: records the backend fails to accept are
+// appended to disk and replayed in order once it recovers. The spool sends the reliable acks of the dispatcher, when
+// a record is delivered or spooled, so the wrapped producer must be built without reliable ack types: a replayed
+// record was already acked when spooled. A record is reported delivered once delivered or spooled.
+type Producer struct {
+	dispatcher             telemetry.Dispatcher
+	inner                  telemetry.Producer
+	wal                    *wal
+	retryInterval          time.Duration
+	transmitDecodedRecords bool
+	logger                 *logrus.Logger
+	airbrakeHandler        *airbrake.Handler
+	ackChan                chan (*telemetry.Ack)
+	reliableAckTxTypes     map[string]interface{}
+
+	// spooling is set while spooled records wait to be replayed, new records are spooled behind them. handoff
+	// holds new records back while the last spooled records are produced.
+	spooling atomic.Bool
+	handoff  sync.RWMutex
+
+	// inflight maps the records of the replayed batch to their indexes in it
+	mu            sync.Mutex
+	inflight      map[recordKey][]int
+	replayResults chan replayResult
+	wake          chan struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+	wg            sync.WaitGroup
+
+	telemetry.DeliveryNotifier
+}
+
+// recordKey identifies a record across copies, the wrapped producer may notify the delivery of a copy of the replayed
+// record
+type recordKey struct {
+	socketID string
+	txType   string
+	txid     string
+}
+
+func keyOf(entry *telemetry.Record) recordKey {
+	return recordKey{socketID: entry.SocketID, txType: entry.TxType, txid: entry.Txid}
+}
+
+// replayResult is the delivery outcome of a record of the replayed batch
+type replayResult struct {
+	index int
+	err   error
+}
+
+// errUndecodable is the outcome of a spooled record which cannot be decoded, dropped on replay
+var errUndecodable = errors.New("undecodable spooled record")
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	depth            adapter.Gauge
+	bytes            adapter.Gauge
+	appendCount      adapter.Counter
+	replayCount      adapter.Counter
+	droppedCount     adapter.Counter
+	errorCount       adapter.Counter
+	reliableAckCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// NewProducer opens the spool of a dispatcher and starts replaying any record left from a previous run
+func NewProducer(config *Config, dispatcher telemetry.Dispatcher, inner telemetry.Producer, transmitDecodedRecords bool, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (*Producer, error) {
+	registerMetricsOnce(metricsCollector)
+
+	reporter, ok := inner.(telemetry.DeliveryReporter)
+	if !ok {
+		return nil, fmt.Errorf("dispatcher %s does not support spooling", dispatcher)
+	}
+	if config.Dir == "" {
+		return nil, errors.New("spool dir is required")
+	}
+
+	segmentBytes := config.SegmentBytes
+	if segmentBytes <= 0 {
+		segmentBytes = defaultSegmentBytes
+	}
+	if config.MaxBytes > 0 && config.MaxBytes < segmentBytes {
+		return nil, fmt.Errorf("spool max_bytes %d cannot be lower than segment_bytes %d", config.MaxBytes, segmentBytes)
+	}
+	retryIntervalMs := config.RetryIntervalMs
+	if retryIntervalMs <= 0 {
+		retryIntervalMs = defaultRetryIntervalMs
+	}
+
+	w, err := openWAL(filepath.Join(config.Dir, string(dispatcher)), segmentBytes, config.MaxBytes, time.Duration(config.MaxAgeSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	producer := &Producer{
+		dispatcher:             dispatcher,
+		inner:                  inner,
+		wal:                    w,
+		retryInterval:          time.Duration(retryIntervalMs) * time.Millisecond,
+		transmitDecodedRecords: transmitDecodedRecords,
+		logger:                 logger,
+		airbrakeHandler:        airbrakeHandler,
+		ackChan:                ackChan,
+		reliableAckTxTypes:     reliableAckTxTypes,
+		replayResults:          make(chan replayResult, replayBatchSize),
+		wake:                   make(chan struct{}, 1),
+		done:                   make(chan struct{}),
+	}
+	producer.spooling.Store(w.Depth() > 0)
+	reporter.SetDeliveryHandler(producer.handleDelivery)
+	producer.reportDepth()
+
+	producer.wg.Add(1)
+	go producer.replay()
+	producer.logger.ActivityLog("spool_registered", logrus.LogInfo{"dispatcher": dispatcher, "depth": w.Depth()})
+	return producer, nil
+}
+
+// Produce sends the record to the wrapped producer, or straight to the spool while older records wait to be
+// replayed so ordering is preserved
+func (p *Producer) Produce(entry *telemetry.Record) {
+	p.handoff.RLock()
+	defer p.handoff.RUnlock()
+	if p.spooling.Load() {
+		p.spoolRecord(entry)
+		return
+	}
+	p.inner.Produce(entry)
+}
+
+// Close stops the replay and closes the wrapped producer
+func (p *Producer) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.wg.Wait()
+	err := p.inner.Close()
+	if walErr := p.wal.Close(); err == nil {
+		err = walErr
+	}
+	return err
+}
+
+// ProcessReliableAck sends to ackChan if reliable ack is configured
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	_, ok := p.reliableAckTxTypes[entry.TxType]
+	if ok {
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	}
+}
+
+// ReportError to airbrake and logger
+func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo) {
+	p.airbrakeHandler.ReportLogMessage(logrus.ERROR, message, err, logInfo)
+	p.logger.ErrorLog(message, err, logInfo)
+}
+
+// Unwrap returns the producer wrapped by the spool
+func (p *Producer) Unwrap() telemetry.Producer {
+	return p.inner
+}
+
+// handleDelivery is notified by the wrapped producer of every delivery outcome
+func (p *Producer) handleDelivery(entry *telemetry.Record, err error) {
+	key := keyOf(entry)
+	p.mu.Lock()
+	indexes, replaying := p.inflight[key]
+	if len(indexes) > 1 {
+		p.inflight[key] = indexes[1:]
+	} else {
+		delete(p.inflight, key)
+	}
+	p.mu.Unlock()
+	if replaying {
+		select {
+		case p.replayResults <- replayResult{index: indexes[0], err: err}:
+		default:
+		}
+		return
+	}
+
+	if err == nil {
+		p.ProcessReliableAck(entry)
+		p.NotifyDelivery(entry, nil)
+		return
+	}
+	if errors.Is(err, telemetry.ErrRecordRejected) {
+		p.NotifyDelivery(entry, err)
+		return
+	}
+	p.spoolRecord(entry)
+}
+
+// spoolRecord durably appends the record, it is acknowledged once written
+func (p *Producer) spoolRecord(entry *telemetry.Record) {
+	data, err := encodeRecord(entry)
+	if err == nil {
+		var dropped int
+		dropped, err = p.wal.Append(data)
+		p.reportDropped(dropped)
+	}
+	if err != nil {
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+		p.ReportError("spool_append_error", err, logrus.LogInfo{"dispatcher": p.dispatcher, "record_type": entry.TxType, "txid": entry.Txid})
+		p.NotifyDelivery(entry, err)
+		return
+	}
+
+	p.spooling.Store(true)
+	metricsRegistry.appendCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	p.reportDepth()
+	p.ProcessReliableAck(entry)
+	p.NotifyDelivery(entry, nil)
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// replay drains the spool in order, waiting for the backend to recover on failures
+func (p *Producer) replay() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		p.drain()
+		select {
+		case <-p.done:
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain replays spooled records by batches until the spool is empty or a delivery fails
+func (p *Producer) drain() {
+	for {
+		batch, err := p.sendBatch()
+		if err != nil {
+			metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+			p.ReportError("spool_read_error", err, logrus.LogInfo{"dispatcher": p.dispatcher})
+			return
+		}
+		if batch == nil {
+			return
+		}
+		if err := p.awaitBatch(batch); err != nil {
+			p.spooling.Store(true)
+			p.logger.Log(logrus.DEBUG, "spool_replay_failed", logrus.LogInfo{"dispatcher": p.dispatcher, "error": err.Error(), "depth": p.wal.Depth()})
+			return
+		}
+	}
+}
+
+// replayedBatch tracks the delivery outcomes of a batch of spooled records
+type replayedBatch struct {
+	refs     []*frameRef
+	entries  []*telemetry.Record
+	results  []error
+	reported []bool
+	pending  int
+}
+
+// sendBatch produces the next spooled records without waiting for each other, it returns nil once the spool is
+// empty. New records go to the wrapped producer again once the last spooled records are produced, so the backend
+// catches up with live traffic instead of staying behind the spool.
+func (p *Producer) sendBatch() (*replayedBatch, error) {
+	payloads, refs, err := p.wal.PeekBatch(replayBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) < replayBatchSize {
+		// new records wait for the last spooled records to be produced
+		p.handoff.Lock()
+		defer p.handoff.Unlock()
+		if payloads, refs, err = p.wal.PeekBatch(replayBatchSize); err != nil {
+			return nil, err
+		}
+		if len(refs) < replayBatchSize {
+			p.spooling.Store(false)
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	batch := &replayedBatch{
+		refs:     refs,
+		entries:  make([]*telemetry.Record, len(refs)),
+		results:  make([]error, len(refs)),
+		reported: make([]bool, len(refs)),
+	}
+	inflight := make(map[recordKey][]int, len(refs))
+	for i, data := range payloads {
+		entry, err := p.decodeRecord(data)
+		if err != nil {
+			p.ReportError("spool_decode_error", err, logrus.LogInfo{"dispatcher": p.dispatcher})
+			batch.results[i], batch.reported[i] = errUndecodable, true
+			continue
+		}
+		batch.entries[i] = entry
+		inflight[keyOf(entry)] = append(inflight[keyOf(entry)], i)
+		batch.pending++
+	}
+
+	p.mu.Lock()
+	p.inflight = inflight
+	p.mu.Unlock()
+	// drop any late result from a previously timed out batch
+	for drained := false; !drained; {
+		select {
+		case <-p.replayResults:
+		default:
+			drained = true
+		}
+	}
+	for _, entry := range batch.entries {
+		if entry != nil {
+			p.inner.Produce(entry)
+		}
+	}
+	return batch, nil
+}
+
+// awaitBatch commits the frames of a batch in order as their deliveries are reported. After a failure, it waits for
+// the records still in flight, so they are not spooled again, and leaves the frames from the failed one on to the
+// next replay.
+func (p *Producer) awaitBatch(batch *replayedBatch) error {
+	var failure error
+	committed := 0
+	timeout := time.NewTimer(replayTimeout)
+	defer timeout.Stop()
+	for {
+		for failure == nil && committed < len(batch.refs) && batch.reported[committed] {
+			failure = p.commitReplayed(batch.refs[committed], batch.entries[committed], batch.results[committed])
+			committed++
+		}
+		if batch.pending == 0 {
+			return failure
+		}
+		select {
+		case result := <-p.replayResults:
+			batch.results[result.index], batch.reported[result.index] = result.err, true
+			batch.pending--
+		case <-timeout.C:
+			return errors.New("spool replay timed out")
+		case <-p.done:
+			return errors.New("spool closed")
+		}
+	}
+}
+
+// commitReplayed commits the frame of a replayed record once delivered or dropped, it returns the error of a failed
+// delivery without committing it
+func (p *Producer) commitReplayed(ref *frameRef, entry *telemetry.Record, err error) error {
+	switch {
+	case err == nil:
+		p.commit(ref)
+		metricsRegistry.replayCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	case errors.Is(err, errUndecodable), errors.Is(err, telemetry.ErrRecordRejected):
+		p.commit(ref)
+		p.reportDropped(1)
+	default:
+		return err
+	}
+	return nil
+}
+
+func (p *Producer) commit(ref *frameRef) {
+	if err := p.wal.Commit(ref); err != nil {
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+		p.ReportError("spool_commit_error", err, logrus.LogInfo{"dispatcher": p.dispatcher})
+	}
+	p.reportDepth()
+}
+
+func (p *Producer) reportDropped(dropped int) {
+	if dropped == 0 {
+		return
+	}
+	metricsRegistry.droppedCount.Add(int64(dropped), map[string]string{"dispatcher": string(p.dispatcher)})
+	p.logger.ActivityLog("spool_records_dropped", logrus.LogInfo{"dispatcher": p.dispatcher, "count": dropped})
+}
+
+func (p *Producer) reportDepth() {
+	metricsRegistry.depth.Set(int64(p.wal.Depth()), map[string]string{"dispatcher": string(p.dispatcher)})
+	metricsRegistry.bytes.Set(p.wal.Size(), map[string]string{"dispatcher": string(p.dispatcher)})
+}
+
+func encodeRecord(entry *telemetry.Record) ([]byte, error) {
+	spooled := spooledRecord{
+		TxType:              entry.TxType,
+		Txid:                entry.Txid,
+		TripID:              entry.TripID,
+		Vin:                 entry.Vin,
+		SocketID:            entry.SocketID,
+		DeviceClientVersion: entry.DeviceClientVersion,
+		ReceivedTimestamp:   entry.ReceivedTimestamp,
+		Timestamp:           entry.Timestamp,
+		Version:             entry.Version,
+		PayloadBytes:        entry.PayloadBytes,
+		RawBytes:            entry.RawBytes,
+	}
+	if message := entry.GetProtoMessage(); message != nil {
+		protoBytes, err := proto.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		spooled.ProtoBytes = protoBytes
+	}
+	return json.Marshal(spooled)
+}
+
+func (p *Producer) decodeRecord(data []byte) (*telemetry.Record, error) {
+	var spooled spooledRecord
+	if err := json.Unmarshal(data, &spooled); err != nil {
+		return nil, err
+	}
+	entry := &telemetry.Record{
+		TxType:              spooled.TxType,
+		Txid:                spooled.Txid,
+		TripID:              spooled.TripID,
+		Vin:                 spooled.Vin,
+		SocketID:            spooled.SocketID,
+		DeviceClientVersion: spooled.DeviceClientVersion,
+		ReceivedTimestamp:   spooled.ReceivedTimestamp,
+		Timestamp:           spooled.Timestamp,
+		Version:             spooled.Version,
+		PayloadBytes:        spooled.PayloadBytes,
+		RawBytes:            spooled.RawBytes,
+	}
+	if err := entry.Restore(spooled.ProtoBytes, p.transmitDecodedRecords); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.depth = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "spool_depth",
+		Help:   "The number of records waiting in the spool.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.bytes = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "spool_bytes",
+		Help:   "The number of bytes used by the spool on disk.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.appendCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "spool_append_total",
+		Help:   "The number of records appended to the spool.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.replayCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "spool_replay_total",
+		Help:   "The number of spooled records successfully replayed.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.droppedCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "spool_dropped_total",
+		Help:   "The number of spooled records dropped because of the size or age caps, or rejected on replay.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "spool_err",
+		Help:   "The number of errors while reading or writing the spool.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "spool_reliable_ack_total",
+		Help:   "The number of spooled records for which we sent a reliable ACK.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+}
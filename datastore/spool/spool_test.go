@@ -0,0 +1,245 @@
+package spool
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+type fakeProducer struct {
+	telemetry.DeliveryNotifier
+
+	mu       sync.Mutex
+	failing  bool
+	rejected bool
+	delay    time.Duration
+	produced []string
+}
+
+func (f *fakeProducer) Produce(entry *telemetry.Record) {
+	f.mu.Lock()
+	failing, rejected, delay := f.failing, f.rejected, f.delay
+	if !failing && !rejected {
+		f.produced = append(f.produced, entry.Txid)
+	}
+	f.mu.Unlock()
+
+	if delay > 0 {
+		go func() {
+			time.Sleep(delay)
+			f.NotifyDelivery(entry, nil)
+		}()
+		return
+	}
+	switch {
+	case rejected:
+		f.NotifyDelivery(entry, telemetry.ErrRecordRejected)
+	case failing:
+		f.NotifyDelivery(entry, errors.New("backend unavailable"))
+	default:
+		f.NotifyDelivery(entry, nil)
+	}
+}
+
+func (f *fakeProducer) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *fakeProducer) producedTxids() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.produced...)
+}
+
+func (f *fakeProducer) Close() error { return nil }
+
+func (f *fakeProducer) ProcessReliableAck(_ *telemetry.Record) {}
+
+func (f *fakeProducer) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
+// copyingProducer notifies the delivery of a copy of each record
+type copyingProducer struct {
+	*fakeProducer
+}
+
+func (c *copyingProducer) Produce(entry *telemetry.Record) {
+	clone := *entry
+	c.fakeProducer.Produce(&clone)
+}
+
+type plainProducer struct{}
+
+func (p *plainProducer) Produce(_ *telemetry.Record)                     {}
+func (p *plainProducer) Close() error                                    { return nil }
+func (p *plainProducer) ProcessReliableAck(_ *telemetry.Record)          {}
+func (p *plainProducer) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
+var _ = Describe("Producer", func() {
+	var (
+		config  *Config
+		inner   *fakeProducer
+		ackChan chan *telemetry.Ack
+		logger  *logrus.Logger
+		spooler *Producer
+	)
+
+	newSpool := func() *Producer {
+		producer, err := NewProducer(config, telemetry.Kafka, inner, false, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, map[string]interface{}{"V": true}, logger)
+		Expect(err).NotTo(HaveOccurred())
+		return producer
+	}
+
+	BeforeEach(func() {
+		config = &Config{Dir: GinkgoT().TempDir(), RetryIntervalMs: 10}
+		inner = &fakeProducer{}
+		ackChan = make(chan *telemetry.Ack, 10)
+		logger, _ = logrus.NoOpLogger()
+	})
+
+	AfterEach(func() {
+		if spooler != nil {
+			Expect(spooler.Close()).To(Succeed())
+			spooler = nil
+		}
+	})
+
+	It("requires a producer reporting deliveries", func() {
+		_, err := NewProducer(config, telemetry.Kinesis, &plainProducer{}, false, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, nil, logger)
+		Expect(err).To(MatchError("dispatcher kinesis does not support spooling"))
+	})
+
+	It("produces directly while the backend is healthy", func() {
+		spooler = newSpool()
+		spooler.Produce(&telemetry.Record{TxType: "V", Txid: "1"})
+
+		Expect(inner.producedTxids()).To(Equal([]string{"1"}))
+		Expect(spooler.wal.Depth()).To(Equal(0))
+		Expect(ackChan).To(HaveLen(1))
+		Expect((<-ackChan).Record.Txid).To(Equal("1"))
+	})
+
+	It("spools failed records, acks them and replays them in order", func() {
+		spooler = newSpool()
+		inner.setFailing(true)
+
+		spooler.Produce(&telemetry.Record{TxType: "V", Txid: "1"})
+		spooler.Produce(&telemetry.Record{TxType: "alerts", Txid: "2"})
+		spooler.Produce(&telemetry.Record{TxType: "V", Txid: "3"})
+
+		Eventually(ackChan).Should(HaveLen(2))
+		Expect((<-ackChan).Record.Txid).To(Equal("1"))
+		Expect((<-ackChan).Record.Txid).To(Equal("3"))
+		Expect(spooler.wal.Depth()).To(Equal(3))
+
+		inner.setFailing(false)
+		Eventually(spooler.wal.Depth).Should(Equal(0))
+		Expect(inner.producedTxids()).To(Equal([]string{"1", "2", "3"}))
+		Consistently(ackChan, "50ms").Should(BeEmpty())
+	})
+
+	It("catches up with records arriving while the spool drains", func() {
+		spooler = newSpool()
+		inner.setFailing(true)
+		next := 0
+		produce := func() {
+			spooler.Produce(&telemetry.Record{TxType: "alerts", Txid: strconv.Itoa(next)})
+			next++
+		}
+		for next < 100 {
+			produce()
+		}
+		Eventually(spooler.wal.Depth).Should(Equal(100))
+
+		inner.mu.Lock()
+		inner.failing = false
+		inner.delay = 5 * time.Millisecond
+		inner.mu.Unlock()
+		Eventually(func() int {
+			produce()
+			return spooler.wal.Depth()
+		}, "5s", "1ms").Should(Equal(0))
+
+		expected := make([]string, next)
+		for i := range expected {
+			expected[i] = strconv.Itoa(i)
+		}
+		Eventually(inner.producedTxids).Should(Equal(expected))
+	})
+
+	It("matches the replayed record by key when the wrapped producer notifies a copy", func() {
+		copying := &copyingProducer{fakeProducer: inner}
+		producer, err := NewProducer(config, telemetry.Kafka, copying, false, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, map[string]interface{}{"V": true}, logger)
+		Expect(err).NotTo(HaveOccurred())
+		spooler = producer
+
+		inner.setFailing(true)
+		spooler.Produce(&telemetry.Record{TxType: "V", Txid: "1", SocketID: "socket"})
+		Eventually(ackChan).Should(HaveLen(1))
+		<-ackChan
+
+		inner.setFailing(false)
+		Eventually(spooler.wal.Depth).Should(Equal(0))
+		Expect(inner.producedTxids()).To(Equal([]string{"1"}))
+		Consistently(ackChan, "50ms").Should(BeEmpty())
+	})
+
+	It("skips records rejected on replay", func() {
+		spooler = newSpool()
+		inner.setFailing(true)
+		spooler.Produce(&telemetry.Record{TxType: "V", Txid: "1"})
+		Eventually(spooler.wal.Depth).Should(Equal(1))
+
+		inner.mu.Lock()
+		inner.failing = false
+		inner.rejected = true
+		inner.mu.Unlock()
+		Eventually(spooler.wal.Depth).Should(Equal(0))
+		Expect(inner.producedTxids()).To(BeEmpty())
+	})
+
+	It("replays records left by a previous run", func() {
+		inner.setFailing(true)
+		spooler = newSpool()
+		spooler.Produce(&telemetry.Record{TxType: "V", Txid: "1"})
+		Eventually(spooler.wal.Depth).Should(Equal(1))
+		Expect(spooler.Close()).To(Succeed())
+
+		inner = &fakeProducer{}
+		spooler = newSpool()
+		Eventually(spooler.wal.Depth).Should(Equal(0))
+		Expect(inner.producedTxids()).To(Equal([]string{"1"}))
+	})
+
+	It("restores the proto message of spooled records", func() {
+		spooler = newSpool()
+		alerts := &protos.VehicleAlerts{Vin: "TEST123", Alerts: []*protos.VehicleAlert{{Name: "alert1"}}}
+		protoBytes, err := proto.Marshal(alerts)
+		Expect(err).NotTo(HaveOccurred())
+
+		record := &telemetry.Record{TxType: "alerts", Txid: "1", Vin: "TEST123", PayloadBytes: protoBytes}
+		Expect(record.Restore(protoBytes, false)).To(Succeed())
+
+		data, err := encodeRecord(record)
+		Expect(err).NotTo(HaveOccurred())
+		restored, err := spooler.decodeRecord(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.Txid).To(Equal("1"))
+		Expect(restored.Vin).To(Equal("TEST123"))
+		Expect(restored.Payload()).To(Equal(protoBytes))
+		Expect(proto.Equal(restored.GetProtoMessage(), alerts)).To(BeTrue())
+	})
+})
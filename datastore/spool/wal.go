@@ -0,0 +1,452 @@
+package spool
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExtension = ".seg"
+	cursorFileName   = "cursor"
+	frameHeaderSize  = 8
+	maxFrameSize     = 64 << 20
+)
+
+var errCorruptFrame = errors.New("corrupt spool frame")
+
+// segment is a single append-only file of the write-ahead log
+type segment struct {
+	id        uint64
+	path      string
+	size      int64
+	records   int
+	lastWrite time.Time
+}
+
+// frameRef locates a frame returned by Peek
+type frameRef struct {
+	segment uint64
+	offset  int64
+	size    int64
+}
+
+// cursor points at the next frame to replay
+type cursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// wal is an ordered, segmented, on-disk queue of frames.
+// Each frame is a 4 byte big endian length, a 4 byte crc32 of the payload and the payload itself.
+type wal struct {
+	dir          string
+	segmentBytes int64
+	maxBytes     int64
+	maxAge       time.Duration
+
+	mu           sync.Mutex
+	segments     []*segment
+	writer       *os.File
+	readOffset   int64
+	headConsumed int
+	depth        int
+	size         int64
+}
+
+// openWAL opens or creates the write-ahead log in dir, truncating any partially written tail
+func openWAL(dir string, segmentBytes, maxBytes int64, maxAge time.Duration) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &wal{
+		dir:          dir,
+		segmentBytes: segmentBytes,
+		maxBytes:     maxBytes,
+		maxAge:       maxAge,
+	}
+
+	ids, err := listSegmentIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := w.readCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		path := w.segmentPath(id)
+		if id < pos.Segment {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		seg, err := scanSegment(id, path)
+		if err != nil {
+			return nil, err
+		}
+		w.segments = append(w.segments, seg)
+	}
+
+	if len(w.segments) > 0 && w.segments[0].id == pos.Segment && pos.Offset <= w.segments[0].size {
+		w.readOffset, w.headConsumed, err = countFrames(w.segments[0].path, pos.Offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, seg := range w.segments {
+		w.size += seg.size
+		w.depth += seg.records
+		if i == 0 {
+			w.depth -= w.headConsumed
+		}
+	}
+
+	if len(w.segments) == 0 {
+		if err := w.createSegment(1); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	tail := w.segments[len(w.segments)-1]
+	w.writer, err = os.OpenFile(tail.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Append durably writes a frame at the end of the log.
+// It returns the number of records dropped to honor the size and age caps.
+func (w *wal) Append(payload []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frameSize := int64(frameHeaderSize + len(payload))
+	tail := w.segments[len(w.segments)-1]
+	if tail.size > 0 && tail.size+frameSize > w.segmentBytes {
+		if err := w.roll(); err != nil {
+			return 0, err
+		}
+		tail = w.segments[len(w.segments)-1]
+	}
+
+	frame := make([]byte, frameSize)
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	copy(frame[frameHeaderSize:], payload)
+	if _, err := w.writer.Write(frame); err != nil {
+		return 0, err
+	}
+	if err := w.writer.Sync(); err != nil {
+		return 0, err
+	}
+
+	tail.size += frameSize
+	tail.records++
+	tail.lastWrite = time.Now()
+	w.size += frameSize
+	w.depth++
+	return w.enforceLimits()
+}
+
+// Peek returns the oldest frame of the log without removing it, nil if the log is empty
+func (w *wal) Peek() ([]byte, *frameRef, error) {
+	payloads, refs, err := w.PeekBatch(1)
+	if err != nil || len(refs) == 0 {
+		return nil, nil, err
+	}
+	return payloads[0], refs[0], nil
+}
+
+// PeekBatch returns up to limit of the oldest frames of the log in order, without removing them. They are committed
+// in the same order.
+func (w *wal) PeekBatch(limit int) ([][]byte, []*frameRef, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.depth == 0 {
+		return nil, nil, nil
+	}
+	if w.readOffset >= w.segments[0].size {
+		if err := w.advanceHead(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var payloads [][]byte
+	var refs []*frameRef
+	offset := w.readOffset
+	for _, seg := range w.segments {
+		if len(refs) == limit {
+			break
+		}
+		file, err := os.Open(seg.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for offset < seg.size && len(refs) < limit {
+			payload, frameSize, err := readFrame(file, offset)
+			if err != nil {
+				_ = file.Close()
+				return nil, nil, err
+			}
+			payloads = append(payloads, payload)
+			refs = append(refs, &frameRef{segment: seg.id, offset: offset, size: frameSize})
+			offset += frameSize
+		}
+		_ = file.Close()
+		offset = 0
+	}
+	return payloads, refs, nil
+}
+
+// Commit removes the frame returned by Peek. It is a no-op if the frame was dropped in the meantime
+// to honor the size or age caps.
+func (w *wal) Commit(ref *frameRef) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.depth == 0 || w.segments[0].id != ref.segment || w.readOffset != ref.offset {
+		return nil
+	}
+
+	w.readOffset += ref.size
+	w.headConsumed++
+	w.depth--
+	if w.depth == 0 {
+		return w.reset()
+	}
+	if w.readOffset >= w.segments[0].size && len(w.segments) > 1 {
+		return w.advanceHead()
+	}
+	return w.writeCursor()
+}
+
+// Depth returns the number of frames waiting to be replayed
+func (w *wal) Depth() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.depth
+}
+
+// Size returns the number of bytes used by the segments on disk
+func (w *wal) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// Close releases the segment being written
+func (w *wal) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writer.Close()
+}
+
+// enforceLimits drops the oldest segments while the log exceeds its size or age cap
+func (w *wal) enforceLimits() (int, error) {
+	dropped := 0
+	for w.depth > 0 {
+		head := w.segments[0]
+		overSize := w.maxBytes > 0 && w.size > w.maxBytes
+		overAge := w.maxAge > 0 && time.Since(head.lastWrite) > w.maxAge
+		if !overSize && !overAge {
+			break
+		}
+		dropped += head.records - w.headConsumed
+		if len(w.segments) == 1 {
+			w.depth = 0
+			return dropped, w.reset()
+		}
+		w.depth -= head.records - w.headConsumed
+		if err := w.advanceHead(); err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+// advanceHead deletes the head segment and moves the cursor to the next one
+func (w *wal) advanceHead() error {
+	head := w.segments[0]
+	if err := os.Remove(head.path); err != nil {
+		return err
+	}
+	w.size -= head.size
+	w.segments = w.segments[1:]
+	w.readOffset = 0
+	w.headConsumed = 0
+	return w.writeCursor()
+}
+
+// reset deletes every segment once the log is drained and starts a fresh one
+func (w *wal) reset() error {
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	nextID := w.segments[len(w.segments)-1].id + 1
+	for _, seg := range w.segments {
+		if err := os.Remove(seg.path); err != nil {
+			return err
+		}
+	}
+	w.segments = nil
+	w.size = 0
+	w.readOffset = 0
+	w.headConsumed = 0
+	return w.createSegment(nextID)
+}
+
+// roll closes the segment being written and starts the next one
+func (w *wal) roll() error {
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	return w.createSegment(w.segments[len(w.segments)-1].id + 1)
+}
+
+func (w *wal) createSegment(id uint64) error {
+	path := w.segmentPath(id)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w.writer = file
+	w.segments = append(w.segments, &segment{id: id, path: path, lastWrite: time.Now()})
+	return w.writeCursor()
+}
+
+func (w *wal) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", id, segmentExtension))
+}
+
+func (w *wal) readCursor() (cursor, error) {
+	var pos cursor
+	data, err := os.ReadFile(filepath.Join(w.dir, cursorFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return pos, nil
+	}
+	if err != nil {
+		return pos, err
+	}
+	if err := json.Unmarshal(data, &pos); err != nil {
+		return cursor{}, nil
+	}
+	return pos, nil
+}
+
+// writeCursor atomically persists the replay position
+func (w *wal) writeCursor() error {
+	pos := cursor{Segment: w.segments[0].id, Offset: w.readOffset}
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(w.dir, cursorFileName)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func listSegmentIDs(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// scanSegment counts the valid frames of a segment and truncates anything after the last one
+func scanSegment(id uint64, path string) (*segment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	validSize, records, err := countFrames(path, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if validSize < info.Size() {
+		if err := os.Truncate(path, validSize); err != nil {
+			return nil, err
+		}
+	}
+	return &segment{id: id, path: path, size: validSize, records: records, lastWrite: info.ModTime()}, nil
+}
+
+// countFrames walks the valid frames of a file up to limit bytes, returning the offset reached and the frame count
+func countFrames(path string, limit int64) (int64, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var offset int64
+	records := 0
+	for offset < limit {
+		_, frameSize, err := readFrame(file, offset)
+		if errors.Is(err, errCorruptFrame) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		offset += frameSize
+		records++
+	}
+	return offset, records, nil
+}
+
+// readFrame reads the frame at offset, returning its payload and total size
+func readFrame(file *os.File, offset int64) ([]byte, int64, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := file.ReadAt(header, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, errCorruptFrame
+		}
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxFrameSize {
+		return nil, 0, errCorruptFrame
+	}
+	payload := make([]byte, length)
+	if _, err := file.ReadAt(payload, offset+frameHeaderSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, errCorruptFrame
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errCorruptFrame
+	}
+	return payload, int64(frameHeaderSize + len(payload)), nil
+}
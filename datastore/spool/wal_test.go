@@ -0,0 +1,187 @@
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("wal", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	drain := func(w *wal) []string {
+		var out []string
+		for {
+			payload, ref, err := w.Peek()
+			Expect(err).NotTo(HaveOccurred())
+			if ref == nil {
+				return out
+			}
+			out = append(out, string(payload))
+			Expect(w.Commit(ref)).To(Succeed())
+		}
+	}
+
+	It("replays frames in order", func() {
+		w, err := openWAL(dir, 64, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+
+		for i := 0; i < 10; i++ {
+			_, err := w.Append([]byte(fmt.Sprintf("record-%d", i)))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(w.Depth()).To(Equal(10))
+
+		segments, err := listSegmentIDs(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(segments)).To(BeNumerically(">", 1))
+
+		out := drain(w)
+		Expect(out).To(HaveLen(10))
+		Expect(out[0]).To(Equal("record-0"))
+		Expect(out[9]).To(Equal("record-9"))
+		Expect(w.Depth()).To(Equal(0))
+		Expect(w.Size()).To(BeEquivalentTo(0))
+	})
+
+	It("peeks batches across segments, committed in order", func() {
+		w, err := openWAL(dir, 64, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+		for i := 0; i < 10; i++ {
+			_, err := w.Append([]byte(fmt.Sprintf("record-%d", i)))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		payloads, refs, err := w.PeekBatch(7)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refs).To(HaveLen(7))
+		Expect(refs[0].segment).NotTo(Equal(refs[6].segment))
+		Expect(string(payloads[0])).To(Equal("record-0"))
+		Expect(string(payloads[6])).To(Equal("record-6"))
+		for _, ref := range refs[:5] {
+			Expect(w.Commit(ref)).To(Succeed())
+		}
+		Expect(w.Depth()).To(Equal(5))
+
+		payloads, refs, err = w.PeekBatch(7)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refs).To(HaveLen(5))
+		Expect(string(payloads[0])).To(Equal("record-5"))
+		for _, ref := range refs {
+			Expect(w.Commit(ref)).To(Succeed())
+		}
+		Expect(w.Depth()).To(Equal(0))
+	})
+
+	It("resumes from the cursor after a restart", func() {
+		w, err := openWAL(dir, 64, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 6; i++ {
+			_, err := w.Append([]byte(fmt.Sprintf("record-%d", i)))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		for i := 0; i < 4; i++ {
+			_, ref, err := w.Peek()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.Commit(ref)).To(Succeed())
+		}
+		Expect(w.Close()).To(Succeed())
+
+		w, err = openWAL(dir, 64, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+		Expect(w.Depth()).To(Equal(2))
+		Expect(drain(w)).To(Equal([]string{"record-4", "record-5"}))
+	})
+
+	It("truncates a partially written frame", func() {
+		w, err := openWAL(dir, 1024, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Append([]byte("complete"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+
+		ids, err := listSegmentIDs(dir)
+		Expect(err).NotTo(HaveOccurred())
+		file, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%020d%s", ids[0], segmentExtension)), os.O_WRONLY|os.O_APPEND, 0o644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Write([]byte{0, 0, 0, 20, 1, 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		w, err = openWAL(dir, 1024, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+		Expect(w.Depth()).To(Equal(1))
+		_, err = w.Append([]byte("next"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drain(w)).To(Equal([]string{"complete", "next"}))
+	})
+
+	It("drops the oldest segments past the size cap", func() {
+		w, err := openWAL(dir, 64, 128, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+
+		dropped := 0
+		for i := 0; i < 20; i++ {
+			n, err := w.Append([]byte(fmt.Sprintf("record-%02d", i)))
+			Expect(err).NotTo(HaveOccurred())
+			dropped += n
+		}
+		Expect(dropped).To(BeNumerically(">", 0))
+		Expect(w.Size()).To(BeNumerically("<=", 128))
+		Expect(w.Depth()).To(Equal(20 - dropped))
+
+		out := drain(w)
+		Expect(out).To(HaveLen(20 - dropped))
+		Expect(out[len(out)-1]).To(Equal("record-19"))
+	})
+
+	It("drops segments past the age cap", func() {
+		w, err := openWAL(dir, 32, 0, 10*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+
+		_, err = w.Append([]byte("old-record-1"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Append([]byte("old-record-2"))
+		Expect(err).NotTo(HaveOccurred())
+		time.Sleep(20 * time.Millisecond)
+
+		dropped, err := w.Append([]byte("new-record"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dropped).To(Equal(2))
+		Expect(drain(w)).To(Equal([]string{"new-record"}))
+	})
+
+	It("ignores a commit for a frame dropped in the meantime", func() {
+		w, err := openWAL(dir, 32, 0, 10*time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Close()
+
+		_, err = w.Append([]byte("old-record"))
+		Expect(err).NotTo(HaveOccurred())
+		_, ref, err := w.Peek()
+		Expect(err).NotTo(HaveOccurred())
+
+		time.Sleep(20 * time.Millisecond)
+		_, err = w.Append([]byte("new-record-1"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Append([]byte("new-record-2"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(w.Commit(ref)).To(Succeed())
+		Expect(drain(w)).To(Equal([]string{"new-record-1", "new-record-2"}))
+	})
+})
@@ -0,0 +1,377 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/simple/transformers"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// DefaultBufferSize is the number of events buffered per subscriber
+	DefaultBufferSize = 1000
+
+	// SlowConsumerDrop drops the events a subscriber is too slow to receive
+	SlowConsumerDrop = "drop"
+	// SlowConsumerDisconnect disconnects a subscriber too slow to receive its events
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// ErrHubClosed is returned when subscribing to a closed hub
+var ErrHubClosed = errors.New("stream hub closed")
+
+// payloadKeys are the keys of transformers.PayloadToMap which are not fields, kept by field filters
+var payloadKeys = []string{"Vin", "CreatedAt", "IsResend"}
+
+// Config for the stream dispatcher
+type Config struct {
+	// Token is the bearer token required by the stream endpoints, the STREAM_TOKEN environment variable takes
+	// precedence. Default: the admin token
+	Token string `json:"token,omitempty"`
+
+	// BufferSize is the number of events buffered per subscriber. Default: 1000
+	BufferSize int `json:"buffer_size,omitempty"`
+
+	// SlowConsumer is the action taken when the buffer of a subscriber is full, "drop" or "disconnect". Default: drop
+	SlowConsumer string `json:"slow_consumer,omitempty"`
+
+	// Verbose controls whether the types of the payload values are explicitly shown, like the logger dispatcher
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+// Filter selects the records sent to a subscriber, an empty list selects everything
+type Filter struct {
+	// Vins of the vehicles streamed
+	Vins []string
+
+	// RecordTypes streamed (ex.: "V", "alerts")
+	RecordTypes []string
+
+	// Fields of V records streamed, using protos.Field names (ex.: "VehicleSpeed")
+	Fields []string
+}
+
+// Event is the JSON representation of a record sent to subscribers
+type Event struct {
+	Vin        string      `json:"vin"`
+	Txid       string      `json:"txid"`
+	RecordType string      `json:"record_type"`
+	ReceivedAt int64       `json:"received_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	subscriberCount adapter.Gauge
+	sentCount       adapter.Counter
+	droppedCount    adapter.Counter
+	disconnectCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Hub fans the records dispatched to the stream dispatcher out to the subscribers of the stream endpoints
+type Hub struct {
+	bufferSize   int
+	slowConsumer string
+	verbose      bool
+	logger       *logrus.Logger
+
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+}
+
+// Subscription receives the events matching its filter until it is closed or disconnected by the hub
+type Subscription struct {
+	hub         *Hub
+	vins        map[string]struct{}
+	recordTypes map[string]struct{}
+	fields      map[string]struct{}
+
+	events    chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHub validates the stream configuration
+func NewHub(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Hub, error) {
+	registerMetricsOnce(metricsCollector)
+
+	hub := &Hub{
+		bufferSize:    config.BufferSize,
+		slowConsumer:  config.SlowConsumer,
+		verbose:       config.Verbose,
+		logger:        logger,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+	if hub.bufferSize <= 0 {
+		hub.bufferSize = DefaultBufferSize
+	}
+	switch hub.slowConsumer {
+	case "":
+		hub.slowConsumer = SlowConsumerDrop
+	case SlowConsumerDrop, SlowConsumerDisconnect:
+	default:
+		return nil, fmt.Errorf("invalid stream slow_consumer: %s", hub.slowConsumer)
+	}
+	return hub, nil
+}
+
+// Subscribe registers a subscriber, it must close the subscription once done
+func (h *Hub) Subscribe(filter *Filter) (*Subscription, error) {
+	subscription := &Subscription{
+		hub:         h,
+		vins:        toSet(filter.Vins),
+		recordTypes: toSet(filter.RecordTypes),
+		fields:      toSet(filter.Fields),
+		events:      make(chan []byte, h.bufferSize),
+		done:        make(chan struct{}),
+	}
+	for field := range subscription.fields {
+		if _, ok := protos.Field_value[field]; !ok {
+			return nil, fmt.Errorf("unknown field: %s", field)
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	h.subscriptions[subscription] = struct{}{}
+	metricsRegistry.subscriberCount.Set(int64(len(h.subscriptions)), map[string]string{})
+	return subscription, nil
+}
+
+// Publish sends the record to the matching subscribers without blocking, applying the slow consumer action to
+// the subscribers whose buffer is full
+func (h *Hub) Publish(record *telemetry.Record) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var event *encodedEvent
+	for subscription := range h.subscriptions {
+		if !subscription.matches(record) {
+			continue
+		}
+		if event == nil {
+			var err error
+			if event, err = h.encode(record); err != nil {
+				h.logger.ErrorLog("stream_encode_error", err, logrus.LogInfo{"vin": record.Vin, "txtype": record.TxType})
+				return
+			}
+		}
+		data, ok := event.filter(subscription.fields)
+		if !ok {
+			continue
+		}
+		h.send(subscription, data, record.TxType)
+	}
+}
+
+// Close disconnects every subscriber
+func (h *Hub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.closed = true
+	for subscription := range h.subscriptions {
+		subscription.disconnect()
+		delete(h.subscriptions, subscription)
+	}
+	metricsRegistry.subscriberCount.Set(0, map[string]string{})
+}
+
+func (h *Hub) send(subscription *Subscription, data []byte, recordType string) {
+	select {
+	case <-subscription.done:
+		return
+	default:
+	}
+
+	select {
+	case subscription.events <- data:
+		metricsRegistry.sentCount.Inc(map[string]string{"record_type": recordType})
+	default:
+		if h.slowConsumer == SlowConsumerDisconnect {
+			subscription.disconnect()
+			metricsRegistry.disconnectCount.Inc(map[string]string{})
+			return
+		}
+		metricsRegistry.droppedCount.Inc(map[string]string{"record_type": recordType})
+	}
+}
+
+func (h *Hub) unsubscribe(subscription *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.subscriptions[subscription]; ok {
+		delete(h.subscriptions, subscription)
+		metricsRegistry.subscriberCount.Set(int64(len(h.subscriptions)), map[string]string{})
+	}
+}
+
+// encodedEvent is the event of a record, encoded once for every subscriber without field filter
+type encodedEvent struct {
+	event *Event
+	data  []byte
+}
+
+func (h *Hub) encode(record *telemetry.Record) (*encodedEvent, error) {
+	data, ok := transformers.MessageToMap(record.GetProtoMessage(), h.verbose, record.Vin, h.logger)
+	if !ok {
+		return nil, fmt.Errorf("unknown txType: %s", record.TxType)
+	}
+	event := &Event{
+		Vin:        record.Vin,
+		Txid:       record.Txid,
+		RecordType: record.TxType,
+		ReceivedAt: record.ReceivedTimestamp,
+		Data:       data,
+	}
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedEvent{event: event, data: encoded}, nil
+}
+
+// filter returns the event with only the given fields of a V record, false when it has none of them
+func (e *encodedEvent) filter(fields map[string]struct{}) ([]byte, bool) {
+	payload, ok := e.event.Data.(map[string]interface{})
+	if len(fields) == 0 || !ok || e.event.RecordType != "V" {
+		return e.data, true
+	}
+
+	filtered := make(map[string]interface{}, len(fields)+len(payloadKeys))
+	for field := range fields {
+		if value, ok := payload[field]; ok {
+			filtered[field] = value
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, false
+	}
+	for _, key := range payloadKeys {
+		filtered[key] = payload[key]
+	}
+
+	event := *e.event
+	event.Data = filtered
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Events returns the JSON encoded events of the subscription
+func (s *Subscription) Events() <-chan []byte {
+	return s.events
+}
+
+// Done is closed once the subscription is closed, or disconnected by the hub
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close unsubscribes from the hub
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+	s.disconnect()
+}
+
+func (s *Subscription) disconnect() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func (s *Subscription) matches(record *telemetry.Record) bool {
+	return contains(s.vins, record.Vin) && contains(s.recordTypes, record.TxType)
+}
+
+// contains returns whether the value is in the set, an empty set contains everything
+func contains(set map[string]struct{}, value string) bool {
+	if len(set) == 0 {
+		return true
+	}
+	_, ok := set[value]
+	return ok
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	return set
+}
+
+// Producer publishes the records to the subscribers of a hub
+type Producer struct {
+	hub *Hub
+}
+
+// NewProducer returns a producer publishing to the hub, which outlives the producer
+func NewProducer(hub *Hub) telemetry.Producer {
+	return &Producer{hub: hub}
+}
+
+// Produce publishes the record to the subscribers
+func (p *Producer) Produce(entry *telemetry.Record) {
+	p.hub.Publish(entry)
+}
+
+// Close is a no-op, the hub is closed by its owner so subscribers survive config reloads
+func (p *Producer) Close() error {
+	return nil
+}
+
+// ProcessReliableAck noop method
+func (p *Producer) ProcessReliableAck(_ *telemetry.Record) {
+}
+
+// ReportError noop method
+func (p *Producer) ReportError(_ string, _ error, _ logrus.LogInfo) {
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.subscriberCount = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "stream_subscribers",
+		Help:   "The number of subscribers to the stream endpoints.",
+		Labels: []string{},
+	})
+
+	metricsRegistry.sentCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "stream_sent_total",
+		Help:   "The number of events queued to stream subscribers.",
+		Labels: []string{"record_type"},
+	})
+
+	metricsRegistry.droppedCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "stream_dropped_total",
+		Help:   "The number of events dropped because a stream subscriber was too slow.",
+		Labels: []string{"record_type"},
+	})
+
+	metricsRegistry.disconnectCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "stream_slow_consumer_disconnect_total",
+		Help:   "The number of stream subscribers disconnected for being too slow.",
+		Labels: []string{},
+	})
+}
@@ -0,0 +1,172 @@
+package stream_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/stream"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Stream hub", func() {
+	var (
+		logger *logrus.Logger
+		hub    *stream.Hub
+	)
+
+	newRecord := func(vin, txType string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newPayloadRecord := func(vin string) *telemetry.Record {
+		return newRecord(vin, "V", &protos.Payload{
+			Vin:       vin,
+			CreatedAt: timestamppb.Now(),
+			Data: []*protos.Datum{
+				{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 42}}},
+				{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 80}}},
+			},
+		})
+	}
+
+	newHub := func(config *stream.Config) *stream.Hub {
+		hub, err := stream.NewHub(config, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		return hub
+	}
+
+	subscribe := func(filter *stream.Filter) *stream.Subscription {
+		subscription, err := hub.Subscribe(filter)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(subscription.Close)
+		return subscription
+	}
+
+	receive := func(subscription *stream.Subscription) *stream.Event {
+		var data []byte
+		Eventually(subscription.Events()).Should(Receive(&data))
+		event := &stream.Event{}
+		Expect(json.Unmarshal(data, event)).To(Succeed())
+		return event
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		hub = newHub(&stream.Config{})
+	})
+
+	AfterEach(func() {
+		hub.Close()
+	})
+
+	It("sends the records to every subscriber", func() {
+		first := subscribe(&stream.Filter{})
+		second := subscribe(&stream.Filter{})
+
+		stream.NewProducer(hub).Produce(newPayloadRecord("vin-1"))
+
+		for _, subscription := range []*stream.Subscription{first, second} {
+			event := receive(subscription)
+			Expect(event.Vin).To(Equal("vin-1"))
+			Expect(event.Txid).To(Equal("1234"))
+			Expect(event.RecordType).To(Equal("V"))
+			Expect(event.Data).To(HaveKeyWithValue("VehicleSpeed", 42.0))
+			Expect(event.Data).To(HaveKeyWithValue("Soc", 80.0))
+		}
+	})
+
+	It("filters by vin and record type", func() {
+		subscription := subscribe(&stream.Filter{Vins: []string{"vin-2"}, RecordTypes: []string{"alerts"}})
+
+		hub.Publish(newPayloadRecord("vin-2"))
+		hub.Publish(newRecord("vin-1", "alerts", &protos.VehicleAlerts{Vin: "vin-1", Alerts: []*protos.VehicleAlert{{Name: "alert-1"}}}))
+		hub.Publish(newRecord("vin-2", "alerts", &protos.VehicleAlerts{Vin: "vin-2", Alerts: []*protos.VehicleAlert{{Name: "alert-2"}}}))
+
+		event := receive(subscription)
+		Expect(event.Vin).To(Equal("vin-2"))
+		Expect(event.RecordType).To(Equal("alerts"))
+		Expect(event.Data).To(ConsistOf(HaveKeyWithValue("Name", "alert-2")))
+		Consistently(subscription.Events()).ShouldNot(Receive())
+	})
+
+	It("keeps only the fields of the filter", func() {
+		subscription := subscribe(&stream.Filter{Fields: []string{"Soc", "Odometer"}})
+
+		hub.Publish(newPayloadRecord("vin-1"))
+
+		event := receive(subscription)
+		Expect(event.Data).To(HaveKeyWithValue("Soc", 80.0))
+		Expect(event.Data).To(HaveKey("Vin"))
+		Expect(event.Data).NotTo(HaveKey("VehicleSpeed"))
+	})
+
+	It("skips the records without any field of the filter", func() {
+		subscription := subscribe(&stream.Filter{Fields: []string{"Odometer"}})
+
+		hub.Publish(newPayloadRecord("vin-1"))
+
+		Consistently(subscription.Events()).ShouldNot(Receive())
+	})
+
+	It("rejects unknown fields", func() {
+		_, err := hub.Subscribe(&stream.Filter{Fields: []string{"NotAField"}})
+		Expect(err).To(MatchError("unknown field: NotAField"))
+	})
+
+	It("drops the events of slow consumers", func() {
+		hub = newHub(&stream.Config{BufferSize: 1})
+		subscription := subscribe(&stream.Filter{})
+
+		hub.Publish(newPayloadRecord("vin-1"))
+		hub.Publish(newPayloadRecord("vin-2"))
+
+		Expect(receive(subscription).Vin).To(Equal("vin-1"))
+		Consistently(subscription.Events()).ShouldNot(Receive())
+		Expect(subscription.Done()).NotTo(BeClosed())
+	})
+
+	It("disconnects slow consumers", func() {
+		hub = newHub(&stream.Config{BufferSize: 1, SlowConsumer: stream.SlowConsumerDisconnect})
+		slow := subscribe(&stream.Filter{})
+		other := subscribe(&stream.Filter{Vins: []string{"vin-2"}})
+
+		hub.Publish(newPayloadRecord("vin-1"))
+		hub.Publish(newPayloadRecord("vin-2"))
+
+		Expect(slow.Done()).To(BeClosed())
+		Expect(other.Done()).NotTo(BeClosed())
+		Expect(receive(other).Vin).To(Equal("vin-2"))
+	})
+
+	It("rejects an invalid slow consumer action", func() {
+		_, err := stream.NewHub(&stream.Config{SlowConsumer: "block"}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).To(MatchError("invalid stream slow_consumer: block"))
+	})
+
+	It("disconnects every subscriber on close", func() {
+		subscription := subscribe(&stream.Filter{})
+
+		hub.Close()
+
+		Expect(subscription.Done()).To(BeClosed())
+		_, err := hub.Subscribe(&stream.Filter{})
+		Expect(err).To(MatchError(stream.ErrHubClosed))
+	})
+})
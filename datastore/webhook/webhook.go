@@ -0,0 +1,528 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// Default values for the webhook producer configuration options.
+const (
+	DefaultBatchSize      = 100
+	DefaultLingerMs       = 1000
+	DefaultMaxRetries     = 5
+	DefaultRetryBackoffMs = 500
+	DefaultMaxBackoffMs   = 30000
+	DefaultTimeoutMs      = 10000
+	DefaultQueueSize      = 10000
+
+	// FormatJSON posts batches as a JSON array of records
+	FormatJSON = "json"
+	// FormatProtobuf posts batches as length-delimited protobuf messages
+	FormatProtobuf = "protobuf"
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body
+	SignatureHeader = "X-Signature"
+
+	secretEnv = "WEBHOOK_SECRET"
+)
+
+// Config for the webhook producer
+type Config struct {
+	// URL receives the records of types without an entry in URLs
+	URL string `json:"url,omitempty"`
+
+	// URLs is a mapping of record types to the URL receiving them
+	URLs map[string]string `json:"urls,omitempty"`
+
+	// Format of the request body, "json" or "protobuf". Default: json
+	Format string `json:"format,omitempty"`
+
+	// BatchSize is the maximum number of records per request. Default: 100
+	BatchSize int `json:"batch_size,omitempty"`
+
+	// LingerMs is how long a partial batch waits for more records before being sent. Default: 1000
+	LingerMs int `json:"linger_ms,omitempty"`
+
+	// MaxRetries is the number of retries of a failed request. Default: 5
+	MaxRetries *int `json:"max_retries,omitempty"`
+
+	// RetryBackoffMs is the initial retry delay, doubled after each attempt. Default: 500
+	RetryBackoffMs int `json:"retry_backoff_ms,omitempty"`
+
+	// MaxBackoffMs caps the retry delay. Default: 30000
+	MaxBackoffMs int `json:"max_backoff_ms,omitempty"`
+
+	// TimeoutMs is the timeout of a single request. Default: 10000
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+
+	// QueueSize is the number of records waiting to be sent to a URL, records are failed while it is full so a slow
+	// endpoint does not block the vehicle connections. Default: 10000, at least the batch size
+	QueueSize int `json:"queue_size,omitempty"`
+
+	// Secret signs request bodies in the X-Signature header, the WEBHOOK_SECRET env variable takes precedence
+	Secret string `json:"secret,omitempty"`
+
+	// Headers are added to every request
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// jsonRecord is the JSON representation of a record in a batch
+type jsonRecord struct {
+	Vin        string          `json:"vin"`
+	Txid       string          `json:"txid"`
+	RecordType string          `json:"record_type"`
+	ReceivedAt int64           `json:"received_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Producer client to handle webhook interactions
+type Producer struct {
+	dispatcher         telemetry.Dispatcher
+	config             *Config
+	client             *http.Client
+	secret             []byte
+	maxRetries         int
+	logger             *logrus.Logger
+	airbrakeHandler    *airbrake.Handler
+	ackChan            chan (*telemetry.Ack)
+	reliableAckTxTypes map[string]interface{}
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mu       sync.RWMutex
+	closed   bool
+	batchers map[string]*batcher
+	wg       sync.WaitGroup
+
+	telemetry.DeliveryNotifier
+}
+
+// batcher accumulates the records posted to a single URL
+type batcher struct {
+	url     string
+	records chan *telemetry.Record
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	produceCount     adapter.Counter
+	bytesTotal       adapter.Counter
+	queueFullCount   adapter.Counter
+	requestCount     adapter.Counter
+	retryCount       adapter.Counter
+	errorCount       adapter.Counter
+	reliableAckCount adapter.Counter
+	batchSize        adapter.Timer
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.Webhook, newProducerFromConfig, telemetry.IgnoresRecordTypes())
+}
+
+// newProducerFromConfig is the producer factory of the webhook dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected Webhook to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+	return NewProducer(config, params.Dispatcher, params.MetricsCollector, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
+}
+
+// NewProducer validates the webhook configuration and starts one batching worker per URL
+func NewProducer(config *Config, dispatcher telemetry.Dispatcher, metricsCollector metrics.MetricCollector, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (telemetry.Producer, error) {
+	registerMetricsOnce(metricsCollector)
+
+	if config.URL == "" && len(config.URLs) == 0 {
+		return nil, errors.New("webhook url or urls must be configured")
+	}
+	if config.Format == "" {
+		config.Format = FormatJSON
+	}
+	if config.Format != FormatJSON && config.Format != FormatProtobuf {
+		return nil, fmt.Errorf("unsupported webhook format: %s", config.Format)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.LingerMs <= 0 {
+		config.LingerMs = DefaultLingerMs
+	}
+	if config.RetryBackoffMs <= 0 {
+		config.RetryBackoffMs = DefaultRetryBackoffMs
+	}
+	if config.MaxBackoffMs <= 0 {
+		config.MaxBackoffMs = DefaultMaxBackoffMs
+	}
+	if config.TimeoutMs <= 0 {
+		config.TimeoutMs = DefaultTimeoutMs
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	config.QueueSize = max(config.QueueSize, config.BatchSize)
+	maxRetries := DefaultMaxRetries
+	if config.MaxRetries != nil {
+		maxRetries = *config.MaxRetries
+	}
+
+	secret := config.Secret
+	if envSecret, ok := os.LookupEnv(secretEnv); ok {
+		secret = envSecret
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	producer := &Producer{
+		dispatcher:         dispatcher,
+		config:             config,
+		client:             &http.Client{Timeout: time.Duration(config.TimeoutMs) * time.Millisecond},
+		secret:             []byte(secret),
+		maxRetries:         maxRetries,
+		logger:             logger,
+		airbrakeHandler:    airbrakeHandler,
+		ackChan:            ackChan,
+		reliableAckTxTypes: reliableAckTxTypes,
+		ctx:                ctx,
+		cancel:             cancel,
+		batchers:           make(map[string]*batcher),
+	}
+	for _, url := range append([]string{config.URL}, mapValues(config.URLs)...) {
+		if _, ok := producer.batchers[url]; ok || url == "" {
+			continue
+		}
+		b := &batcher{url: url, records: make(chan *telemetry.Record, config.QueueSize)}
+		producer.batchers[url] = b
+		producer.wg.Add(1)
+		go producer.runBatcher(b)
+	}
+	producer.logger.ActivityLog("webhook_registered", logrus.LogInfo{"format": config.Format, "batch_size": config.BatchSize, "signed": len(secret) > 0})
+	return producer, nil
+}
+
+// Produce queues the record in the batch of its URL, or fails it when the queue is full
+func (p *Producer) Produce(entry *telemetry.Record) {
+	b, ok := p.batchers[p.urlFor(entry.TxType)]
+	if !ok {
+		p.ReportError("webhook_url_not_configured", nil, logrus.LogInfo{"record_type": entry.TxType})
+		p.NotifyDelivery(entry, telemetry.ErrRecordRejected)
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.NotifyDelivery(entry, errors.New("webhook producer closed"))
+		return
+	}
+	select {
+	case b.records <- entry:
+	default:
+		metricsRegistry.queueFullCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+		p.NotifyDelivery(entry, errors.New("webhook queue full"))
+		return
+	}
+	metricsRegistry.produceCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	metricsRegistry.bytesTotal.Add(int64(entry.Length()), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+}
+
+// Close stops the retries, then sends the pending batches once and stops the workers
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, b := range p.batchers {
+			close(b.records)
+		}
+	}
+	p.mu.Unlock()
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+// ProcessReliableAck sends to ackChan if reliable ack is configured
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	_, ok := p.reliableAckTxTypes[entry.TxType]
+	if ok {
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	}
+}
+
+// ReportError to airbrake and logger
+func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo) {
+	p.airbrakeHandler.ReportLogMessage(logrus.ERROR, message, err, logInfo)
+	p.logger.ErrorLog(message, err, logInfo)
+}
+
+func (p *Producer) urlFor(txType string) string {
+	if url, ok := p.config.URLs[txType]; ok {
+		return url
+	}
+	return p.config.URL
+}
+
+func mapValues(input map[string]string) []string {
+	values := make([]string, 0, len(input))
+	for _, value := range input {
+		values = append(values, value)
+	}
+	return values
+}
+
+// runBatcher sends a batch once it is full or once the linger time elapsed since its first record
+func (p *Producer) runBatcher(b *batcher) {
+	defer p.wg.Done()
+	linger := time.Duration(p.config.LingerMs) * time.Millisecond
+	timer := time.NewTimer(linger)
+	timer.Stop()
+
+	batch := make([]*telemetry.Record, 0, p.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		p.send(b.url, batch)
+		batch = make([]*telemetry.Record, 0, p.config.BatchSize)
+	}
+
+	for {
+		select {
+		case entry, ok := <-b.records:
+			if !ok {
+				timer.Stop()
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) == 1 {
+				timer.Reset(linger)
+			}
+			if len(batch) >= p.config.BatchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// send posts a batch with retries, then reports the outcome of each record
+func (p *Producer) send(url string, batch []*telemetry.Record) {
+	metricsRegistry.batchSize.Observe(int64(len(batch)), map[string]string{"dispatcher": string(p.dispatcher)})
+	body, contentType, err := p.encode(batch)
+	if err != nil {
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+		p.ReportError("webhook_encode_error", err, logrus.LogInfo{"url": url, "batch_size": len(batch)})
+		p.notifyBatch(batch, telemetry.ErrRecordRejected)
+		return
+	}
+
+	backoff := time.Duration(p.config.RetryBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(p.config.MaxBackoffMs) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err = p.post(url, body, contentType)
+		if err == nil {
+			for _, entry := range batch {
+				p.ProcessReliableAck(entry)
+			}
+			p.notifyBatch(batch, nil)
+			return
+		}
+
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			err = fmt.Errorf("%w: %v", telemetry.ErrRecordRejected, err)
+			break
+		}
+		if attempt >= p.maxRetries || p.ctx.Err() != nil {
+			break
+		}
+		metricsRegistry.retryCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+		p.logger.Log(logrus.DEBUG, "webhook_retry", logrus.LogInfo{"url": url, "attempt": attempt + 1, "error": err.Error()})
+		select {
+		case <-time.After(backoff):
+		case <-p.ctx.Done():
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+
+	metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher)})
+	p.ReportError("webhook_post_error", err, logrus.LogInfo{"url": url, "batch_size": len(batch)})
+	p.notifyBatch(batch, err)
+}
+
+func (p *Producer) notifyBatch(batch []*telemetry.Record, err error) {
+	for _, entry := range batch {
+		p.NotifyDelivery(entry, err)
+	}
+}
+
+// statusError is returned for non 2xx responses
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected webhook response status: %d", e.statusCode)
+}
+
+// retryable returns whether the request may succeed later: server errors and throttling
+func (e *statusError) retryable() bool {
+	return e.statusCode >= http.StatusInternalServerError || e.statusCode == http.StatusTooManyRequests || e.statusCode == http.StatusRequestTimeout
+}
+
+// post sends a request, bounded by the client timeout rather than the producer context so pending batches are still
+// sent on close
+func (p *Producer) post(url string, body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if len(p.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(p.secret, body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		metricsRegistry.requestCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "status": "error"})
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	metricsRegistry.requestCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "status": strconv.Itoa(resp.StatusCode)})
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{statusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// encode serializes a batch in the configured format
+func (p *Producer) encode(batch []*telemetry.Record) ([]byte, string, error) {
+	if p.config.Format == FormatProtobuf {
+		var body []byte
+		for _, entry := range batch {
+			payload := entry.Payload()
+			if message := entry.GetProtoMessage(); message != nil {
+				var err error
+				if payload, err = proto.Marshal(message); err != nil {
+					return nil, "", err
+				}
+			}
+			body = protowire.AppendBytes(body, payload)
+		}
+		return body, "application/x-protobuf; delimited=true", nil
+	}
+
+	records := make([]jsonRecord, 0, len(batch))
+	for _, entry := range batch {
+		payload, err := entry.GetJSONPayload()
+		if err != nil {
+			return nil, "", err
+		}
+		records = append(records, jsonRecord{
+			Vin:        entry.Vin,
+			Txid:       entry.Txid,
+			RecordType: entry.TxType,
+			ReceivedAt: entry.ReceivedTimestamp,
+			Payload:    payload,
+		})
+	}
+	body, err := json.Marshal(records)
+	return body, "application/json", err
+}
+
+// Sign returns the value of the X-Signature header for a body: "sha256=" followed by its hex encoded HMAC-SHA256
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.produceCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_produce_total",
+		Help:   "The number of records queued to webhooks.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.queueFullCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_queue_full_total",
+		Help:   "The number of records failed because the queue of their webhook was full.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.bytesTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_produce_total_bytes",
+		Help:   "The number of bytes queued to webhooks.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.requestCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_request_total",
+		Help:   "The number of webhook requests by response status.",
+		Labels: []string{"dispatcher", "status"},
+	})
+
+	metricsRegistry.retryCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_retry_total",
+		Help:   "The number of webhook requests retried.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_err",
+		Help:   "The number of batches which could not be delivered to webhooks.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "webhook_reliable_ack_total",
+		Help:   "The number of records produced to webhooks for which we sent a reliable ACK.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.batchSize = metricsCollector.RegisterTimer(adapter.CollectorOptions{
+		Name:   "webhook_batch_size",
+		Help:   "The number of records per webhook request.",
+		Labels: []string{"dispatcher"},
+	})
+}
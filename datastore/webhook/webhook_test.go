@@ -0,0 +1,212 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/webhook"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+type receivedRequest struct {
+	body      []byte
+	signature string
+	header    http.Header
+}
+
+var _ = Describe("Webhook producer", func() {
+	var (
+		logger     *logrus.Logger
+		serializer *telemetry.BinarySerializer
+		server     *httptest.Server
+		mu         sync.Mutex
+		requests   []receivedRequest
+		statusCode atomic.Int32
+		ackChan    chan *telemetry.Ack
+		producer   telemetry.Producer
+	)
+
+	newRecord := func(txid string) *telemetry.Record {
+		payload, err := proto.Marshal(&protos.Payload{
+			Vin:  "42",
+			Data: []*protos.Datum{{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: "D"}}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		message := messages.StreamMessage{TXID: []byte(txid), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte("V"), Payload: payload}
+		recordMsg, err := message.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	receivedRequests := func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedRequest{}, requests...)
+	}
+
+	newProducer := func(config *webhook.Config) telemetry.Producer {
+		config.URL = server.URL
+		p, err := webhook.NewProducer(config, telemetry.Webhook, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, map[string]interface{}{"V": true}, logger)
+		Expect(err).NotTo(HaveOccurred())
+		return p
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		serializer = telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{}, logger)
+		requests = nil
+		statusCode.Store(http.StatusOK)
+		ackChan = make(chan *telemetry.Ack, 10)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			requests = append(requests, receivedRequest{body: body, signature: r.Header.Get(webhook.SignatureHeader), header: r.Header})
+			mu.Unlock()
+			w.WriteHeader(int(statusCode.Load()))
+		}))
+	})
+
+	AfterEach(func() {
+		if producer != nil {
+			Expect(producer.Close()).To(Succeed())
+			producer = nil
+		}
+		server.Close()
+	})
+
+	It("posts a full batch as signed json and acks it", func() {
+		producer = newProducer(&webhook.Config{BatchSize: 2, LingerMs: 60000, Secret: "s3cr3t", Headers: map[string]string{"Authorization": "Bearer token"}})
+		producer.Produce(newRecord("1"))
+		producer.Produce(newRecord("2"))
+
+		Eventually(receivedRequests).Should(HaveLen(1))
+		request := receivedRequests()[0]
+		Expect(request.signature).To(Equal(webhook.Sign([]byte("s3cr3t"), request.body)))
+		Expect(request.header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(request.header.Get("Authorization")).To(Equal("Bearer token"))
+
+		var records []map[string]interface{}
+		Expect(json.Unmarshal(request.body, &records)).To(Succeed())
+		Expect(records).To(HaveLen(2))
+		Expect(records[0]["txid"]).To(Equal("1"))
+		Expect(records[0]["record_type"]).To(Equal("V"))
+		Expect(records[0]["payload"]).To(HaveKeyWithValue("vin", "42"))
+
+		Eventually(ackChan).Should(HaveLen(2))
+	})
+
+	It("sends a partial batch after the linger time", func() {
+		producer = newProducer(&webhook.Config{BatchSize: 10, LingerMs: 10})
+		producer.Produce(newRecord("1"))
+
+		Eventually(receivedRequests).Should(HaveLen(1))
+		Expect(receivedRequests()[0].signature).To(BeEmpty())
+	})
+
+	It("posts length-delimited protobuf", func() {
+		producer = newProducer(&webhook.Config{BatchSize: 2, LingerMs: 60000, Format: webhook.FormatProtobuf})
+		producer.Produce(newRecord("1"))
+		producer.Produce(newRecord("2"))
+
+		Eventually(receivedRequests).Should(HaveLen(1))
+		body := receivedRequests()[0].body
+		for i := 0; i < 2; i++ {
+			payload, n := protowire.ConsumeBytes(body)
+			Expect(n).To(BeNumerically(">", 0))
+			data := &protos.Payload{}
+			Expect(proto.Unmarshal(payload, data)).To(Succeed())
+			Expect(data.GetVin()).To(Equal("42"))
+			body = body[n:]
+		}
+		Expect(body).To(BeEmpty())
+	})
+
+	It("retries server errors and acks once delivered", func() {
+		statusCode.Store(http.StatusServiceUnavailable)
+		maxRetries := 100
+		producer = newProducer(&webhook.Config{BatchSize: 1, RetryBackoffMs: 5, MaxBackoffMs: 10, MaxRetries: &maxRetries})
+		producer.Produce(newRecord("1"))
+
+		Eventually(receivedRequests).Should(HaveLen(2))
+		Expect(ackChan).To(BeEmpty())
+		statusCode.Store(http.StatusAccepted)
+		Eventually(ackChan).Should(HaveLen(1))
+	})
+
+	It("does not ack nor retry client errors", func() {
+		statusCode.Store(http.StatusBadRequest)
+		maxRetries := 3
+		producer = newProducer(&webhook.Config{BatchSize: 1, RetryBackoffMs: 5, MaxRetries: &maxRetries})
+		producer.Produce(newRecord("1"))
+
+		Eventually(receivedRequests).Should(HaveLen(1))
+		Consistently(receivedRequests, "50ms").Should(HaveLen(1))
+		Expect(ackChan).To(BeEmpty())
+	})
+
+	It("flushes pending records on close", func() {
+		producer = newProducer(&webhook.Config{BatchSize: 10, LingerMs: 60000})
+		producer.Produce(newRecord("1"))
+		Expect(producer.Close()).To(Succeed())
+		producer = nil
+
+		Expect(receivedRequests()).To(HaveLen(1))
+	})
+
+	It("fails records without blocking once the queue is full", func() {
+		statusCode.Store(http.StatusServiceUnavailable)
+		maxRetries := 100
+		producer = newProducer(&webhook.Config{BatchSize: 1, QueueSize: 1, RetryBackoffMs: 60000, MaxRetries: &maxRetries})
+		failed := make(chan string, 10)
+		producer.(telemetry.DeliveryReporter).SetDeliveryHandler(func(entry *telemetry.Record, err error) {
+			if err != nil {
+				failed <- entry.Txid
+			}
+		})
+
+		producer.Produce(newRecord("1"))
+		Eventually(receivedRequests).Should(HaveLen(1))
+		producer.Produce(newRecord("2"))
+		producer.Produce(newRecord("3"))
+
+		Expect(failed).To(Receive(Equal("3")))
+		Expect(failed).To(BeEmpty())
+	})
+
+	It("does not wait out the retries on close", func() {
+		statusCode.Store(http.StatusServiceUnavailable)
+		maxRetries := 100
+		producer = newProducer(&webhook.Config{BatchSize: 1, RetryBackoffMs: 60000, MaxRetries: &maxRetries})
+		producer.Produce(newRecord("1"))
+		Eventually(receivedRequests).Should(HaveLen(1))
+
+		closed := make(chan error, 1)
+		go func() { closed <- producer.Close() }()
+		Eventually(closed, 5*time.Second).Should(Receive(BeNil()))
+		producer = nil
+		Expect(ackChan).To(BeEmpty())
+	})
+
+	It("requires a url", func() {
+		_, err := webhook.NewProducer(&webhook.Config{}, telemetry.Webhook, metrics.NewCollector(nil, logger), airbrake.NewAirbrakeHandler(nil), ackChan, nil, logger)
+		Expect(err).To(MatchError("webhook url or urls must be configured"))
+	})
+})
@@ -3,6 +3,8 @@ package zmq
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"sync"
 
@@ -60,29 +62,35 @@ const MonitorSocketAddr = "inproc://zmq_socket_monitor.rep"
 // Producer implements the telemetry.Producer interface by publishing to a
 // bound zmq socket.
 type Producer struct {
+	dispatcher         telemetry.Dispatcher
 	namespace          string
 	ctx                context.Context
 	sock               *zmq4.Socket
 	logger             *logrus.Logger
 	airbrakeHandler    *airbrake.Handler
-	ackChan            chan (*telemetry.Record)
+	ackChan            chan (*telemetry.Ack)
 	reliableAckTxTypes map[string]interface{}
+
+	telemetry.DeliveryNotifier
 }
 
 // Produce the record to the socket.
 func (p *Producer) Produce(rec *telemetry.Record) {
 	if p.ctx.Err() != nil {
+		p.NotifyDelivery(rec, p.ctx.Err())
 		return
 	}
 	nBytes, err := p.sock.SendMessage(telemetry.BuildTopicName(p.namespace, rec.TxType), rec.Payload())
 	if err != nil {
-		metricsRegistry.errorCount.Inc(map[string]string{"record_type": rec.TxType})
+		metricsRegistry.errorCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": rec.TxType})
 		p.ReportError("zmq_dispatch_error", err, nil)
+		p.NotifyDelivery(rec, err)
 		return
 	}
 	p.ProcessReliableAck(rec)
-	metricsRegistry.byteTotal.Add(int64(nBytes), map[string]string{"record_type": rec.TxType})
-	metricsRegistry.publishCount.Inc(map[string]string{"record_type": rec.TxType})
+	p.NotifyDelivery(rec, nil)
+	metricsRegistry.byteTotal.Add(int64(nBytes), map[string]string{"dispatcher": string(p.dispatcher), "record_type": rec.TxType})
+	metricsRegistry.publishCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": rec.TxType})
 }
 
 // ReportError to airbrake and logger
@@ -106,13 +114,29 @@ func (p *Producer) Close() error {
 func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
 	_, ok := p.reliableAckTxTypes[entry.TxType]
 	if ok {
-		p.ackChan <- entry
-		metricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": entry.TxType})
+		p.ackChan <- &telemetry.Ack{Record: entry, Dispatcher: p.dispatcher}
+		metricsRegistry.reliableAckCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+	}
+}
+
+func init() {
+	telemetry.RegisterProducerFactory(telemetry.ZMQ, newProducerFromConfig, telemetry.IgnoresRecordTypes())
+}
+
+// newProducerFromConfig is the producer factory of the ZMQ dispatcher
+func newProducerFromConfig(rawConfig json.RawMessage, params *telemetry.ProducerParams) (telemetry.Producer, error) {
+	if len(rawConfig) == 0 {
+		return nil, errors.New("expected ZMQ to be configured")
+	}
+	config := &Config{}
+	if err := json.Unmarshal(rawConfig, config); err != nil {
+		return nil, fmt.Errorf("invalid ZMQ config: %w", err)
 	}
+	return NewProducer(context.Background(), config, params.Dispatcher, params.MetricsCollector, params.Namespace, params.AirbrakeHandler, params.AckChan, params.ReliableAckTxTypes, params.Logger)
 }
 
 // NewProducer creates a ZMQProducer with the given config.
-func NewProducer(ctx context.Context, config *Config, metrics metrics.MetricCollector, namespace string, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Record), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (producer telemetry.Producer, err error) {
+func NewProducer(ctx context.Context, config *Config, dispatcher telemetry.Dispatcher, metrics metrics.MetricCollector, namespace string, airbrakeHandler *airbrake.Handler, ackChan chan (*telemetry.Ack), reliableAckTxTypes map[string]interface{}, logger *logrus.Logger) (producer telemetry.Producer, err error) {
 	registerMetricsOnce(metrics)
 	sock, err := zmq4.NewSocket(zmq4.PUB)
 	if err != nil {
@@ -176,6 +200,7 @@ func NewProducer(ctx context.Context, config *Config, metrics metrics.MetricColl
 	}
 
 	return &Producer{
+		dispatcher:         dispatcher,
 		namespace:          namespace,
 		ctx:                ctx,
 		sock:               sock,
@@ -229,25 +254,25 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 	metricsRegistry.errorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "zmq_err",
 		Help:   "The number of errors while producing to ZMQ.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.publishCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "zmq_publish_total",
 		Help:   "The number of messages published to ZMQ.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.byteTotal = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "zmq_publish_total_bytes",
 		Help:   "The number of bytes published to ZMQ.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 
 	metricsRegistry.reliableAckCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
 		Name:   "zmq_reliable_ack_total",
 		Help:   "The number of records produced to ZMQ for which we sent a reliable ACK.",
-		Labels: []string{"record_type"},
+		Labels: []string{"dispatcher", "record_type"},
 	})
 }
 
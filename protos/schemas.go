@@ -0,0 +1,8 @@
+package protos
+
+import "embed"
+
+// Schemas holds the proto definitions of the records, keyed by file name (ex.: vehicle_data.proto)
+//
+//go:embed *.proto
+var Schemas embed.FS
@@ -0,0 +1,421 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v5.28.3
+// source: protos/vehicle_alert_lifecycle.proto
+
+package protos
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AlertAudience the target audience for the alert, as in VehicleAlert.
+type AlertAudience int32
+
+const (
+	AlertAudience_Unknown    AlertAudience = 0
+	AlertAudience_Customer   AlertAudience = 1
+	AlertAudience_Service    AlertAudience = 2
+	AlertAudience_ServiceFix AlertAudience = 3
+)
+
+// Enum value maps for AlertAudience.
+var (
+	AlertAudience_name = map[int32]string{
+		0: "Unknown",
+		1: "Customer",
+		2: "Service",
+		3: "ServiceFix",
+	}
+	AlertAudience_value = map[string]int32{
+		"Unknown":    0,
+		"Customer":   1,
+		"Service":    2,
+		"ServiceFix": 3,
+	}
+)
+
+func (x AlertAudience) Enum() *AlertAudience {
+	p := new(AlertAudience)
+	*p = x
+	return p
+}
+
+func (x AlertAudience) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AlertAudience) Descriptor() protoreflect.EnumDescriptor {
+	return file_protos_vehicle_alert_lifecycle_proto_enumTypes[0].Descriptor()
+}
+
+func (AlertAudience) Type() protoreflect.EnumType {
+	return &file_protos_vehicle_alert_lifecycle_proto_enumTypes[0]
+}
+
+func (x AlertAudience) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AlertAudience.Descriptor instead.
+func (AlertAudience) EnumDescriptor() ([]byte, []int) {
+	return file_protos_vehicle_alert_lifecycle_proto_rawDescGZIP(), []int{0}
+}
+
+// AlertEvent is whether the alert opened or closed.
+type AlertEvent int32
+
+const (
+	AlertEvent_UNKNOWN AlertEvent = 0
+	AlertEvent_OPENED  AlertEvent = 1
+	AlertEvent_CLOSED  AlertEvent = 2
+)
+
+// Enum value maps for AlertEvent.
+var (
+	AlertEvent_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "OPENED",
+		2: "CLOSED",
+	}
+	AlertEvent_value = map[string]int32{
+		"UNKNOWN": 0,
+		"OPENED":  1,
+		"CLOSED":  2,
+	}
+)
+
+func (x AlertEvent) Enum() *AlertEvent {
+	p := new(AlertEvent)
+	*p = x
+	return p
+}
+
+func (x AlertEvent) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AlertEvent) Descriptor() protoreflect.EnumDescriptor {
+	return file_protos_vehicle_alert_lifecycle_proto_enumTypes[1].Descriptor()
+}
+
+func (AlertEvent) Type() protoreflect.EnumType {
+	return &file_protos_vehicle_alert_lifecycle_proto_enumTypes[1]
+}
+
+func (x AlertEvent) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AlertEvent.Descriptor instead.
+func (AlertEvent) EnumDescriptor() ([]byte, []int) {
+	return file_protos_vehicle_alert_lifecycle_proto_rawDescGZIP(), []int{1}
+}
+
+// VehicleAlertLifecycle is a collection of the alerts a single vehicle opened and closed in a batch of alerts.
+type VehicleAlertLifecycle struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Events    []*AlertLifecycleEvent `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Vin       string                 `protobuf:"bytes,3,opt,name=vin,proto3" json:"vin,omitempty"`
+}
+
+func (x *VehicleAlertLifecycle) Reset() {
+	*x = VehicleAlertLifecycle{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protos_vehicle_alert_lifecycle_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VehicleAlertLifecycle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VehicleAlertLifecycle) ProtoMessage() {}
+
+func (x *VehicleAlertLifecycle) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_vehicle_alert_lifecycle_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VehicleAlertLifecycle.ProtoReflect.Descriptor instead.
+func (*VehicleAlertLifecycle) Descriptor() ([]byte, []int) {
+	return file_protos_vehicle_alert_lifecycle_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VehicleAlertLifecycle) GetEvents() []*AlertLifecycleEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *VehicleAlertLifecycle) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *VehicleAlertLifecycle) GetVin() string {
+	if x != nil {
+		return x.Vin
+	}
+	return ""
+}
+
+// AlertLifecycleEvent is an alert which opened or closed. ended_at and duration_seconds are only set once closed.
+type AlertLifecycleEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name            string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Event           AlertEvent             `protobuf:"varint,2,opt,name=event,proto3,enum=telemetry.vehicle_alert_lifecycle.AlertEvent" json:"event,omitempty"`
+	Audiences       []AlertAudience        `protobuf:"varint,3,rep,packed,name=audiences,proto3,enum=telemetry.vehicle_alert_lifecycle.AlertAudience" json:"audiences,omitempty"`
+	StartedAt       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	EndedAt         *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`
+	DurationSeconds float64                `protobuf:"fixed64,6,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+}
+
+func (x *AlertLifecycleEvent) Reset() {
+	*x = AlertLifecycleEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protos_vehicle_alert_lifecycle_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlertLifecycleEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlertLifecycleEvent) ProtoMessage() {}
+
+func (x *AlertLifecycleEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_vehicle_alert_lifecycle_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlertLifecycleEvent.ProtoReflect.Descriptor instead.
+func (*AlertLifecycleEvent) Descriptor() ([]byte, []int) {
+	return file_protos_vehicle_alert_lifecycle_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AlertLifecycleEvent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AlertLifecycleEvent) GetEvent() AlertEvent {
+	if x != nil {
+		return x.Event
+	}
+	return AlertEvent_UNKNOWN
+}
+
+func (x *AlertLifecycleEvent) GetAudiences() []AlertAudience {
+	if x != nil {
+		return x.Audiences
+	}
+	return nil
+}
+
+func (x *AlertLifecycleEvent) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *AlertLifecycleEvent) GetEndedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndedAt
+	}
+	return nil
+}
+
+func (x *AlertLifecycleEvent) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+var File_protos_vehicle_alert_lifecycle_proto protoreflect.FileDescriptor
+
+var file_protos_vehicle_alert_lifecycle_proto_rawDesc = []byte{
+	0x0a, 0x24, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2f, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65,
+	0x5f, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x5f, 0x6c, 0x69, 0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x21, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72,
+	0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x5f,
+	0x6c, 0x69, 0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb4, 0x01, 0x0a, 0x15, 0x56,
+	0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x66, 0x65, 0x63,
+	0x79, 0x63, 0x6c, 0x65, 0x12, 0x4e, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x36, 0x2e, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72, 0x79,
+	0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x5f, 0x6c,
+	0x69, 0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65, 0x2e, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x4c, 0x69,
+	0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x06, 0x65, 0x76,
+	0x65, 0x6e, 0x74, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x5f,
+	0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12,
+	0x10, 0x0a, 0x03, 0x76, 0x69, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x76, 0x69,
+	0x6e, 0x22, 0xdb, 0x02, 0x0a, 0x13, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x4c, 0x69, 0x66, 0x65, 0x63,
+	0x79, 0x63, 0x6c, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x43, 0x0a,
+	0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2d, 0x2e, 0x74,
+	0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72, 0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65,
+	0x5f, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x5f, 0x6c, 0x69, 0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65,
+	0x2e, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x05, 0x65, 0x76, 0x65,
+	0x6e, 0x74, 0x12, 0x4e, 0x0a, 0x09, 0x61, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x30, 0x2e, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72,
+	0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x5f,
+	0x6c, 0x69, 0x66, 0x65, 0x63, 0x79, 0x63, 0x6c, 0x65, 0x2e, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x41,
+	0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65, 0x52, 0x09, 0x61, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63,
+	0x65, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x09, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x35, 0x0a,
+	0x08, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x65, 0x6e, 0x64,
+	0x65, 0x64, 0x41, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f,
+	0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x2a,
+	0x47, 0x0a, 0x0d, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x41, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65,
+	0x12, 0x0b, 0x0a, 0x07, 0x55, 0x6e, 0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x10, 0x00, 0x12, 0x0c, 0x0a,
+	0x08, 0x43, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x65, 0x72, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x10, 0x02, 0x12, 0x0e, 0x0a, 0x0a, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x46, 0x69, 0x78, 0x10, 0x03, 0x2a, 0x31, 0x0a, 0x0a, 0x41, 0x6c, 0x65, 0x72,
+	0x74, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57,
+	0x4e, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x50, 0x45, 0x4e, 0x45, 0x44, 0x10, 0x01, 0x12,
+	0x0a, 0x0a, 0x06, 0x43, 0x4c, 0x4f, 0x53, 0x45, 0x44, 0x10, 0x02, 0x42, 0x2f, 0x5a, 0x2d, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x65, 0x73, 0x6c, 0x61, 0x6d,
+	0x6f, 0x74, 0x6f, 0x72, 0x73, 0x2f, 0x66, 0x6c, 0x65, 0x65, 0x74, 0x2d, 0x74, 0x65, 0x6c, 0x65,
+	0x6d, 0x65, 0x74, 0x72, 0x79, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_protos_vehicle_alert_lifecycle_proto_rawDescOnce sync.Once
+	file_protos_vehicle_alert_lifecycle_proto_rawDescData = file_protos_vehicle_alert_lifecycle_proto_rawDesc
+)
+
+func file_protos_vehicle_alert_lifecycle_proto_rawDescGZIP() []byte {
+	file_protos_vehicle_alert_lifecycle_proto_rawDescOnce.Do(func() {
+		file_protos_vehicle_alert_lifecycle_proto_rawDescData = protoimpl.X.CompressGZIP(file_protos_vehicle_alert_lifecycle_proto_rawDescData)
+	})
+	return file_protos_vehicle_alert_lifecycle_proto_rawDescData
+}
+
+var file_protos_vehicle_alert_lifecycle_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_protos_vehicle_alert_lifecycle_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_protos_vehicle_alert_lifecycle_proto_goTypes = []interface{}{
+	(AlertAudience)(0),            // 0: telemetry.vehicle_alert_lifecycle.AlertAudience
+	(AlertEvent)(0),               // 1: telemetry.vehicle_alert_lifecycle.AlertEvent
+	(*VehicleAlertLifecycle)(nil), // 2: telemetry.vehicle_alert_lifecycle.VehicleAlertLifecycle
+	(*AlertLifecycleEvent)(nil),   // 3: telemetry.vehicle_alert_lifecycle.AlertLifecycleEvent
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
+}
+var file_protos_vehicle_alert_lifecycle_proto_depIdxs = []int32{
+	3, // 0: telemetry.vehicle_alert_lifecycle.VehicleAlertLifecycle.events:type_name -> telemetry.vehicle_alert_lifecycle.AlertLifecycleEvent
+	4, // 1: telemetry.vehicle_alert_lifecycle.VehicleAlertLifecycle.created_at:type_name -> google.protobuf.Timestamp
+	1, // 2: telemetry.vehicle_alert_lifecycle.AlertLifecycleEvent.event:type_name -> telemetry.vehicle_alert_lifecycle.AlertEvent
+	0, // 3: telemetry.vehicle_alert_lifecycle.AlertLifecycleEvent.audiences:type_name -> telemetry.vehicle_alert_lifecycle.AlertAudience
+	4, // 4: telemetry.vehicle_alert_lifecycle.AlertLifecycleEvent.started_at:type_name -> google.protobuf.Timestamp
+	4, // 5: telemetry.vehicle_alert_lifecycle.AlertLifecycleEvent.ended_at:type_name -> google.protobuf.Timestamp
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_protos_vehicle_alert_lifecycle_proto_init() }
+func file_protos_vehicle_alert_lifecycle_proto_init() {
+	if File_protos_vehicle_alert_lifecycle_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_protos_vehicle_alert_lifecycle_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VehicleAlertLifecycle); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protos_vehicle_alert_lifecycle_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlertLifecycleEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_protos_vehicle_alert_lifecycle_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_protos_vehicle_alert_lifecycle_proto_goTypes,
+		DependencyIndexes: file_protos_vehicle_alert_lifecycle_proto_depIdxs,
+		EnumInfos:         file_protos_vehicle_alert_lifecycle_proto_enumTypes,
+		MessageInfos:      file_protos_vehicle_alert_lifecycle_proto_msgTypes,
+	}.Build()
+	File_protos_vehicle_alert_lifecycle_proto = out.File
+	file_protos_vehicle_alert_lifecycle_proto_rawDesc = nil
+	file_protos_vehicle_alert_lifecycle_proto_goTypes = nil
+	file_protos_vehicle_alert_lifecycle_proto_depIdxs = nil
+}
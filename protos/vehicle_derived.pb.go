@@ -0,0 +1,250 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v5.28.3
+// source: protos/vehicle_derived.proto
+
+package protos
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// VehicleDerivedSignals is a collection of signals computed from a V record of a single vehicle.
+type VehicleDerivedSignals struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Signals   []*DerivedSignal       `protobuf:"bytes,1,rep,name=signals,proto3" json:"signals,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Vin       string                 `protobuf:"bytes,3,opt,name=vin,proto3" json:"vin,omitempty"`
+}
+
+func (x *VehicleDerivedSignals) Reset() {
+	*x = VehicleDerivedSignals{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protos_vehicle_derived_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VehicleDerivedSignals) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VehicleDerivedSignals) ProtoMessage() {}
+
+func (x *VehicleDerivedSignals) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_vehicle_derived_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VehicleDerivedSignals.ProtoReflect.Descriptor instead.
+func (*VehicleDerivedSignals) Descriptor() ([]byte, []int) {
+	return file_protos_vehicle_derived_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VehicleDerivedSignals) GetSignals() []*DerivedSignal {
+	if x != nil {
+		return x.Signals
+	}
+	return nil
+}
+
+func (x *VehicleDerivedSignals) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *VehicleDerivedSignals) GetVin() string {
+	if x != nil {
+		return x.Vin
+	}
+	return ""
+}
+
+// DerivedSignal is the value of a signal configured in derived.signals, computed from the fields of the vehicle.
+type DerivedSignal struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name  string  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *DerivedSignal) Reset() {
+	*x = DerivedSignal{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protos_vehicle_derived_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DerivedSignal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DerivedSignal) ProtoMessage() {}
+
+func (x *DerivedSignal) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_vehicle_derived_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DerivedSignal.ProtoReflect.Descriptor instead.
+func (*DerivedSignal) Descriptor() ([]byte, []int) {
+	return file_protos_vehicle_derived_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DerivedSignal) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DerivedSignal) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+var File_protos_vehicle_derived_proto protoreflect.FileDescriptor
+
+var file_protos_vehicle_derived_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2f, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65,
+	0x5f, 0x64, 0x65, 0x72, 0x69, 0x76, 0x65, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x19,
+	0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72, 0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c,
+	0x65, 0x5f, 0x64, 0x65, 0x72, 0x69, 0x76, 0x65, 0x64, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa8, 0x01, 0x0a, 0x15, 0x56,
+	0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x44, 0x65, 0x72, 0x69, 0x76, 0x65, 0x64, 0x53, 0x69, 0x67,
+	0x6e, 0x61, 0x6c, 0x73, 0x12, 0x42, 0x0a, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72,
+	0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x64, 0x65, 0x72, 0x69, 0x76, 0x65,
+	0x64, 0x2e, 0x44, 0x65, 0x72, 0x69, 0x76, 0x65, 0x64, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x52,
+	0x07, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x64, 0x41, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x76, 0x69, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x76, 0x69, 0x6e, 0x22, 0x39, 0x0a, 0x0d, 0x44, 0x65, 0x72, 0x69, 0x76, 0x65, 0x64,
+	0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x42, 0x2f, 0x5a, 0x2d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74,
+	0x65, 0x73, 0x6c, 0x61, 0x6d, 0x6f, 0x74, 0x6f, 0x72, 0x73, 0x2f, 0x66, 0x6c, 0x65, 0x65, 0x74,
+	0x2d, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72, 0x79, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_protos_vehicle_derived_proto_rawDescOnce sync.Once
+	file_protos_vehicle_derived_proto_rawDescData = file_protos_vehicle_derived_proto_rawDesc
+)
+
+func file_protos_vehicle_derived_proto_rawDescGZIP() []byte {
+	file_protos_vehicle_derived_proto_rawDescOnce.Do(func() {
+		file_protos_vehicle_derived_proto_rawDescData = protoimpl.X.CompressGZIP(file_protos_vehicle_derived_proto_rawDescData)
+	})
+	return file_protos_vehicle_derived_proto_rawDescData
+}
+
+var file_protos_vehicle_derived_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_protos_vehicle_derived_proto_goTypes = []interface{}{
+	(*VehicleDerivedSignals)(nil), // 0: telemetry.vehicle_derived.VehicleDerivedSignals
+	(*DerivedSignal)(nil),         // 1: telemetry.vehicle_derived.DerivedSignal
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+}
+var file_protos_vehicle_derived_proto_depIdxs = []int32{
+	1, // 0: telemetry.vehicle_derived.VehicleDerivedSignals.signals:type_name -> telemetry.vehicle_derived.DerivedSignal
+	2, // 1: telemetry.vehicle_derived.VehicleDerivedSignals.created_at:type_name -> google.protobuf.Timestamp
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_protos_vehicle_derived_proto_init() }
+func file_protos_vehicle_derived_proto_init() {
+	if File_protos_vehicle_derived_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_protos_vehicle_derived_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VehicleDerivedSignals); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protos_vehicle_derived_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DerivedSignal); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_protos_vehicle_derived_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_protos_vehicle_derived_proto_goTypes,
+		DependencyIndexes: file_protos_vehicle_derived_proto_depIdxs,
+		MessageInfos:      file_protos_vehicle_derived_proto_msgTypes,
+	}.Build()
+	File_protos_vehicle_derived_proto = out.File
+	file_protos_vehicle_derived_proto_rawDesc = nil
+	file_protos_vehicle_derived_proto_goTypes = nil
+	file_protos_vehicle_derived_proto_depIdxs = nil
+}
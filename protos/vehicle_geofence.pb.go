@@ -0,0 +1,358 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v5.28.3
+// source: protos/vehicle_geofence.proto
+
+package protos
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// GeofenceTransition is whether the vehicle entered or exited the geofence.
+type GeofenceTransition int32
+
+const (
+	GeofenceTransition_UNKNOWN GeofenceTransition = 0
+	GeofenceTransition_ENTER   GeofenceTransition = 1
+	GeofenceTransition_EXIT    GeofenceTransition = 2
+)
+
+// Enum value maps for GeofenceTransition.
+var (
+	GeofenceTransition_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "ENTER",
+		2: "EXIT",
+	}
+	GeofenceTransition_value = map[string]int32{
+		"UNKNOWN": 0,
+		"ENTER":   1,
+		"EXIT":    2,
+	}
+)
+
+func (x GeofenceTransition) Enum() *GeofenceTransition {
+	p := new(GeofenceTransition)
+	*p = x
+	return p
+}
+
+func (x GeofenceTransition) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GeofenceTransition) Descriptor() protoreflect.EnumDescriptor {
+	return file_protos_vehicle_geofence_proto_enumTypes[0].Descriptor()
+}
+
+func (GeofenceTransition) Type() protoreflect.EnumType {
+	return &file_protos_vehicle_geofence_proto_enumTypes[0]
+}
+
+func (x GeofenceTransition) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GeofenceTransition.Descriptor instead.
+func (GeofenceTransition) EnumDescriptor() ([]byte, []int) {
+	return file_protos_vehicle_geofence_proto_rawDescGZIP(), []int{0}
+}
+
+// VehicleGeofenceEvents is a collection of the geofences a single vehicle entered or exited.
+type VehicleGeofenceEvents struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Events    []*GeofenceEvent       `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Vin       string                 `protobuf:"bytes,3,opt,name=vin,proto3" json:"vin,omitempty"`
+}
+
+func (x *VehicleGeofenceEvents) Reset() {
+	*x = VehicleGeofenceEvents{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protos_vehicle_geofence_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VehicleGeofenceEvents) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VehicleGeofenceEvents) ProtoMessage() {}
+
+func (x *VehicleGeofenceEvents) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_vehicle_geofence_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VehicleGeofenceEvents.ProtoReflect.Descriptor instead.
+func (*VehicleGeofenceEvents) Descriptor() ([]byte, []int) {
+	return file_protos_vehicle_geofence_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VehicleGeofenceEvents) GetEvents() []*GeofenceEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *VehicleGeofenceEvents) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *VehicleGeofenceEvents) GetVin() string {
+	if x != nil {
+		return x.Vin
+	}
+	return ""
+}
+
+// GeofenceEvent is a vehicle entering or exiting a geofence, at the location which triggered it. exited_at is only
+// set on exit.
+type GeofenceEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Geofence   string                 `protobuf:"bytes,1,opt,name=geofence,proto3" json:"geofence,omitempty"`
+	Transition GeofenceTransition     `protobuf:"varint,2,opt,name=transition,proto3,enum=telemetry.vehicle_geofence.GeofenceTransition" json:"transition,omitempty"`
+	EnteredAt  *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=entered_at,json=enteredAt,proto3" json:"entered_at,omitempty"`
+	ExitedAt   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=exited_at,json=exitedAt,proto3" json:"exited_at,omitempty"`
+	Latitude   float64                `protobuf:"fixed64,5,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude  float64                `protobuf:"fixed64,6,opt,name=longitude,proto3" json:"longitude,omitempty"`
+}
+
+func (x *GeofenceEvent) Reset() {
+	*x = GeofenceEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protos_vehicle_geofence_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GeofenceEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeofenceEvent) ProtoMessage() {}
+
+func (x *GeofenceEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_protos_vehicle_geofence_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeofenceEvent.ProtoReflect.Descriptor instead.
+func (*GeofenceEvent) Descriptor() ([]byte, []int) {
+	return file_protos_vehicle_geofence_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GeofenceEvent) GetGeofence() string {
+	if x != nil {
+		return x.Geofence
+	}
+	return ""
+}
+
+func (x *GeofenceEvent) GetTransition() GeofenceTransition {
+	if x != nil {
+		return x.Transition
+	}
+	return GeofenceTransition_UNKNOWN
+}
+
+func (x *GeofenceEvent) GetEnteredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EnteredAt
+	}
+	return nil
+}
+
+func (x *GeofenceEvent) GetExitedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExitedAt
+	}
+	return nil
+}
+
+func (x *GeofenceEvent) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *GeofenceEvent) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+var File_protos_vehicle_geofence_proto protoreflect.FileDescriptor
+
+var file_protos_vehicle_geofence_proto_rawDesc = []byte{
+	0x0a, 0x1d, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2f, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65,
+	0x5f, 0x67, 0x65, 0x6f, 0x66, 0x65, 0x6e, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x1a, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74, 0x72, 0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63,
+	0x6c, 0x65, 0x5f, 0x67, 0x65, 0x6f, 0x66, 0x65, 0x6e, 0x63, 0x65, 0x1a, 0x1f, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa7, 0x01, 0x0a,
+	0x15, 0x56, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x47, 0x65, 0x6f, 0x66, 0x65, 0x6e, 0x63, 0x65,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x41, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x74, 0x65, 0x6c, 0x65, 0x6d, 0x65, 0x74,
+	0x72, 0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x67, 0x65, 0x6f, 0x66, 0x65,
+	0x6e, 0x63, 0x65, 0x2e, 0x47, 0x65, 0x6f, 0x66, 0x65, 0x6e, 0x63, 0x65, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x52, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x64, 0x41, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x76, 0x69, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x76, 0x69, 0x6e, 0x22, 0xa9, 0x02, 0x0a, 0x0d, 0x47, 0x65, 0x6f, 0x66, 0x65,
+	0x6e, 0x63, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x67, 0x65, 0x6f, 0x66,
+	0x65, 0x6e, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x67, 0x65, 0x6f, 0x66,
+	0x65, 0x6e, 0x63, 0x65, 0x12, 0x4e, 0x0a, 0x0a, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2e, 0x2e, 0x74, 0x65, 0x6c, 0x65, 0x6d,
+	0x65, 0x74, 0x72, 0x79, 0x2e, 0x76, 0x65, 0x68, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x67, 0x65, 0x6f,
+	0x66, 0x65, 0x6e, 0x63, 0x65, 0x2e, 0x47, 0x65, 0x6f, 0x66, 0x65, 0x6e, 0x63, 0x65, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x39, 0x0a, 0x0a, 0x65, 0x6e, 0x74, 0x65, 0x72, 0x65, 0x64, 0x5f,
+	0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x65, 0x6e, 0x74, 0x65, 0x72, 0x65, 0x64, 0x41, 0x74, 0x12,
+	0x37, 0x0a, 0x09, 0x65, 0x78, 0x69, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08,
+	0x65, 0x78, 0x69, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x61, 0x74, 0x69,
+	0x74, 0x75, 0x64, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x6c, 0x61, 0x74, 0x69,
+	0x74, 0x75, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6c, 0x6f, 0x6e, 0x67, 0x69, 0x74, 0x75, 0x64,
+	0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x6c, 0x6f, 0x6e, 0x67, 0x69, 0x74, 0x75,
+	0x64, 0x65, 0x2a, 0x36, 0x0a, 0x12, 0x47, 0x65, 0x6f, 0x66, 0x65, 0x6e, 0x63, 0x65, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e,
+	0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x4e, 0x54, 0x45, 0x52, 0x10, 0x01,
+	0x12, 0x08, 0x0a, 0x04, 0x45, 0x58, 0x49, 0x54, 0x10, 0x02, 0x42, 0x2f, 0x5a, 0x2d, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x65, 0x73, 0x6c, 0x61, 0x6d, 0x6f,
+	0x74, 0x6f, 0x72, 0x73, 0x2f, 0x66, 0x6c, 0x65, 0x65, 0x74, 0x2d, 0x74, 0x65, 0x6c, 0x65, 0x6d,
+	0x65, 0x74, 0x72, 0x79, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_protos_vehicle_geofence_proto_rawDescOnce sync.Once
+	file_protos_vehicle_geofence_proto_rawDescData = file_protos_vehicle_geofence_proto_rawDesc
+)
+
+func file_protos_vehicle_geofence_proto_rawDescGZIP() []byte {
+	file_protos_vehicle_geofence_proto_rawDescOnce.Do(func() {
+		file_protos_vehicle_geofence_proto_rawDescData = protoimpl.X.CompressGZIP(file_protos_vehicle_geofence_proto_rawDescData)
+	})
+	return file_protos_vehicle_geofence_proto_rawDescData
+}
+
+var file_protos_vehicle_geofence_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_protos_vehicle_geofence_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_protos_vehicle_geofence_proto_goTypes = []interface{}{
+	(GeofenceTransition)(0),       // 0: telemetry.vehicle_geofence.GeofenceTransition
+	(*VehicleGeofenceEvents)(nil), // 1: telemetry.vehicle_geofence.VehicleGeofenceEvents
+	(*GeofenceEvent)(nil),         // 2: telemetry.vehicle_geofence.GeofenceEvent
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_protos_vehicle_geofence_proto_depIdxs = []int32{
+	2, // 0: telemetry.vehicle_geofence.VehicleGeofenceEvents.events:type_name -> telemetry.vehicle_geofence.GeofenceEvent
+	3, // 1: telemetry.vehicle_geofence.VehicleGeofenceEvents.created_at:type_name -> google.protobuf.Timestamp
+	0, // 2: telemetry.vehicle_geofence.GeofenceEvent.transition:type_name -> telemetry.vehicle_geofence.GeofenceTransition
+	3, // 3: telemetry.vehicle_geofence.GeofenceEvent.entered_at:type_name -> google.protobuf.Timestamp
+	3, // 4: telemetry.vehicle_geofence.GeofenceEvent.exited_at:type_name -> google.protobuf.Timestamp
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_protos_vehicle_geofence_proto_init() }
+func file_protos_vehicle_geofence_proto_init() {
+	if File_protos_vehicle_geofence_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_protos_vehicle_geofence_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VehicleGeofenceEvents); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protos_vehicle_geofence_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GeofenceEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_protos_vehicle_geofence_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_protos_vehicle_geofence_proto_goTypes,
+		DependencyIndexes: file_protos_vehicle_geofence_proto_depIdxs,
+		EnumInfos:         file_protos_vehicle_geofence_proto_enumTypes,
+		MessageInfos:      file_protos_vehicle_geofence_proto_msgTypes,
+	}.Build()
+	File_protos_vehicle_geofence_proto = out.File
+	file_protos_vehicle_geofence_proto_rawDesc = nil
+	file_protos_vehicle_geofence_proto_goTypes = nil
+	file_protos_vehicle_geofence_proto_depIdxs = nil
+}
@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/server/streaming"
+)
+
+type adminServer struct {
+	registry *streaming.SocketRegistry
+	reloader *streaming.Reloader
+	logger   *logrus.Logger
+}
+
+// newAdminHandler returns the admin API, every route requires the bearer token
+func newAdminHandler(token string, registry *streaming.SocketRegistry, reloader *streaming.Reloader, logger *logrus.Logger) http.Handler {
+	adminServer := &adminServer{registry: registry, reloader: reloader, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/sockets", adminServer.ListSockets())
+	mux.HandleFunc("GET /admin/sockets/{vin}", adminServer.GetSockets())
+	mux.HandleFunc("DELETE /admin/sockets/{vin}", adminServer.CloseSockets())
+	mux.HandleFunc("POST /admin/reload", adminServer.Reload())
+	return authenticate(token, mux)
+}
+
+// authenticate rejects the requests without the bearer token
+func authenticate(expectedToken string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expectedToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListSockets API lists every connected socket
+func (s *adminServer) ListSockets() func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.registry.ListSockets())
+	}
+}
+
+// GetSockets API lists the sockets connected for a vin
+func (s *adminServer) GetSockets() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vin := r.PathValue("vin")
+		sockets := make([]streaming.SocketInfo, 0)
+		for _, socket := range s.registry.ListSockets() {
+			if socket.DeviceID == vin {
+				sockets = append(sockets, socket)
+			}
+		}
+		if len(sockets) == 0 {
+			http.Error(w, "no socket connected for vin", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, sockets)
+	}
+}
+
+// CloseSockets API force-closes the sockets connected for a vin
+func (s *adminServer) CloseSockets() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vin := r.PathValue("vin")
+		closed := s.registry.CloseDeviceSockets(vin)
+		s.logger.ActivityLog("admin_close_sockets", logrus.LogInfo{"vin": vin, "closed": closed, "remote_addr": r.RemoteAddr})
+		if closed == 0 {
+			http.Error(w, "no socket connected for vin", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"closed": closed})
+	}
+}
+
+// Reload API re-reads the configuration file and applies it without closing sockets
+func (s *adminServer) Reload() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logger.ActivityLog("admin_reload", logrus.LogInfo{"remote_addr": r.RemoteAddr})
+		if err := s.reloader.Reload(); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,68 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/config"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/server/streaming"
+)
+
+var _ = Describe("Admin server", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		reloader := streaming.NewReloader(&config.Config{}, airbrake.NewAirbrakeHandler(nil), logger)
+		handler = newAdminHandler("secret", streaming.NewSocketRegistry(), reloader, logger)
+	})
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	DescribeTable("rejects unauthenticated requests",
+		func(method, path, token string) {
+			recorder := serve(method, path, token)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		},
+		Entry("without a token", http.MethodGet, "/admin/sockets", ""),
+		Entry("with a wrong token", http.MethodGet, "/admin/sockets", "wrong"),
+		Entry("when closing a socket", http.MethodDelete, "/admin/sockets/device-1", "wrong"),
+		Entry("when reloading", http.MethodPost, "/admin/reload", ""),
+	)
+
+	It("lists connected sockets", func() {
+		recorder := serve(http.MethodGet, "/admin/sockets", "secret")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(MatchJSON("[]"))
+	})
+
+	It("returns not found for a vin without socket", func() {
+		Expect(serve(http.MethodGet, "/admin/sockets/device-1", "secret").Code).To(Equal(http.StatusNotFound))
+		Expect(serve(http.MethodDelete, "/admin/sockets/device-1", "secret").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("reports reload errors", func() {
+		recorder := serve(http.MethodPost, "/admin/reload", "secret")
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"error": "server is not started"}`))
+	})
+
+	It("rejects unsupported methods", func() {
+		Expect(serve(http.MethodPost, "/admin/sockets", "secret").Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
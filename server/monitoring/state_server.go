@@ -0,0 +1,46 @@
+package monitoring
+
+import (
+	"net/http"
+
+	"github.com/teslamotors/fleet-telemetry/telemetry/alerts"
+	"github.com/teslamotors/fleet-telemetry/telemetry/state"
+)
+
+type stateServer struct {
+	store        *state.Store
+	alertTracker *alerts.Tracker
+}
+
+// newStateHandler returns the API of the last known state and the open alerts of the vehicles, every route requires
+// the bearer token. The routes of a nil store or tracker are not served.
+func newStateHandler(token string, store *state.Store, alertTracker *alerts.Tracker) http.Handler {
+	stateServer := &stateServer{store: store, alertTracker: alertTracker}
+	mux := http.NewServeMux()
+	if store != nil {
+		mux.HandleFunc("GET /vehicles/{vin}/state", stateServer.GetState())
+	}
+	if alertTracker != nil {
+		mux.HandleFunc("GET /vehicles/{vin}/alerts", stateServer.GetAlerts())
+	}
+	return authenticate(token, mux)
+}
+
+// GetState API returns the last known state of a vehicle
+func (s *stateServer) GetState() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vehicle, ok := s.store.Get(r.PathValue("vin"))
+		if !ok {
+			http.Error(w, "no state for vin", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, vehicle)
+	}
+}
+
+// GetAlerts API returns the open alerts of a vehicle, oldest first
+func (s *stateServer) GetAlerts() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.alertTracker.Open(r.PathValue("vin")))
+	}
+}
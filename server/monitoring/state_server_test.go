@@ -0,0 +1,106 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/alerts"
+	"github.com/teslamotors/fleet-telemetry/telemetry/state"
+)
+
+var _ = Describe("State server", func() {
+	var (
+		handler http.Handler
+		store   *state.Store
+	)
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		var err error
+		store, err = state.NewStore(&state.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		handler = newStateHandler("secret", store, nil)
+
+		payload, err := proto.Marshal(&protos.Payload{
+			CreatedAt: timestamppb.Now(),
+			Data:      []*protos.Datum{{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 80}}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		message, err := (&messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.device-1"), MessageTopic: []byte("V"), Payload: payload}).ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "device-1", SenderID: "vehicle_device.device-1"}, map[string][]telemetry.Producer{}, logger)
+		serializer.SetObserver(store)
+		record, err := telemetry.NewRecord(serializer, message, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		serializer.Dispatch(record)
+	})
+
+	AfterEach(func() {
+		Expect(store.Close()).To(Succeed())
+	})
+
+	serve := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	It("returns the state of a vehicle", func() {
+		recorder := serve("/vehicles/device-1/state", "secret")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"vin":"device-1"`))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"Soc":{"value":{"doubleValue":80}`))
+	})
+
+	It("returns not found for an unknown vin", func() {
+		Expect(serve("/vehicles/device-2/state", "secret").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects unauthenticated requests", func() {
+		Expect(serve("/vehicles/device-1/state", "wrong").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("does not serve the alerts without tracker", func() {
+		Expect(serve("/vehicles/device-1/alerts", "secret").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("returns the open alerts of a vehicle", func() {
+		logger, _ := logrus.NoOpLogger()
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+		handler = newStateHandler("secret", nil, tracker)
+
+		payload, err := proto.Marshal(&protos.VehicleAlerts{Alerts: []*protos.VehicleAlert{
+			{Name: "ChargePortFault", Audiences: []protos.Audience{protos.Audience_Customer}, StartedAt: timestamppb.New(time.Unix(1700000000, 0))},
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		message, err := (&messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.device-1"), MessageTopic: []byte("alerts"), Payload: payload}).ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "device-1", SenderID: "vehicle_device.device-1"}, map[string][]telemetry.Producer{}, logger)
+		serializer.AddDeriver(tracker)
+		record, err := telemetry.NewRecord(serializer, message, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		serializer.Dispatch(record)
+
+		recorder := serve("/vehicles/device-1/alerts", "secret")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`[{"name":"ChargePortFault","audiences":["Customer"],"started_at":"2023-11-14T22:13:20Z"}]`))
+		Expect(serve("/vehicles/device-1/state", "secret").Code).To(Equal(http.StatusNotFound))
+	})
+})
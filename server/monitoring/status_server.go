@@ -7,6 +7,9 @@ import (
 	"github.com/teslamotors/fleet-telemetry/config"
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/server/streaming"
+	"github.com/teslamotors/fleet-telemetry/telemetry/alerts"
+	"github.com/teslamotors/fleet-telemetry/telemetry/state"
 )
 
 type statusServer struct {
@@ -19,11 +22,26 @@ func (s *statusServer) Status() func(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-// StartStatusServer initializes the status server on http
-func StartStatusServer(config *config.Config, logger *logrus.Logger, airbrakeHandler *airbrake.Handler) {
+// StartStatusServer initializes the status server on http, along with the admin API when an admin token is configured.
+// The last known state and the open alerts of the vehicles are served with the admin API when stateStore and
+// alertTracker are not nil, and the records of the stream dispatcher when it is configured with a token.
+func StartStatusServer(config *config.Config, logger *logrus.Logger, airbrakeHandler *airbrake.Handler, registry *streaming.SocketRegistry, reloader *streaming.Reloader, stateStore *state.Store, alertTracker *alerts.Tracker) {
 	statusServer := &statusServer{}
 	mux := http.NewServeMux()
 	mux.Handle("/status", airbrakeHandler.WithReporting(http.HandlerFunc(statusServer.Status())))
+	if token := config.AdminToken(); token != "" {
+		mux.Handle("/admin/", airbrakeHandler.WithReporting(newAdminHandler(token, registry, reloader, logger)))
+		logger.ActivityLog("admin_api_configured", nil)
+		if stateStore != nil || alertTracker != nil {
+			mux.Handle("/vehicles/", airbrakeHandler.WithReporting(newStateHandler(token, stateStore, alertTracker)))
+			logger.ActivityLog("state_api_configured", logrus.LogInfo{"state": stateStore != nil, "alerts": alertTracker != nil})
+		}
+	}
+	if token := config.StreamToken(); token != "" && config.StreamHub != nil {
+		// not wrapped with airbrake reporting, its response writer can neither flush nor be hijacked
+		mux.Handle("/stream/", newStreamHandler(token, config.StreamHub, logger))
+		logger.ActivityLog("stream_api_configured", nil)
+	}
 	go func() {
 		if err := http.ListenAndServe(fmt.Sprintf(":%d", config.StatusPort), mux); err != nil {
 			logger.ErrorLog("status", err, nil)
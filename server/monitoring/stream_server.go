@@ -0,0 +1,158 @@
+package monitoring
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/stream"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+)
+
+const (
+	// streamKeepAliveInterval is the interval between two keep alive comments or pings sent to idle subscribers
+	streamKeepAliveInterval = 15 * time.Second
+
+	// streamWriteTimeout bounds a websocket write to a subscriber
+	streamWriteTimeout = 10 * time.Second
+)
+
+var streamUpgrader = websocket.Upgrader{
+	// subscribers are internal apps authenticated by their token, not browsers
+	CheckOrigin:     func(_ *http.Request) bool { return true },
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type streamServer struct {
+	hub    *stream.Hub
+	logger *logrus.Logger
+}
+
+// newStreamHandler returns the endpoints streaming the records of the stream dispatcher, every route requires the
+// bearer token. Subscribers filter with the comma separated vins, record_types and fields query parameters.
+func newStreamHandler(token string, hub *stream.Hub, logger *logrus.Logger) http.Handler {
+	streamServer := &streamServer{hub: hub, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stream/events", streamServer.Events())
+	mux.HandleFunc("GET /stream/ws", streamServer.Websocket())
+	return authenticate(token, mux)
+}
+
+// Events API streams the records as server-sent events
+func (s *streamServer) Events() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscription, err := s.hub.Subscribe(parseStreamFilter(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer subscription.Close()
+		s.logger.ActivityLog("stream_subscribed", logrus.LogInfo{"protocol": "sse", "remote_addr": r.RemoteAddr})
+
+		controller := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := controller.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(streamKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-subscription.Done():
+				return
+			case event := <-subscription.Events():
+				_, err = fmt.Fprintf(w, "data: %s\n\n", event)
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err == nil {
+				err = controller.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Websocket API streams the records as websocket text messages
+func (s *streamServer) Websocket() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscription, err := s.hub.Subscribe(parseStreamFilter(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer subscription.Close()
+
+		conn, err := streamUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.logger.ActivityLog("stream_subscribed", logrus.LogInfo{"protocol": "websocket", "remote_addr": r.RemoteAddr})
+
+		// messages of the subscriber are discarded, reading detects when it goes away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		keepAlive := time.NewTicker(streamKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-subscription.Done():
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "subscription closed"), time.Now().Add(streamWriteTimeout))
+				return
+			case event := <-subscription.Events():
+				_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				err = conn.WriteMessage(websocket.TextMessage, event)
+			case <-keepAlive.C:
+				err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseStreamFilter reads the filter of a subscriber from the query parameters
+func parseStreamFilter(r *http.Request) *stream.Filter {
+	query := r.URL.Query()
+	return &stream.Filter{
+		Vins:        splitQueryValues(query["vins"]),
+		RecordTypes: splitQueryValues(query["record_types"]),
+		Fields:      splitQueryValues(query["fields"]),
+	}
+}
+
+// splitQueryValues accepts both repeated parameters and comma separated values
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
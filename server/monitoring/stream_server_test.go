@@ -0,0 +1,122 @@
+package monitoring
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/datastore/stream"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Stream server", func() {
+	var (
+		logger *logrus.Logger
+		hub    *stream.Hub
+		server *httptest.Server
+	)
+
+	newRecord := func(vin string) *telemetry.Record {
+		payload, err := proto.Marshal(&protos.Payload{
+			Vin:       vin,
+			CreatedAt: timestamppb.Now(),
+			Data: []*protos.Datum{
+				{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 80}}},
+				{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 42}}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		message, err := (&messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte("V"), Payload: payload}).ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, message, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	get := func(path, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(resp.Body.Close)
+		return resp
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		var err error
+		hub, err = stream.NewHub(&stream.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		server = httptest.NewServer(newStreamHandler("secret", hub, logger))
+	})
+
+	AfterEach(func() {
+		hub.Close()
+		server.Close()
+	})
+
+	It("streams server-sent events", func() {
+		resp := get("/stream/events?vins=vin-1&fields=Soc", "secret")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		hub.Publish(newRecord("vin-2"))
+		hub.Publish(newRecord("vin-1"))
+
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(HavePrefix("data: "))
+		Expect(line).To(ContainSubstring(`"vin":"vin-1"`))
+		Expect(line).To(ContainSubstring(`"Soc":80`))
+		Expect(line).NotTo(ContainSubstring("VehicleSpeed"))
+	})
+
+	It("streams websocket messages", func() {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/stream/ws?record_types=V"
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"Bearer secret"}})
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		hub.Publish(newRecord("vin-1"))
+
+		messageType, data, err := conn.ReadMessage()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messageType).To(Equal(websocket.TextMessage))
+		Expect(string(data)).To(ContainSubstring(`"vin":"vin-1"`))
+		Expect(string(data)).To(ContainSubstring(`"VehicleSpeed":42`))
+	})
+
+	It("closes the websocket when the subscription is disconnected", func() {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/stream/ws"
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"Bearer secret"}})
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		hub.Close()
+
+		_, _, err = conn.ReadMessage()
+		Expect(websocket.IsCloseError(err, websocket.CloseGoingAway)).To(BeTrue())
+	})
+
+	It("rejects unknown fields", func() {
+		Expect(get("/stream/events?fields=NotAField", "secret").StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects unauthenticated requests", func() {
+		Expect(get("/stream/events", "wrong").StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+})
@@ -0,0 +1,111 @@
+package streaming
+
+import (
+	"sync"
+	"time"
+
+	"github.com/teslamotors/fleet-telemetry/config"
+)
+
+// defaultRateLimiterName names the limiter shared by record types without their own limit
+const defaultRateLimiterName = "default"
+
+// RateLimitState is a point in time snapshot of a rate limiter
+type RateLimitState struct {
+	Limit           int                    `json:"limit"`
+	IntervalSeconds float64                `json:"interval_sec"`
+	Action          config.RateLimitAction `json:"action"`
+	Remaining       int                    `json:"remaining"`
+}
+
+// slidingWindow allows up to limit messages in any interval, as the go-rate limiter used before per record type
+// limits: it keeps the times of the last limit messages, a message being allowed once the oldest is an interval old
+type slidingWindow struct {
+	mutex sync.Mutex
+	rule  config.RateLimitRule
+	times []time.Time
+	next  int
+}
+
+func newSlidingWindow(rule *config.RateLimitRule) *slidingWindow {
+	if rule == nil || rule.Interval <= 0 {
+		return nil
+	}
+	return &slidingWindow{rule: *rule, times: make([]time.Time, max(rule.Limit, 0))}
+}
+
+// Allow records the message if fewer than limit messages were allowed in the last interval
+func (w *slidingWindow) Allow() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.times) == 0 {
+		return false
+	}
+	now := time.Now()
+	if oldest := w.times[w.next]; !oldest.IsZero() && now.Sub(oldest) < w.rule.Interval {
+		return false
+	}
+	w.times[w.next] = now
+	w.next = (w.next + 1) % len(w.times)
+	return true
+}
+
+// State returns the rule and the messages left in the current interval
+func (w *slidingWindow) State() RateLimitState {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	remaining := 0
+	for _, allowedAt := range w.times {
+		if allowedAt.IsZero() || now.Sub(allowedAt) >= w.rule.Interval {
+			remaining++
+		}
+	}
+	return RateLimitState{
+		Limit:           w.rule.Limit,
+		IntervalSeconds: w.rule.Interval.Seconds(),
+		Action:          w.rule.Action,
+		Remaining:       remaining,
+	}
+}
+
+// rateLimiters holds the limiters of a socket, one per record type with its own limit and one shared by the others
+type rateLimiters struct {
+	rules  *config.RateLimitRules
+	byName map[string]*slidingWindow
+}
+
+func newRateLimiters(rules *config.RateLimitRules) *rateLimiters {
+	limiters := &rateLimiters{rules: rules, byName: make(map[string]*slidingWindow)}
+	if rules == nil {
+		return limiters
+	}
+	if window := newSlidingWindow(rules.Default); window != nil {
+		limiters.byName[defaultRateLimiterName] = window
+	}
+	for recordType, rule := range rules.RecordTypes {
+		limiters.byName[recordType] = newSlidingWindow(rule)
+	}
+	return limiters
+}
+
+// forRecordType returns the limiter of a record type, nil when it is unlimited
+func (r *rateLimiters) forRecordType(recordType string) *slidingWindow {
+	if window, ok := r.byName[recordType]; ok {
+		return window
+	}
+	return r.byName[defaultRateLimiterName]
+}
+
+// States returns the state of every limiter, keyed by record type or "default"
+func (r *rateLimiters) States() map[string]RateLimitState {
+	states := make(map[string]RateLimitState, len(r.byName))
+	for name, window := range r.byName {
+		if window != nil {
+			states[name] = window.State()
+		}
+	}
+	return states
+}
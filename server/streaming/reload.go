@@ -0,0 +1,96 @@
+package streaming
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/fleet-telemetry/config"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// RetiredProducerGracePeriod is how long a producer replaced by a configuration reload keeps receiving
+// the records dispatched before the swap, before it is closed
+const RetiredProducerGracePeriod = 5 * time.Second
+
+var errServerNotStarted = errors.New("server is not started")
+
+// Reloader applies changes of the configuration file to a running server without closing its sockets
+type Reloader struct {
+	mutex           sync.Mutex
+	config          *config.Config
+	producers       map[telemetry.Dispatcher]telemetry.Producer
+	server          *Server
+	airbrakeHandler *airbrake.Handler
+	logger          *logrus.Logger
+}
+
+// NewReloader returns a reloader for the configuration, which can reload once Start is called
+func NewReloader(config *config.Config, airbrakeHandler *airbrake.Handler, logger *logrus.Logger) *Reloader {
+	return &Reloader{
+		config:          config,
+		airbrakeHandler: airbrakeHandler,
+		logger:          logger,
+	}
+}
+
+// Start sets the server and the producers the reloader swaps configurations into
+func (r *Reloader) Start(server *Server, producers map[telemetry.Dispatcher]telemetry.Producer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.server = server
+	r.producers = producers
+}
+
+// Producers returns the producers in use, keyed by dispatcher
+func (r *Reloader) Producers() map[telemetry.Dispatcher]telemetry.Producer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.producers
+}
+
+// Reload re-reads the configuration file, rebuilds the producers whose settings changed and swaps the
+// new dispatch rules into every connected socket. The current configuration is kept if the new one is invalid.
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.server == nil {
+		return errServerNotStarted
+	}
+
+	reloaded, err := r.config.ReloadApplicationConfiguration()
+	if err != nil {
+		return r.reportError("config_reload_error", err)
+	}
+	producers, producerRules, retired, err := reloaded.ReconfigureProducers(r.config, r.producers, r.airbrakeHandler, r.logger)
+	if err != nil {
+		return r.reportError("config_reload_producers_error", err)
+	}
+
+	r.server.Reconfigure(reloaded, producerRules)
+	r.config = reloaded
+	r.producers = producers
+
+	time.AfterFunc(RetiredProducerGracePeriod, func() {
+		for _, producer := range retired {
+			if err := producer.Close(); err != nil {
+				r.logger.ErrorLog("retired_producer_close_error", err, nil)
+			}
+		}
+	})
+
+	serverMetricsRegistry.configReloadCount.Inc(map[string]string{"status": "ok"})
+	r.logger.ActivityLog("config_reloaded", logrus.LogInfo{"dispatchers": len(producers), "retired_dispatchers": len(retired), "open_sockets": r.server.registry.NumConnectedSockets()})
+	return nil
+}
+
+func (r *Reloader) reportError(message string, err error) error {
+	serverMetricsRegistry.configReloadCount.Inc(map[string]string{"status": "error"})
+	r.logger.ErrorLog(message, err, nil)
+	return err
+}
@@ -3,9 +3,13 @@ package streaming
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
@@ -19,6 +23,14 @@ import (
 	"github.com/teslamotors/fleet-telemetry/protos"
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/ackpolicy"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/telemetry/deadletter"
+	"github.com/teslamotors/fleet-telemetry/telemetry/dedup"
+	"github.com/teslamotors/fleet-telemetry/telemetry/derived"
+	"github.com/teslamotors/fleet-telemetry/telemetry/geofence"
+	"github.com/teslamotors/fleet-telemetry/telemetry/session"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 )
 
 var (
@@ -35,12 +47,19 @@ var (
 
 const (
 	connectitivityTopic = "connectivity"
+
+	// ackExpiryInterval is how often the records waiting for the acks of a reliable ack policy are checked for timeouts
+	ackExpiryInterval = time.Second
 )
 
+// errReliableAckTimeout is sent to the vehicle when the dispatchers of a record did not confirm it in time
+var errReliableAckTimeout = errors.New("reliable ack timeout")
+
 // ServerMetrics stores metrics reported from this package
 type ServerMetrics struct {
 	reliableAckCount     adapter.Counter
 	reliableAckMissCount adapter.Counter
+	configReloadCount    adapter.Counter
 }
 
 // Server stores server resources
@@ -56,9 +75,38 @@ type Server struct {
 
 	registry *SocketRegistry
 
-	ackChan chan (*telemetry.Record)
+	ackChan chan (*telemetry.Ack)
+
+	// acks counts the acks of the records of a type with a reliable ack policy
+	acks *ackpolicy.Tracker
+
+	// capture writes the raw messages of the vehicles to disk, nil when capture is disabled
+	capture *capture.Writer
+
+	// deadLetter receives the messages which fail to parse, nil when dead letters are not configured
+	deadLetter *deadletter.Handler
+
+	// dedup remembers the txids received recently, nil when duplicates are not suppressed
+	dedup *dedup.Cache
+
+	// unitConverter normalizes the values of V records, nil when units are not configured
+	unitConverter *units.Converter
+
+	// derivers compute records from the V records, ex.: derived signals and geofence events
+	derivers []telemetry.Deriver
+
+	// segmenter assigns the records to trip and charge sessions, nil when sessions are not tracked
+	segmenter telemetry.Segmenter
 
+	// observer is notified of every record dispatched, ex.: the last known state store
+	observer telemetry.Observer
+
+	// mutex guards the settings a configuration reload swaps: config, DispatchRules,
+	// reliableAckSources and the serializers of connected sockets
+	mutex              sync.RWMutex
+	config             *config.Config
 	reliableAckSources map[string]telemetry.Dispatcher
+	serializers        map[*telemetry.BinarySerializer]struct{}
 }
 
 // InitServer initializes the main server
@@ -71,12 +119,56 @@ func InitServer(c *config.Config, airbrakeHandler *airbrake.Handler, producerRul
 		airbrakeHandler:    airbrakeHandler,
 		registry:           registry,
 		ackChan:            c.AckChan,
+		acks:               ackpolicy.NewTracker(c.MetricCollector, logger),
+		config:             c,
 		reliableAckSources: c.ReliableAckSources,
+		serializers:        make(map[*telemetry.BinarySerializer]struct{}),
+		unitConverter:      c.UnitConverter,
 	}
 	registerServerMetricsOnce(socketServer.metricsCollector)
 
+	if c.Derived != nil {
+		engine, err := derived.NewEngine(c.Derived, c.MetricCollector, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		socketServer.derivers = append(socketServer.derivers, engine)
+	}
+	if c.Geofence != nil {
+		evaluator, err := geofence.NewEvaluator(c.Geofence, c.MetricCollector, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		socketServer.derivers = append(socketServer.derivers, evaluator)
+	}
+	if c.Sessions != nil {
+		tracker, err := session.NewTracker(c.Sessions, c.MetricCollector, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		socketServer.segmenter = tracker
+	}
+	if c.Capture != nil {
+		var err error
+		if socketServer.capture, err = capture.NewWriter(c.Capture); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.DeadLetter != nil {
+		var err error
+		if socketServer.deadLetter, err = deadletter.NewHandler(c.DeadLetter, c.MetricCollector, logger); err != nil {
+			if socketServer.capture != nil {
+				_ = socketServer.capture.Close()
+			}
+			return nil, nil, err
+		}
+	}
+	if c.Dedup != nil {
+		socketServer.dedup = dedup.NewCache(c.Dedup)
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", socketServer.ServeBinaryWs(c))
+	mux.HandleFunc("/", socketServer.ServeBinaryWs())
 	mux.Handle("/status", socketServer.airbrakeHandler.WithReporting(http.HandlerFunc(socketServer.Status())))
 
 	server := &http.Server{Addr: fmt.Sprintf("%v:%v", c.Host, c.Port), Handler: mux}
@@ -84,16 +176,132 @@ func InitServer(c *config.Config, airbrakeHandler *airbrake.Handler, producerRul
 	return server, socketServer, nil
 }
 
+// Reconfigure swaps a reloaded configuration and its dispatch rules into the server and every connected socket,
+// without closing them
+func (s *Server) Reconfigure(c *config.Config, producerRules map[string][]telemetry.Producer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.config = c
+	s.DispatchRules = producerRules
+	s.reliableAckSources = c.ReliableAckSources
+	for serializer := range s.serializers {
+		serializer.SetDispatchRules(producerRules)
+	}
+	s.registry.UpdateConfig(c)
+}
+
+// SetObserver sets the observer notified of every record dispatched by the sockets connected afterwards
+func (s *Server) SetObserver(observer telemetry.Observer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.observer = observer
+}
+
+// AddDeriver adds a deriver of the records dispatched by the sockets connected afterwards
+func (s *Server) AddDeriver(deriver telemetry.Deriver) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.derivers = append(s.derivers, deriver)
+}
+
+// Close releases the resources of the server once its sockets are closed, stopping the eviction of its derivers and segmenter
+func (s *Server) Close() error {
+	var errs []error
+	for _, deriver := range s.derivers {
+		if closer, ok := deriver.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	if closer, ok := s.segmenter.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	if s.capture != nil {
+		errs = append(errs, s.capture.Close())
+	}
+	if s.deadLetter != nil {
+		errs = append(errs, s.deadLetter.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) reliableAckSource(txType string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return string(s.reliableAckSources[txType])
+}
+
+// reliableAckPolicy returns the dispatchers of the reliable ack policy of a record type, comma separated, and whether
+// the record type has one
+func (s *Server) reliableAckPolicy(txType string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	policy, ok := s.config.ReliableAckPolicies[txType]
+	if !ok || policy == nil {
+		return "", false
+	}
+	dispatchers := make([]string, 0, len(policy.Dispatchers))
+	for _, dispatcher := range policy.Dispatchers {
+		dispatchers = append(dispatchers, string(dispatcher))
+	}
+	return strings.Join(dispatchers, ","), true
+}
+
 func (s *Server) handleAcks() {
-	for record := range s.ackChan {
-		reliableAckSource := string(s.reliableAckSources[record.TxType])
-		if record.Serializer != nil {
-			if socket := s.registry.GetSocket(record.SocketID); socket != nil {
-				serverMetricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": record.TxType, "dispatcher": reliableAckSource})
-				socket.respondToVehicle(record, nil)
-			} else {
-				serverMetricsRegistry.reliableAckMissCount.Inc(map[string]string{"record_type": record.TxType, "dispatcher": reliableAckSource})
+	ticker := time.NewTicker(ackExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ack, ok := <-s.ackChan:
+			if !ok {
+				return
 			}
+			s.handleAck(ack)
+		case <-ticker.C:
+			s.expireAcks()
+		}
+	}
+}
+
+// handleAck acks the record to the vehicle once its reliable ack source, or enough dispatchers of its reliable ack
+// policy, confirmed it
+func (s *Server) handleAck(ack *telemetry.Ack) {
+	record := ack.Record
+	if record.Serializer == nil {
+		return
+	}
+	reliableAckSource := s.reliableAckSource(record.TxType)
+	if tracked, confirmed := s.acks.Ack(record, ack.Dispatcher); tracked {
+		if !confirmed {
+			return
+		}
+		reliableAckSource, _ = s.reliableAckPolicy(record.TxType)
+	} else if _, ok := s.reliableAckPolicy(record.TxType); ok {
+		// the record already timed out, the vehicle got an error
+		return
+	}
+
+	if socket := s.registry.GetSocket(record.SocketID); socket != nil {
+		serverMetricsRegistry.reliableAckCount.Inc(map[string]string{"record_type": record.TxType, "dispatcher": reliableAckSource})
+		socket.respondToVehicle(record, nil)
+		if s.dedup != nil {
+			s.dedup.Ack(record.Vin, record.TxType, record.Txid)
+		}
+	} else {
+		serverMetricsRegistry.reliableAckMissCount.Inc(map[string]string{"record_type": record.TxType, "dispatcher": reliableAckSource})
+	}
+}
+
+// expireAcks sends an error to the vehicles whose records were not confirmed by their reliable ack policy in time,
+// so they send them again
+func (s *Server) expireAcks() {
+	for _, record := range s.acks.Expire() {
+		if s.dedup != nil {
+			s.dedup.Forget(record.Vin, record.TxType, record.Txid)
+		}
+		if socket := s.registry.GetSocket(record.SocketID); socket != nil {
+			socket.respondWithError(record, errReliableAckTimeout)
 		}
 	}
 }
@@ -106,7 +314,7 @@ func (s *Server) Status() func(w http.ResponseWriter, r *http.Request) {
 }
 
 // ServeBinaryWs serves a http query and upgrades it to a websocket -- only serves binary data coming from the ws
-func (s *Server) ServeBinaryWs(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+func (s *Server) ServeBinaryWs() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if ws := s.promoteToWebsocket(w, r); ws != nil {
 			ctx := context.WithValue(context.Background(), SocketContext, map[string]interface{}{"request": r})
@@ -117,9 +325,7 @@ func (s *Server) ServeBinaryWs(config *config.Config) func(w http.ResponseWriter
 				return
 			}
 
-			binarySerializer := telemetry.NewBinarySerializer(requestIdentity, s.DispatchRules, s.logger)
-			socketManager := NewSocketManager(ctx, requestIdentity, ws, config, s.logger)
-			s.registerSocket(socketManager, binarySerializer)
+			socketManager, binarySerializer := s.registerSocket(ctx, requestIdentity, ws)
 			defer s.deregisterSocket(socketManager, binarySerializer)
 
 			socketManager.ProcessTelemetry(binarySerializer)
@@ -128,8 +334,7 @@ func (s *Server) ServeBinaryWs(config *config.Config) func(w http.ResponseWriter
 }
 
 func (s *Server) dispatchConnectivityEvent(sm *SocketManager, serializer *telemetry.BinarySerializer, event protos.ConnectivityEvent) error {
-	connectivityDispatcher, ok := s.DispatchRules[connectitivityTopic]
-	if !ok {
+	if _, ok := serializer.CurrentDispatchRules()[connectitivityTopic]; !ok && !serializer.HasObserver() && !serializer.HasSegmenter() {
 		return nil
 	}
 
@@ -161,26 +366,49 @@ func (s *Server) dispatchConnectivityEvent(sm *SocketManager, serializer *teleme
 	if err != nil {
 		return err
 	}
-	record, err := telemetry.NewRecord(serializer, message, sm.UUID, sm.transmitDecodedRecords)
+	record, err := telemetry.NewRecord(serializer, message, sm.UUID, sm.transmitDecodedRecords())
 	if err != nil {
 		return err
 	}
-	for _, dispatcher := range connectivityDispatcher {
-		dispatcher.Produce(record)
-	}
+	serializer.Dispatch(record)
 	return nil
 }
 
-func (s *Server) registerSocket(sm *SocketManager, serializer *telemetry.BinarySerializer) {
+// registerSocket creates the socket and serializer of a connection with the current configuration. It holds the
+// server lock while registering them, so a concurrent reload cannot miss the new socket.
+func (s *Server) registerSocket(ctx context.Context, requestIdentity *telemetry.RequestIdentity, ws *websocket.Conn) (*SocketManager, *telemetry.BinarySerializer) {
+	s.mutex.Lock()
+	serializer := telemetry.NewBinarySerializer(requestIdentity, s.DispatchRules, s.logger)
+	serializer.SetObserver(s.observer)
+	if s.unitConverter != nil {
+		serializer.SetPayloadTransformer(s.unitConverter)
+	}
+	for _, deriver := range s.derivers {
+		serializer.AddDeriver(deriver)
+	}
+	if s.segmenter != nil {
+		serializer.SetSegmenter(s.segmenter)
+	}
+	sm := NewSocketManager(ctx, requestIdentity, ws, s.config, s.logger)
+	sm.capture = s.capture
+	sm.deadLetter = s.deadLetter
+	sm.dedup = s.dedup
+	sm.acks = s.acks
+	s.serializers[serializer] = struct{}{}
 	s.registry.RegisterSocket(sm)
+	s.mutex.Unlock()
+
 	event := protos.ConnectivityEvent_CONNECTED
 	if err := s.dispatchConnectivityEvent(sm, serializer, event); err != nil {
 		s.logger.ErrorLog("connectivity_registeration_error", err, logrus.LogInfo{"deviceID": sm.requestIdentity.DeviceID, "event": event})
 	}
-
+	return sm, serializer
 }
 
 func (s *Server) deregisterSocket(sm *SocketManager, serializer *telemetry.BinarySerializer) {
+	s.mutex.Lock()
+	delete(s.serializers, serializer)
+	s.mutex.Unlock()
 	s.registry.DeregisterSocket(sm)
 	event := protos.ConnectivityEvent_DISCONNECTED
 	if err := s.dispatchConnectivityEvent(sm, serializer, event); err != nil {
@@ -245,4 +473,10 @@ func registerServerMetrics(metricsCollector metrics.MetricCollector) {
 		Help:   "The number of missing reliable acknowledgements.",
 		Labels: []string{"record_type", "dispatcher"},
 	})
+
+	serverMetricsRegistry.configReloadCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "config_reload_total",
+		Help:   "The number of configuration reloads.",
+		Labels: []string{"status"},
+	})
 }
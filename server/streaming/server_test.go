@@ -4,9 +4,12 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -18,9 +21,13 @@ import (
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
 	"github.com/teslamotors/fleet-telemetry/messages"
 	"github.com/teslamotors/fleet-telemetry/metrics/adapter/noop"
+	"github.com/teslamotors/fleet-telemetry/protos"
 	"github.com/teslamotors/fleet-telemetry/server/airbrake"
 	"github.com/teslamotors/fleet-telemetry/server/streaming"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/telemetry/deadletter"
+	"github.com/teslamotors/fleet-telemetry/telemetry/dedup"
 )
 
 // withTLSState wraps a handler to inject a tls.ConnectionState into each request,
@@ -83,7 +90,7 @@ var _ = Describe("Socket handler test", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		// No TLS state injected, simulates a non-mTLS connection
-		srv := httptest.NewServer(http.HandlerFunc(s.ServeBinaryWs(conf)))
+		srv := httptest.NewServer(http.HandlerFunc(s.ServeBinaryWs()))
 		defer srv.Close()
 		u, _ := url.Parse(srv.URL)
 		u.Scheme = "ws"
@@ -122,7 +129,7 @@ var _ = Describe("Socket handler test", func() {
 			},
 			VerifiedChains: nil,
 		}
-		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs(conf)), tlsState))
+		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
 		defer srv.Close()
 		u, _ := url.Parse(srv.URL)
 		u.Scheme = "ws"
@@ -164,7 +171,7 @@ var _ = Describe("Socket handler test", func() {
 			PeerCertificates: []*x509.Certificate{realCert, spoofedCert},
 			VerifiedChains:   [][]*x509.Certificate{{realCert}},
 		}
-		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs(conf)), tlsState))
+		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
 		defer srv.Close()
 		u, _ := url.Parse(srv.URL)
 		u.Scheme = "ws"
@@ -193,4 +200,296 @@ var _ = Describe("Socket handler test", func() {
 		Eventually(spy.captured).Should(Receive(&record))
 		Expect(record.Vin).To(Equal("device-1"))
 	})
+
+	It("Reconfigure swaps dispatch rules into connected sockets", func() {
+		logger, _ := logrus.NoOpLogger()
+
+		previous := &spyProducer{captured: make(chan *telemetry.Record, 1)}
+		reloaded := &spyProducer{captured: make(chan *telemetry.Record, 1)}
+
+		conf := &config.Config{MetricCollector: noop.NewCollector()}
+
+		registry := streaming.NewSocketRegistry()
+		producerRules = map[string][]telemetry.Producer{"V": {previous}}
+		_, s, err := streaming.InitServer(conf, airbrake.NewAirbrakeHandler(nil), producerRules, logger, registry)
+		Expect(err).NotTo(HaveOccurred())
+
+		cert := makeCert("device-1", "TeslaMotors")
+		tlsState := &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
+		defer srv.Close()
+		u, _ := url.Parse(srv.URL)
+		u.Scheme = "ws"
+
+		dialer := &websocket.Dialer{HandshakeTimeout: 1 * time.Second}
+		conn, _, err := dialer.Dial(u.String(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = conn.Close() }()
+		Eventually(registry.NumConnectedSockets).Should(Equal(1))
+
+		reloadedConf := &config.Config{MetricCollector: conf.MetricCollector, RateLimit: &config.RateLimit{Enabled: false}}
+		producerRules = map[string][]telemetry.Producer{"V": {reloaded}}
+		s.Reconfigure(reloadedConf, producerRules)
+
+		streamMsg := messages.StreamMessage{
+			TXID:         []byte("test-txid"),
+			SenderID:     []byte("vehicle_device.device-1"),
+			DeviceID:     []byte("device-1"),
+			DeviceType:   []byte("vehicle_device"),
+			MessageTopic: []byte("V"),
+			Payload:      []byte{},
+		}
+		msgBytes, err := streamMsg.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.WriteMessage(websocket.BinaryMessage, msgBytes)).To(Succeed())
+
+		Eventually(reloaded.captured).Should(Receive())
+		Consistently(previous.captured).ShouldNot(Receive())
+		Expect(registry.NumConnectedSockets()).To(Equal(1))
+	})
+
+	It("captures the raw messages of the vehicles", func() {
+		logger, _ := logrus.NoOpLogger()
+
+		spy := &spyProducer{captured: make(chan *telemetry.Record, 1)}
+		captureDir := GinkgoT().TempDir()
+		conf := &config.Config{
+			MetricCollector: noop.NewCollector(),
+			Capture:         &capture.Config{Dir: captureDir},
+		}
+
+		registry := streaming.NewSocketRegistry()
+		producerRules = map[string][]telemetry.Producer{"V": {spy}}
+		_, s, err := streaming.InitServer(conf, airbrake.NewAirbrakeHandler(nil), producerRules, logger, registry)
+		Expect(err).NotTo(HaveOccurred())
+
+		cert := makeCert("device-1", "TeslaMotors")
+		tlsState := &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
+		defer srv.Close()
+		u, _ := url.Parse(srv.URL)
+		u.Scheme = "ws"
+
+		dialer := &websocket.Dialer{HandshakeTimeout: 1 * time.Second}
+		conn, _, err := dialer.Dial(u.String(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = conn.Close() }()
+
+		streamMsg := messages.StreamMessage{
+			TXID:         []byte("test-txid"),
+			SenderID:     []byte("vehicle_device.device-1"),
+			DeviceID:     []byte("device-1"),
+			DeviceType:   []byte("vehicle_device"),
+			MessageTopic: []byte("V"),
+			Payload:      []byte{},
+		}
+		msgBytes, err := streamMsg.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.WriteMessage(websocket.BinaryMessage, msgBytes)).To(Succeed())
+
+		var record *telemetry.Record
+		Eventually(spy.captured).Should(Receive(&record))
+		Expect(s.Close()).To(Succeed())
+
+		files, err := capture.ListFiles(captureDir)
+		Expect(err).NotTo(HaveOccurred())
+		var frames []*capture.Frame
+		Expect(capture.ReadFiles(files, func(frame *capture.Frame) error {
+			frames = append(frames, frame)
+			return nil
+		})).To(Succeed())
+		Expect(frames).To(HaveLen(1))
+		Expect(frames[0].Vin).To(Equal("device-1"))
+		Expect(frames[0].RawBytes).To(Equal(record.RawBytes))
+
+		replayed, err := messages.StreamMessageFromBytes(frames[0].RawBytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(replayed.TXID)).To(Equal("test-txid"))
+	})
+
+	It("dead letters the messages which fail to decode", func() {
+		logger, _ := logrus.NoOpLogger()
+
+		spy := &spyProducer{captured: make(chan *telemetry.Record, 1)}
+		deadLetterFile := filepath.Join(GinkgoT().TempDir(), "deadletter.jsonl")
+		conf := &config.Config{
+			MetricCollector: noop.NewCollector(),
+			DeadLetter:      &deadletter.Config{File: deadLetterFile},
+		}
+
+		registry := streaming.NewSocketRegistry()
+		producerRules = map[string][]telemetry.Producer{deadletter.RecordType: {spy}}
+		_, s, err := streaming.InitServer(conf, airbrake.NewAirbrakeHandler(nil), producerRules, logger, registry)
+		Expect(err).NotTo(HaveOccurred())
+
+		cert := makeCert("device-1", "TeslaMotors")
+		tlsState := &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+		srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
+		defer srv.Close()
+		u, _ := url.Parse(srv.URL)
+		u.Scheme = "ws"
+
+		dialer := &websocket.Dialer{HandshakeTimeout: 1 * time.Second}
+		conn, _, err := dialer.Dial(u.String(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = conn.Close() }()
+
+		streamMsg := messages.StreamMessage{
+			TXID:         []byte("test-txid"),
+			SenderID:     []byte("vehicle_device.device-1"),
+			DeviceID:     []byte("device-1"),
+			DeviceType:   []byte("vehicle_device"),
+			MessageTopic: []byte("V"),
+			Payload:      []byte{0xff},
+		}
+		msgBytes, err := streamMsg.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.WriteMessage(websocket.BinaryMessage, msgBytes)).To(Succeed())
+
+		var record *telemetry.Record
+		Eventually(spy.captured).Should(Receive(&record))
+		Expect(record.TxType).To(Equal(deadletter.RecordType))
+		Expect(record.Vin).To(Equal("device-1"))
+		message, ok := record.GetProtoMessage().(*protos.VehicleDeadLetter)
+		Expect(ok).To(BeTrue())
+		Expect(message.GetVin()).To(Equal("device-1"))
+		Expect(message.GetReason()).To(Equal(protos.DeadLetterReason_INVALID_MESSAGE))
+		Expect(message.GetTxid()).To(Equal("test-txid"))
+		Expect(message.GetRecordType()).To(Equal("V"))
+		Expect(message.GetRawBytes()).To(Equal(msgBytes))
+		Expect(s.Close()).To(Succeed())
+
+		data, err := os.ReadFile(deadLetterFile)
+		Expect(err).NotTo(HaveOccurred())
+		letter := &deadletter.Letter{}
+		Expect(json.Unmarshal(data, letter)).To(Succeed())
+		Expect(letter.Reason).To(Equal(deadletter.ReasonInvalidMessage))
+		Expect(letter.Vin).To(Equal("device-1"))
+		Expect(letter.RecordType).To(Equal("V"))
+		Expect(letter.RawBytes).To(Equal(msgBytes))
+	})
+
+	Context("dedup", func() {
+		var (
+			spy      *spyProducer
+			registry *streaming.SocketRegistry
+			srvURL   string
+			ackChan  chan *telemetry.Ack
+		)
+
+		startServer := func(dedupConfig *dedup.Config, reliableAckSources map[string]telemetry.Dispatcher) {
+			logger, _ := logrus.NoOpLogger()
+			spy = &spyProducer{captured: make(chan *telemetry.Record, 2)}
+			ackChan = make(chan *telemetry.Ack)
+			conf := &config.Config{
+				MetricCollector:    noop.NewCollector(),
+				Dedup:              dedupConfig,
+				ReliableAckSources: reliableAckSources,
+				AckChan:            ackChan,
+			}
+
+			registry = streaming.NewSocketRegistry()
+			producerRules = map[string][]telemetry.Producer{"V": {spy}}
+			_, s, err := streaming.InitServer(conf, airbrake.NewAirbrakeHandler(nil), producerRules, logger, registry)
+			Expect(err).NotTo(HaveOccurred())
+
+			cert := makeCert("device-1", "TeslaMotors")
+			tlsState := &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert},
+				VerifiedChains:   [][]*x509.Certificate{{cert}},
+			}
+			srv := httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
+			DeferCleanup(srv.Close)
+			u, _ := url.Parse(srv.URL)
+			u.Scheme = "ws"
+			srvURL = u.String()
+		}
+
+		sendRecord := func() *websocket.Conn {
+			dialer := &websocket.Dialer{HandshakeTimeout: 1 * time.Second}
+			conn, _, err := dialer.Dial(srvURL, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			streamMsg := messages.StreamMessage{
+				TXID:         []byte("test-txid"),
+				SenderID:     []byte("vehicle_device.device-1"),
+				DeviceID:     []byte("device-1"),
+				DeviceType:   []byte("vehicle_device"),
+				MessageTopic: []byte("V"),
+				Payload:      []byte{},
+			}
+			msgBytes, err := streamMsg.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn.WriteMessage(websocket.BinaryMessage, msgBytes)).To(Succeed())
+			return conn
+		}
+
+		It("acks a duplicate txid without dispatching it, across reconnects", func() {
+			startServer(&dedup.Config{}, nil)
+
+			conn := sendRecord()
+			Eventually(spy.captured).Should(Receive())
+			Expect(conn.Close()).To(Succeed())
+			Eventually(registry.NumConnectedSockets).Should(Equal(0))
+
+			conn = sendRecord()
+			defer func() { _ = conn.Close() }()
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err := conn.ReadMessage()
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(spy.captured).ShouldNot(Receive())
+		})
+
+		It("dispatches the resend of a record whose delivery failed, until its reliable ack is sent", func() {
+			startServer(&dedup.Config{}, map[string]telemetry.Dispatcher{"V": telemetry.Kafka})
+
+			// the first copy is never acked, as when its delivery fails
+			conn := sendRecord()
+			Eventually(spy.captured).Should(Receive())
+			Expect(conn.Close()).To(Succeed())
+			Eventually(registry.NumConnectedSockets).Should(Equal(0))
+
+			conn = sendRecord()
+			var record *telemetry.Record
+			Eventually(spy.captured).Should(Receive(&record))
+			ackChan <- &telemetry.Ack{Record: record, Dispatcher: telemetry.Kafka}
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err := conn.ReadMessage()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn.Close()).To(Succeed())
+			Eventually(registry.NumConnectedSockets).Should(Equal(0))
+
+			// once acked, the next copy is a duplicate
+			conn = sendRecord()
+			defer func() { _ = conn.Close() }()
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err = conn.ReadMessage()
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(spy.captured).ShouldNot(Receive())
+		})
+
+		It("tags duplicates in observe only mode", func() {
+			startServer(&dedup.Config{ObserveOnly: true}, nil)
+
+			conn := sendRecord()
+			defer func() { _ = conn.Close() }()
+			var record *telemetry.Record
+			Eventually(spy.captured).Should(Receive(&record))
+			Expect(record.Metadata()).NotTo(HaveKey("duplicate"))
+
+			duplicateConn := sendRecord()
+			defer func() { _ = duplicateConn.Close() }()
+			Eventually(spy.captured).Should(Receive(&record))
+			Expect(record.Metadata()).To(HaveKeyWithValue("duplicate", "true"))
+		})
+	})
 })
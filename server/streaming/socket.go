@@ -1,17 +1,17 @@
 package streaming
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"net"
 	"net/http"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/beefsack/go-rate"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
@@ -20,6 +20,10 @@ import (
 	"github.com/teslamotors/fleet-telemetry/metrics"
 	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/ackpolicy"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/telemetry/deadletter"
+	"github.com/teslamotors/fleet-telemetry/telemetry/dedup"
 )
 
 type contextKeyType int
@@ -37,6 +41,14 @@ const WriteLoopDeadline = 10 * time.Second
 // the graceful-drain path (SIGTERM/SIGINT) rather than a vehicle-initiated close.
 var errServerShutdown = errors.New("server_shutdown")
 
+// errAdminClose is recorded as the close_reason when an operator force-closes a
+// socket through the admin API.
+var errAdminClose = errors.New("admin_close")
+
+// errRateLimitExceeded is recorded as the close_reason when a socket is closed by a
+// rate limit configured with the close action.
+var errRateLimitExceeded = errors.New("rate_limit_exceeded")
+
 // SocketManager is a struct responsible for managing the socket connection with the clients
 type SocketManager struct {
 	Ws           *websocket.Conn
@@ -45,18 +57,43 @@ type SocketManager struct {
 	StartTime    time.Time
 	UUID         string
 
-	config                 *config.Config
-	logger                 *logrus.Logger
-	requestIdentity        *telemetry.RequestIdentity
-	requestInfo            map[string]interface{}
-	metricsCollector       metrics.MetricCollector
-	stopChan               chan struct{}
-	writeChan              chan SocketMessage
-	transmitDecodedRecords bool
-	vinsSignalTracking     map[string]struct{}
+	logger           *logrus.Logger
+	requestIdentity  *telemetry.RequestIdentity
+	requestInfo      map[string]interface{}
+	metricsCollector metrics.MetricCollector
+	stopChan         chan struct{}
+	writeChan        chan SocketMessage
+	settings         atomic.Pointer[socketSettings]
+	capture          *capture.Writer
+	dedup            *dedup.Cache
+	acks             *ackpolicy.Tracker
+	deadLetter       *deadletter.Handler
 
 	closeReasonMu sync.Mutex
 	closeReason   string
+
+	statsMu       sync.Mutex
+	lastMessageAt atomic.Int64
+}
+
+// socketSettings holds the settings of a socket that a configuration reload can change
+type socketSettings struct {
+	config             *config.Config
+	rateLimiters       *rateLimiters
+	vinsSignalTracking map[string]struct{}
+}
+
+// SocketInfo is a point in time snapshot of a connected socket
+type SocketInfo struct {
+	UUID             string                    `json:"uuid"`
+	DeviceID         string                    `json:"device_id"`
+	SenderID         string                    `json:"sender_id"`
+	NetworkInterface string                    `json:"network_interface"`
+	ClientVersion    string                    `json:"client_version"`
+	ConnectedAt      time.Time                 `json:"connected_at"`
+	LastMessageAt    *time.Time                `json:"last_message_at,omitempty"`
+	RecordsStats     map[string]int            `json:"records_stats"`
+	RateLimits       map[string]RateLimitState `json:"rate_limits,omitempty"`
 }
 
 // SocketMessage represents incoming socket connection
@@ -77,6 +114,8 @@ type Metrics struct {
 	socketErrorCount             adapter.Counter
 	recordSizeBytesTotal         adapter.Counter
 	recordCount                  adapter.Counter
+	captureErrorCount            adapter.Counter
+	duplicateRecordCount         adapter.Counter
 	signalsCount                 adapter.Gauge
 	vinSignalCount               adapter.Gauge
 }
@@ -92,23 +131,42 @@ func NewSocketManager(ctx context.Context, requestIdentity *telemetry.RequestIde
 
 	requestLogInfo, socketUUID := buildRequestContext(ctx)
 
-	return &SocketManager{
+	sm := &SocketManager{
 		Ws:           ws,
 		MsgType:      websocket.BinaryMessage,
 		RecordsStats: make(map[string]int),
 		StartTime:    time.Now(),
 		UUID:         socketUUID.String(),
 
-		config:                 config,
-		metricsCollector:       config.MetricCollector,
-		logger:                 logger,
-		requestInfo:            requestLogInfo,
-		writeChan:              make(chan SocketMessage, 1000),
-		stopChan:               make(chan struct{}),
-		requestIdentity:        requestIdentity,
-		transmitDecodedRecords: config.TransmitDecodedRecords,
-		vinsSignalTracking:     config.VinsToTrack(),
+		metricsCollector: config.MetricCollector,
+		logger:           logger,
+		requestInfo:      requestLogInfo,
+		writeChan:        make(chan SocketMessage, 1000),
+		stopChan:         make(chan struct{}),
+		requestIdentity:  requestIdentity,
 	}
+	sm.UpdateConfig(config)
+	return sm
+}
+
+// UpdateConfig applies a reloaded configuration to the socket. The rate limiters are kept,
+// along with their current state, unless the rate limits of the vehicle changed.
+func (sm *SocketManager) UpdateConfig(config *config.Config) {
+	settings := &socketSettings{
+		config:             config,
+		vinsSignalTracking: config.VinsToTrack(),
+	}
+	vin := ""
+	if sm.requestIdentity != nil {
+		vin = sm.requestIdentity.DeviceID
+	}
+	rules := config.RateLimitRules(vin)
+	if previous := sm.settings.Load(); previous != nil && reflect.DeepEqual(previous.rateLimiters.rules, rules) {
+		settings.rateLimiters = previous.rateLimiters
+	} else {
+		settings.rateLimiters = newRateLimiters(rules)
+	}
+	sm.settings.Store(settings)
 }
 
 func buildRequestContext(ctx context.Context) (logInfo map[string]interface{}, socketUUID uuid.UUID) {
@@ -218,10 +276,42 @@ func (sm *SocketManager) recordCloseReason(err error) {
 // only records a close reason and closes the underlying connection, which is what
 // wakes ReadMessage.
 func (sm *SocketManager) RequestClose() {
-	sm.recordCloseReason(errServerShutdown)
+	sm.requestCloseWithReason(errServerShutdown)
+}
+
+func (sm *SocketManager) requestCloseWithReason(reason error) {
+	sm.recordCloseReason(reason)
 	_ = sm.Ws.Close()
 }
 
+// Info returns a snapshot of the socket's identity and activity. Safe to call from
+// another goroutine while the socket is processing telemetry.
+func (sm *SocketManager) Info() SocketInfo {
+	info := SocketInfo{
+		UUID:             sm.UUID,
+		NetworkInterface: sm.GetNetworkInterface(),
+		ConnectedAt:      sm.StartTime,
+	}
+	if sm.requestIdentity != nil {
+		info.DeviceID = sm.requestIdentity.DeviceID
+		info.SenderID = sm.requestIdentity.SenderID
+		info.ClientVersion = sm.requestIdentity.DeviceClientVersion
+	}
+	if lastMessageAt := sm.lastMessageAt.Load(); lastMessageAt > 0 {
+		t := time.Unix(0, lastMessageAt)
+		info.LastMessageAt = &t
+	}
+	info.RateLimits = sm.settings.Load().rateLimiters.States()
+
+	sm.statsMu.Lock()
+	defer sm.statsMu.Unlock()
+	info.RecordsStats = make(map[string]int, len(sm.RecordsStats))
+	for key, value := range sm.RecordsStats {
+		info.RecordsStats[key] = value
+	}
+	return info
+}
+
 // Close shuts down a socket connection for a single client and log metrics
 func (sm *SocketManager) Close() {
 	if err := sm.Ws.Close(); err != nil {
@@ -243,6 +333,9 @@ func (sm *SocketManager) Close() {
 
 // RecordsStatsToLogInfo converts the stats map into a loggable map, keeping values int-typed
 func (sm *SocketManager) RecordsStatsToLogInfo() map[string]interface{} {
+	sm.statsMu.Lock()
+	defer sm.statsMu.Unlock()
+
 	total := 0
 	logInfo := make(map[string]interface{})
 	for key, value := range sm.RecordsStats {
@@ -262,16 +355,6 @@ func (sm *SocketManager) ProcessTelemetry(serializer *telemetry.BinarySerializer
 
 	sm.logger.ActivityLog("socket_connected", sm.requestInfo)
 	go sm.writer()
-	var rl *rate.RateLimiter
-
-	if sm.config.RateLimit == nil {
-		// No rate limit config - apply default
-		rl = rate.New(100, 60*time.Second)
-	} else if sm.config.RateLimit.Enabled {
-		// Rate limiting explicitly enabled with custom values
-		rl = rate.New(sm.config.RateLimit.MessageLimit, sm.config.RateLimit.MessageIntervalTimeSecond)
-	}
-	// else: RateLimit config exists but Enabled is false - no rate limiting
 
 	var rateLimitStartTime time.Time
 	messagesRateLimited := 0
@@ -285,38 +368,52 @@ func (sm *SocketManager) ProcessTelemetry(serializer *telemetry.BinarySerializer
 			}
 			return
 		}
+		sm.lastMessageAt.Store(time.Now().UnixNano())
+		record, err := sm.newRecord(serializer, message)
 
-		// check rate limit
-		if rl != nil {
-			if ok, _ := rl.Try(); !ok {
-				if messagesRateLimited == 0 {
-					rateLimitStartTime = time.Now()
-				}
-				// client exceeded the rate limit
-				messagesRateLimited++
-				record, _ := telemetry.NewRecord(serializer, message, sm.UUID, sm.transmitDecodedRecords)
-				sm.trackSignalUsage(record)
-				metricsRegistry.rateLimitExceededCount.Inc(map[string]string{"device_id": sm.requestIdentity.DeviceID, "txtype": record.TxType})
-				continue
+		// check the rate limit of the record type
+		if limiter := sm.settings.Load().rateLimiters.forRecordType(record.TxType); limiter != nil && !limiter.Allow() {
+			if messagesRateLimited == 0 {
+				rateLimitStartTime = time.Now()
 			}
-			if messagesRateLimited > 0 {
-				parts := bytes.Split(message, []byte(","))
-				if len(parts) > 2 {
-					duration := time.Since(rateLimitStartTime) / time.Second
-
-					sm.logger.ErrorLog("rate_limit_exceeded", nil, logrus.LogInfo{"txid": parts[2], "duration_sec": duration, "messages_rate_limited": messagesRateLimited})
-				}
-				messagesRateLimited = 0
+			// client exceeded the rate limit
+			messagesRateLimited++
+			sm.trackSignalUsage(record)
+			metricsRegistry.rateLimitExceededCount.Inc(map[string]string{"device_id": sm.requestIdentity.DeviceID, "txtype": record.TxType})
+			if !sm.handleRateLimited(record, err, limiter.rule.Action) {
+				return
 			}
+			continue
+		}
+		if messagesRateLimited > 0 {
+			duration := time.Since(rateLimitStartTime) / time.Second
+			sm.logger.ErrorLog("rate_limit_exceeded", nil, logrus.LogInfo{"txid": record.Txid, "duration_sec": duration, "messages_rate_limited": messagesRateLimited})
+			messagesRateLimited = 0
 		}
-		sm.ParseAndProcessRecord(serializer, message)
+		sm.processRecordWithError(record, err)
 	}
 }
 
+// handleRateLimited applies the action of the exceeded rate limit to the record. It returns false when
+// the socket must be closed.
+func (sm *SocketManager) handleRateLimited(record *telemetry.Record, err error, action config.RateLimitAction) bool {
+	switch action {
+	case config.RateLimitAck:
+		if err == nil {
+			sm.respondToVehicle(record, nil)
+		}
+	case config.RateLimitClose:
+		sm.recordCloseReason(errRateLimitExceeded)
+		sm.logger.ErrorLog("rate_limit_close", nil, logrus.LogInfo{"txid": record.Txid, "record_type": record.TxType, "device_id": sm.requestIdentity.DeviceID})
+		return false
+	}
+	return true
+}
+
 func (sm *SocketManager) trackSignalUsage(record *telemetry.Record) {
 	metricsRegistry.signalsCount.Add(int64(record.SignalsCount()), map[string]string{"record_type": record.TxType})
 	vin := record.Vin
-	if _, ok := sm.vinsSignalTracking[vin]; !ok {
+	if _, ok := sm.settings.Load().vinsSignalTracking[vin]; !ok {
 		return
 	}
 	metricsRegistry.vinSignalCount.Add(int64(record.SignalsCount()), map[string]string{"vin": vin, "record_type": record.TxType})
@@ -324,14 +421,47 @@ func (sm *SocketManager) trackSignalUsage(record *telemetry.Record) {
 
 // ParseAndProcessRecord reads incoming client message and dispatches to relevant producer
 func (sm *SocketManager) ParseAndProcessRecord(serializer *telemetry.BinarySerializer, message []byte) *telemetry.Record {
-	record, err := telemetry.NewRecord(serializer, message, sm.UUID, sm.transmitDecodedRecords)
+	record, err := sm.newRecord(serializer, message)
+	sm.processRecordWithError(record, err)
+	return record
+}
+
+// newRecord parses a message of the vehicle, captures its raw bytes when capture is enabled, and hands it to the
+// dead letter handler when it fails to parse
+func (sm *SocketManager) newRecord(serializer *telemetry.BinarySerializer, message []byte) (*telemetry.Record, error) {
+	receivedAt := time.Now()
+	record, err := telemetry.NewRecord(serializer, message, sm.UUID, sm.transmitDecodedRecords())
+	if sm.capture != nil {
+		if captureErr := sm.capture.Write(sm.requestIdentity.DeviceID, receivedAt, record.RawBytes); captureErr != nil {
+			sm.logger.ErrorLog("capture_write_error", captureErr, logrus.LogInfo{"txid": record.Txid, "record_type": record.TxType})
+			metricsRegistry.captureErrorCount.Inc(map[string]string{})
+		}
+	}
+	if reason, ok := deadletter.Reason(err); ok && sm.deadLetter != nil {
+		sm.deadLetter.Handle(record, &deadletter.Letter{
+			ReceivedAt:    receivedAt,
+			Reason:        reason,
+			Error:         err.Error(),
+			SocketID:      sm.UUID,
+			Vin:           sm.requestIdentity.DeviceID,
+			ClientVersion: sm.requestIdentity.DeviceClientVersion,
+			Txid:          record.Txid,
+			RecordType:    record.TxType,
+			RawBytes:      message,
+		})
+	}
+	return record, err
+}
+
+// processRecordWithError responds to a record that failed to parse, or dispatches it
+func (sm *SocketManager) processRecordWithError(record *telemetry.Record, err error) {
 	logInfo := logrus.LogInfo{"txid": record.Txid, "record_type": record.TxType}
 
 	if err != nil {
 		if err == telemetry.ErrMessageTooBig {
 			sm.respondToVehicle(record, err)
 			metricsRegistry.recordTooBigCount.Inc(map[string]string{})
-			return record
+			return
 		}
 
 		switch typedError := err.(type) {
@@ -341,7 +471,7 @@ func (sm *SocketManager) ParseAndProcessRecord(serializer *telemetry.BinarySeria
 			sm.logger.ErrorLog("unauthorized_sender_id", nil, logInfo)
 			metricsRegistry.unauthorizedSenderCount.Inc(map[string]string{})
 			sm.respondToVehicle(record, nil) // respond to the client message was accepted so they are not resending it over and over
-			return record
+			return
 		case *telemetry.UnknownMessageType:
 			logInfo["msg_txid"] = typedError.Txid
 			logInfo["msg_type"] = string(typedError.GuessedType)
@@ -350,10 +480,16 @@ func (sm *SocketManager) ParseAndProcessRecord(serializer *telemetry.BinarySeria
 			sm.respondToVehicle(record, nil) // respond to the client message was accepted so they are not resending it over and over
 		default:
 			sm.respondToVehicle(record, err)
-			return record
+			return
 		}
 	}
 
+	if sm.suppressDuplicate(record) {
+		return
+	}
+
+	sm.trackReliableAck(record)
+
 	// write the record out to kafka
 	sm.ReportMetricBytesPerRecords(record.TxType, record.Length())
 	sm.processRecord(record)
@@ -362,14 +498,49 @@ func (sm *SocketManager) ParseAndProcessRecord(serializer *telemetry.BinarySeria
 	if !sm.reliableAck(record) {
 		sm.respondToVehicle(record, nil)
 	}
-	return record
+}
+
+// suppressDuplicate acks a record already acked without dispatching it again. When dedup only observes, the
+// duplicate is tagged and dispatched anyway. A record with reliable acks is only remembered once its ack is sent, so
+// the resend of a record whose delivery failed is dispatched again.
+func (sm *SocketManager) suppressDuplicate(record *telemetry.Record) bool {
+	if sm.dedup == nil || record.Txid == "" || !sm.dedup.Seen(record.Vin, record.TxType, record.Txid, sm.reliableAck(record)) {
+		return false
+	}
+	if sm.dedup.ObserveOnly() {
+		metricsRegistry.duplicateRecordCount.Inc(map[string]string{"record_type": record.TxType, "action": "observed"})
+		record.Duplicate = true
+		return false
+	}
+	metricsRegistry.duplicateRecordCount.Inc(map[string]string{"record_type": record.TxType, "action": "suppressed"})
+	sm.respondToVehicle(record, nil)
+	return true
 }
 
 func (sm *SocketManager) reliableAck(record *telemetry.Record) bool {
-	_, ok := sm.config.ReliableAckSources[record.TxType]
+	cfg := sm.settings.Load().config
+	if _, ok := cfg.ReliableAckSources[record.TxType]; ok {
+		return true
+	}
+	_, ok := cfg.ReliableAckPolicies[record.TxType]
 	return ok
 }
 
+// trackReliableAck waits for the acks of the dispatchers of the reliable ack policy of the record, if any. It runs
+// before the record is dispatched so that no ack is missed.
+func (sm *SocketManager) trackReliableAck(record *telemetry.Record) {
+	if sm.acks == nil || record.Serializer == nil {
+		return
+	}
+	if policy, ok := sm.settings.Load().config.ReliableAckPolicies[record.TxType]; ok && policy != nil {
+		sm.acks.Track(record, policy)
+	}
+}
+
+func (sm *SocketManager) transmitDecodedRecords() bool {
+	return sm.settings.Load().config.TransmitDecodedRecords
+}
+
 func (sm *SocketManager) processRecord(record *telemetry.Record) {
 	record.Dispatch()
 	metricsRegistry.dispatchCount.Inc(map[string]string{"record_type": record.TxType})
@@ -395,6 +566,13 @@ func (sm *SocketManager) respondToVehicle(record *telemetry.Record, err error) {
 	sm.writeChan <- SocketMessage{sm.MsgType, record.Txid, response}
 }
 
+// respondWithError sends an error message to the client for a record it should send again
+func (sm *SocketManager) respondWithError(record *telemetry.Record, err error) {
+	logInfo := logrus.LogInfo{"txid": record.Txid, "record_type": record.TxType, "device_id": sm.requestIdentity.DeviceID, "response_type": "error"}
+	sm.logger.ErrorLog("record_not_acked", err, logInfo)
+	sm.writeChan <- SocketMessage{sm.MsgType, record.Txid, record.Error(err)}
+}
+
 func (sm *SocketManager) writer() {
 	defer func() {
 
@@ -428,7 +606,9 @@ func (sm *SocketManager) writeMessage(msgType int, msg []byte) error {
 
 // ReportMetricBytesPerRecords records metrics for metric size
 func (sm *SocketManager) ReportMetricBytesPerRecords(recordType string, byteSize int) {
+	sm.statsMu.Lock()
 	sm.RecordsStats[recordType] += byteSize
+	sm.statsMu.Unlock()
 
 	metricsRegistry.recordSizeBytesTotal.Add(int64(byteSize), map[string]string{"record_type": recordType})
 	metricsRegistry.recordCount.Inc(map[string]string{"record_type": recordType})
@@ -493,6 +673,18 @@ func registerMetrics(metricsCollector metrics.MetricCollector) {
 		Labels: []string{"record_type"},
 	})
 
+	metricsRegistry.captureErrorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "capture_error_total",
+		Help:   "The number of records that could not be captured.",
+		Labels: []string{},
+	})
+
+	metricsRegistry.duplicateRecordCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "duplicate_record_total",
+		Help:   "The number of records received again with the same txid, suppressed or only observed.",
+		Labels: []string{"record_type", "action"},
+	})
+
 	metricsRegistry.signalsCount = metricsCollector.RegisterGauge(adapter.CollectorOptions{
 		Name:   "signal_count",
 		Help:   "Total number of signals received per record type",
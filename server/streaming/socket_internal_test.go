@@ -7,8 +7,11 @@ import (
 	"os"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/teslamotors/fleet-telemetry/config"
 )
 
 func TestIsExpectedDisconnect(t *testing.T) {
@@ -51,3 +54,68 @@ func TestIsExpectedDisconnect(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimiters(t *testing.T) {
+	limiters := newRateLimiters(&config.RateLimitRules{
+		Default:     &config.RateLimitRule{Limit: 2, Interval: time.Hour, Action: config.RateLimitDrop},
+		RecordTypes: map[string]*config.RateLimitRule{"alerts": {Limit: 1, Interval: time.Hour, Action: config.RateLimitAck}},
+	})
+
+	for i, want := range []bool{true, true, false} {
+		if got := limiters.forRecordType("V").Allow(); got != want {
+			t.Errorf("V message %d allowed = %v, want %v", i, got, want)
+		}
+	}
+	// alerts have their own limit, so the exhausted default limit does not starve them
+	for i, want := range []bool{true, false} {
+		if got := limiters.forRecordType("alerts").Allow(); got != want {
+			t.Errorf("alerts message %d allowed = %v, want %v", i, got, want)
+		}
+	}
+
+	states := limiters.States()
+	if len(states) != 2 {
+		t.Fatalf("got %d limiter states, want 2", len(states))
+	}
+	if state := states["alerts"]; state.Limit != 1 || state.Action != config.RateLimitAck || state.Remaining != 0 {
+		t.Errorf("unexpected alerts state %+v", state)
+	}
+	if state := states[defaultRateLimiterName]; state.Limit != 2 || state.IntervalSeconds != time.Hour.Seconds() {
+		t.Errorf("unexpected default state %+v", state)
+	}
+}
+
+func TestRateLimitersUnlimited(t *testing.T) {
+	if limiter := newRateLimiters(nil).forRecordType("V"); limiter != nil {
+		t.Errorf("expected no limiter when rate limiting is disabled")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	window := newSlidingWindow(&config.RateLimitRule{Limit: 10, Interval: time.Second})
+	for i := 0; i < 10; i++ {
+		if !window.Allow() {
+			t.Fatalf("message %d should be allowed", i)
+		}
+	}
+	if window.Allow() {
+		t.Fatalf("window should be full")
+	}
+
+	// half of the messages were allowed more than an interval ago
+	for i := 0; i < 5; i++ {
+		window.times[i] = window.times[i].Add(-time.Second)
+	}
+	if remaining := window.State().Remaining; remaining != 5 {
+		t.Errorf("got %v remaining messages, want 5", remaining)
+	}
+	for i := 0; i < 5; i++ {
+		if !window.Allow() {
+			t.Fatalf("message %d should be allowed once the oldest messages left the window", i)
+		}
+	}
+	// unlike a token bucket, an idle period does not allow a burst on top of the limit
+	if window.Allow() {
+		t.Fatalf("window should be full")
+	}
+}
@@ -1,6 +1,11 @@
 package streaming
 
-import "sync"
+import (
+	"sort"
+	"sync"
+
+	"github.com/teslamotors/fleet-telemetry/config"
+)
 
 // SocketRegistry is a library to handle keeping track of connected sockets
 type SocketRegistry struct {
@@ -69,3 +74,51 @@ func (s *SocketRegistry) CloseAllSockets() {
 		socket.RequestClose()
 	}
 }
+
+// ListSockets returns a snapshot of every connected socket, ordered by device id
+// and connection time
+func (s *SocketRegistry) ListSockets() []SocketInfo {
+	s.mutex.RLock()
+	infos := make([]SocketInfo, 0, len(s.sockets))
+	for _, socket := range s.sockets {
+		infos = append(infos, socket.Info())
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].DeviceID != infos[j].DeviceID {
+			return infos[i].DeviceID < infos[j].DeviceID
+		}
+		return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+	})
+	return infos
+}
+
+// CloseDeviceSockets asks every socket connected for a device (VIN) to close, the
+// same way CloseAllSockets does, and returns how many were found. A vehicle can
+// briefly hold more than one socket while it reconnects.
+func (s *SocketRegistry) CloseDeviceSockets(deviceID string) int {
+	s.mutex.RLock()
+	var sockets []*SocketManager
+	for _, socket := range s.sockets {
+		if socket.requestIdentity != nil && socket.requestIdentity.DeviceID == deviceID {
+			sockets = append(sockets, socket)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, socket := range sockets {
+		socket.requestCloseWithReason(errAdminClose)
+	}
+	return len(sockets)
+}
+
+// UpdateConfig applies a reloaded configuration to every connected socket
+func (s *SocketRegistry) UpdateConfig(config *config.Config) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, socket := range s.sockets {
+		socket.UpdateConfig(config)
+	}
+}
@@ -0,0 +1,79 @@
+package streaming_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/teslamotors/fleet-telemetry/config"
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter/noop"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+	"github.com/teslamotors/fleet-telemetry/server/streaming"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Socket registry test", func() {
+	var (
+		registry *streaming.SocketRegistry
+		srv      *httptest.Server
+		conn     *websocket.Conn
+	)
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		conf := &config.Config{MetricCollector: noop.NewCollector()}
+
+		registry = streaming.NewSocketRegistry()
+		_, s, err := streaming.InitServer(conf, airbrake.NewAirbrakeHandler(nil), map[string][]telemetry.Producer{}, logger, registry)
+		Expect(err).NotTo(HaveOccurred())
+
+		cert := makeCert("device-1", "TeslaMotors")
+		tlsState := &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+		srv = httptest.NewServer(withTLSState(http.HandlerFunc(s.ServeBinaryWs()), tlsState))
+		u, _ := url.Parse(srv.URL)
+		u.Scheme = "ws"
+
+		dialer := &websocket.Dialer{HandshakeTimeout: 1 * time.Second}
+		header := http.Header{"Version": {"2024.44.25"}, "X-Network-Interface": {"wifi"}}
+		conn, _, err = dialer.Dial(u.String(), header)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(registry.NumConnectedSockets).Should(Equal(1))
+	})
+
+	AfterEach(func() {
+		_ = conn.Close()
+		srv.Close()
+	})
+
+	It("lists connected sockets", func() {
+		sockets := registry.ListSockets()
+		Expect(sockets).To(HaveLen(1))
+		Expect(sockets[0].DeviceID).To(Equal("device-1"))
+		Expect(sockets[0].SenderID).To(Equal("vehicle_device.device-1"))
+		Expect(sockets[0].ClientVersion).To(Equal("2024.44.25"))
+		Expect(sockets[0].NetworkInterface).To(Equal("wifi"))
+		Expect(sockets[0].UUID).NotTo(BeEmpty())
+		Expect(sockets[0].ConnectedAt).NotTo(BeZero())
+		Expect(sockets[0].LastMessageAt).To(BeNil())
+	})
+
+	It("closes the sockets of a device", func() {
+		Expect(registry.CloseDeviceSockets("unknown")).To(Equal(0))
+		Expect(registry.NumConnectedSockets()).To(Equal(1))
+
+		Expect(registry.CloseDeviceSockets("device-1")).To(Equal(1))
+		Eventually(registry.NumConnectedSockets).Should(Equal(0))
+	})
+})
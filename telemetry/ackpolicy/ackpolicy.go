@@ -0,0 +1,248 @@
+package ackpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// Requirements of a policy other than a number of dispatchers
+const (
+	// RequireAny acks a record once one of the dispatchers confirmed it
+	RequireAny Requirement = "any"
+	// RequireAll acks a record once every dispatcher confirmed it
+	RequireAll Requirement = "all"
+)
+
+// DefaultTimeoutSeconds is how long a record waits for the acks of its dispatchers by default
+const DefaultTimeoutSeconds = 30
+
+// Policy is the set of dispatchers which must confirm the records of a type before the vehicle gets its ack
+type Policy struct {
+	// Dispatchers confirming the records, each must be one of the dispatchers of the record type
+	Dispatchers []telemetry.Dispatcher `json:"dispatchers"`
+
+	// Require is "any", "all" or the number of dispatchers which must confirm a record. Default: all
+	Require Requirement `json:"require,omitempty"`
+
+	// TimeoutSeconds is how long a record waits for its acks, the vehicle then gets an error so it sends the record
+	// again. Default: 30
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// Requirement is "any", "all" or the number of dispatchers which must confirm a record, given as a JSON string or
+// number
+type Requirement string
+
+// UnmarshalJSON accepts a number of dispatchers as well as a string
+func (r *Requirement) UnmarshalJSON(data []byte) error {
+	var count int
+	if err := json.Unmarshal(data, &count); err == nil {
+		*r = Requirement(strconv.Itoa(count))
+		return nil
+	}
+	var requirement string
+	if err := json.Unmarshal(data, &requirement); err != nil {
+		return fmt.Errorf("invalid reliable ack requirement: %s", data)
+	}
+	*r = Requirement(requirement)
+	return nil
+}
+
+// Validate ensures the policy names distinct dispatchers and a requirement they can meet
+func (p *Policy) Validate() error {
+	if len(p.Dispatchers) == 0 {
+		return fmt.Errorf("reliable ack policy without dispatchers")
+	}
+	seen := make(map[telemetry.Dispatcher]struct{}, len(p.Dispatchers))
+	for _, dispatcher := range p.Dispatchers {
+		if _, ok := seen[dispatcher]; ok {
+			return fmt.Errorf("duplicate dispatcher in reliable ack policy: %s", dispatcher)
+		}
+		seen[dispatcher] = struct{}{}
+	}
+	if p.TimeoutSeconds < 0 {
+		return fmt.Errorf("invalid reliable ack timeout: %d", p.TimeoutSeconds)
+	}
+	_, err := p.Required()
+	return err
+}
+
+// Required returns the number of dispatchers which must confirm a record
+func (p *Policy) Required() (int, error) {
+	switch p.Require {
+	case "", RequireAll:
+		return len(p.Dispatchers), nil
+	case RequireAny:
+		return 1, nil
+	}
+	count, err := strconv.Atoi(string(p.Require))
+	if err != nil || count < 1 || count > len(p.Dispatchers) {
+		return 0, fmt.Errorf("invalid reliable ack requirement: %s, expected any, all or 1 to %d", p.Require, len(p.Dispatchers))
+	}
+	return count, nil
+}
+
+// Timeout returns how long a record waits for its acks
+func (p *Policy) Timeout() time.Duration {
+	if p.TimeoutSeconds <= 0 {
+		return DefaultTimeoutSeconds * time.Second
+	}
+	return time.Duration(p.TimeoutSeconds) * time.Second
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	pending      adapter.Gauge
+	timeoutCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// key identifies a record sent on a connection
+type key struct {
+	socketID string
+	txType   string
+	txid     string
+}
+
+// entry is a record waiting for the acks of its dispatchers
+type entry struct {
+	record      *telemetry.Record
+	required    int
+	dispatchers map[telemetry.Dispatcher]struct{}
+	acked       map[telemetry.Dispatcher]struct{}
+	confirmed   bool
+	expiresAt   time.Time
+}
+
+// Tracker counts the dispatchers which acked each record, per txid
+type Tracker struct {
+	logger *logrus.Logger
+	now    func() time.Time
+
+	mutex   sync.Mutex
+	entries map[key]*entry
+}
+
+// NewTracker returns a tracker without pending records
+func NewTracker(metricsCollector metrics.MetricCollector, logger *logrus.Logger) *Tracker {
+	registerMetricsOnce(metricsCollector)
+	return &Tracker{
+		logger:  logger,
+		now:     time.Now,
+		entries: make(map[key]*entry),
+	}
+}
+
+// Track starts waiting for the acks of a record, it must be called before the record is dispatched
+func (t *Tracker) Track(record *telemetry.Record, policy *Policy) {
+	required, err := policy.Required()
+	if err != nil {
+		t.logger.ErrorLog("reliable_ack_policy_error", err, logrus.LogInfo{"record_type": record.TxType})
+		return
+	}
+
+	dispatchers := make(map[telemetry.Dispatcher]struct{}, len(policy.Dispatchers))
+	for _, dispatcher := range policy.Dispatchers {
+		dispatchers[dispatcher] = struct{}{}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries[keyOf(record)] = &entry{
+		record:      record,
+		required:    required,
+		dispatchers: dispatchers,
+		acked:       make(map[telemetry.Dispatcher]struct{}, len(dispatchers)),
+		expiresAt:   t.now().Add(policy.Timeout()),
+	}
+	metricsRegistry.pending.Set(int64(len(t.entries)), map[string]string{})
+}
+
+// Ack counts the ack of a dispatcher. Only the first ack of each dispatcher of the policy counts, ex.: a dispatcher
+// retrying a delivery does not stand for another one. It returns whether the record is tracked, and whether this ack
+// is the one meeting the requirement of its policy, the vehicle being acked once.
+func (t *Tracker) Ack(record *telemetry.Record, dispatcher telemetry.Dispatcher) (tracked bool, confirmed bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	k := keyOf(record)
+	pending, ok := t.entries[k]
+	if !ok {
+		return false, false
+	}
+	if _, ok := pending.dispatchers[dispatcher]; !ok {
+		return true, false
+	}
+	pending.acked[dispatcher] = struct{}{}
+	if len(pending.acked) >= len(pending.dispatchers) {
+		delete(t.entries, k)
+		metricsRegistry.pending.Set(int64(len(t.entries)), map[string]string{})
+	}
+	if pending.confirmed || len(pending.acked) < pending.required {
+		return true, false
+	}
+	pending.confirmed = true
+	return true, true
+}
+
+// Expire forgets the records waiting past their timeout, and returns those which did not get enough acks
+func (t *Tracker) Expire() []*telemetry.Record {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	var expired []*telemetry.Record
+	for k, pending := range t.entries {
+		if now.Before(pending.expiresAt) {
+			continue
+		}
+		delete(t.entries, k)
+		if !pending.confirmed {
+			expired = append(expired, pending.record)
+			metricsRegistry.timeoutCount.Inc(map[string]string{"record_type": k.txType})
+		}
+	}
+	metricsRegistry.pending.Set(int64(len(t.entries)), map[string]string{})
+	return expired
+}
+
+// Pending returns the number of records waiting for acks
+func (t *Tracker) Pending() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.entries)
+}
+
+func keyOf(record *telemetry.Record) key {
+	return key{socketID: record.SocketID, txType: record.TxType, txid: record.Txid}
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.pending = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "reliable_ack_pending",
+		Help:   "The number of records waiting for the acks of their reliable ack policy.",
+		Labels: []string{},
+	})
+
+	metricsRegistry.timeoutCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "reliable_ack_timeout_total",
+		Help:   "The number of records which did not get the acks of their reliable ack policy in time.",
+		Labels: []string{"record_type"},
+	})
+}
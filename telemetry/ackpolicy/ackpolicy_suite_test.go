@@ -0,0 +1,13 @@
+package ackpolicy
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAckPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AckPolicy Suite")
+}
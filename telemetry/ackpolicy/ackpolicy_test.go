@@ -0,0 +1,162 @@
+package ackpolicy
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Reliable ack policy", func() {
+	It("parses the requirement as a string or a number", func() {
+		policy := &Policy{}
+		Expect(json.Unmarshal([]byte(`{"dispatchers":["kafka","kinesis","pubsub"],"require":2}`), policy)).To(Succeed())
+		Expect(policy.Require).To(Equal(Requirement("2")))
+		Expect(policy.Required()).To(Equal(2))
+
+		Expect(json.Unmarshal([]byte(`{"dispatchers":["kafka","kinesis"],"require":"any"}`), policy)).To(Succeed())
+		Expect(policy.Required()).To(Equal(1))
+
+		Expect(json.Unmarshal([]byte(`{"dispatchers":["kafka"],"require":true}`), policy)).To(MatchError("invalid reliable ack requirement: true"))
+	})
+
+	It("requires every dispatcher by default", func() {
+		policy := &Policy{Dispatchers: []telemetry.Dispatcher{"kafka", "kinesis"}}
+		Expect(policy.Required()).To(Equal(2))
+		Expect(policy.Timeout()).To(Equal(DefaultTimeoutSeconds * time.Second))
+	})
+
+	DescribeTable("rejects invalid policies",
+		func(policy *Policy, expectedErr string) {
+			Expect(policy.Validate()).To(MatchError(expectedErr))
+		},
+		Entry("without dispatchers", &Policy{}, "reliable ack policy without dispatchers"),
+		Entry("with a duplicate dispatcher", &Policy{Dispatchers: []telemetry.Dispatcher{"kafka", "kafka"}}, "duplicate dispatcher in reliable ack policy: kafka"),
+		Entry("with a negative timeout", &Policy{Dispatchers: []telemetry.Dispatcher{"kafka"}, TimeoutSeconds: -1}, "invalid reliable ack timeout: -1"),
+		Entry("requiring more dispatchers than it has", &Policy{Dispatchers: []telemetry.Dispatcher{"kafka", "kinesis"}, Require: "3"}, "invalid reliable ack requirement: 3, expected any, all or 1 to 2"),
+		Entry("requiring no dispatcher", &Policy{Dispatchers: []telemetry.Dispatcher{"kafka"}, Require: "0"}, "invalid reliable ack requirement: 0, expected any, all or 1 to 1"),
+		Entry("with an unknown requirement", &Policy{Dispatchers: []telemetry.Dispatcher{"kafka"}, Require: "most"}, "invalid reliable ack requirement: most, expected any, all or 1 to 1"),
+	)
+})
+
+var _ = Describe("Reliable ack tracker", func() {
+	var (
+		tracker *Tracker
+		clock   time.Time
+		policy  *Policy
+		record  *telemetry.Record
+	)
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		clock = time.Now()
+		tracker = NewTracker(metrics.NewCollector(nil, logger), logger)
+		tracker.now = func() time.Time { return clock }
+		policy = &Policy{Dispatchers: []telemetry.Dispatcher{"kafka", "kinesis", "pubsub"}, TimeoutSeconds: 10}
+		record = &telemetry.Record{SocketID: "socket-1", TxType: "V", Txid: "1"}
+	})
+
+	It("ignores the acks of records it does not track", func() {
+		tracked, confirmed := tracker.Ack(record, "kafka")
+		Expect(tracked).To(BeFalse())
+		Expect(confirmed).To(BeFalse())
+	})
+
+	It("confirms a record once every dispatcher acked it", func() {
+		tracker.Track(record, policy)
+
+		for _, dispatcher := range policy.Dispatchers[:2] {
+			tracked, confirmed := tracker.Ack(record, dispatcher)
+			Expect(tracked).To(BeTrue())
+			Expect(confirmed).To(BeFalse())
+		}
+		tracked, confirmed := tracker.Ack(record, "pubsub")
+		Expect(tracked).To(BeTrue())
+		Expect(confirmed).To(BeTrue())
+		Expect(tracker.Pending()).To(Equal(0))
+	})
+
+	It("counts each dispatcher once", func() {
+		policy.Require = "2"
+		tracker.Track(record, policy)
+
+		for i := 0; i < 3; i++ {
+			tracked, confirmed := tracker.Ack(record, "kafka")
+			Expect(tracked).To(BeTrue())
+			Expect(confirmed).To(BeFalse())
+		}
+		_, confirmed := tracker.Ack(record, "kinesis")
+		Expect(confirmed).To(BeTrue())
+		Expect(tracker.Pending()).To(Equal(1))
+	})
+
+	It("ignores the acks of dispatchers outside of the policy", func() {
+		tracker.Track(record, &Policy{Dispatchers: []telemetry.Dispatcher{"kafka"}})
+
+		tracked, confirmed := tracker.Ack(record, "kinesis")
+		Expect(tracked).To(BeTrue())
+		Expect(confirmed).To(BeFalse())
+		Expect(tracker.Pending()).To(Equal(1))
+	})
+
+	It("confirms a record once, on the first ack, for any dispatcher", func() {
+		policy.Require = RequireAny
+		tracker.Track(record, policy)
+
+		tracked, confirmed := tracker.Ack(record, "kinesis")
+		Expect(tracked).To(BeTrue())
+		Expect(confirmed).To(BeTrue())
+
+		for _, dispatcher := range []telemetry.Dispatcher{"kafka", "pubsub"} {
+			tracked, confirmed = tracker.Ack(record, dispatcher)
+			Expect(tracked).To(BeTrue())
+			Expect(confirmed).To(BeFalse())
+		}
+		Expect(tracker.Pending()).To(Equal(0))
+	})
+
+	It("confirms a record once a quorum of dispatchers acked it", func() {
+		policy.Require = "2"
+		tracker.Track(record, policy)
+
+		_, confirmed := tracker.Ack(record, "kafka")
+		Expect(confirmed).To(BeFalse())
+		_, confirmed = tracker.Ack(record, "pubsub")
+		Expect(confirmed).To(BeTrue())
+		Expect(tracker.Pending()).To(Equal(1))
+	})
+
+	It("counts the acks per connection, record type and txid", func() {
+		tracker.Track(record, &Policy{Dispatchers: []telemetry.Dispatcher{"kafka"}})
+
+		_, confirmed := tracker.Ack(&telemetry.Record{SocketID: "socket-2", TxType: "V", Txid: "1"}, "kafka")
+		Expect(confirmed).To(BeFalse())
+		_, confirmed = tracker.Ack(&telemetry.Record{SocketID: "socket-1", TxType: "alerts", Txid: "1"}, "kafka")
+		Expect(confirmed).To(BeFalse())
+		_, confirmed = tracker.Ack(record, "kafka")
+		Expect(confirmed).To(BeTrue())
+	})
+
+	It("expires the records not confirmed in time", func() {
+		confirmedRecord := &telemetry.Record{SocketID: "socket-1", TxType: "V", Txid: "2"}
+		policy.Require = RequireAny
+		tracker.Track(record, policy)
+		tracker.Track(confirmedRecord, policy)
+		tracker.Ack(confirmedRecord, "kafka")
+
+		clock = clock.Add(9 * time.Second)
+		Expect(tracker.Expire()).To(BeEmpty())
+
+		clock = clock.Add(time.Second)
+		Expect(tracker.Expire()).To(ConsistOf(record))
+		Expect(tracker.Pending()).To(Equal(0))
+
+		tracked, _ := tracker.Ack(record, "kafka")
+		Expect(tracked).To(BeFalse())
+	})
+})
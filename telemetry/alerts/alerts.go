@@ -0,0 +1,241 @@
+package alerts
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// RecordType is the record type of the alert events, dispatched with the rules of the "alert_lifecycle" records
+	RecordType = "alert_lifecycle"
+
+	// EventOpened is the event of an alert which started
+	EventOpened = "alert_opened"
+	// EventClosed is the event of an alert which ended
+	EventClosed = "alert_closed"
+)
+
+// Config of the alert lifecycle tracking
+type Config struct {
+	// Audiences restricts the tracking to the alerts of these audiences, ex.: ["Customer"]. Default: every alert
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// OpenAlert is an alert which started and has not ended yet
+type OpenAlert struct {
+	Name      string    `json:"name"`
+	Audiences []string  `json:"audiences"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	openCount  adapter.Gauge
+	eventCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Tracker keeps the open alerts of every vehicle across the batches of alerts they send, and across reconnects. They
+// are kept in memory only: after a restart, the alerts still open are opened again when the vehicle sends them, and
+// the ones which ended meanwhile are opened and closed at once.
+type Tracker struct {
+	audiences map[string]struct{}
+	logger    *logrus.Logger
+
+	mutex    sync.RWMutex
+	vehicles map[string]*vehicle
+}
+
+// vehicle is the open alerts of a vehicle, and the start of the last occurrence of each alert which ended, so an
+// alert sent again is not reported twice
+type vehicle struct {
+	open   map[string]*OpenAlert
+	closed map[string]time.Time
+}
+
+// NewTracker returns a tracker of the alerts of the vehicles
+func NewTracker(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) *Tracker {
+	registerMetricsOnce(metricsCollector)
+
+	t := &Tracker{logger: logger, vehicles: make(map[string]*vehicle)}
+	if len(config.Audiences) > 0 {
+		t.audiences = make(map[string]struct{}, len(config.Audiences))
+		for _, audience := range config.Audiences {
+			t.audiences[audience] = struct{}{}
+		}
+	}
+	return t
+}
+
+// Derive returns a record of the alerts opened and closed by a record of alerts, nil when there is none. It carries a
+// VehicleAlertLifecycle message with one event per alert opened or closed.
+func (t *Tracker) Derive(entry *telemetry.Record) *telemetry.Record {
+	message, ok := entry.GetProtoMessage().(*protos.VehicleAlerts)
+	if !ok {
+		return nil
+	}
+	events := t.apply(entry.Vin, message.GetAlerts())
+	if len(events) == 0 {
+		return nil
+	}
+	record, err := entry.WithProtoMessage(&protos.VehicleAlertLifecycle{
+		Events:    events,
+		CreatedAt: message.GetCreatedAt(),
+		Vin:       entry.Vin,
+	})
+	if err != nil {
+		t.logger.ErrorLog("alert_lifecycle_encode_error", err, logrus.LogInfo{"vin": entry.Vin, "txid": entry.Txid})
+		return nil
+	}
+	record.TxType = RecordType
+	return record
+}
+
+// Open returns the open alerts of a vehicle, oldest first
+func (t *Tracker) Open(vin string) []*OpenAlert {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	open := make([]*OpenAlert, 0)
+	if state, ok := t.vehicles[vin]; ok {
+		for _, alert := range state.open {
+			open = append(open, alert)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].StartedAt.Before(open[j].StartedAt) })
+	return open
+}
+
+// apply updates the open alerts of the vehicle with a batch of alerts and returns the events. An alert is identified
+// by its name and start time: an alert starting again replaces the open one, which is closed when the new one starts,
+// and alerts older than the ones already received are ignored. Alerts without a start time cannot be tracked.
+func (t *Tracker) apply(vin string, batch []*protos.VehicleAlert) []*protos.AlertLifecycleEvent {
+	alerts := make([]*protos.VehicleAlert, 0, len(batch))
+	for _, alert := range batch {
+		if alert.GetStartedAt() != nil && t.tracks(alert) {
+			alerts = append(alerts, alert)
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].GetStartedAt().AsTime().Before(alerts[j].GetStartedAt().AsTime())
+	})
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	state, ok := t.vehicles[vin]
+	if !ok {
+		state = &vehicle{open: make(map[string]*OpenAlert), closed: make(map[string]time.Time)}
+		t.vehicles[vin] = state
+	}
+
+	var events []*protos.AlertLifecycleEvent
+	for _, alert := range alerts {
+		name, startedAt := alert.GetName(), alert.GetStartedAt().AsTime()
+		if closedStart, ok := state.closed[name]; ok && !startedAt.After(closedStart) {
+			continue
+		}
+		open, isOpen := state.open[name]
+		if isOpen && startedAt.Before(open.StartedAt) {
+			continue
+		}
+		if isOpen && startedAt.After(open.StartedAt) {
+			events = append(events, state.close(open, startedAt))
+			isOpen = false
+		}
+		if !isOpen {
+			open = &OpenAlert{Name: name, Audiences: audiencesOf(alert), StartedAt: startedAt}
+			events = append(events, state.openAlert(open))
+		}
+		if alert.GetEndedAt() != nil {
+			events = append(events, state.close(open, alert.GetEndedAt().AsTime()))
+		}
+	}
+	return events
+}
+
+// tracks returns whether the alert is for one of the audiences tracked
+func (t *Tracker) tracks(alert *protos.VehicleAlert) bool {
+	if t.audiences == nil {
+		return true
+	}
+	for _, audience := range alert.GetAudiences() {
+		if _, ok := t.audiences[audience.String()]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *vehicle) openAlert(alert *OpenAlert) *protos.AlertLifecycleEvent {
+	v.open[alert.Name] = alert
+	metricsRegistry.openCount.Inc(map[string]string{"alert": alert.Name})
+	metricsRegistry.eventCount.Inc(map[string]string{"event": EventOpened})
+	return event(protos.AlertEvent_OPENED, alert, nil)
+}
+
+func (v *vehicle) close(alert *OpenAlert, endedAt time.Time) *protos.AlertLifecycleEvent {
+	delete(v.open, alert.Name)
+	v.closed[alert.Name] = alert.StartedAt
+	metricsRegistry.openCount.Sub(1, map[string]string{"alert": alert.Name})
+	metricsRegistry.eventCount.Inc(map[string]string{"event": EventClosed})
+	return event(protos.AlertEvent_CLOSED, alert, &endedAt)
+}
+
+// event returns an event of an alert, with its duration once closed
+func event(kind protos.AlertEvent, alert *OpenAlert, endedAt *time.Time) *protos.AlertLifecycleEvent {
+	audiences := make([]protos.AlertAudience, 0, len(alert.Audiences))
+	for _, audience := range alert.Audiences {
+		audiences = append(audiences, protos.AlertAudience(protos.AlertAudience_value[audience]))
+	}
+	event := &protos.AlertLifecycleEvent{
+		Name:      alert.Name,
+		Event:     kind,
+		Audiences: audiences,
+		StartedAt: timestamppb.New(alert.StartedAt),
+	}
+	if endedAt != nil {
+		event.EndedAt = timestamppb.New(*endedAt)
+		event.DurationSeconds = endedAt.Sub(alert.StartedAt).Seconds()
+	}
+	return event
+}
+
+func audiencesOf(alert *protos.VehicleAlert) []string {
+	audiences := make([]string, 0, len(alert.GetAudiences()))
+	for _, audience := range alert.GetAudiences() {
+		audiences = append(audiences, audience.String())
+	}
+	return audiences
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.openCount = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "alerts_open",
+		Help:   "The number of vehicles with an open alert, by alert.",
+		Labels: []string{"alert"},
+	})
+
+	metricsRegistry.eventCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "alert_events_total",
+		Help:   "The number of alerts opened and closed.",
+		Labels: []string{"event"},
+	})
+}
@@ -0,0 +1,142 @@
+package alerts_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/alerts"
+)
+
+var _ = Describe("Alert tracker", func() {
+	var (
+		logger *logrus.Logger
+		start  time.Time
+	)
+
+	newAlert := func(name string, startedAt time.Duration, endedAt *time.Duration, audiences ...protos.Audience) *protos.VehicleAlert {
+		alert := &protos.VehicleAlert{Name: name, Audiences: audiences, StartedAt: timestamppb.New(start.Add(startedAt))}
+		if endedAt != nil {
+			alert.EndedAt = timestamppb.New(start.Add(*endedAt))
+		}
+		return alert
+	}
+
+	after := func(d time.Duration) *time.Duration {
+		return &d
+	}
+
+	newRecord := func(vin string, batch ...*protos.VehicleAlert) *telemetry.Record {
+		payload, err := proto.Marshal(&protos.VehicleAlerts{Alerts: batch})
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte("alerts"), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	events := func(record *telemetry.Record) []*protos.AlertLifecycleEvent {
+		if record == nil {
+			return nil
+		}
+		Expect(record.TxType).To(Equal(alerts.RecordType))
+		message := &protos.VehicleAlertLifecycle{}
+		Expect(proto.Unmarshal(record.Payload(), message)).To(Succeed())
+		Expect(message.GetVin()).To(Equal(record.Vin))
+		return message.GetEvents()
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		start = time.Unix(1700000000, 0).UTC()
+	})
+
+	It("emits an event when an alert opens and closes", func() {
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+
+		opened := events(tracker.Derive(newRecord("42", newAlert("ChargePortFault", 0, nil, protos.Audience_Customer, protos.Audience_Service))))
+		Expect(opened).To(HaveLen(1))
+		Expect(opened[0].GetName()).To(Equal("ChargePortFault"))
+		Expect(opened[0].GetEvent()).To(Equal(protos.AlertEvent_OPENED))
+		Expect(opened[0].GetAudiences()).To(Equal([]protos.AlertAudience{protos.AlertAudience_Customer, protos.AlertAudience_Service}))
+		Expect(opened[0].GetStartedAt().AsTime()).To(Equal(start))
+		Expect(opened[0].GetEndedAt()).To(BeNil())
+		Expect(tracker.Open("42")).To(Equal([]*alerts.OpenAlert{{Name: "ChargePortFault", Audiences: []string{"Customer", "Service"}, StartedAt: start}}))
+
+		closed := events(tracker.Derive(newRecord("42", newAlert("ChargePortFault", 0, after(90*time.Second), protos.Audience_Customer))))
+		Expect(closed).To(HaveLen(1))
+		Expect(closed[0].GetEvent()).To(Equal(protos.AlertEvent_CLOSED))
+		Expect(closed[0].GetDurationSeconds()).To(Equal(90.0))
+		Expect(closed[0].GetEndedAt().AsTime()).To(Equal(start.Add(90 * time.Second)))
+		Expect(closed[0].GetAudiences()).To(Equal([]protos.AlertAudience{protos.AlertAudience_Customer, protos.AlertAudience_Service}))
+		Expect(tracker.Open("42")).To(BeEmpty())
+	})
+
+	It("does not report an alert sent again", func() {
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(events(tracker.Derive(newRecord("42", newAlert("A", 0, nil))))).To(HaveLen(1))
+		Expect(tracker.Derive(newRecord("42", newAlert("A", 0, nil)))).To(BeNil())
+
+		Expect(events(tracker.Derive(newRecord("42", newAlert("A", 0, after(time.Minute)))))).To(HaveLen(1))
+		Expect(tracker.Derive(newRecord("42", newAlert("A", 0, after(time.Minute))))).To(BeNil())
+		Expect(tracker.Derive(newRecord("42", newAlert("A", 0, nil)))).To(BeNil())
+	})
+
+	It("opens and closes an alert ended within a batch", func() {
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+		batch := events(tracker.Derive(newRecord("42", newAlert("B", time.Minute, after(2*time.Minute)), newAlert("A", 0, nil))))
+		Expect(batch).To(HaveLen(3))
+		Expect(batch[0].GetEvent()).To(Equal(protos.AlertEvent_OPENED))
+		Expect(batch[0].GetName()).To(Equal("A"))
+		Expect(batch[1].GetEvent()).To(Equal(protos.AlertEvent_OPENED))
+		Expect(batch[1].GetName()).To(Equal("B"))
+		Expect(batch[2].GetEvent()).To(Equal(protos.AlertEvent_CLOSED))
+		Expect(batch[2].GetDurationSeconds()).To(Equal(60.0))
+		Expect(tracker.Open("42")).To(HaveLen(1))
+	})
+
+	It("closes an open alert when it starts again", func() {
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(events(tracker.Derive(newRecord("42", newAlert("A", 0, nil))))).To(HaveLen(1))
+
+		restarted := events(tracker.Derive(newRecord("42", newAlert("A", time.Hour, nil))))
+		Expect(restarted).To(HaveLen(2))
+		Expect(restarted[0].GetEvent()).To(Equal(protos.AlertEvent_CLOSED))
+		Expect(restarted[0].GetDurationSeconds()).To(Equal(3600.0))
+		Expect(restarted[1].GetEvent()).To(Equal(protos.AlertEvent_OPENED))
+		Expect(tracker.Open("42")[0].StartedAt).To(Equal(start.Add(time.Hour)))
+
+		Expect(tracker.Derive(newRecord("42", newAlert("A", 0, after(time.Minute))))).To(BeNil())
+	})
+
+	It("tracks the alerts of the configured audiences", func() {
+		tracker := alerts.NewTracker(&alerts.Config{Audiences: []string{"Customer"}}, metrics.NewCollector(nil, logger), logger)
+		Expect(tracker.Derive(newRecord("42", newAlert("A", 0, nil, protos.Audience_Service)))).To(BeNil())
+		Expect(events(tracker.Derive(newRecord("42", newAlert("B", 0, nil, protos.Audience_Service, protos.Audience_Customer))))).To(HaveLen(1))
+	})
+
+	It("tracks each vehicle separately", func() {
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(events(tracker.Derive(newRecord("42", newAlert("A", 0, nil))))).To(HaveLen(1))
+		Expect(events(tracker.Derive(newRecord("43", newAlert("A", 0, nil))))).To(HaveLen(1))
+		Expect(tracker.Open("44")).To(BeEmpty())
+	})
+
+	It("ignores other records and alerts without start", func() {
+		tracker := alerts.NewTracker(&alerts.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(tracker.Derive(&telemetry.Record{TxType: "V"})).To(BeNil())
+		Expect(tracker.Derive(newRecord("42", &protos.VehicleAlert{Name: "A"}))).To(BeNil())
+	})
+})
@@ -0,0 +1,223 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxFileBytes is the size at which a new capture file is started
+	DefaultMaxFileBytes = 64 << 20
+
+	filePrefix      = "capture-"
+	fileExtension   = ".cap"
+	frameHeaderSize = 8
+	maxFrameSize    = 64 << 20
+)
+
+// ErrCorruptFrame is returned when a capture file holds a frame that fails its checksum
+var ErrCorruptFrame = errors.New("corrupt capture frame")
+
+// Config for the capture of the raw messages received from vehicles
+type Config struct {
+	// Dir is the directory the capture files are written to
+	Dir string `json:"dir"`
+
+	// MaxFileBytes is the size at which a new capture file is started. Default: 64MB
+	MaxFileBytes int64 `json:"max_file_bytes,omitempty"`
+
+	// MaxFiles is the number of capture files kept, the oldest are deleted past it. Default: unlimited
+	MaxFiles int `json:"max_files,omitempty"`
+
+	// Vins restricts the capture to these vehicles. Default: every vehicle
+	Vins []string `json:"vins,omitempty"`
+}
+
+// Frame is a captured message
+type Frame struct {
+	ReceivedAt time.Time
+	Vin        string
+	RawBytes   []byte
+}
+
+// Writer appends captured messages to rotating files. It is safe for concurrent use.
+type Writer struct {
+	dir          string
+	maxFileBytes int64
+	maxFiles     int
+	vins         map[string]struct{}
+
+	mutex    sync.Mutex
+	file     *os.File
+	fileSize int64
+	lastName int64
+}
+
+// NewWriter creates the capture directory and opens a new capture file in it
+func NewWriter(config *Config) (*Writer, error) {
+	if config.Dir == "" {
+		return nil, errors.New("capture dir is required")
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		dir:          config.Dir,
+		maxFileBytes: config.MaxFileBytes,
+		maxFiles:     config.MaxFiles,
+	}
+	if w.maxFileBytes <= 0 {
+		w.maxFileBytes = DefaultMaxFileBytes
+	}
+	if len(config.Vins) > 0 {
+		w.vins = make(map[string]struct{}, len(config.Vins))
+		for _, vin := range config.Vins {
+			w.vins[vin] = struct{}{}
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.rotate(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Captures returns true if messages of the vin are captured
+func (w *Writer) Captures(vin string) bool {
+	if w.vins == nil {
+		return true
+	}
+	_, ok := w.vins[vin]
+	return ok
+}
+
+// Write appends a message received from a vehicle. Messages of vins not captured are ignored.
+func (w *Writer) Write(vin string, receivedAt time.Time, rawBytes []byte) error {
+	if len(rawBytes) == 0 || !w.Captures(vin) {
+		return nil
+	}
+	frame := encodeFrame(vin, receivedAt, rawBytes)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if w.fileSize > 0 && w.fileSize+int64(len(frame)) > w.maxFileBytes {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(frame)
+	w.fileSize += int64(n)
+	return err
+}
+
+// Close closes the capture file being written
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotate closes the file being written, starts a new one and deletes the oldest files past the retention
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	// file names sort in creation order, even when two files are created within the same nanosecond
+	name := max(time.Now().UnixNano(), w.lastName+1)
+	file, err := os.OpenFile(filepath.Join(w.dir, fmt.Sprintf("%s%020d%s", filePrefix, name, fileExtension)), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.fileSize = 0
+	w.lastName = name
+
+	if w.maxFiles <= 0 {
+		return nil
+	}
+	files, err := ListFiles(w.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > w.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// ListFiles returns the capture files of a directory, oldest first
+func ListFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileExtension) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// encodeFrame builds a frame: a 4 byte big endian length, a 4 byte crc32 of the payload and the payload itself.
+// The payload is the 8 byte receive time in unix nanoseconds, the 2 byte length of the vin, the vin and the message.
+func encodeFrame(vin string, receivedAt time.Time, rawBytes []byte) []byte {
+	payloadSize := 8 + 2 + len(vin) + len(rawBytes)
+	frame := make([]byte, frameHeaderSize+payloadSize)
+	payload := frame[frameHeaderSize:]
+	binary.BigEndian.PutUint64(payload[0:8], uint64(receivedAt.UnixNano()))
+	binary.BigEndian.PutUint16(payload[8:10], uint16(len(vin)))
+	copy(payload[10:], vin)
+	copy(payload[10+len(vin):], rawBytes)
+
+	binary.BigEndian.PutUint32(frame[0:4], uint32(payloadSize))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	return frame
+}
+
+func decodeFrame(payload []byte) (*Frame, error) {
+	if len(payload) < 10 {
+		return nil, ErrCorruptFrame
+	}
+	vinLength := int(binary.BigEndian.Uint16(payload[8:10]))
+	if len(payload) < 10+vinLength {
+		return nil, ErrCorruptFrame
+	}
+	return &Frame{
+		ReceivedAt: time.Unix(0, int64(binary.BigEndian.Uint64(payload[0:8]))),
+		Vin:        string(payload[10 : 10+vinLength]),
+		RawBytes:   payload[10+vinLength:],
+	}, nil
+}
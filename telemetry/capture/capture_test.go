@@ -0,0 +1,104 @@
+package capture_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+)
+
+var _ = Describe("Capture", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	readAll := func() []*capture.Frame {
+		files, err := capture.ListFiles(dir)
+		Expect(err).NotTo(HaveOccurred())
+		var frames []*capture.Frame
+		Expect(capture.ReadFiles(files, func(frame *capture.Frame) error {
+			frames = append(frames, frame)
+			return nil
+		})).To(Succeed())
+		return frames
+	}
+
+	It("reads back the captured messages in order", func() {
+		writer, err := capture.NewWriter(&capture.Config{Dir: dir})
+		Expect(err).NotTo(HaveOccurred())
+
+		receivedAt := time.Unix(1700000000, 123456789)
+		for i := 0; i < 3; i++ {
+			Expect(writer.Write("DEVICE-1", receivedAt.Add(time.Duration(i)*time.Second), []byte(fmt.Sprintf("message-%d", i)))).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		frames := readAll()
+		Expect(frames).To(HaveLen(3))
+		for i, frame := range frames {
+			Expect(frame.Vin).To(Equal("DEVICE-1"))
+			Expect(frame.ReceivedAt.Equal(receivedAt.Add(time.Duration(i) * time.Second))).To(BeTrue())
+			Expect(string(frame.RawBytes)).To(Equal(fmt.Sprintf("message-%d", i)))
+		}
+	})
+
+	It("rotates files and keeps the newest ones", func() {
+		writer, err := capture.NewWriter(&capture.Config{Dir: dir, MaxFileBytes: 64, MaxFiles: 2})
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 10; i++ {
+			Expect(writer.Write("DEVICE-1", time.Now(), []byte(fmt.Sprintf("message-%d-with-some-padding", i)))).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		files, err := capture.ListFiles(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(2))
+
+		frames := readAll()
+		Expect(frames).NotTo(BeEmpty())
+		Expect(string(frames[len(frames)-1].RawBytes)).To(Equal("message-9-with-some-padding"))
+	})
+
+	It("only captures the configured vins", func() {
+		writer, err := capture.NewWriter(&capture.Config{Dir: dir, Vins: []string{"DEVICE-2"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Write("DEVICE-1", time.Now(), []byte("ignored"))).To(Succeed())
+		Expect(writer.Write("DEVICE-2", time.Now(), []byte("captured"))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		frames := readAll()
+		Expect(frames).To(HaveLen(1))
+		Expect(frames[0].Vin).To(Equal("DEVICE-2"))
+	})
+
+	It("reports a truncated frame", func() {
+		writer, err := capture.NewWriter(&capture.Config{Dir: dir})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Write("DEVICE-1", time.Now(), []byte("message"))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		files, err := capture.ListFiles(dir)
+		Expect(err).NotTo(HaveOccurred())
+		info, err := os.Stat(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Truncate(files[0], info.Size()-2)).To(Succeed())
+
+		reader, err := capture.OpenReader(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		_, err = reader.Next()
+		Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+	})
+
+	It("requires a dir", func() {
+		_, err := capture.NewWriter(&capture.Config{})
+		Expect(err).To(MatchError("capture dir is required"))
+	})
+})
@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Reader reads the frames of a capture file in order
+type Reader struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// OpenReader opens a capture file
+func OpenReader(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{file: file, reader: bufio.NewReader(file)}, nil
+}
+
+// Next returns the next frame of the file, io.EOF once every frame was read.
+// A frame truncated by the end of the file, ex.: the server stopped while writing it, returns io.ErrUnexpectedEOF.
+func (r *Reader) Next() (*Frame, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > maxFrameSize {
+		return nil, ErrCorruptFrame
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, ErrCorruptFrame
+	}
+	return decodeFrame(payload)
+}
+
+// Close closes the capture file
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// ReadFiles calls fn with every frame of the files, in order. It stops at the first error.
+func ReadFiles(paths []string, fn func(*Frame) error) error {
+	for _, path := range paths {
+		if err := readFile(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(path string, fn func(*Frame) error) error {
+	reader, err := OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		frame, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+}
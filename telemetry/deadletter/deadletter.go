@@ -0,0 +1,208 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// RecordType is the record type of the dead letters, dispatched with the rules of the "deadletter" records
+	RecordType = "deadletter"
+
+	// ReasonMessageTooBig is the reason of the messages above telemetry.SizeLimit
+	ReasonMessageTooBig = "message_too_big"
+	// ReasonUnknownMessageType is the reason of the messages which are not stream messages
+	ReasonUnknownMessageType = "unknown_message_type"
+	// ReasonInvalidMessage is the reason of the messages which failed to deserialize or decode
+	ReasonInvalidMessage = "invalid_message"
+)
+
+// reasons are the proto values of the reasons of the dead letters
+var reasons = map[string]protos.DeadLetterReason{
+	ReasonMessageTooBig:      protos.DeadLetterReason_MESSAGE_TOO_BIG,
+	ReasonUnknownMessageType: protos.DeadLetterReason_UNKNOWN_MESSAGE_TYPE,
+	ReasonInvalidMessage:     protos.DeadLetterReason_INVALID_MESSAGE,
+}
+
+// Config of the dead letters
+type Config struct {
+	// File the dead letters are appended to as JSON lines. Default: dead letters are only dispatched
+	File string `json:"file,omitempty"`
+}
+
+// Letter is a message of a vehicle which could not be dispatched, and why
+type Letter struct {
+	ReceivedAt    time.Time `json:"received_at"`
+	Reason        string    `json:"reason"`
+	Error         string    `json:"error"`
+	SocketID      string    `json:"socket_id"`
+	Vin           string    `json:"vin"`
+	ClientVersion string    `json:"client_version,omitempty"`
+	Txid          string    `json:"txid,omitempty"`
+	RecordType    string    `json:"record_type,omitempty"`
+	RawBytes      []byte    `json:"raw_bytes"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	letterCount      adapter.Counter
+	fileErrorCount   adapter.Counter
+	encodeErrorCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Handler writes the dead letters to a file and dispatches them to the producers of the "deadletter" records.
+// It is safe for concurrent use.
+type Handler struct {
+	logger *logrus.Logger
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewHandler opens the dead letter file, if any
+func NewHandler(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Handler, error) {
+	registerMetricsOnce(metricsCollector)
+
+	h := &Handler{logger: logger}
+	if config.File == "" {
+		return h, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(config.File), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	h.file = file
+	return h, nil
+}
+
+// Reason returns the reason a message which failed to parse with err is a dead letter, false when it is not one
+// (ex.: a message of an unauthorized sender)
+func Reason(err error) (string, bool) {
+	var unauthorizedSenderID *telemetry.UnauthorizedSenderIDError
+	var unknownMessageType *telemetry.UnknownMessageType
+	switch {
+	case err == nil, errors.As(err, &unauthorizedSenderID):
+		return "", false
+	case errors.Is(err, telemetry.ErrMessageTooBig):
+		return ReasonMessageTooBig, true
+	case errors.As(err, &unknownMessageType):
+		return ReasonUnknownMessageType, true
+	default:
+		return ReasonInvalidMessage, true
+	}
+}
+
+// Handle writes the letter to the file and dispatches it as a "deadletter" record with the dispatch rules of the
+// serializer of the record which failed to parse. The record carries a VehicleDeadLetter message.
+func (h *Handler) Handle(record *telemetry.Record, letter *Letter) {
+	metricsRegistry.letterCount.Inc(map[string]string{"reason": letter.Reason})
+	if err := h.write(letter); err != nil {
+		h.logger.ErrorLog("dead_letter_write_error", err, logrus.LogInfo{"vin": letter.Vin, "txid": letter.Txid})
+		metricsRegistry.fileErrorCount.Inc(map[string]string{})
+	}
+
+	if record.Serializer == nil {
+		return
+	}
+	producers := record.Serializer.CurrentDispatchRules()[RecordType]
+	if len(producers) == 0 {
+		return
+	}
+	deadLetter, err := record.WithProtoMessage(&protos.VehicleDeadLetter{
+		Vin:           letter.Vin,
+		Reason:        reasons[letter.Reason],
+		Error:         letter.Error,
+		ReceivedAt:    timestamppb.New(letter.ReceivedAt),
+		SocketId:      letter.SocketID,
+		ClientVersion: letter.ClientVersion,
+		Txid:          letter.Txid,
+		RecordType:    letter.RecordType,
+		RawBytes:      letter.RawBytes,
+	})
+	if err != nil {
+		h.logger.ErrorLog("dead_letter_encode_error", err, logrus.LogInfo{"vin": letter.Vin, "txid": letter.Txid})
+		metricsRegistry.encodeErrorCount.Inc(map[string]string{})
+		return
+	}
+	deadLetter.TxType = RecordType
+	deadLetter.Vin = letter.Vin
+	deadLetter.SocketID = letter.SocketID
+	deadLetter.DeviceClientVersion = letter.ClientVersion
+	deadLetter.ReceivedTimestamp = letter.ReceivedAt.UnixMilli()
+	deadLetter.RawBytes = letter.RawBytes
+	for _, producer := range producers {
+		producer.Produce(deadLetter)
+	}
+}
+
+// Close closes the dead letter file
+func (h *Handler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+func (h *Handler) write(letter *Letter) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	_, err = h.file.Write(append(line, '\n'))
+	return err
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.letterCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "dead_letter_total",
+		Help:   "The number of messages of the vehicles which could not be dispatched, by reason.",
+		Labels: []string{"reason"},
+	})
+
+	metricsRegistry.fileErrorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "dead_letter_file_error_total",
+		Help:   "The number of dead letters which could not be written to the file.",
+		Labels: []string{},
+	})
+
+	metricsRegistry.encodeErrorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "dead_letter_encode_error_total",
+		Help:   "The number of dead letters which could not be encoded as records.",
+		Labels: []string{},
+	})
+}
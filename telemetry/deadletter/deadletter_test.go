@@ -0,0 +1,123 @@
+package deadletter_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/deadletter"
+)
+
+type producerTester struct {
+	produced []*telemetry.Record
+}
+
+func (p *producerTester) Close() error                                    { return nil }
+func (p *producerTester) Produce(entry *telemetry.Record)                 { p.produced = append(p.produced, entry) }
+func (p *producerTester) ProcessReliableAck(_ *telemetry.Record)          {}
+func (p *producerTester) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
+var _ = Describe("Dead letters", func() {
+	var (
+		logger *logrus.Logger
+		file   string
+	)
+
+	newLetter := func(txid string) *deadletter.Letter {
+		return &deadletter.Letter{
+			ReceivedAt:    time.Unix(1700000000, 0).UTC(),
+			Reason:        deadletter.ReasonMessageTooBig,
+			Error:         telemetry.ErrMessageTooBig.Error(),
+			SocketID:      "socket-1",
+			Vin:           "42",
+			ClientVersion: "2024.1",
+			Txid:          txid,
+			RawBytes:      []byte{0x00, 0xff},
+		}
+	}
+
+	readLetters := func() []*deadletter.Letter {
+		data, err := os.Open(file)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = data.Close() }()
+		var letters []*deadletter.Letter
+		scanner := bufio.NewScanner(data)
+		for scanner.Scan() {
+			letter := &deadletter.Letter{}
+			Expect(json.Unmarshal(scanner.Bytes(), letter)).To(Succeed())
+			letters = append(letters, letter)
+		}
+		return letters
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		file = filepath.Join(GinkgoT().TempDir(), "dead", "letters.jsonl")
+	})
+
+	DescribeTable("classifies the errors",
+		func(err error, expectedReason string, expectedOk bool) {
+			reason, ok := deadletter.Reason(err)
+			Expect(ok).To(Equal(expectedOk))
+			Expect(reason).To(Equal(expectedReason))
+		},
+		Entry("no error", nil, "", false),
+		Entry("message too big", telemetry.ErrMessageTooBig, deadletter.ReasonMessageTooBig, true),
+		Entry("unknown message type", &telemetry.UnknownMessageType{Txid: "1"}, deadletter.ReasonUnknownMessageType, true),
+		Entry("unauthorized sender", &telemetry.UnauthorizedSenderIDError{}, "", false),
+		Entry("decode error", errors.New("proto: cannot parse invalid wire-format data"), deadletter.ReasonInvalidMessage, true),
+	)
+
+	It("appends the letters to the file", func() {
+		handler, err := deadletter.NewHandler(&deadletter.Config{File: file}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.Handle(&telemetry.Record{}, newLetter("1"))
+		handler.Handle(&telemetry.Record{}, newLetter("2"))
+		Expect(handler.Close()).To(Succeed())
+
+		letters := readLetters()
+		Expect(letters).To(HaveLen(2))
+		Expect(letters[0]).To(Equal(newLetter("1")))
+		Expect(letters[1].Txid).To(Equal("2"))
+	})
+
+	It("dispatches the letters to the producers of the dead letter records", func() {
+		handler, err := deadletter.NewHandler(&deadletter.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		producer := &producerTester{}
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{deadletter.RecordType: {producer}}, logger)
+
+		handler.Handle(&telemetry.Record{Serializer: serializer}, newLetter("1"))
+		Expect(producer.produced).To(HaveLen(1))
+
+		record := producer.produced[0]
+		Expect(record.TxType).To(Equal(deadletter.RecordType))
+		Expect(record.Vin).To(Equal("42"))
+		Expect(record.SocketID).To(Equal("socket-1"))
+		Expect(record.DeviceClientVersion).To(Equal("2024.1"))
+		Expect(record.RawBytes).To(Equal([]byte{0x00, 0xff}))
+
+		message, ok := record.GetProtoMessage().(*protos.VehicleDeadLetter)
+		Expect(ok).To(BeTrue())
+		Expect(message.GetVin()).To(Equal("42"))
+		Expect(message.GetReason()).To(Equal(protos.DeadLetterReason_MESSAGE_TOO_BIG))
+		Expect(message.GetError()).To(Equal(telemetry.ErrMessageTooBig.Error()))
+		Expect(message.GetReceivedAt().AsTime()).To(Equal(time.Unix(1700000000, 0).UTC()))
+		Expect(message.GetSocketId()).To(Equal("socket-1"))
+		Expect(message.GetClientVersion()).To(Equal("2024.1"))
+		Expect(message.GetTxid()).To(Equal("1"))
+		Expect(message.GetRawBytes()).To(Equal([]byte{0x00, 0xff}))
+		Expect(handler.Close()).To(Succeed())
+	})
+})
@@ -0,0 +1,149 @@
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Default values for the dedup configuration options
+const (
+	DefaultTTLSeconds = 600
+	DefaultMaxEntries = 100000
+)
+
+// Config of the duplicate record suppression
+type Config struct {
+	// TTLSeconds is how long the txid of a record is remembered. Default: 600
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+
+	// MaxEntries bounds the number of txids remembered, the oldest are forgotten first. Default: 100000
+	MaxEntries int `json:"max_entries,omitempty"`
+
+	// ObserveOnly dispatches duplicates anyway, tagged with duplicate=true in their metadata, to assess dedup
+	// before enabling it
+	ObserveOnly bool `json:"observe_only,omitempty"`
+}
+
+// key identifies a record sent by a vehicle
+type key struct {
+	vin    string
+	txType string
+	txid   string
+}
+
+type entry struct {
+	key       key
+	acked     bool
+	expiresAt time.Time
+}
+
+// Cache remembers the records received recently, across the connections of the vehicles. A record waiting for its
+// reliable ack is pending: its copies are not duplicates until one of them is acked, since the vehicle resends a
+// record whose delivery failed.
+type Cache struct {
+	ttl         time.Duration
+	maxEntries  int
+	observeOnly bool
+	now         func() time.Time
+
+	mutex   sync.Mutex
+	entries map[key]*list.Element
+	order   *list.List
+}
+
+// NewCache returns an empty cache
+func NewCache(config *Config) *Cache {
+	cache := &Cache{
+		ttl:         time.Duration(config.TTLSeconds) * time.Second,
+		maxEntries:  config.MaxEntries,
+		observeOnly: config.ObserveOnly,
+		now:         time.Now,
+		entries:     make(map[key]*list.Element),
+		order:       list.New(),
+	}
+	if cache.ttl <= 0 {
+		cache.ttl = DefaultTTLSeconds * time.Second
+	}
+	if cache.maxEntries <= 0 {
+		cache.maxEntries = DefaultMaxEntries
+	}
+	return cache
+}
+
+// ObserveOnly returns whether duplicates are dispatched anyway
+func (c *Cache) ObserveOnly() bool {
+	return c.observeOnly
+}
+
+// Seen returns whether a record was already acked within the TTL. Otherwise the record is remembered as acked, or as
+// pending when awaitAck is set, until Ack is called once its reliable ack is sent.
+func (c *Cache) Seen(vin, txType, txid string, awaitAck bool) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	c.evictExpired(now)
+
+	k := key{vin: vin, txType: txType, txid: txid}
+	if element, ok := c.entries[k]; ok {
+		return element.Value.(*entry).acked
+	}
+	c.add(k, !awaitAck, now)
+	return false
+}
+
+// Ack remembers a record as acked to the vehicle, its next copies are duplicates
+func (c *Cache) Ack(vin, txType, txid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	c.evictExpired(now)
+
+	k := key{vin: vin, txType: txType, txid: txid}
+	if element, ok := c.entries[k]; ok {
+		c.remove(element)
+	}
+	c.add(k, true, now)
+}
+
+// Forget forgets a record, ex.: once the vehicle got an error for it
+func (c *Cache) Forget(vin, txType, txid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key{vin: vin, txType: txType, txid: txid}]; ok {
+		c.remove(element)
+	}
+}
+
+// add remembers a record for the TTL, forgetting the oldest record past the max entries
+func (c *Cache) add(k key, acked bool, now time.Time) {
+	c.entries[k] = c.order.PushBack(&entry{key: k, acked: acked, expiresAt: now.Add(c.ttl)})
+	if c.order.Len() > c.maxEntries {
+		c.remove(c.order.Front())
+	}
+}
+
+// Len returns the number of records remembered
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// evictExpired forgets the expired records, which are the oldest since they all share the same TTL
+func (c *Cache) evictExpired(now time.Time) {
+	for element := c.order.Front(); element != nil; element = c.order.Front() {
+		if element.Value.(*entry).expiresAt.After(now) {
+			return
+		}
+		c.remove(element)
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
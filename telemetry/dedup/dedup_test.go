@@ -0,0 +1,88 @@
+package dedup
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dedup cache", func() {
+	var (
+		cache *Cache
+		clock time.Time
+	)
+
+	newCache := func(config *Config) *Cache {
+		cache := NewCache(config)
+		cache.now = func() time.Time { return clock }
+		return cache
+	}
+
+	BeforeEach(func() {
+		clock = time.Now()
+		cache = newCache(&Config{TTLSeconds: 60, MaxEntries: 3})
+	})
+
+	It("detects the records already received", func() {
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeTrue())
+	})
+
+	It("keys records by vin, record type and txid", func() {
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+		Expect(cache.Seen("vin-2", "V", "1", false)).To(BeFalse())
+		Expect(cache.Seen("vin-1", "alerts", "1", false)).To(BeFalse())
+		Expect(cache.Seen("vin-1", "V", "2", false)).To(BeFalse())
+	})
+
+	It("forgets records after the TTL", func() {
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+		clock = clock.Add(30 * time.Second)
+		Expect(cache.Seen("vin-1", "V", "2", false)).To(BeFalse())
+
+		clock = clock.Add(30 * time.Second)
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+		Expect(cache.Seen("vin-1", "V", "2", false)).To(BeTrue())
+	})
+
+	It("forgets the oldest records past the max entries", func() {
+		for _, txid := range []string{"1", "2", "3", "4"} {
+			Expect(cache.Seen("vin-1", "V", txid, false)).To(BeFalse())
+		}
+		Expect(cache.Len()).To(Equal(3))
+		Expect(cache.Seen("vin-1", "V", "4", false)).To(BeTrue())
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+	})
+
+	It("does not suppress the copies of a record waiting for its reliable ack", func() {
+		Expect(cache.Seen("vin-1", "V", "1", true)).To(BeFalse())
+		Expect(cache.Seen("vin-1", "V", "1", true)).To(BeFalse())
+
+		cache.Ack("vin-1", "V", "1")
+		Expect(cache.Seen("vin-1", "V", "1", true)).To(BeTrue())
+	})
+
+	It("remembers acked records for the TTL from their ack", func() {
+		Expect(cache.Seen("vin-1", "V", "1", true)).To(BeFalse())
+		clock = clock.Add(50 * time.Second)
+		cache.Ack("vin-1", "V", "1")
+
+		clock = clock.Add(50 * time.Second)
+		Expect(cache.Seen("vin-1", "V", "1", true)).To(BeTrue())
+	})
+
+	It("forgets records", func() {
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+		cache.Forget("vin-1", "V", "1")
+		Expect(cache.Len()).To(Equal(0))
+		Expect(cache.Seen("vin-1", "V", "1", false)).To(BeFalse())
+	})
+
+	It("applies the defaults", func() {
+		cache := NewCache(&Config{ObserveOnly: true})
+		Expect(cache.ttl).To(Equal(DefaultTTLSeconds * time.Second))
+		Expect(cache.maxEntries).To(Equal(DefaultMaxEntries))
+		Expect(cache.ObserveOnly()).To(BeTrue())
+	})
+})
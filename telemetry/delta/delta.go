@@ -0,0 +1,379 @@
+package delta
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// DefaultVehicleTTLMinutes is how long the state of a vehicle without records is kept by default
+const DefaultVehicleTTLMinutes = 60
+
+// Config of the change-only emission of a dispatcher
+type Config struct {
+	// AlwaysSend lists the protos.Field names (ex.: "Gear") sent even when their value did not change
+	AlwaysSend []string `json:"always_send,omitempty"`
+
+	// KeyframeIntervalMinutes sends the full known state of a vehicle with its first payload after this interval,
+	// so subscribers which missed a change catch up. Default: no keyframe
+	KeyframeIntervalMinutes int `json:"keyframe_interval_minutes,omitempty"`
+
+	// VehicleTTLMinutes is how long the state of a vehicle without records is kept, its next record is then sent in
+	// full. Default: 60
+	VehicleTTLMinutes int `json:"vehicle_ttl_minutes,omitempty"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	strippedFieldsCount    adapter.Counter
+	suppressedRecordsCount adapter.Counter
+	keyframeCount          adapter.Counter
+	vehicleCount           adapter.Gauge
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Producer strips the fields of V records whose value did not change since the last record of the vehicle,
+// before handing them to the wrapped producer. The values of a record are only remembered once the wrapped producer
+// delivered it, so the values of a failed delivery are sent again with the next record of the vehicle.
+type Producer struct {
+	dispatcher       telemetry.Dispatcher
+	inner            telemetry.Producer
+	alwaysSend       map[protos.Field]struct{}
+	keyframeInterval time.Duration
+	vehicleTTL       time.Duration
+	logger           *logrus.Logger
+	now              func() time.Time
+
+	vehicles sync.Map
+
+	// pending are the changes of the records sent to a wrapped producer reporting deliveries
+	reportsDelivery bool
+	pendingMutex    sync.Mutex
+	pending         map[recordKey]*change
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// vehicle is the last value of each field delivered for a vehicle
+type vehicle struct {
+	mutex      sync.Mutex
+	fields     map[protos.Field]*fieldValue
+	keyframeAt time.Time
+	seenAt     time.Time
+}
+
+type fieldValue struct {
+	value     *protos.Value
+	timestamp time.Time
+}
+
+// change is the values a record sends, remembered once it is delivered
+type change struct {
+	vin      string
+	fields   map[protos.Field]*fieldValue
+	keyframe bool
+	at       time.Time
+}
+
+// recordKey identifies a record across copies, the wrapped producer may notify the delivery of a copy
+type recordKey struct {
+	socketID string
+	txType   string
+	txid     string
+}
+
+func keyOf(entry *telemetry.Record) recordKey {
+	return recordKey{socketID: entry.SocketID, txType: entry.TxType, txid: entry.Txid}
+}
+
+// NewProducer validates the config and wraps the producer of a dispatcher with change-only emission
+func NewProducer(dispatcher telemetry.Dispatcher, inner telemetry.Producer, config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Producer, error) {
+	registerMetricsOnce(metricsCollector)
+
+	alwaysSend := make(map[protos.Field]struct{}, len(config.AlwaysSend))
+	for _, name := range config.AlwaysSend {
+		value, ok := protos.Field_value[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field in delta always_send of %s: %s", dispatcher, name)
+		}
+		alwaysSend[protos.Field(value)] = struct{}{}
+	}
+	if config.KeyframeIntervalMinutes < 0 {
+		return nil, fmt.Errorf("delta keyframe_interval_minutes of %s must not be negative", dispatcher)
+	}
+	if config.VehicleTTLMinutes < 0 {
+		return nil, fmt.Errorf("delta vehicle_ttl_minutes of %s must not be negative", dispatcher)
+	}
+	vehicleTTLMinutes := config.VehicleTTLMinutes
+	if vehicleTTLMinutes == 0 {
+		vehicleTTLMinutes = DefaultVehicleTTLMinutes
+	}
+
+	producer := &Producer{
+		dispatcher:       dispatcher,
+		inner:            inner,
+		alwaysSend:       alwaysSend,
+		keyframeInterval: time.Duration(config.KeyframeIntervalMinutes) * time.Minute,
+		vehicleTTL:       time.Duration(vehicleTTLMinutes) * time.Minute,
+		logger:           logger,
+		now:              time.Now,
+		pending:          make(map[recordKey]*change),
+		done:             make(chan struct{}),
+	}
+	if reporter, ok := inner.(telemetry.DeliveryReporter); ok {
+		producer.reportsDelivery = true
+		reporter.SetDeliveryHandler(producer.handleDelivery)
+	}
+
+	producer.wg.Add(1)
+	go producer.runEviction()
+	logger.ActivityLog("delta_registered", logrus.LogInfo{"dispatcher": dispatcher})
+	return producer, nil
+}
+
+// Produce strips the unchanged fields of the record and sends it to the wrapped producer.
+// A record left without any field is not sent, but is still acknowledged since each of its values was delivered
+// by an earlier record. A record which cannot be encoded once stripped is sent whole.
+func (p *Producer) Produce(entry *telemetry.Record) {
+	payload, ok := entry.GetProtoMessage().(*protos.Payload)
+	if !ok {
+		p.inner.Produce(entry)
+		return
+	}
+
+	data, change := p.apply(entry.Vin, payload)
+	labels := map[string]string{"dispatcher": string(p.dispatcher)}
+	if change.keyframe {
+		metricsRegistry.keyframeCount.Inc(labels)
+	} else if stripped := len(payload.GetData()) - len(data); stripped > 0 {
+		metricsRegistry.strippedFieldsCount.Add(int64(stripped), labels)
+	} else {
+		p.send(entry, change)
+		return
+	}
+
+	if len(data) == 0 {
+		metricsRegistry.suppressedRecordsCount.Inc(labels)
+		p.inner.ProcessReliableAck(entry)
+		return
+	}
+
+	changed, err := entry.WithProtoMessage(&protos.Payload{
+		Data:      data,
+		CreatedAt: payload.GetCreatedAt(),
+		Vin:       payload.GetVin(),
+		IsResend:  payload.GetIsResend(),
+	})
+	if err != nil {
+		p.ReportError("delta_encode_error", err, logrus.LogInfo{"dispatcher": p.dispatcher, "record_type": entry.TxType, "txid": entry.Txid})
+		p.send(entry, change)
+		return
+	}
+	p.send(changed, change)
+}
+
+// send produces the record, its change is remembered once delivered, or right away when the wrapped producer does
+// not report deliveries
+func (p *Producer) send(entry *telemetry.Record, c *change) {
+	if !p.reportsDelivery {
+		p.inner.Produce(entry)
+		p.commit(c)
+		return
+	}
+	p.pendingMutex.Lock()
+	p.pending[keyOf(entry)] = c
+	p.pendingMutex.Unlock()
+	p.inner.Produce(entry)
+}
+
+// handleDelivery remembers the change of a delivered record, and forgets the change of a failed one
+func (p *Producer) handleDelivery(entry *telemetry.Record, err error) {
+	k := keyOf(entry)
+	p.pendingMutex.Lock()
+	c, ok := p.pending[k]
+	delete(p.pending, k)
+	p.pendingMutex.Unlock()
+	if ok && err == nil {
+		p.commit(c)
+	}
+}
+
+// apply returns the data to send: the fields which changed since the last delivered values, or every known field
+// of the vehicle when a keyframe is due, along with the change to remember once delivered. Values older than the
+// delivered one are stale and never sent.
+func (p *Producer) apply(vin string, payload *protos.Payload) ([]*protos.Datum, *change) {
+	now := p.now()
+	state := p.vehicle(vin, now)
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.seenAt = now
+
+	timestamp := now
+	if payload.GetCreatedAt() != nil {
+		timestamp = payload.GetCreatedAt().AsTime()
+	}
+	c := &change{vin: vin, fields: make(map[protos.Field]*fieldValue, len(payload.GetData())), at: now}
+	data := make([]*protos.Datum, 0, len(payload.GetData()))
+	for _, datum := range payload.GetData() {
+		last, seen := state.fields[datum.GetKey()]
+		if seen && last.timestamp.After(timestamp) {
+			continue
+		}
+		if _, always := p.alwaysSend[datum.GetKey()]; !seen || always || !proto.Equal(last.value, datum.GetValue()) {
+			data = append(data, datum)
+		}
+		c.fields[datum.GetKey()] = &fieldValue{value: datum.GetValue(), timestamp: timestamp}
+	}
+
+	if p.keyframeInterval == 0 || now.Sub(state.keyframeAt) < p.keyframeInterval {
+		return data, c
+	}
+	c.keyframe = true
+	keyframe := make([]*protos.Datum, 0, len(state.fields)+len(c.fields))
+	for field, last := range state.fields {
+		if _, ok := c.fields[field]; !ok {
+			keyframe = append(keyframe, &protos.Datum{Key: field, Value: last.value})
+		}
+	}
+	for field, value := range c.fields {
+		keyframe = append(keyframe, &protos.Datum{Key: field, Value: value.value})
+	}
+	sort.Slice(keyframe, func(i, j int) bool { return keyframe[i].Key < keyframe[j].Key })
+	return keyframe, c
+}
+
+// commit remembers the values of a delivered record, unless newer values were delivered in the meantime
+func (p *Producer) commit(c *change) {
+	state := p.vehicle(c.vin, c.at)
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	for field, value := range c.fields {
+		if last, ok := state.fields[field]; ok && last.timestamp.After(value.timestamp) {
+			continue
+		}
+		state.fields[field] = value
+	}
+	if c.keyframe {
+		state.keyframeAt = c.at
+	}
+}
+
+// vehicle returns the state of a vehicle, created on its first record
+func (p *Producer) vehicle(vin string, now time.Time) *vehicle {
+	loaded, found := p.vehicles.Load(vin)
+	if !found {
+		loaded, found = p.vehicles.LoadOrStore(vin, &vehicle{fields: make(map[protos.Field]*fieldValue), keyframeAt: now, seenAt: now})
+		if !found {
+			metricsRegistry.vehicleCount.Add(1, map[string]string{"dispatcher": string(p.dispatcher)})
+		}
+	}
+	return loaded.(*vehicle)
+}
+
+// runEviction forgets the vehicles without records for the vehicle TTL, and the changes of records whose delivery
+// was never reported
+func (p *Producer) runEviction() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(min(p.vehicleTTL, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.evict(p.now().Add(-p.vehicleTTL))
+		}
+	}
+}
+
+func (p *Producer) evict(before time.Time) {
+	p.vehicles.Range(func(vin, loaded interface{}) bool {
+		state := loaded.(*vehicle)
+		state.mutex.Lock()
+		expired := state.seenAt.Before(before)
+		state.mutex.Unlock()
+		if expired {
+			p.vehicles.Delete(vin)
+			metricsRegistry.vehicleCount.Sub(1, map[string]string{"dispatcher": string(p.dispatcher)})
+		}
+		return true
+	})
+
+	p.pendingMutex.Lock()
+	defer p.pendingMutex.Unlock()
+	for k, c := range p.pending {
+		if c.at.Before(before) {
+			delete(p.pending, k)
+		}
+	}
+}
+
+// Close stops the eviction and closes the wrapped producer
+func (p *Producer) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.wg.Wait()
+	return p.inner.Close()
+}
+
+// ProcessReliableAck delegates to the wrapped producer
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	p.inner.ProcessReliableAck(entry)
+}
+
+// ReportError delegates to the wrapped producer
+func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo) {
+	p.inner.ReportError(message, err, logInfo)
+}
+
+// Unwrap returns the producer wrapped by the delta stage
+func (p *Producer) Unwrap() telemetry.Producer {
+	return p.inner
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.strippedFieldsCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "delta_stripped_fields_total",
+		Help:   "The number of unchanged fields stripped by change-only emission.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.suppressedRecordsCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "delta_suppressed_records_total",
+		Help:   "The number of records not dispatched because none of their fields changed.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.keyframeCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "delta_keyframes_total",
+		Help:   "The number of records dispatched with the full known state of a vehicle.",
+		Labels: []string{"dispatcher"},
+	})
+
+	metricsRegistry.vehicleCount = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "delta_vehicles",
+		Help:   "The number of vehicles whose delivered values are remembered by change-only emission.",
+		Labels: []string{"dispatcher"},
+	})
+}
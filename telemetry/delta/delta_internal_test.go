@@ -0,0 +1,80 @@
+package delta
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Delta keyframes", func() {
+	var (
+		producer *Producer
+		clock    time.Time
+	)
+
+	stringDatum := func(field protos.Field, value string) *protos.Datum {
+		return &protos.Datum{Key: field, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: value}}}
+	}
+
+	apply := func(data ...*protos.Datum) ([]protos.Field, bool) {
+		sent, change := producer.apply("42", &protos.Payload{CreatedAt: timestamppb.New(clock), Data: data})
+		producer.commit(change)
+		var fields []protos.Field
+		for _, datum := range sent {
+			fields = append(fields, datum.GetKey())
+		}
+		return fields, change.keyframe
+	}
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		var err error
+		producer, err = NewProducer(telemetry.MQTT, nil, &Config{KeyframeIntervalMinutes: 5}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		clock = time.Now()
+		producer.now = func() time.Time { return clock }
+	})
+
+	It("sends every known field once the interval elapsed", func() {
+		fields, keyframe := apply(stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80"))
+		Expect(keyframe).To(BeFalse())
+		Expect(fields).To(Equal([]protos.Field{protos.Field_Gear, protos.Field_Soc}))
+
+		clock = clock.Add(time.Minute)
+		fields, keyframe = apply(stringDatum(protos.Field_Gear, "D"))
+		Expect(keyframe).To(BeFalse())
+		Expect(fields).To(BeEmpty())
+
+		clock = clock.Add(5 * time.Minute)
+		fields, keyframe = apply(stringDatum(protos.Field_Gear, "D"))
+		Expect(keyframe).To(BeTrue())
+		Expect(fields).To(Equal([]protos.Field{protos.Field_Soc, protos.Field_Gear}))
+
+		clock = clock.Add(time.Minute)
+		_, keyframe = apply(stringDatum(protos.Field_Gear, "R"))
+		Expect(keyframe).To(BeFalse())
+	})
+
+	It("forgets the vehicles without records for the vehicle TTL", func() {
+		fields, _ := apply(stringDatum(protos.Field_Gear, "D"))
+		Expect(fields).To(Equal([]protos.Field{protos.Field_Gear}))
+
+		clock = clock.Add(time.Minute)
+		producer.evict(clock.Add(-producer.vehicleTTL))
+		fields, _ = apply(stringDatum(protos.Field_Gear, "D"))
+		Expect(fields).To(BeEmpty())
+
+		clock = clock.Add(producer.vehicleTTL + time.Minute)
+		producer.evict(clock.Add(-producer.vehicleTTL))
+		fields, _ = apply(stringDatum(protos.Field_Gear, "D"))
+		Expect(fields).To(Equal([]protos.Field{protos.Field_Gear}))
+	})
+})
@@ -0,0 +1,13 @@
+package delta_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDelta(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Delta Suite")
+}
@@ -0,0 +1,226 @@
+package delta_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/delta"
+)
+
+type captureProducer struct {
+	produced []*telemetry.Record
+	acked    []*telemetry.Record
+}
+
+func (c *captureProducer) Produce(entry *telemetry.Record) {
+	c.produced = append(c.produced, entry)
+}
+
+func (c *captureProducer) Close() error { return nil }
+
+func (c *captureProducer) ProcessReliableAck(entry *telemetry.Record) {
+	c.acked = append(c.acked, entry)
+}
+
+func (c *captureProducer) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
+// reportingProducer reports the delivery of each record, failing them while failing is set
+type reportingProducer struct {
+	telemetry.DeliveryNotifier
+	captureProducer
+	failing bool
+}
+
+func (r *reportingProducer) Produce(entry *telemetry.Record) {
+	if r.failing {
+		r.NotifyDelivery(entry, errors.New("backend unavailable"))
+		return
+	}
+	r.captureProducer.Produce(entry)
+	r.NotifyDelivery(entry, nil)
+}
+
+var _ = Describe("Delta producer", func() {
+	var (
+		logger *logrus.Logger
+		inner  *captureProducer
+		start  time.Time
+	)
+
+	stringDatum := func(field protos.Field, value string) *protos.Datum {
+		return &protos.Datum{Key: field, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: value}}}
+	}
+
+	newRecord := func(vin string, transmitDecodedRecords bool, txType string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", transmitDecodedRecords)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newPayloadRecord := func(vin string, createdAt time.Time, data ...*protos.Datum) *telemetry.Record {
+		return newRecord(vin, false, "V", &protos.Payload{Vin: vin, CreatedAt: timestamppb.New(createdAt), Data: data})
+	}
+
+	newProducer := func(config *delta.Config) *delta.Producer {
+		producer, err := delta.NewProducer(telemetry.Kinesis, inner, config, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		return producer
+	}
+
+	dispatchedFields := func(record *telemetry.Record) []protos.Field {
+		data := &protos.Payload{}
+		Expect(proto.Unmarshal(record.Payload(), data)).To(Succeed())
+		var fields []protos.Field
+		for _, datum := range data.GetData() {
+			fields = append(fields, datum.GetKey())
+		}
+		return fields
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		inner = &captureProducer{}
+		start = time.Now().Add(-time.Hour)
+	})
+
+	It("sends every field of the first record", func() {
+		producer := newProducer(&delta.Config{})
+		record := newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80"))
+		producer.Produce(record)
+
+		Expect(inner.produced).To(ConsistOf(BeIdenticalTo(record)))
+	})
+
+	It("strips the fields which did not change", func() {
+		producer := newProducer(&delta.Config{})
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80")))
+		record := newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "79"))
+		producer.Produce(record)
+
+		Expect(inner.produced).To(HaveLen(2))
+		Expect(dispatchedFields(inner.produced[1])).To(Equal([]protos.Field{protos.Field_Soc}))
+		Expect(inner.produced[1].Txid).To(Equal("1234"))
+		Expect(dispatchedFields(record)).To(HaveLen(2))
+	})
+
+	It("tracks each vehicle separately", func() {
+		producer := newProducer(&delta.Config{})
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D")))
+		producer.Produce(newPayloadRecord("43", start, stringDatum(protos.Field_Gear, "D")))
+
+		Expect(inner.produced).To(HaveLen(2))
+		Expect(dispatchedFields(inner.produced[1])).To(Equal([]protos.Field{protos.Field_Gear}))
+	})
+
+	It("always sends the configured fields", func() {
+		producer := newProducer(&delta.Config{AlwaysSend: []string{"Gear"}})
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80")))
+		producer.Produce(newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80")))
+
+		Expect(inner.produced).To(HaveLen(2))
+		Expect(dispatchedFields(inner.produced[1])).To(Equal([]protos.Field{protos.Field_Gear}))
+	})
+
+	It("sends the whole record when the stripped one cannot be encoded", func() {
+		producer := newProducer(&delta.Config{})
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80")))
+		record := newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "79"))
+		record.GetProtoMessage().(*protos.Payload).Data[1] = stringDatum(protos.Field_Soc, "\xff")
+		producer.Produce(record)
+
+		Expect(inner.produced).To(HaveLen(2))
+		Expect(inner.produced[1]).To(BeIdenticalTo(record))
+		Expect(dispatchedFields(inner.produced[1])).To(Equal([]protos.Field{protos.Field_Gear, protos.Field_Soc}))
+	})
+
+	It("acknowledges without sending the records without any change", func() {
+		producer := newProducer(&delta.Config{})
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D")))
+		resend := newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "D"))
+		producer.Produce(resend)
+
+		Expect(inner.produced).To(HaveLen(1))
+		Expect(inner.acked).To(ConsistOf(BeIdenticalTo(resend)))
+	})
+
+	It("sends the values of a failed delivery again", func() {
+		reporting := &reportingProducer{}
+		producer, err := delta.NewProducer(telemetry.Kinesis, reporting, &delta.Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { Expect(producer.Close()).To(Succeed()) }()
+
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80")))
+		reporting.failing = true
+		producer.Produce(newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "R"), stringDatum(protos.Field_Soc, "80")))
+		reporting.failing = false
+		resend := newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "R"), stringDatum(protos.Field_Soc, "80"))
+		producer.Produce(resend)
+
+		Expect(reporting.produced).To(HaveLen(2))
+		Expect(dispatchedFields(reporting.produced[1])).To(Equal([]protos.Field{protos.Field_Gear}))
+		Expect(reporting.acked).To(BeEmpty())
+
+		producer.Produce(newPayloadRecord("42", start.Add(2*time.Second), stringDatum(protos.Field_Gear, "R")))
+		Expect(reporting.produced).To(HaveLen(2))
+		Expect(reporting.acked).To(HaveLen(1))
+	})
+
+	It("ignores values older than the last one", func() {
+		producer := newProducer(&delta.Config{})
+		producer.Produce(newPayloadRecord("42", start, stringDatum(protos.Field_Gear, "D")))
+		producer.Produce(newPayloadRecord("42", start.Add(-time.Second), stringDatum(protos.Field_Gear, "P")))
+		producer.Produce(newPayloadRecord("42", start.Add(time.Second), stringDatum(protos.Field_Gear, "D")))
+
+		Expect(inner.produced).To(HaveLen(1))
+		Expect(inner.acked).To(HaveLen(2))
+	})
+
+	It("re-encodes decoded records as JSON", func() {
+		producer := newProducer(&delta.Config{})
+		producer.Produce(newRecord("42", true, "V", &protos.Payload{CreatedAt: timestamppb.New(start), Data: []*protos.Datum{stringDatum(protos.Field_Gear, "D"), stringDatum(protos.Field_Soc, "80")}}))
+		producer.Produce(newRecord("42", true, "V", &protos.Payload{CreatedAt: timestamppb.New(start.Add(time.Second)), Data: []*protos.Datum{stringDatum(protos.Field_Gear, "R"), stringDatum(protos.Field_Soc, "80")}}))
+
+		Expect(inner.produced).To(HaveLen(2))
+		var payload map[string]interface{}
+		Expect(json.Unmarshal(inner.produced[1].Payload(), &payload)).To(Succeed())
+		Expect(payload["data"]).To(HaveLen(1))
+		Expect(string(inner.produced[1].Payload())).To(ContainSubstring("Gear"))
+		Expect(string(inner.produced[1].Payload())).NotTo(ContainSubstring("Soc"))
+	})
+
+	It("passes through other record types", func() {
+		producer := newProducer(&delta.Config{})
+		record := newRecord("42", false, "alerts", &protos.VehicleAlerts{Vin: "42"})
+		producer.Produce(record)
+		producer.Produce(record)
+
+		Expect(inner.produced).To(HaveLen(2))
+	})
+
+	DescribeTable("rejects invalid configs",
+		func(config *delta.Config, errMessage string) {
+			_, err := delta.NewProducer(telemetry.Kinesis, inner, config, metrics.NewCollector(nil, logger), logger)
+			Expect(err).To(MatchError(errMessage))
+		},
+		Entry("unknown field", &delta.Config{AlwaysSend: []string{"NotAField"}}, "unknown field in delta always_send of kinesis: NotAField"),
+		Entry("negative keyframe interval", &delta.Config{KeyframeIntervalMinutes: -1}, "delta keyframe_interval_minutes of kinesis must not be negative"),
+	)
+})
@@ -0,0 +1,345 @@
+package derived
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// RecordType is the record type of the derived signals, dispatched with the rules of the "derived" records
+	RecordType = "derived"
+
+	// DefaultVehicleTTLMinutes is how long the values of a vehicle without V records are kept by default
+	DefaultVehicleTTLMinutes = 60
+)
+
+// Config of the signals derived from the V records
+type Config struct {
+	// Signals computed for every vehicle
+	Signals []*Signal `json:"signals"`
+
+	// VehicleTTLMinutes is how long the values and sessions of a vehicle without V records are kept, its signals then
+	// start over as for a new vehicle. Default: 60
+	VehicleTTLMinutes int `json:"vehicle_ttl_minutes,omitempty"`
+}
+
+// Signal is a value computed from the fields of the vehicles
+type Signal struct {
+	// Name of the signal in the derived records
+	Name string `json:"name"`
+
+	// Expression combines numbers and protos.Field names with +, -, *, / and parentheses. delta(Field) is the change
+	// of a field since its previous value, change(Field) its change since the start of the session.
+	// Ex.: "PackVoltage * PackCurrent / 1000"
+	Expression string `json:"expression"`
+
+	// Session restricts the signal to the records received while a field has one of the values, change() restarting
+	// with each session. Default: a session lasting as long as the server
+	Session *Session `json:"session,omitempty"`
+}
+
+// Session is the period during which a field has one of the values (ex.: DetailedChargeState charging)
+type Session struct {
+	// Field is the protos.Field name whose value delimits the session
+	Field string `json:"field"`
+
+	// Values of the field during the session, enums by name (ex.: "DetailedChargeStateCharging")
+	Values []string `json:"values"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	signalCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Engine computes the derived signals of the V records, keeping the values they need per vehicle in memory
+type Engine struct {
+	signals    []*signal
+	vehicleTTL time.Duration
+	logger     *logrus.Logger
+	now        func() time.Time
+
+	vehicles sync.Map
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+type signal struct {
+	name          string
+	expression    node
+	fields        map[protos.Field]struct{}
+	sessionField  protos.Field
+	sessionValues map[string]struct{}
+}
+
+// vehicle is the last numeric value of each field of a vehicle, and the state of its sessions
+type vehicle struct {
+	mutex    sync.Mutex
+	values   map[protos.Field]float64
+	previous map[protos.Field]float64
+	received map[protos.Field]struct{}
+	sessions []*session
+	seenAt   time.Time
+}
+
+// session of a signal for a vehicle
+type session struct {
+	active    bool
+	baselines map[protos.Field]float64
+}
+
+// NewEngine validates the expressions of the signals
+func NewEngine(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Engine, error) {
+	registerMetricsOnce(metricsCollector)
+
+	if config.VehicleTTLMinutes < 0 {
+		return nil, errors.New("derived vehicle_ttl_minutes must not be negative")
+	}
+	vehicleTTLMinutes := config.VehicleTTLMinutes
+	if vehicleTTLMinutes == 0 {
+		vehicleTTLMinutes = DefaultVehicleTTLMinutes
+	}
+
+	engine := &Engine{
+		vehicleTTL: time.Duration(vehicleTTLMinutes) * time.Minute,
+		logger:     logger,
+		now:        time.Now,
+		done:       make(chan struct{}),
+	}
+	names := make(map[string]struct{}, len(config.Signals))
+	for _, configured := range config.Signals {
+		if configured.Name == "" {
+			return nil, fmt.Errorf("derived signal without name: %s", configured.Expression)
+		}
+		if _, ok := names[configured.Name]; ok {
+			return nil, fmt.Errorf("duplicate derived signal: %s", configured.Name)
+		}
+		names[configured.Name] = struct{}{}
+
+		expression, err := parseExpression(configured.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of derived signal %s: %w", configured.Name, err)
+		}
+		s := &signal{name: configured.Name, expression: expression, fields: make(map[protos.Field]struct{})}
+		expression.collectFields(s.fields)
+		if configured.Session != nil {
+			if s.sessionField, err = parseField(configured.Session.Field); err != nil {
+				return nil, fmt.Errorf("invalid session of derived signal %s: %w", configured.Name, err)
+			}
+			s.sessionValues = make(map[string]struct{}, len(configured.Session.Values))
+			for _, value := range configured.Session.Values {
+				s.sessionValues[value] = struct{}{}
+			}
+		}
+		engine.signals = append(engine.signals, s)
+	}
+
+	engine.wg.Add(1)
+	go engine.runEviction()
+	return engine, nil
+}
+
+// Derive returns a record of the signals computed from a V record, nil when none was. Resent payloads are ignored,
+// since their values are older than the ones already received.
+func (e *Engine) Derive(entry *telemetry.Record) *telemetry.Record {
+	payload, ok := entry.GetProtoMessage().(*protos.Payload)
+	if !ok || payload.GetIsResend() {
+		return nil
+	}
+
+	computed := e.apply(entry.Vin, payload)
+	if len(computed) == 0 {
+		return nil
+	}
+	record, err := entry.WithProtoMessage(&protos.VehicleDerivedSignals{
+		Signals:   computed,
+		CreatedAt: payload.GetCreatedAt(),
+		Vin:       entry.Vin,
+	})
+	if err != nil {
+		e.logger.ErrorLog("derived_encode_error", err, logrus.LogInfo{"vin": entry.Vin, "txid": entry.Txid})
+		return nil
+	}
+	record.TxType = RecordType
+	return record
+}
+
+// apply remembers the values of the payload and computes the signals reading any of its fields
+func (e *Engine) apply(vin string, payload *protos.Payload) []*protos.DerivedSignal {
+	loaded, found := e.vehicles.Load(vin)
+	if !found {
+		loaded, _ = e.vehicles.LoadOrStore(vin, e.newVehicle())
+	}
+	state := loaded.(*vehicle)
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.seenAt = e.now()
+
+	state.received = make(map[protos.Field]struct{}, len(payload.GetData()))
+	for _, datum := range payload.GetData() {
+		state.received[datum.GetKey()] = struct{}{}
+		e.updateSessions(state, datum)
+		value, ok := numberOf(datum.GetValue())
+		if !ok {
+			continue
+		}
+		if last, ok := state.values[datum.GetKey()]; ok {
+			state.previous[datum.GetKey()] = last
+		}
+		state.values[datum.GetKey()] = value
+	}
+
+	var computed []*protos.DerivedSignal
+	for i, s := range e.signals {
+		if !state.sessions[i].active || !s.reads(state.received) {
+			continue
+		}
+		value, ok := s.expression.eval(&scope{vehicle: state, session: state.sessions[i]})
+		if !ok {
+			continue
+		}
+		computed = append(computed, &protos.DerivedSignal{Name: s.name, Value: value})
+		metricsRegistry.signalCount.Inc(map[string]string{"signal": s.name})
+	}
+	return computed
+}
+
+func (e *Engine) newVehicle() *vehicle {
+	state := &vehicle{
+		values:   make(map[protos.Field]float64),
+		previous: make(map[protos.Field]float64),
+		sessions: make([]*session, len(e.signals)),
+	}
+	for i, s := range e.signals {
+		state.sessions[i] = &session{active: s.sessionValues == nil, baselines: make(map[protos.Field]float64)}
+	}
+	return state
+}
+
+// runEviction forgets the vehicles without V records for the vehicle TTL
+func (e *Engine) runEviction() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(min(e.vehicleTTL, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.evict(e.now().Add(-e.vehicleTTL))
+		}
+	}
+}
+
+func (e *Engine) evict(before time.Time) {
+	e.vehicles.Range(func(vin, loaded interface{}) bool {
+		state := loaded.(*vehicle)
+		state.mutex.Lock()
+		expired := state.seenAt.Before(before)
+		state.mutex.Unlock()
+		if expired {
+			e.vehicles.Delete(vin)
+		}
+		return true
+	})
+}
+
+// Close stops the eviction
+func (e *Engine) Close() error {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+	return nil
+}
+
+// updateSessions starts or ends the sessions delimited by the field of the datum
+func (e *Engine) updateSessions(state *vehicle, datum *protos.Datum) {
+	for i, s := range e.signals {
+		if s.sessionValues == nil || s.sessionField != datum.GetKey() {
+			continue
+		}
+		text, _ := textOf(datum.GetValue())
+		_, active := s.sessionValues[text]
+		if active && !state.sessions[i].active {
+			state.sessions[i].baselines = make(map[protos.Field]float64)
+		}
+		state.sessions[i].active = active
+	}
+}
+
+// reads returns whether the signal reads any of the fields
+func (s *signal) reads(fields map[protos.Field]struct{}) bool {
+	for field := range s.fields {
+		if _, ok := fields[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// numberOf returns the numeric value of a datum, including numbers sent as strings
+func numberOf(value *protos.Value) (float64, bool) {
+	switch v := value.GetValue().(type) {
+	case *protos.Value_DoubleValue:
+		return v.DoubleValue, true
+	case *protos.Value_FloatValue:
+		return float64(v.FloatValue), true
+	case *protos.Value_IntValue:
+		return float64(v.IntValue), true
+	case *protos.Value_LongValue:
+		return float64(v.LongValue), true
+	case *protos.Value_StringValue:
+		number, err := strconv.ParseFloat(v.StringValue, 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// textOf returns the value of a datum as text, enums by name
+func textOf(value *protos.Value) (string, bool) {
+	message := value.ProtoReflect()
+	field := message.WhichOneof(message.Descriptor().Oneofs().ByName("value"))
+	if field == nil {
+		return "", false
+	}
+	if field.Kind() != protoreflect.EnumKind {
+		return fmt.Sprint(message.Get(field).Interface()), true
+	}
+	enumValue := field.Enum().Values().ByNumber(message.Get(field).Enum())
+	if enumValue == nil {
+		return "", false
+	}
+	return string(enumValue.Name()), true
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.signalCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "derived_signals_total",
+		Help:   "The number of derived signal values computed.",
+		Labels: []string{"signal"},
+	})
+}
@@ -0,0 +1,55 @@
+package derived
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("Derived signals eviction", func() {
+	var (
+		engine *Engine
+		clock  time.Time
+	)
+
+	apply := func(odometer float64) []*protos.DerivedSignal {
+		return engine.apply("42", &protos.Payload{Data: []*protos.Datum{
+			{Key: protos.Field_Odometer, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: odometer}}},
+		}})
+	}
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		var err error
+		engine, err = NewEngine(&Config{Signals: []*Signal{{Name: "distance", Expression: "delta(Odometer)"}}}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(engine.Close)
+		clock = time.Now()
+		engine.now = func() time.Time { return clock }
+	})
+
+	It("forgets the vehicles without records for the vehicle TTL", func() {
+		Expect(apply(100)).To(BeEmpty())
+
+		clock = clock.Add(time.Minute)
+		engine.evict(clock.Add(-engine.vehicleTTL))
+		computed := apply(110)
+		Expect(computed).To(HaveLen(1))
+		Expect(computed[0].GetValue()).To(Equal(10.0))
+
+		clock = clock.Add(engine.vehicleTTL + time.Minute)
+		engine.evict(clock.Add(-engine.vehicleTTL))
+		Expect(apply(120)).To(BeEmpty())
+	})
+
+	It("rejects a negative vehicle TTL", func() {
+		logger, _ := logrus.NoOpLogger()
+		_, err := NewEngine(&Config{VehicleTTLMinutes: -1}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).To(MatchError("derived vehicle_ttl_minutes must not be negative"))
+	})
+})
@@ -0,0 +1,13 @@
+package derived_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDerived(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Derived Suite")
+}
@@ -0,0 +1,151 @@
+package derived_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/derived"
+)
+
+var _ = Describe("Derived signals", func() {
+	var logger *logrus.Logger
+
+	stringDatum := func(field protos.Field, value string) *protos.Datum {
+		return &protos.Datum{Key: field, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: value}}}
+	}
+
+	newRecord := func(vin string, transmitDecodedRecords bool, payload *protos.Payload) *telemetry.Record {
+		payloadBytes, err := proto.Marshal(payload)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte("V"), Payload: payloadBytes}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", transmitDecodedRecords)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newPayloadRecord := func(vin string, data ...*protos.Datum) *telemetry.Record {
+		return newRecord(vin, false, &protos.Payload{Vin: vin, Data: data})
+	}
+
+	newEngine := func(signals ...*derived.Signal) *derived.Engine {
+		engine, err := derived.NewEngine(&derived.Config{Signals: signals}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		return engine
+	}
+
+	derivedSignals := func(record *telemetry.Record) map[string]float64 {
+		Expect(record).NotTo(BeNil())
+		Expect(record.TxType).To(Equal(derived.RecordType))
+		message := &protos.VehicleDerivedSignals{}
+		Expect(proto.Unmarshal(record.Payload(), message)).To(Succeed())
+		Expect(message.GetVin()).To(Equal(record.Vin))
+		values := make(map[string]float64, len(message.GetSignals()))
+		for _, signal := range message.GetSignals() {
+			values[signal.GetName()] = signal.GetValue()
+		}
+		return values
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+	})
+
+	DescribeTable("rejects invalid signals",
+		func(signal *derived.Signal, expected string) {
+			_, err := derived.NewEngine(&derived.Config{Signals: []*derived.Signal{signal}}, metrics.NewCollector(nil, logger), logger)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("unknown field", &derived.Signal{Name: "power", Expression: "PackVoltage * Current"}, "invalid expression of derived signal power: unknown field: Current"),
+		Entry("unknown function", &derived.Signal{Name: "trip", Expression: "sum(Odometer)"}, "invalid expression of derived signal trip: unknown function: sum"),
+		Entry("unbalanced parentheses", &derived.Signal{Name: "power", Expression: "(PackVoltage * PackCurrent"}, "invalid expression of derived signal power: expected ')' at position 26"),
+		Entry("trailing operator", &derived.Signal{Name: "power", Expression: "PackVoltage *"}, "invalid expression of derived signal power: unexpected end of expression"),
+		Entry("missing name", &derived.Signal{Expression: "Soc"}, "derived signal without name: Soc"),
+		Entry("unknown session field", &derived.Signal{Name: "energy", Expression: "change(ACChargingEnergyIn)", Session: &derived.Session{Field: "Charging"}}, "invalid session of derived signal energy: unknown field: Charging"),
+	)
+
+	It("rejects duplicate signals", func() {
+		_, err := derived.NewEngine(&derived.Config{Signals: []*derived.Signal{{Name: "soc", Expression: "Soc"}, {Name: "soc", Expression: "Soc"}}}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).To(MatchError("duplicate derived signal: soc"))
+	})
+
+	It("computes arithmetic expressions with operator precedence", func() {
+		engine := newEngine(&derived.Signal{Name: "power", Expression: "-PackVoltage * (PackCurrent + 10) / 1000 + 1"})
+		record := engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_PackVoltage, "400"), stringDatum(protos.Field_PackCurrent, "-60")))
+		Expect(derivedSignals(record)).To(Equal(map[string]float64{"power": 21}))
+		Expect(record.Vin).To(Equal("42"))
+		Expect(record.Txid).To(Equal("1234"))
+	})
+
+	It("combines the last known values of the vehicle", func() {
+		engine := newEngine(&derived.Signal{Name: "power", Expression: "PackVoltage * PackCurrent"})
+		Expect(engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_PackVoltage, "400")))).To(BeNil())
+		Expect(engine.Derive(newPayloadRecord("43", stringDatum(protos.Field_PackCurrent, "2")))).To(BeNil())
+
+		record := engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_PackCurrent, "2")))
+		Expect(derivedSignals(record)).To(Equal(map[string]float64{"power": 800}))
+
+		Expect(engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_Gear, "D")))).To(BeNil())
+	})
+
+	It("computes deltas between the values received", func() {
+		engine := newEngine(&derived.Signal{Name: "distance", Expression: "delta(Odometer)"})
+		Expect(engine.Derive(newPayloadRecord("42", &protos.Datum{Key: protos.Field_Odometer, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 100}}}))).To(BeNil())
+
+		record := engine.Derive(newPayloadRecord("42", &protos.Datum{Key: protos.Field_Odometer, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 102.5}}}))
+		Expect(derivedSignals(record)).To(Equal(map[string]float64{"distance": 2.5}))
+	})
+
+	It("computes changes since the start of the session", func() {
+		engine := newEngine(&derived.Signal{
+			Name:       "charged",
+			Expression: "change(Soc)",
+			Session:    &derived.Session{Field: "DetailedChargeState", Values: []string{"DetailedChargeStateCharging"}},
+		})
+		chargeState := func(state protos.DetailedChargeStateValue) *protos.Datum {
+			return &protos.Datum{Key: protos.Field_DetailedChargeState, Value: &protos.Value{Value: &protos.Value_DetailedChargeStateValue{DetailedChargeStateValue: state}}}
+		}
+
+		Expect(engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_Soc, "40")))).To(BeNil())
+		record := engine.Derive(newPayloadRecord("42", chargeState(protos.DetailedChargeStateValue_DetailedChargeStateCharging), stringDatum(protos.Field_Soc, "50")))
+		Expect(derivedSignals(record)).To(Equal(map[string]float64{"charged": 0}))
+		record = engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_Soc, "65")))
+		Expect(derivedSignals(record)).To(Equal(map[string]float64{"charged": 15}))
+
+		Expect(engine.Derive(newPayloadRecord("42", chargeState(protos.DetailedChargeStateValue_DetailedChargeStateComplete), stringDatum(protos.Field_Soc, "66")))).To(BeNil())
+
+		record = engine.Derive(newPayloadRecord("42", chargeState(protos.DetailedChargeStateValue_DetailedChargeStateCharging), stringDatum(protos.Field_Soc, "60")))
+		Expect(derivedSignals(record)).To(Equal(map[string]float64{"charged": 0}))
+	})
+
+	It("skips divisions by zero", func() {
+		engine := newEngine(&derived.Signal{Name: "ratio", Expression: "Soc / PackCurrent"})
+		Expect(engine.Derive(newPayloadRecord("42", stringDatum(protos.Field_Soc, "40"), stringDatum(protos.Field_PackCurrent, "0")))).To(BeNil())
+	})
+
+	It("ignores resent payloads and other record types", func() {
+		engine := newEngine(&derived.Signal{Name: "soc", Expression: "Soc"})
+		Expect(engine.Derive(newRecord("42", false, &protos.Payload{Vin: "42", IsResend: true, Data: []*protos.Datum{stringDatum(protos.Field_Soc, "40")}}))).To(BeNil())
+		Expect(engine.Derive(&telemetry.Record{TxType: "alerts"})).To(BeNil())
+	})
+
+	It("encodes the derived record as JSON with transmit_decoded_records", func() {
+		engine := newEngine(&derived.Signal{Name: "soc", Expression: "Soc"})
+		record := engine.Derive(newRecord("42", true, &protos.Payload{Vin: "42", Data: []*protos.Datum{stringDatum(protos.Field_Soc, "40")}}))
+		Expect(record).NotTo(BeNil())
+		message := &protos.VehicleDerivedSignals{}
+		Expect(protojson.Unmarshal(record.Payload(), message)).To(Succeed())
+		Expect(message.GetSignals()).To(HaveLen(1))
+		Expect(message.GetSignals()[0].GetName()).To(Equal("soc"))
+	})
+})
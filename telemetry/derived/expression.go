@@ -0,0 +1,296 @@
+package derived
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// Functions of the expressions, their argument is a field
+const (
+	// FunctionDelta is the change of a field since its previous value, only computed when the field is received
+	FunctionDelta = "delta"
+	// FunctionChange is the change of a field since the start of the session of the signal
+	FunctionChange = "change"
+)
+
+// node is a parsed expression
+type node interface {
+	// eval returns the value of the node, false when it cannot be computed (ex.: unknown field, division by zero)
+	eval(scope *scope) (float64, bool)
+	// collectFields adds the fields read by the node to the set
+	collectFields(fields map[protos.Field]struct{})
+}
+
+// scope is what an expression is evaluated against
+type scope struct {
+	vehicle *vehicle
+	session *session
+}
+
+type number float64
+
+func (n number) eval(_ *scope) (float64, bool) {
+	return float64(n), true
+}
+
+func (n number) collectFields(_ map[protos.Field]struct{}) {}
+
+type fieldRef protos.Field
+
+func (f fieldRef) eval(scope *scope) (float64, bool) {
+	value, ok := scope.vehicle.values[protos.Field(f)]
+	return value, ok
+}
+
+func (f fieldRef) collectFields(fields map[protos.Field]struct{}) {
+	fields[protos.Field(f)] = struct{}{}
+}
+
+type negation struct {
+	operand node
+}
+
+func (n *negation) eval(scope *scope) (float64, bool) {
+	value, ok := n.operand.eval(scope)
+	return -value, ok
+}
+
+func (n *negation) collectFields(fields map[protos.Field]struct{}) {
+	n.operand.collectFields(fields)
+}
+
+type binary struct {
+	operator    byte
+	left, right node
+}
+
+func (b *binary) eval(scope *scope) (float64, bool) {
+	// both operands are evaluated so the functions of the right operand see every record
+	left, leftOk := b.left.eval(scope)
+	right, rightOk := b.right.eval(scope)
+	if !leftOk || !rightOk {
+		return 0, false
+	}
+	switch b.operator {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	default:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+}
+
+func (b *binary) collectFields(fields map[protos.Field]struct{}) {
+	b.left.collectFields(fields)
+	b.right.collectFields(fields)
+}
+
+type call struct {
+	function string
+	field    protos.Field
+}
+
+func (c *call) eval(scope *scope) (float64, bool) {
+	value, ok := scope.vehicle.values[c.field]
+	if !ok {
+		return 0, false
+	}
+	if c.function == FunctionDelta {
+		if _, received := scope.vehicle.received[c.field]; !received {
+			return 0, false
+		}
+		previous, ok := scope.vehicle.previous[c.field]
+		return value - previous, ok
+	}
+	baseline, ok := scope.session.baselines[c.field]
+	if !ok {
+		scope.session.baselines[c.field] = value
+		baseline = value
+	}
+	return value - baseline, true
+}
+
+func (c *call) collectFields(fields map[protos.Field]struct{}) {
+	fields[c.field] = struct{}{}
+}
+
+// parser is a recursive descent parser of the arithmetic expressions of the derived signals
+type parser struct {
+	input string
+	pos   int
+}
+
+// parseExpression parses an expression of numbers, protos.Field names and functions combined with +, -, *, / and
+// parentheses, ex.: "PackVoltage * PackCurrent / 1000"
+func parseExpression(input string) (node, error) {
+	p := &parser{input: input}
+	expression, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return expression, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	for err == nil {
+		operator, ok := p.consumeOperator("+-")
+		if !ok {
+			return left, nil
+		}
+		var right node
+		if right, err = p.parseProduct(); err == nil {
+			left = &binary{operator: operator, left: left, right: right}
+		}
+	}
+	return nil, err
+}
+
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil {
+		operator, ok := p.consumeOperator("*/")
+		if !ok {
+			return left, nil
+		}
+		var right node
+		if right, err = p.parseUnary(); err == nil {
+			left = &binary{operator: operator, left: left, right: right}
+		}
+	}
+	return nil, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.consumeOperator("-"); !ok {
+		return p.parsePrimary()
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &negation{operand: operand}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, errors.New("unexpected end of expression")
+	}
+	switch char := p.input[p.pos]; {
+	case char == '(':
+		p.pos++
+		expression, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return expression, nil
+	case isDigit(char) || char == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", p.input[start:p.pos])
+		}
+		return number(value), nil
+	case isLetter(char):
+		name := p.parseIdentifier()
+		if p.skipSpaces(); p.pos < len(p.input) && p.input[p.pos] == '(' {
+			return p.parseCall(name)
+		}
+		field, err := parseField(name)
+		if err != nil {
+			return nil, err
+		}
+		return fieldRef(field), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", char, p.pos)
+	}
+}
+
+func (p *parser) parseCall(function string) (node, error) {
+	if function != FunctionDelta && function != FunctionChange {
+		return nil, fmt.Errorf("unknown function: %s", function)
+	}
+	p.pos++
+	p.skipSpaces()
+	field, err := parseField(p.parseIdentifier())
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return &call{function: function, field: field}, nil
+}
+
+func (p *parser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.input) && (isLetter(p.input[p.pos]) || isDigit(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// consumeOperator consumes the next character if it is one of the operators
+func (p *parser) consumeOperator(operators string) (byte, bool) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0, false
+	}
+	for i := 0; i < len(operators); i++ {
+		if p.input[p.pos] == operators[i] {
+			p.pos++
+			return operators[i], true
+		}
+	}
+	return 0, false
+}
+
+func (p *parser) expect(char byte) error {
+	p.skipSpaces()
+	if p.pos >= len(p.input) || p.input[p.pos] != char {
+		return fmt.Errorf("expected %q at position %d", char, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func parseField(name string) (protos.Field, error) {
+	value, ok := protos.Field_value[name]
+	if !ok {
+		return protos.Field_Unknown, fmt.Errorf("unknown field: %s", name)
+	}
+	return protos.Field(value), nil
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isLetter(char byte) bool {
+	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char == '_'
+}
@@ -0,0 +1,191 @@
+package filter
+
+import (
+	"fmt"
+	"sync"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// Rule lists the fields kept or dropped from a record type, using protos.Field names (ex.: "VehicleSpeed")
+type Rule struct {
+	// Include keeps only the listed fields
+	Include []string `json:"include,omitempty"`
+
+	// Exclude drops the listed fields
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// fieldSet is a validated Rule
+type fieldSet struct {
+	fields  map[protos.Field]struct{}
+	include bool
+}
+
+// FieldFilters maps record types to the fields kept for them
+type FieldFilters map[string]*fieldSet
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	droppedFieldsCount  adapter.Counter
+	droppedRecordsCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// NewFieldFilters validates the rules of a dispatcher, keyed by record type
+func NewFieldFilters(rules map[string]*Rule) (FieldFilters, error) {
+	filters := make(FieldFilters, len(rules))
+	for recordType, rule := range rules {
+		if recordType != "V" {
+			return nil, fmt.Errorf("field filters are only supported for V records, got: %s", recordType)
+		}
+		if rule == nil || (len(rule.Include) == 0) == (len(rule.Exclude) == 0) {
+			return nil, fmt.Errorf("field filter for record %s must set exactly one of include or exclude", recordType)
+		}
+
+		names := rule.Exclude
+		if len(rule.Include) > 0 {
+			names = rule.Include
+		}
+		set := &fieldSet{fields: make(map[protos.Field]struct{}, len(names)), include: len(rule.Include) > 0}
+		for _, name := range names {
+			value, ok := protos.Field_value[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown field in filter for record %s: %s", recordType, name)
+			}
+			set.fields[protos.Field(value)] = struct{}{}
+		}
+		filters[recordType] = set
+	}
+	return filters, nil
+}
+
+// keep returns whether the field is dispatched
+func (s *fieldSet) keep(field protos.Field) bool {
+	_, ok := s.fields[field]
+	return ok == s.include
+}
+
+// Producer drops the filtered fields of records before handing them to the wrapped producer
+type Producer struct {
+	telemetry.DeliveryNotifier
+	dispatcher telemetry.Dispatcher
+	inner      telemetry.Producer
+	filters    FieldFilters
+	logger     *logrus.Logger
+}
+
+// NewProducer wraps the producer of a dispatcher with its field filters
+func NewProducer(dispatcher telemetry.Dispatcher, inner telemetry.Producer, filters FieldFilters, metricsCollector metrics.MetricCollector, logger *logrus.Logger) *Producer {
+	registerMetricsOnce(metricsCollector)
+	logger.ActivityLog("field_filter_registered", logrus.LogInfo{"dispatcher": dispatcher})
+	return &Producer{
+		dispatcher: dispatcher,
+		inner:      inner,
+		filters:    filters,
+		logger:     logger,
+	}
+}
+
+// SetDeliveryHandler registers the handler notified of the records failed by the filter, and of the deliveries of
+// the wrapped producer when it reports them
+func (p *Producer) SetDeliveryHandler(handler telemetry.DeliveryHandler) {
+	p.DeliveryNotifier.SetDeliveryHandler(handler)
+	if reporter, ok := p.inner.(telemetry.DeliveryReporter); ok {
+		reporter.SetDeliveryHandler(handler)
+	}
+}
+
+// Produce filters the record and sends it to the wrapped producer.
+// A record left without any field is not sent, but is still acknowledged. A record which cannot be encoded once
+// filtered is failed rather than sent with the filtered fields.
+func (p *Producer) Produce(entry *telemetry.Record) {
+	set, ok := p.filters[entry.TxType]
+	if !ok {
+		p.inner.Produce(entry)
+		return
+	}
+	payload, ok := entry.GetProtoMessage().(*protos.Payload)
+	if !ok {
+		p.inner.Produce(entry)
+		return
+	}
+
+	data := make([]*protos.Datum, 0, len(payload.GetData()))
+	for _, datum := range payload.GetData() {
+		if set.keep(datum.GetKey()) {
+			data = append(data, datum)
+		}
+	}
+	dropped := len(payload.GetData()) - len(data)
+	if dropped == 0 {
+		p.inner.Produce(entry)
+		return
+	}
+	metricsRegistry.droppedFieldsCount.Add(int64(dropped), map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+
+	if len(data) == 0 {
+		metricsRegistry.droppedRecordsCount.Inc(map[string]string{"dispatcher": string(p.dispatcher), "record_type": entry.TxType})
+		p.inner.ProcessReliableAck(entry)
+		return
+	}
+
+	filtered, err := entry.WithProtoMessage(&protos.Payload{
+		Data:      data,
+		CreatedAt: payload.GetCreatedAt(),
+		Vin:       payload.GetVin(),
+		IsResend:  payload.GetIsResend(),
+	})
+	if err != nil {
+		p.ReportError("field_filter_encode_error", err, logrus.LogInfo{"dispatcher": p.dispatcher, "record_type": entry.TxType, "txid": entry.Txid})
+		p.NotifyDelivery(entry, err)
+		return
+	}
+	p.inner.Produce(filtered)
+}
+
+// Close closes the wrapped producer
+func (p *Producer) Close() error {
+	return p.inner.Close()
+}
+
+// ProcessReliableAck delegates to the wrapped producer
+func (p *Producer) ProcessReliableAck(entry *telemetry.Record) {
+	p.inner.ProcessReliableAck(entry)
+}
+
+// ReportError delegates to the wrapped producer
+func (p *Producer) ReportError(message string, err error, logInfo logrus.LogInfo) {
+	p.inner.ReportError(message, err, logInfo)
+}
+
+// Unwrap returns the producer wrapped by the filter
+func (p *Producer) Unwrap() telemetry.Producer {
+	return p.inner
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.droppedFieldsCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "field_filter_dropped_fields_total",
+		Help:   "The number of fields dropped by field filters.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+
+	metricsRegistry.droppedRecordsCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "field_filter_dropped_records_total",
+		Help:   "The number of records not dispatched because every field was filtered.",
+		Labels: []string{"dispatcher", "record_type"},
+	})
+}
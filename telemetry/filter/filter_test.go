@@ -0,0 +1,151 @@
+package filter_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/filter"
+)
+
+type captureProducer struct {
+	produced []*telemetry.Record
+	acked    []*telemetry.Record
+}
+
+func (c *captureProducer) Produce(entry *telemetry.Record) {
+	c.produced = append(c.produced, entry)
+}
+
+func (c *captureProducer) Close() error { return nil }
+
+func (c *captureProducer) ProcessReliableAck(entry *telemetry.Record) {
+	c.acked = append(c.acked, entry)
+}
+
+func (c *captureProducer) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
+var _ = Describe("Field filter", func() {
+	var (
+		logger     *logrus.Logger
+		serializer *telemetry.BinarySerializer
+		inner      *captureProducer
+	)
+
+	stringDatum := func(field protos.Field, value string) *protos.Datum {
+		return &protos.Datum{Key: field, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: value}}}
+	}
+
+	newRecord := func(txType string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newProducer := func(rules map[string]*filter.Rule) *filter.Producer {
+		filters, err := filter.NewFieldFilters(rules)
+		Expect(err).NotTo(HaveOccurred())
+		return filter.NewProducer(telemetry.MQTT, inner, filters, metrics.NewCollector(nil, logger), logger)
+	}
+
+	payload := &protos.Payload{
+		Vin: "42",
+		Data: []*protos.Datum{
+			stringDatum(protos.Field_VehicleName, "cybertruck"),
+			stringDatum(protos.Field_Gear, "D"),
+			stringDatum(protos.Field_Soc, "80"),
+		},
+	}
+
+	dispatchedFields := func(record *telemetry.Record) []protos.Field {
+		data := &protos.Payload{}
+		Expect(proto.Unmarshal(record.Payload(), data)).To(Succeed())
+		var fields []protos.Field
+		for _, datum := range data.GetData() {
+			fields = append(fields, datum.GetKey())
+		}
+		return fields
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		serializer = telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{}, logger)
+		inner = &captureProducer{}
+	})
+
+	It("keeps only included fields", func() {
+		producer := newProducer(map[string]*filter.Rule{"V": {Include: []string{"Gear", "Soc"}}})
+		record := newRecord("V", payload)
+		producer.Produce(record)
+
+		Expect(inner.produced).To(HaveLen(1))
+		Expect(dispatchedFields(inner.produced[0])).To(Equal([]protos.Field{protos.Field_Gear, protos.Field_Soc}))
+		Expect(inner.produced[0].Txid).To(Equal("1234"))
+		Expect(dispatchedFields(record)).To(HaveLen(3))
+	})
+
+	It("drops excluded fields", func() {
+		producer := newProducer(map[string]*filter.Rule{"V": {Exclude: []string{"VehicleName"}}})
+		producer.Produce(newRecord("V", payload))
+
+		Expect(inner.produced).To(HaveLen(1))
+		Expect(dispatchedFields(inner.produced[0])).To(Equal([]protos.Field{protos.Field_Gear, protos.Field_Soc}))
+	})
+
+	It("passes through records without matching rule", func() {
+		producer := newProducer(map[string]*filter.Rule{"V": {Include: []string{"Gear"}}})
+		record := newRecord("alerts", &protos.VehicleAlerts{Vin: "42"})
+		producer.Produce(record)
+
+		Expect(inner.produced).To(Equal([]*telemetry.Record{record}))
+	})
+
+	It("acks records left without any field instead of producing them", func() {
+		producer := newProducer(map[string]*filter.Rule{"V": {Include: []string{"VehicleSpeed"}}})
+		record := newRecord("V", payload)
+		producer.Produce(record)
+
+		Expect(inner.produced).To(BeEmpty())
+		Expect(inner.acked).To(Equal([]*telemetry.Record{record}))
+	})
+
+	It("fails records which cannot be encoded once filtered", func() {
+		producer := newProducer(map[string]*filter.Rule{"V": {Exclude: []string{"VehicleName"}}})
+		var failed []*telemetry.Record
+		var failures []error
+		producer.SetDeliveryHandler(func(entry *telemetry.Record, err error) {
+			failed = append(failed, entry)
+			failures = append(failures, err)
+		})
+		record := newRecord("V", payload)
+		record.GetProtoMessage().(*protos.Payload).Data[1] = stringDatum(protos.Field_Gear, "\xff")
+		producer.Produce(record)
+
+		Expect(inner.produced).To(BeEmpty())
+		Expect(inner.acked).To(BeEmpty())
+		Expect(failed).To(Equal([]*telemetry.Record{record}))
+		Expect(failures[0]).To(HaveOccurred())
+	})
+
+	DescribeTable("rejects invalid rules",
+		func(rules map[string]*filter.Rule, errMessage string) {
+			_, err := filter.NewFieldFilters(rules)
+			Expect(err).To(MatchError(errMessage))
+		},
+		Entry("unsupported record type", map[string]*filter.Rule{"alerts": {Include: []string{"Gear"}}}, "field filters are only supported for V records, got: alerts"),
+		Entry("both include and exclude", map[string]*filter.Rule{"V": {Include: []string{"Gear"}, Exclude: []string{"Soc"}}}, "field filter for record V must set exactly one of include or exclude"),
+		Entry("neither include nor exclude", map[string]*filter.Rule{"V": {}}, "field filter for record V must set exactly one of include or exclude"),
+		Entry("unknown field", map[string]*filter.Rule{"V": {Include: []string{"NotAField"}}}, "unknown field in filter for record V: NotAField"),
+	)
+})
@@ -0,0 +1,227 @@
+package geofence
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// RecordType is the record type of the geofence events, dispatched with the rules of the "geofence" records
+	RecordType = "geofence"
+
+	// DefaultVehicleTTLMinutes is how long the geofences a vehicle without locations is inside are kept by default
+	DefaultVehicleTTLMinutes = 60
+)
+
+// Config of the geofences
+type Config struct {
+	// File is the GeoJSON FeatureCollection of the geofences
+	File string `json:"file"`
+
+	// VehicleTTLMinutes is how long the geofences a vehicle without locations is inside are kept, its next location
+	// inside a geofence then counts as an enter. Default: 60
+	VehicleTTLMinutes int `json:"vehicle_ttl_minutes,omitempty"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	eventCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Evaluator tracks whether each vehicle is inside the geofences, from the Location of the V records
+type Evaluator struct {
+	fences     []*fence
+	vehicleTTL time.Duration
+	logger     *logrus.Logger
+	now        func() time.Time
+
+	vehicles sync.Map
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// vehicle is the time a vehicle entered each geofence it is inside
+type vehicle struct {
+	mutex     sync.Mutex
+	enteredAt map[string]time.Time
+	seenAt    time.Time
+}
+
+// NewEvaluator loads the geofences of the config
+func NewEvaluator(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Evaluator, error) {
+	registerMetricsOnce(metricsCollector)
+
+	if config.VehicleTTLMinutes < 0 {
+		return nil, errors.New("geofence vehicle_ttl_minutes must not be negative")
+	}
+	vehicleTTLMinutes := config.VehicleTTLMinutes
+	if vehicleTTLMinutes == 0 {
+		vehicleTTLMinutes = DefaultVehicleTTLMinutes
+	}
+
+	fences, err := loadFences(config.File)
+	if err != nil {
+		return nil, err
+	}
+	evaluator := &Evaluator{
+		fences:     fences,
+		vehicleTTL: time.Duration(vehicleTTLMinutes) * time.Minute,
+		logger:     logger,
+		now:        time.Now,
+		done:       make(chan struct{}),
+	}
+	evaluator.wg.Add(1)
+	go evaluator.runEviction()
+	logger.ActivityLog("geofences_loaded", logrus.LogInfo{"file": config.File, "count": len(fences)})
+	return evaluator, nil
+}
+
+// Derive returns a record of the geofences the vehicle entered or exited, nil when there is none. Each event carries
+// the time the vehicle entered the geofence, and the time it exited it on exit.
+func (e *Evaluator) Derive(entry *telemetry.Record) *telemetry.Record {
+	payload, ok := entry.GetProtoMessage().(*protos.Payload)
+	if !ok || payload.GetIsResend() {
+		return nil
+	}
+	location := locationOf(payload)
+	if location == nil {
+		return nil
+	}
+
+	timestamp := time.Now()
+	if payload.GetCreatedAt() != nil {
+		timestamp = payload.GetCreatedAt().AsTime()
+	}
+	events := e.apply(entry.Vin, location, timestamp)
+	if len(events) == 0 {
+		return nil
+	}
+	record, err := entry.WithProtoMessage(&protos.VehicleGeofenceEvents{
+		Events:    events,
+		CreatedAt: payload.GetCreatedAt(),
+		Vin:       entry.Vin,
+	})
+	if err != nil {
+		e.logger.ErrorLog("geofence_encode_error", err, logrus.LogInfo{"vin": entry.Vin, "txid": entry.Txid})
+		return nil
+	}
+	record.TxType = RecordType
+	return record
+}
+
+// apply updates the geofences the vehicle is inside and returns the events of the ones it entered or exited
+func (e *Evaluator) apply(vin string, location *protos.LocationValue, timestamp time.Time) []*protos.GeofenceEvent {
+	loaded, found := e.vehicles.Load(vin)
+	if !found {
+		loaded, _ = e.vehicles.LoadOrStore(vin, &vehicle{enteredAt: make(map[string]time.Time)})
+	}
+	state := loaded.(*vehicle)
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.seenAt = e.now()
+
+	var events []*protos.GeofenceEvent
+	for _, fence := range e.fences {
+		enteredAt, wasInside := state.enteredAt[fence.name]
+		inside := fence.shape.contains(location.GetLatitude(), location.GetLongitude())
+		switch {
+		case inside && !wasInside:
+			state.enteredAt[fence.name] = timestamp
+			events = append(events, &protos.GeofenceEvent{
+				Geofence:   fence.name,
+				Transition: protos.GeofenceTransition_ENTER,
+				EnteredAt:  timestamppb.New(timestamp),
+				Latitude:   location.GetLatitude(),
+				Longitude:  location.GetLongitude(),
+			})
+			metricsRegistry.eventCount.Inc(map[string]string{"geofence": fence.name, "event": "enter"})
+		case !inside && wasInside:
+			delete(state.enteredAt, fence.name)
+			events = append(events, &protos.GeofenceEvent{
+				Geofence:   fence.name,
+				Transition: protos.GeofenceTransition_EXIT,
+				EnteredAt:  timestamppb.New(enteredAt),
+				ExitedAt:   timestamppb.New(timestamp),
+				Latitude:   location.GetLatitude(),
+				Longitude:  location.GetLongitude(),
+			})
+			metricsRegistry.eventCount.Inc(map[string]string{"geofence": fence.name, "event": "exit"})
+		}
+	}
+	return events
+}
+
+// runEviction forgets the vehicles without locations for the vehicle TTL
+func (e *Evaluator) runEviction() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(min(e.vehicleTTL, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.evict(e.now().Add(-e.vehicleTTL))
+		}
+	}
+}
+
+func (e *Evaluator) evict(before time.Time) {
+	e.vehicles.Range(func(vin, loaded interface{}) bool {
+		state := loaded.(*vehicle)
+		state.mutex.Lock()
+		expired := state.seenAt.Before(before)
+		state.mutex.Unlock()
+		if expired {
+			e.vehicles.Delete(vin)
+		}
+		return true
+	})
+}
+
+// Close stops the eviction
+func (e *Evaluator) Close() error {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+	return nil
+}
+
+// locationOf returns the location of the payload, nil when it has none
+func locationOf(payload *protos.Payload) *protos.LocationValue {
+	for _, datum := range payload.GetData() {
+		if datum.GetKey() == protos.Field_Location {
+			return datum.GetValue().GetLocationValue()
+		}
+	}
+	return nil
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.eventCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "geofence_events_total",
+		Help:   "The number of times vehicles entered or exited a geofence.",
+		Labels: []string{"geofence", "event"},
+	})
+}
@@ -0,0 +1,61 @@
+package geofence
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("Geofence eviction", func() {
+	var (
+		evaluator *Evaluator
+		clock     time.Time
+	)
+
+	apply := func() []protos.GeofenceTransition {
+		var transitions []protos.GeofenceTransition
+		for _, event := range evaluator.apply("42", &protos.LocationValue{Latitude: 37.418, Longitude: -122.148}, clock) {
+			transitions = append(transitions, event.GetTransition())
+		}
+		return transitions
+	}
+
+	BeforeEach(func() {
+		path := filepath.Join(GinkgoT().TempDir(), "geofences.json")
+		Expect(os.WriteFile(path, []byte(`{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "properties": {"name": "home", "radius": 150}, "geometry": {"type": "Point", "coordinates": [-122.148, 37.418]}}
+		]}`), 0o644)).To(Succeed())
+		logger, _ := logrus.NoOpLogger()
+		var err error
+		evaluator, err = NewEvaluator(&Config{File: path}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(evaluator.Close)
+		clock = time.Now()
+		evaluator.now = func() time.Time { return clock }
+	})
+
+	It("forgets the vehicles without locations for the vehicle TTL", func() {
+		Expect(apply()).To(Equal([]protos.GeofenceTransition{protos.GeofenceTransition_ENTER}))
+
+		clock = clock.Add(time.Minute)
+		evaluator.evict(clock.Add(-evaluator.vehicleTTL))
+		Expect(apply()).To(BeEmpty())
+
+		clock = clock.Add(evaluator.vehicleTTL + time.Minute)
+		evaluator.evict(clock.Add(-evaluator.vehicleTTL))
+		Expect(apply()).To(Equal([]protos.GeofenceTransition{protos.GeofenceTransition_ENTER}))
+	})
+
+	It("rejects a negative vehicle TTL", func() {
+		logger, _ := logrus.NoOpLogger()
+		_, err := NewEvaluator(&Config{VehicleTTLMinutes: -1}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).To(MatchError("geofence vehicle_ttl_minutes must not be negative"))
+	})
+})
@@ -0,0 +1,13 @@
+package geofence_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGeofence(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Geofence Suite")
+}
@@ -0,0 +1,156 @@
+package geofence_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/geofence"
+)
+
+const testGeofences = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"properties": {"name": "depot"},
+			"geometry": {
+				"type": "Polygon",
+				"coordinates": [
+					[[-122.15, 37.41], [-122.14, 37.41], [-122.14, 37.42], [-122.15, 37.42], [-122.15, 37.41]],
+					[[-122.146, 37.414], [-122.144, 37.414], [-122.144, 37.416], [-122.146, 37.416], [-122.146, 37.414]]
+				]
+			}
+		},
+		{
+			"type": "Feature",
+			"id": "charger",
+			"properties": {"radius": 100},
+			"geometry": {"type": "Point", "coordinates": [-122.148, 37.418]}
+		}
+	]
+}`
+
+var _ = Describe("Geofence evaluator", func() {
+	var (
+		logger *logrus.Logger
+		dir    string
+		start  time.Time
+	)
+
+	writeGeofences := func(content string) string {
+		path := filepath.Join(dir, "geofences.json")
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		return path
+	}
+
+	newEvaluator := func() *geofence.Evaluator {
+		evaluator, err := geofence.NewEvaluator(&geofence.Config{File: writeGeofences(testGeofences)}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		return evaluator
+	}
+
+	newLocationRecord := func(vin string, createdAt time.Time, latitude, longitude float64) *telemetry.Record {
+		payload, err := proto.Marshal(&protos.Payload{Vin: vin, CreatedAt: timestamppb.New(createdAt), Data: []*protos.Datum{
+			{Key: protos.Field_Location, Value: &protos.Value{Value: &protos.Value_LocationValue{LocationValue: &protos.LocationValue{Latitude: latitude, Longitude: longitude}}}},
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte("V"), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	events := func(record *telemetry.Record) []*protos.GeofenceEvent {
+		Expect(record).NotTo(BeNil())
+		Expect(record.TxType).To(Equal(geofence.RecordType))
+		message := &protos.VehicleGeofenceEvents{}
+		Expect(proto.Unmarshal(record.Payload(), message)).To(Succeed())
+		Expect(message.GetVin()).To(Equal(record.Vin))
+		return message.GetEvents()
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		dir = GinkgoT().TempDir()
+		start = time.Now().Add(-time.Hour).Truncate(time.Second)
+	})
+
+	It("emits enter and exit events", func() {
+		evaluator := newEvaluator()
+		Expect(evaluator.Derive(newLocationRecord("42", start, 37.40, -122.145))).To(BeNil())
+
+		entered := events(evaluator.Derive(newLocationRecord("42", start.Add(time.Minute), 37.412, -122.148)))
+		Expect(entered).To(HaveLen(1))
+		Expect(entered[0].GetGeofence()).To(Equal("depot"))
+		Expect(entered[0].GetTransition()).To(Equal(protos.GeofenceTransition_ENTER))
+		Expect(entered[0].GetEnteredAt().AsTime()).To(Equal(start.Add(time.Minute).UTC()))
+		Expect(entered[0].GetExitedAt()).To(BeNil())
+		Expect(entered[0].GetLatitude()).To(Equal(37.412))
+		Expect(entered[0].GetLongitude()).To(Equal(-122.148))
+
+		Expect(evaluator.Derive(newLocationRecord("42", start.Add(2*time.Minute), 37.413, -122.148))).To(BeNil())
+
+		exited := events(evaluator.Derive(newLocationRecord("42", start.Add(3*time.Minute), 37.40, -122.145)))
+		Expect(exited).To(HaveLen(1))
+		Expect(exited[0].GetGeofence()).To(Equal("depot"))
+		Expect(exited[0].GetTransition()).To(Equal(protos.GeofenceTransition_EXIT))
+		Expect(exited[0].GetEnteredAt().AsTime()).To(Equal(start.Add(time.Minute).UTC()))
+		Expect(exited[0].GetExitedAt().AsTime()).To(Equal(start.Add(3 * time.Minute).UTC()))
+	})
+
+	It("excludes the holes of polygons", func() {
+		evaluator := newEvaluator()
+		Expect(evaluator.Derive(newLocationRecord("42", start, 37.415, -122.145))).To(BeNil())
+	})
+
+	It("evaluates circles and overlapping geofences", func() {
+		evaluator := newEvaluator()
+		entered := events(evaluator.Derive(newLocationRecord("42", start, 37.4185, -122.1485)))
+		Expect(entered).To(HaveLen(2))
+		Expect(entered[0].GetGeofence()).To(Equal("depot"))
+		Expect(entered[1].GetGeofence()).To(Equal("charger"))
+
+		exited := events(evaluator.Derive(newLocationRecord("42", start.Add(time.Minute), 37.412, -122.141)))
+		Expect(exited).To(HaveLen(1))
+		Expect(exited[0].GetGeofence()).To(Equal("charger"))
+		Expect(exited[0].GetTransition()).To(Equal(protos.GeofenceTransition_EXIT))
+	})
+
+	It("tracks each vehicle separately", func() {
+		evaluator := newEvaluator()
+		Expect(events(evaluator.Derive(newLocationRecord("42", start, 37.412, -122.148)))).To(HaveLen(1))
+		Expect(events(evaluator.Derive(newLocationRecord("43", start, 37.412, -122.148)))).To(HaveLen(1))
+	})
+
+	It("ignores records without location", func() {
+		evaluator := newEvaluator()
+		Expect(evaluator.Derive(&telemetry.Record{TxType: "alerts"})).To(BeNil())
+	})
+
+	DescribeTable("rejects invalid files",
+		func(content string, expected string) {
+			_, err := geofence.NewEvaluator(&geofence.Config{File: writeGeofences(content)}, metrics.NewCollector(nil, logger), logger)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("not a collection", `{"type": "Feature"}`, "expected a FeatureCollection"),
+		Entry("unnamed feature", `{"type": "FeatureCollection", "features": [{"geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"radius": 1}}]}`, "geofence feature 0 has no name"),
+		Entry("circle without radius", `{"type": "FeatureCollection", "features": [{"id": "a", "geometry": {"type": "Point", "coordinates": [0, 0]}}]}`, "invalid geofence a: a Point needs a positive radius property in meters"),
+		Entry("unsupported geometry", `{"type": "FeatureCollection", "features": [{"id": "a", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}}]}`, "invalid geofence a: unsupported geometry: LineString"),
+		Entry("duplicate name", `{"type": "FeatureCollection", "features": [{"id": "a", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"radius": 1}}, {"id": "a", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"radius": 1}}]}`, "duplicate geofence: a"),
+	)
+})
@@ -0,0 +1,203 @@
+package geofence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// earthRadiusMeters is the mean radius of the earth used for the distance to the center of circles
+const earthRadiusMeters = 6371008.8
+
+// shape is the area of a geofence
+type shape interface {
+	contains(latitude, longitude float64) bool
+}
+
+// fence is a named area loaded from the GeoJSON file
+type fence struct {
+	name  string
+	shape shape
+}
+
+type featureCollection struct {
+	Type     string     `json:"type"`
+	Features []*feature `json:"features"`
+}
+
+type feature struct {
+	ID         interface{}            `json:"id"`
+	Geometry   *geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// loadFences reads the features of a GeoJSON FeatureCollection. Polygons and MultiPolygons are used as is, Points
+// with a "radius" property in meters are circles. Each feature is named by its "name" property, or else its id.
+func loadFences(path string) ([]*fence, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	collection := &featureCollection{}
+	if err := json.Unmarshal(data, collection); err != nil {
+		return nil, fmt.Errorf("invalid geofence file %s: %w", path, err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("invalid geofence file %s: expected a FeatureCollection", path)
+	}
+
+	fences := make([]*fence, 0, len(collection.Features))
+	names := make(map[string]struct{}, len(collection.Features))
+	for i, feature := range collection.Features {
+		name := feature.name()
+		if name == "" {
+			return nil, fmt.Errorf("geofence feature %d has no name", i)
+		}
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("duplicate geofence: %s", name)
+		}
+		names[name] = struct{}{}
+
+		shape, err := feature.shape()
+		if err != nil {
+			return nil, fmt.Errorf("invalid geofence %s: %w", name, err)
+		}
+		fences = append(fences, &fence{name: name, shape: shape})
+	}
+	return fences, nil
+}
+
+func (f *feature) name() string {
+	if name, ok := f.Properties["name"].(string); ok && name != "" {
+		return name
+	}
+	if f.ID != nil {
+		return fmt.Sprint(f.ID)
+	}
+	return ""
+}
+
+func (f *feature) shape() (shape, error) {
+	if f.Geometry == nil {
+		return nil, errors.New("missing geometry")
+	}
+	switch f.Geometry.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &rings); err != nil {
+			return nil, err
+		}
+		return newPolygon(rings)
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+		shapes := make(multiPolygon, 0, len(polygons))
+		for _, rings := range polygons {
+			polygon, err := newPolygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, polygon)
+		}
+		return shapes, nil
+	case "Point":
+		var position []float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &position); err != nil {
+			return nil, err
+		}
+		radius, ok := f.Properties["radius"].(float64)
+		if !ok || radius <= 0 || len(position) < 2 {
+			return nil, errors.New("a Point needs a positive radius property in meters")
+		}
+		return &circle{longitude: position[0], latitude: position[1], radius: radius}, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry: %s", f.Geometry.Type)
+	}
+}
+
+// polygon is an outer ring and its holes, as [longitude, latitude] positions
+type polygon struct {
+	rings [][][]float64
+}
+
+func newPolygon(rings [][][]float64) (*polygon, error) {
+	if len(rings) == 0 {
+		return nil, errors.New("a Polygon needs at least one ring")
+	}
+	for _, ring := range rings {
+		if len(ring) < 4 {
+			return nil, errors.New("a Polygon ring needs at least 4 positions")
+		}
+		for _, position := range ring {
+			if len(position) < 2 {
+				return nil, errors.New("a position needs a longitude and a latitude")
+			}
+		}
+	}
+	return &polygon{rings: rings}, nil
+}
+
+func (p *polygon) contains(latitude, longitude float64) bool {
+	if !ringContains(p.rings[0], latitude, longitude) {
+		return false
+	}
+	for _, hole := range p.rings[1:] {
+		if ringContains(hole, latitude, longitude) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains casts a ray from the position and counts the edges of the ring it crosses
+func ringContains(ring [][]float64, latitude, longitude float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		longitudeI, latitudeI := ring[i][0], ring[i][1]
+		longitudeJ, latitudeJ := ring[j][0], ring[j][1]
+		if (latitudeI > latitude) != (latitudeJ > latitude) &&
+			longitude < (longitudeJ-longitudeI)*(latitude-latitudeI)/(latitudeJ-latitudeI)+longitudeI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+type multiPolygon []*polygon
+
+func (m multiPolygon) contains(latitude, longitude float64) bool {
+	for _, polygon := range m {
+		if polygon.contains(latitude, longitude) {
+			return true
+		}
+	}
+	return false
+}
+
+// circle is the area within radius meters of a center
+type circle struct {
+	latitude, longitude float64
+	radius              float64
+}
+
+func (c *circle) contains(latitude, longitude float64) bool {
+	return haversine(c.latitude, c.longitude, latitude, longitude) <= c.radius
+}
+
+// haversine returns the great-circle distance in meters between two positions
+func haversine(latitude1, longitude1, latitude2, longitude2 float64) float64 {
+	phi1, phi2 := latitude1*math.Pi/180, latitude2*math.Pi/180
+	deltaPhi := (latitude2 - latitude1) * math.Pi / 180
+	deltaLambda := (longitude2 - longitude1) * math.Pi / 180
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
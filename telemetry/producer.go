@@ -1,9 +1,12 @@
 package telemetry
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/protos"
 )
 
 // Dispatcher type of telemetry record dispatcher
@@ -24,8 +27,36 @@ const (
 	MQTT Dispatcher = "mqtt"
 	// NATS registers a NATS dispatcher
 	NATS Dispatcher = "nats"
+	// Webhook registers an HTTP webhook dispatcher
+	Webhook Dispatcher = "webhook"
+	// File registers a dispatcher writing records to local files
+	File Dispatcher = "file"
+	// Parquet registers a dispatcher writing V records to parquet files
+	Parquet Dispatcher = "parquet"
+	// Stream registers a dispatcher feeding the SSE and websocket stream endpoints
+	Stream Dispatcher = "stream"
 )
 
+// dispatcherInstanceSeparator separates the type of a dispatcher from the name of one of its instances
+const dispatcherInstanceSeparator = ":"
+
+// Type returns the type of a named dispatcher instance, ex.: kafka for "kafka:analytics". Other dispatchers are
+// their own type.
+func (d Dispatcher) Type() Dispatcher {
+	dispatcherType, _, _ := strings.Cut(string(d), dispatcherInstanceSeparator)
+	return Dispatcher(dispatcherType)
+}
+
+// Instance returns the name of a named dispatcher instance, ex.: analytics for "kafka:analytics", empty otherwise
+func (d Dispatcher) Instance() string {
+	_, instance, _ := strings.Cut(string(d), dispatcherInstanceSeparator)
+	return instance
+}
+
+// ErrRecordRejected is reported to a DeliveryHandler when a producer permanently refuses a record
+// (ex.: unsupported record type or missing destination), so retrying the record would not help
+var ErrRecordRejected = errors.New("record rejected by producer")
+
 // BuildTopicName creates a topic from a namespace and a recordName
 func BuildTopicName(namespace, recordName string) string {
 	return fmt.Sprintf("%s_%s", namespace, recordName)
@@ -38,3 +69,58 @@ type Producer interface {
 	ProcessReliableAck(entry *Record)
 	ReportError(message string, err error, logInfo logrus.LogInfo)
 }
+
+// Ack is a record a producer delivered, sent on the ack channel of the producers configured for reliable acks
+type Ack struct {
+	Record     *Record
+	Dispatcher Dispatcher
+}
+
+// Observer is notified of every record dispatched by a serializer, whichever producers the record goes to
+type Observer interface {
+	Observe(entry *Record)
+}
+
+// PayloadTransformer transforms the payload of the V records received by a serializer, before they are dispatched
+type PayloadTransformer interface {
+	// TransformPayload modifies the payload in place and returns whether it changed
+	TransformPayload(payload *protos.Payload) bool
+}
+
+// Deriver computes a record from a record dispatched by a serializer, dispatched right after it to the producers
+// of its own record type
+type Deriver interface {
+	// Derive returns the derived record, nil when there is none
+	Derive(entry *Record) *Record
+}
+
+// Segmenter assigns the records dispatched by a serializer to sessions, before they are produced
+type Segmenter interface {
+	// Segment sets the TripID of the record and returns the summary of the session it closed, nil when there is none
+	Segment(entry *Record) *Record
+}
+
+// DeliveryHandler is notified with the outcome of a record a producer attempted to deliver, err is nil on success
+type DeliveryHandler func(entry *Record, err error)
+
+// DeliveryReporter is implemented by producers which can report per-record delivery outcomes
+type DeliveryReporter interface {
+	SetDeliveryHandler(handler DeliveryHandler)
+}
+
+// DeliveryNotifier can be embedded by producers to implement DeliveryReporter
+type DeliveryNotifier struct {
+	handler DeliveryHandler
+}
+
+// SetDeliveryHandler registers the handler notified by NotifyDelivery. It must be called before the producer is used.
+func (n *DeliveryNotifier) SetDeliveryHandler(handler DeliveryHandler) {
+	n.handler = handler
+}
+
+// NotifyDelivery reports the outcome of a record to the registered handler, if any
+func (n *DeliveryNotifier) NotifyDelivery(entry *Record, err error) {
+	if n.handler != nil {
+		n.handler(entry, err)
+	}
+}
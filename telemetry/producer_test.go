@@ -12,4 +12,14 @@ var _ = Describe("Test dispatcher", func() {
 	It("builds topic", func() {
 		Expect(telemetry.BuildTopicName("some_namespace", "test_device")).To(Equal("some_namespace_test_device"))
 	})
+
+	DescribeTable("splits named instances",
+		func(dispatcher telemetry.Dispatcher, expectedType telemetry.Dispatcher, expectedInstance string) {
+			Expect(dispatcher.Type()).To(Equal(expectedType))
+			Expect(dispatcher.Instance()).To(Equal(expectedInstance))
+		},
+		Entry("default instance", telemetry.Kafka, telemetry.Kafka, ""),
+		Entry("named instance", telemetry.Dispatcher("kafka:analytics"), telemetry.Kafka, "analytics"),
+		Entry("instance without name", telemetry.Dispatcher("mqtt:"), telemetry.MQTT, ""),
+	)
 })
@@ -46,6 +46,7 @@ type Record struct {
 	Vin                    string
 	PayloadBytes           []byte
 	RawBytes               []byte
+	Duplicate              bool
 	transmitDecodedRecords bool
 	protoMessage           proto.Message
 }
@@ -86,6 +87,12 @@ func (record *Record) Metadata() map[string]string {
 	metadata["txtype"] = record.TxType
 	metadata["version"] = fmt.Sprint(record.Version)
 	metadata["device_client_version"] = record.DeviceClientVersion
+	if record.Duplicate {
+		metadata["duplicate"] = "true"
+	}
+	if record.TripID != "" {
+		metadata["tripid"] = record.TripID
+	}
 	return metadata
 }
 
@@ -144,6 +151,8 @@ func (record *Record) SignalsCount() int {
 		return len(payload.GetData())
 	case *protos.VehicleAlerts:
 		return len(payload.GetAlerts())
+	case *protos.VehicleMetrics:
+		return len(payload.GetMetrics())
 	default:
 		return 0
 	}
@@ -184,6 +193,16 @@ func (record *Record) applyProtoRecordTransforms() error {
 		record.PayloadBytes, err = proto.Marshal(message)
 		record.protoMessage = message
 		return err
+	case "metrics":
+		message := &protos.VehicleMetrics{}
+		err := proto.Unmarshal(record.Payload(), message)
+		if err != nil {
+			return err
+		}
+		message.Vin = record.Vin
+		record.PayloadBytes, err = proto.Marshal(message)
+		record.protoMessage = message
+		return err
 	case "connectivity":
 		message := &protos.VehicleConnectivity{}
 		err := proto.Unmarshal(record.Payload(), message)
@@ -227,6 +246,9 @@ func (record *Record) applyRecordTransforms() error {
 	if err = record.applyProtoRecordTransforms(); err != nil {
 		return err
 	}
+	if err = record.applyPayloadTransformer(); err != nil {
+		return err
+	}
 	if !record.transmitDecodedRecords {
 		return nil
 	}
@@ -234,6 +256,77 @@ func (record *Record) applyRecordTransforms() error {
 	return err
 }
 
+// applyPayloadTransformer applies the payload transformer of the serializer, if any, to V records
+func (record *Record) applyPayloadTransformer() error {
+	payload, ok := record.protoMessage.(*protos.Payload)
+	if !ok || record.Serializer == nil || record.Serializer.transformer == nil {
+		return nil
+	}
+	if !record.Serializer.transformer.TransformPayload(payload) {
+		return nil
+	}
+	var err error
+	record.PayloadBytes, err = proto.Marshal(payload)
+	return err
+}
+
+// Restore reattaches the decoded proto message to a record rebuilt from persisted fields (ex.: by a
+// producer spool), so it can be produced again like a freshly received record
+func (record *Record) Restore(protoBytes []byte, transmitDecodedRecords bool) error {
+	record.transmitDecodedRecords = transmitDecodedRecords
+	message := NewProtoMessage(record.TxType)
+	if message == nil || protoBytes == nil {
+		return nil
+	}
+	if err := proto.Unmarshal(protoBytes, message); err != nil {
+		return err
+	}
+	record.protoMessage = message
+	return nil
+}
+
+// NewProtoMessage returns an empty proto message of the record type, nil if the type is not decoded
+func NewProtoMessage(txType string) proto.Message {
+	switch txType {
+	case "alerts":
+		return &protos.VehicleAlerts{}
+	case "geofence":
+		return &protos.VehicleGeofenceEvents{}
+	case "errors":
+		return &protos.VehicleErrors{}
+	case "deadletter":
+		return &protos.VehicleDeadLetter{}
+	case "V":
+		return &protos.Payload{}
+	case "metrics":
+		return &protos.VehicleMetrics{}
+	case "alert_lifecycle":
+		return &protos.VehicleAlertLifecycle{}
+	case "session":
+		return &protos.VehicleSession{}
+	case "derived":
+		return &protos.VehicleDerivedSignals{}
+	case "connectivity":
+		return &protos.VehicleConnectivity{}
+	default:
+		return nil
+	}
+}
+
+// WithProtoMessage returns a copy of the record carrying message instead of its decoded payload.
+// PayloadBytes is re-encoded the same way as the original record, proto or JSON.
+func (record *Record) WithProtoMessage(message proto.Message) (*Record, error) {
+	clone := *record
+	clone.protoMessage = message
+	var err error
+	if clone.transmitDecodedRecords {
+		clone.PayloadBytes, err = clone.toJSON()
+	} else {
+		clone.PayloadBytes, err = proto.Marshal(message)
+	}
+	return &clone, err
+}
+
 // GetProtoMessage gets extracted protobuf message
 func (record *Record) GetProtoMessage() proto.Message {
 	return record.protoMessage
@@ -16,6 +16,7 @@ import (
 	"github.com/teslamotors/fleet-telemetry/messages"
 	"github.com/teslamotors/fleet-telemetry/protos"
 	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
 )
 
 var _ = Describe("Socket handler test", func() {
@@ -153,6 +154,28 @@ var _ = Describe("Socket handler test", func() {
 		Expect(second.Key).To(Equal(protos.Field_VehicleName))
 	})
 
+	It("applies the payload transformer of the serializer", func() {
+		converter, err := units.NewConverter(&units.Config{System: units.SI})
+		Expect(err).NotTo(HaveOccurred())
+		serializer.SetPayloadTransformer(converter)
+
+		speed := stringDatum(protos.Field_VehicleSpeed, "10")
+		message := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte("V"), Payload: generatePayload("cybertruck", "42", nil, speed)}
+		recordMsg, err := message.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+
+		data := &protos.Payload{}
+		err = proto.Unmarshal(record.Payload(), data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data.Data).To(HaveLen(2))
+		Expect(data.Data[1].Key).To(Equal(protos.Field_VehicleSpeed))
+		Expect(data.Data[1].Value.GetStringValue()).To(Equal("16.09344"))
+		Expect(proto.Equal(record.GetProtoMessage(), data)).To(BeTrue())
+	})
+
 	DescribeTable("number formatting fixes",
 		func(in string, expected string) {
 			brakePedalPos := stringDatum(protos.Field_BrakePedalPos, in)
@@ -302,6 +325,13 @@ var _ = Describe("Socket handler test", func() {
 				}
 				return myMsg.GetVin() == "testConnectivityVin"
 			}),
+			Entry("for txType metrics", "metrics", "testMetricsVin", &protos.VehicleMetrics{Vin: "testMetricsVin"}, func(msg proto.Message) bool {
+				myMsg, ok := msg.(*protos.VehicleMetrics)
+				if !ok {
+					return false
+				}
+				return myMsg.GetVin() == "testMetricsVin"
+			}),
 			Entry("for txType V", "V", "testPayloadVIN", &protos.Payload{Vin: "testPayloadVIN"}, func(msg proto.Message) bool {
 				myMsg, ok := msg.(*protos.Payload)
 				if !ok {
@@ -406,6 +436,71 @@ var _ = Describe("Socket handler test", func() {
 		})
 	})
 
+	Describe("metrics record", func() {
+		It("stamps the vin and counts signals", func() {
+			metrics := &protos.VehicleMetrics{
+				Vin: "spoofedVin",
+				Metrics: []*protos.Metric{
+					{Name: "metric1", Tags: map[string]string{"tag": "a"}, Value: 1.5},
+					{Name: "metric2", Value: 2},
+				},
+			}
+			payloadBytes, err := proto.Marshal(metrics)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte("metrics"), Payload: payloadBytes}
+			recordMsg, err := message.ToBytes()
+			Expect(err).NotTo(HaveOccurred())
+
+			record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record.SignalsCount()).To(Equal(2))
+
+			data := &protos.VehicleMetrics{}
+			Expect(proto.Unmarshal(record.Payload(), data)).To(Succeed())
+			Expect(data.GetVin()).To(Equal("42"))
+
+			jsonPayload, err := record.GetJSONPayload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(jsonPayload)).To(MatchJSON(`{"metrics":[{"name":"metric1","tags":{"tag":"a"},"value":1.5},{"name":"metric2","tags":{},"value":2}],"createdAt":null,"vin":"42"}`))
+		})
+	})
+
+	Describe("WithProtoMessage", func() {
+		DescribeTable("re-encodes the payload of a copy",
+			func(transmitDecodedRecords bool) {
+				message := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte("V"), Payload: generatePayload("cybertruck", "42", nil, stringDatum(protos.Field_Gear, "D"))}
+				recordMsg, err := message.ToBytes()
+				Expect(err).NotTo(HaveOccurred())
+
+				record, err := telemetry.NewRecord(serializer, recordMsg, "1", transmitDecodedRecords)
+				Expect(err).NotTo(HaveOccurred())
+				originalPayload := record.Payload()
+
+				replacement := &protos.Payload{Vin: "42", Data: []*protos.Datum{stringDatum(protos.Field_Gear, "D")}}
+				clone, err := record.WithProtoMessage(replacement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(clone.Txid).To(Equal(record.Txid))
+				Expect(clone.Serializer).To(Equal(record.Serializer))
+				Expect(clone.GetProtoMessage()).To(Equal(replacement))
+				Expect(record.Payload()).To(Equal(originalPayload))
+
+				jsonPayload, err := clone.GetJSONPayload()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(jsonPayload)).To(MatchJSON(`{"data":[{"key":"Gear","value":{"stringValue":"D"}}],"isResend":false,"createdAt":null,"vin":"42"}`))
+				if transmitDecodedRecords {
+					Expect(clone.Payload()).To(Equal(jsonPayload))
+				} else {
+					data := &protos.Payload{}
+					Expect(proto.Unmarshal(clone.Payload(), data)).To(Succeed())
+					Expect(data.GetData()).To(HaveLen(1))
+				}
+			},
+			Entry("as proto", false),
+			Entry("as json", true),
+		)
+	})
+
 	Describe("json record", func() {
 		It("outputs json with all data", func() {
 			message := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device.42"), MessageTopic: []byte("V"), Payload: generatePayload("cybertruck", "42", nil)}
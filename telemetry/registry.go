@@ -0,0 +1,127 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/server/airbrake"
+)
+
+// ProducerParams are the settings shared by the producers of every dispatcher
+type ProducerParams struct {
+	// Dispatcher is the name of the dispatcher instance, ex.: "kafka:analytics", labeling the metrics of the producer
+	Dispatcher Dispatcher
+
+	// Namespace is the prefix of the topics or subjects of the dispatcher
+	Namespace string
+
+	// RecordTypes are the record types dispatched to the producer
+	RecordTypes []string
+
+	// PrometheusEnabled is set when the metrics are served to prometheus
+	PrometheusEnabled bool
+
+	MetricsCollector metrics.MetricCollector
+	AirbrakeHandler  *airbrake.Handler
+
+	// AckChan receives the acks of the records the producer delivered, for the record types in ReliableAckTxTypes
+	AckChan            chan (*Ack)
+	ReliableAckTxTypes map[string]interface{}
+
+	Logger *logrus.Logger
+}
+
+// ProducerFactory builds the producer of a dispatcher from its raw JSON config, nil when the dispatcher is not
+// configured. The factory of a dispatcher type also builds its named instances (ex.: "kafka:analytics").
+type ProducerFactory func(config json.RawMessage, params *ProducerParams) (Producer, error)
+
+// ProducerFactoryOption describes the producers built by a factory
+type ProducerFactoryOption func(*producerRegistration)
+
+// IgnoresRecordTypes declares that the producers of a dispatcher do not depend on ProducerParams.RecordTypes, so they
+// are kept on a config reload which only changes the records dispatched to them
+func IgnoresRecordTypes() ProducerFactoryOption {
+	return func(registration *producerRegistration) {
+		registration.ignoresRecordTypes = func(json.RawMessage) bool { return true }
+	}
+}
+
+// IgnoresRecordTypesUnless is IgnoresRecordTypes for the producers whose config does not satisfy
+// dependsOnRecordTypes, ex.: kafka producers only depend on their record types to register their schemas
+func IgnoresRecordTypesUnless(dependsOnRecordTypes func(config json.RawMessage) bool) ProducerFactoryOption {
+	return func(registration *producerRegistration) {
+		registration.ignoresRecordTypes = func(config json.RawMessage) bool { return !dependsOnRecordTypes(config) }
+	}
+}
+
+// producerRegistration is a registered factory and the options it was registered with
+type producerRegistration struct {
+	factory            ProducerFactory
+	ignoresRecordTypes func(config json.RawMessage) bool
+}
+
+var (
+	producerFactoriesLock sync.RWMutex
+	producerFactories     = make(map[Dispatcher]*producerRegistration)
+)
+
+// RegisterProducerFactory makes a dispatcher available to the records of the config, usually from the init function
+// of the package implementing it. It panics when the dispatcher is already registered or names an instance.
+func RegisterProducerFactory(dispatcher Dispatcher, factory ProducerFactory, options ...ProducerFactoryOption) {
+	producerFactoriesLock.Lock()
+	defer producerFactoriesLock.Unlock()
+
+	if dispatcher != dispatcher.Type() {
+		panic(fmt.Sprintf("producer factory registered for a dispatcher instance: %s", dispatcher))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("nil producer factory registered for dispatcher: %s", dispatcher))
+	}
+	if _, ok := producerFactories[dispatcher]; ok {
+		panic(fmt.Sprintf("producer factory already registered for dispatcher: %s", dispatcher))
+	}
+	registration := &producerRegistration{factory: factory}
+	for _, option := range options {
+		option(registration)
+	}
+	producerFactories[dispatcher] = registration
+}
+
+// LookupProducerFactory returns the factory registered for the type of a dispatcher
+func LookupProducerFactory(dispatcher Dispatcher) (ProducerFactory, bool) {
+	producerFactoriesLock.RLock()
+	defer producerFactoriesLock.RUnlock()
+
+	registration, ok := producerFactories[dispatcher.Type()]
+	if !ok {
+		return nil, false
+	}
+	return registration.factory, true
+}
+
+// ProducerIgnoresRecordTypes returns whether the producer built from the config of a dispatcher ignores its record
+// types, see IgnoresRecordTypes
+func ProducerIgnoresRecordTypes(dispatcher Dispatcher, config json.RawMessage) bool {
+	producerFactoriesLock.RLock()
+	defer producerFactoriesLock.RUnlock()
+
+	registration, ok := producerFactories[dispatcher.Type()]
+	return ok && registration.ignoresRecordTypes != nil && registration.ignoresRecordTypes(config)
+}
+
+// RegisteredDispatchers returns the dispatchers with a registered factory, sorted by name
+func RegisteredDispatchers() []Dispatcher {
+	producerFactoriesLock.RLock()
+	defer producerFactoriesLock.RUnlock()
+
+	dispatchers := make([]Dispatcher, 0, len(producerFactories))
+	for dispatcher := range producerFactories {
+		dispatchers = append(dispatchers, dispatcher)
+	}
+	sort.Slice(dispatchers, func(i, j int) bool { return dispatchers[i] < dispatchers[j] })
+	return dispatchers
+}
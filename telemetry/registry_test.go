@@ -0,0 +1,81 @@
+package telemetry_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+var _ = Describe("Producer factories", func() {
+	var factory telemetry.ProducerFactory
+
+	BeforeEach(func() {
+		factory = func(_ json.RawMessage, _ *telemetry.ProducerParams) (telemetry.Producer, error) {
+			return &CallbackTester{}, nil
+		}
+	})
+
+	It("looks up a registered factory", func() {
+		telemetry.RegisterProducerFactory("registry_lookup", factory)
+
+		registered, ok := telemetry.LookupProducerFactory("registry_lookup")
+		Expect(ok).To(BeTrue())
+		producer, err := registered(nil, &telemetry.ProducerParams{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer).To(BeAssignableToTypeOf(&CallbackTester{}))
+		Expect(telemetry.RegisteredDispatchers()).To(ContainElement(telemetry.Dispatcher("registry_lookup")))
+	})
+
+	It("does not find an unregistered factory", func() {
+		_, ok := telemetry.LookupProducerFactory("registry_unregistered")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("panics when a dispatcher is registered twice", func() {
+		telemetry.RegisterProducerFactory("registry_twice", factory)
+		Expect(func() { telemetry.RegisterProducerFactory("registry_twice", factory) }).To(PanicWith("producer factory already registered for dispatcher: registry_twice"))
+	})
+
+	It("looks up the factory of a named instance by its type", func() {
+		telemetry.RegisterProducerFactory("registry_instance", factory)
+
+		_, ok := telemetry.LookupProducerFactory("registry_instance:partner")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("records whether the producers ignore their record types", func() {
+		telemetry.RegisterProducerFactory("registry_records", factory)
+		telemetry.RegisterProducerFactory("registry_ignores_records", factory, telemetry.IgnoresRecordTypes())
+		telemetry.RegisterProducerFactory("registry_ignores_records_unless", factory, telemetry.IgnoresRecordTypesUnless(func(config json.RawMessage) bool {
+			return string(config) == `{"per_record": true}`
+		}))
+
+		Expect(telemetry.ProducerIgnoresRecordTypes("registry_records", nil)).To(BeFalse())
+		Expect(telemetry.ProducerIgnoresRecordTypes("registry_ignores_records", nil)).To(BeTrue())
+		Expect(telemetry.ProducerIgnoresRecordTypes("registry_ignores_records:partner", nil)).To(BeTrue())
+		Expect(telemetry.ProducerIgnoresRecordTypes("registry_ignores_records_unless", json.RawMessage(`{}`))).To(BeTrue())
+		Expect(telemetry.ProducerIgnoresRecordTypes("registry_ignores_records_unless", json.RawMessage(`{"per_record": true}`))).To(BeFalse())
+		Expect(telemetry.ProducerIgnoresRecordTypes("registry_unregistered", nil)).To(BeFalse())
+	})
+
+	It("panics when a named instance is registered", func() {
+		Expect(func() { telemetry.RegisterProducerFactory("registry_named:partner", factory) }).To(PanicWith("producer factory registered for a dispatcher instance: registry_named:partner"))
+	})
+
+	It("panics when the factory is nil", func() {
+		Expect(func() { telemetry.RegisterProducerFactory("registry_nil", nil) }).To(PanicWith("nil producer factory registered for dispatcher: registry_nil"))
+	})
+
+	It("sorts the registered dispatchers", func() {
+		telemetry.RegisterProducerFactory("registry_sorted_b", factory)
+		telemetry.RegisterProducerFactory("registry_sorted_a", factory)
+
+		dispatchers := telemetry.RegisteredDispatchers()
+		for i := 1; i < len(dispatchers); i++ {
+			Expect(dispatchers[i-1] < dispatchers[i]).To(BeTrue())
+		}
+	})
+})
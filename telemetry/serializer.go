@@ -19,11 +19,17 @@ type RequestIdentity struct {
 
 // BinarySerializer serializes records
 type BinarySerializer struct {
+	// DispatchRules are the rules the serializer was created with, see CurrentDispatchRules
 	DispatchRules   map[string][]Producer
 	RequestIdentity *RequestIdentity
 
 	logger            *logrus.Logger
 	vinMismatchLogged atomic.Bool
+	reloadedRules     atomic.Pointer[map[string][]Producer]
+	observer          Observer
+	transformer       PayloadTransformer
+	derivers          []Deriver
+	segmenter         Segmenter
 }
 
 // NewBinarySerializer returns a dedicated serializer for a current socket connection
@@ -62,7 +68,7 @@ func (bs *BinarySerializer) Deserialize(msg []byte, socketID string) (record *Re
 	record.ReceivedTimestamp = time.Now().Unix() * 1000
 	record.DeviceClientVersion = bs.RequestIdentity.DeviceClientVersion
 
-	if _, ok := bs.DispatchRules[streamMessage.Topic()]; ok {
+	if _, ok := bs.CurrentDispatchRules()[streamMessage.Topic()]; ok {
 		return record, nil
 	}
 
@@ -90,9 +96,75 @@ func (bs *BinarySerializer) Error(err error, record *Record) []byte {
 
 // Dispatch pushes the record to kafka for every rule associated to it
 func (bs *BinarySerializer) Dispatch(record *Record) {
-	for _, producer := range bs.DispatchRules[record.TxType] {
+	var summary *Record
+	if bs.segmenter != nil {
+		summary = bs.segmenter.Segment(record)
+	}
+	if bs.observer != nil {
+		bs.observer.Observe(record)
+	}
+	dispatchRules := bs.CurrentDispatchRules()
+	for _, producer := range dispatchRules[record.TxType] {
 		producer.Produce(record)
 	}
+	for _, deriver := range bs.derivers {
+		derived := deriver.Derive(record)
+		if derived == nil {
+			continue
+		}
+		for _, producer := range dispatchRules[derived.TxType] {
+			producer.Produce(derived)
+		}
+	}
+	if summary != nil {
+		for _, producer := range dispatchRules[summary.TxType] {
+			producer.Produce(summary)
+		}
+	}
+}
+
+// SetObserver sets the observer notified of the records dispatched. It must be set before the first dispatch.
+func (bs *BinarySerializer) SetObserver(observer Observer) {
+	bs.observer = observer
+}
+
+// HasObserver returns true if an observer is notified of the records dispatched
+func (bs *BinarySerializer) HasObserver() bool {
+	return bs.observer != nil
+}
+
+// SetPayloadTransformer sets the transformer applied to the V records received. It must be set before the first
+// record is deserialized.
+func (bs *BinarySerializer) SetPayloadTransformer(transformer PayloadTransformer) {
+	bs.transformer = transformer
+}
+
+// AddDeriver adds a deriver of the records dispatched. It must be added before the first dispatch.
+func (bs *BinarySerializer) AddDeriver(deriver Deriver) {
+	bs.derivers = append(bs.derivers, deriver)
+}
+
+// SetSegmenter sets the segmenter of the records dispatched. It must be set before the first dispatch.
+func (bs *BinarySerializer) SetSegmenter(segmenter Segmenter) {
+	bs.segmenter = segmenter
+}
+
+// HasSegmenter returns true if the records dispatched are assigned to sessions
+func (bs *BinarySerializer) HasSegmenter() bool {
+	return bs.segmenter != nil
+}
+
+// SetDispatchRules atomically replaces the dispatch rules of a live serializer
+func (bs *BinarySerializer) SetDispatchRules(dispatchRules map[string][]Producer) {
+	bs.reloadedRules.Store(&dispatchRules)
+}
+
+// CurrentDispatchRules returns the dispatch rules in use, reflecting any configuration reload
+func (bs *BinarySerializer) CurrentDispatchRules() map[string][]Producer {
+	if dispatchRules := bs.reloadedRules.Load(); dispatchRules != nil {
+		return *dispatchRules
+	}
+	return bs.DispatchRules
 }
 
 // Logger returns logger for the serializer
@@ -34,6 +34,23 @@ func (c *CallbackTester) ReportError(_ string, _ error, _ logrus.LogInfo) {
 	c.errors++
 }
 
+type deriverTester struct{}
+
+func (d *deriverTester) Derive(entry *telemetry.Record) *telemetry.Record {
+	derived := *entry
+	derived.TxType = "D"
+	return &derived
+}
+
+type segmenterTester struct{}
+
+func (s *segmenterTester) Segment(entry *telemetry.Record) *telemetry.Record {
+	entry.TripID = "trip-42"
+	summary := *entry
+	summary.TxType = "S"
+	return &summary
+}
+
 var _ = Describe("BinarySerializer", func() {
 	DispatchKafkaGlobal := &CallbackTester{counter: 0, errors: 0, reliableAck: 0}
 	DispatchRules := map[string][]telemetry.Producer{
@@ -226,6 +243,74 @@ var _ = Describe("BinarySerializer", func() {
 		Expect(CallbackTester.errors).To(Equal(0))
 	})
 
+	It("Dispatches with reloaded rules", func() {
+		var previousTester = &CallbackTester{counter: 0, errors: 0}
+		var reloadedTester = &CallbackTester{counter: 0, errors: 0}
+
+		bs := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{"T": {previousTester}}, nil)
+		bs.SetDispatchRules(map[string][]telemetry.Producer{"T": {reloadedTester}})
+		Expect(bs.CurrentDispatchRules()["T"]).To(ConsistOf(reloadedTester))
+
+		msg := messages.StreamMessage{
+			MessageTopic: []byte("T"),
+			TXID:         []byte("test-42"),
+			Payload:      []byte("disiz a test"),
+			SenderID:     []byte("VIN42"),
+		}
+		msgBytes, e := msg.ToBytes()
+		Expect(e).To(BeNil())
+		result, _ := bs.Deserialize(msgBytes, "Socket-42")
+		bs.Dispatch(result)
+		Expect(previousTester.counter).To(Equal(0))
+		Expect(reloadedTester.counter).To(Equal(1))
+	})
+
+	It("Dispatches derived records", func() {
+		var recordTester = &CallbackTester{counter: 0, errors: 0}
+		var derivedTester = &CallbackTester{counter: 0, errors: 0}
+
+		bs := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{"T": {recordTester}, "D": {derivedTester}}, nil)
+		bs.AddDeriver(&deriverTester{})
+
+		msg := messages.StreamMessage{
+			MessageTopic: []byte("T"),
+			TXID:         []byte("test-42"),
+			Payload:      []byte("disiz a test"),
+			SenderID:     []byte("VIN42"),
+		}
+		msgBytes, e := msg.ToBytes()
+		Expect(e).To(BeNil())
+		result, _ := bs.Deserialize(msgBytes, "Socket-42")
+		bs.Dispatch(result)
+		Expect(recordTester.counter).To(Equal(1))
+		Expect(derivedTester.counter).To(Equal(1))
+	})
+
+	It("Dispatches session summaries", func() {
+		var recordTester = &CallbackTester{counter: 0, errors: 0}
+		var summaryTester = &CallbackTester{counter: 0, errors: 0}
+
+		bs := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{"T": {recordTester}, "S": {summaryTester}}, nil)
+		Expect(bs.HasSegmenter()).To(BeFalse())
+		bs.SetSegmenter(&segmenterTester{})
+		Expect(bs.HasSegmenter()).To(BeTrue())
+
+		msg := messages.StreamMessage{
+			MessageTopic: []byte("T"),
+			TXID:         []byte("test-42"),
+			Payload:      []byte("disiz a test"),
+			SenderID:     []byte("VIN42"),
+		}
+		msgBytes, e := msg.ToBytes()
+		Expect(e).To(BeNil())
+		result, _ := bs.Deserialize(msgBytes, "Socket-42")
+		bs.Dispatch(result)
+		Expect(result.TripID).To(Equal("trip-42"))
+		Expect(result.Metadata()).To(HaveKeyWithValue("tripid", "trip-42"))
+		Expect(recordTester.counter).To(Equal(1))
+		Expect(summaryTester.counter).To(Equal(1))
+	})
+
 	It("Detects unknown types", func() {
 		bs := &telemetry.BinarySerializer{DispatchRules: DispatchRules}
 
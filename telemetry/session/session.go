@@ -0,0 +1,399 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+const (
+	// RecordType is the record type of the session summaries, dispatched with the rules of the "session" records
+	RecordType = "session"
+
+	// Trip is a session during which the vehicle is driven
+	Trip = "trip"
+	// Charge is a session during which the vehicle is charging
+	Charge = "charge"
+
+	// DefaultVehicleTTLMinutes is how long the state of a vehicle without records is kept by default
+	DefaultVehicleTTLMinutes = 60
+)
+
+// Config of the trip and charge sessions
+type Config struct {
+	// DisconnectGraceSeconds keeps a session open while the vehicle reconnects within this delay, ex.: a drive through
+	// a tunnel. Default: sessions end when the vehicle disconnects
+	DisconnectGraceSeconds int `json:"disconnect_grace_seconds,omitempty"`
+
+	// VehicleTTLMinutes is how long the state of a vehicle without records is kept, its open session is then dropped
+	// without summary. It must exceed the disconnect grace. Default: 60
+	VehicleTTLMinutes int `json:"vehicle_ttl_minutes,omitempty"`
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	sessionCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Tracker segments the records of each vehicle into trip and charge sessions, from the Gear, VehicleSpeed and
+// DetailedChargeState of the V records and the connectivity events
+type Tracker struct {
+	disconnectGrace time.Duration
+	vehicleTTL      time.Duration
+	logger          *logrus.Logger
+	now             func() time.Time
+
+	vehicles sync.Map
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// vehicle is the open session of a vehicle and the last values its summary is computed from
+type vehicle struct {
+	mutex          sync.Mutex
+	current        *session
+	connectionID   string
+	disconnectedAt time.Time
+	seenAt         time.Time
+
+	odometer        *float64
+	energyRemaining *float64
+	location        *protos.LocationValue
+}
+
+// session is an open trip or charge
+type session struct {
+	id        string
+	kind      string
+	startedAt time.Time
+
+	startOdometer        *float64
+	startEnergyRemaining *float64
+	startLocation        *protos.LocationValue
+}
+
+// NewTracker validates the config and returns a tracker of the sessions of the vehicles
+func NewTracker(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Tracker, error) {
+	registerMetricsOnce(metricsCollector)
+
+	if config.VehicleTTLMinutes < 0 {
+		return nil, errors.New("sessions vehicle_ttl_minutes must not be negative")
+	}
+	vehicleTTLMinutes := config.VehicleTTLMinutes
+	if vehicleTTLMinutes == 0 {
+		vehicleTTLMinutes = DefaultVehicleTTLMinutes
+	}
+	tracker := &Tracker{
+		disconnectGrace: time.Duration(config.DisconnectGraceSeconds) * time.Second,
+		vehicleTTL:      time.Duration(vehicleTTLMinutes) * time.Minute,
+		logger:          logger,
+		now:             time.Now,
+		done:            make(chan struct{}),
+	}
+	if tracker.vehicleTTL <= tracker.disconnectGrace {
+		return nil, errors.New("sessions vehicle_ttl_minutes must exceed disconnect_grace_seconds")
+	}
+
+	tracker.wg.Add(1)
+	go tracker.runEviction()
+	return tracker, nil
+}
+
+// Segment sets the TripID of the records received during a session, including the ones opening and closing it, and
+// returns the summary of the session the record closed, nil when there is none. Resent and duplicate records are
+// ignored, since they are older than the ones already received.
+func (t *Tracker) Segment(entry *telemetry.Record) *telemetry.Record {
+	if entry.Duplicate {
+		return nil
+	}
+	if payload, ok := entry.GetProtoMessage().(*protos.Payload); ok && payload.GetIsResend() {
+		return nil
+	}
+
+	var closed *session
+	var endedAt time.Time
+	state := t.vehicle(entry.Vin)
+	state.mutex.Lock()
+	state.seenAt = t.now()
+	switch message := entry.GetProtoMessage().(type) {
+	case *protos.Payload:
+		endedAt = timeOf(message.GetCreatedAt())
+		closed = t.applyPayload(entry.Vin, state, message, endedAt)
+	case *protos.VehicleConnectivity:
+		closed, endedAt = t.applyConnectivity(state, message)
+	}
+	if state.current != nil {
+		entry.TripID = state.current.id
+	} else if closed != nil {
+		entry.TripID = closed.id
+	}
+	summary := closed.summarize(entry.Vin, state, endedAt)
+	state.mutex.Unlock()
+
+	if closed == nil {
+		return nil
+	}
+	record, err := entry.WithProtoMessage(summary)
+	if err != nil {
+		t.logger.ErrorLog("session_encode_error", err, logrus.LogInfo{"vin": entry.Vin, "txid": entry.Txid})
+		return nil
+	}
+	record.TxType = RecordType
+	record.TripID = closed.id
+	return record
+}
+
+func (t *Tracker) vehicle(vin string) *vehicle {
+	loaded, found := t.vehicles.Load(vin)
+	if !found {
+		loaded, _ = t.vehicles.LoadOrStore(vin, &vehicle{})
+	}
+	return loaded.(*vehicle)
+}
+
+// runEviction forgets the vehicles without records for the vehicle TTL
+func (t *Tracker) runEviction() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(min(t.vehicleTTL, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.evict(t.now().Add(-t.vehicleTTL))
+		}
+	}
+}
+
+func (t *Tracker) evict(before time.Time) {
+	t.vehicles.Range(func(vin, loaded interface{}) bool {
+		state := loaded.(*vehicle)
+		state.mutex.Lock()
+		expired := state.seenAt.Before(before)
+		if expired && state.current != nil {
+			metricsRegistry.sessionCount.Inc(map[string]string{"session": state.current.kind, "event": "expire"})
+		}
+		state.mutex.Unlock()
+		if expired {
+			t.vehicles.Delete(vin)
+		}
+		return true
+	})
+}
+
+// Close stops the eviction
+func (t *Tracker) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	t.wg.Wait()
+	return nil
+}
+
+// applyPayload updates the last values of the vehicle, then opens or closes its session. A trip starts when the
+// vehicle is put in drive or reverse, or moves, and ends when it is parked. A charge starts when charging starts and
+// ends with any other charge state. Starting one session ends the other one.
+func (t *Tracker) applyPayload(vin string, state *vehicle, payload *protos.Payload, timestamp time.Time) *session {
+	var gear *protos.ShiftState
+	var chargeState *protos.DetailedChargeStateValue
+	var speed float64
+	for _, datum := range payload.GetData() {
+		switch value := datum.GetValue().GetValue().(type) {
+		case *protos.Value_ShiftStateValue:
+			if datum.GetKey() == protos.Field_Gear {
+				gear = &value.ShiftStateValue
+			}
+		case *protos.Value_DetailedChargeStateValue:
+			if datum.GetKey() == protos.Field_DetailedChargeState {
+				chargeState = &value.DetailedChargeStateValue
+			}
+		case *protos.Value_LocationValue:
+			if datum.GetKey() == protos.Field_Location {
+				state.location = value.LocationValue
+			}
+		default:
+			number, ok := numberOf(datum.GetValue())
+			if !ok {
+				continue
+			}
+			switch datum.GetKey() {
+			case protos.Field_VehicleSpeed:
+				speed = number
+			case protos.Field_Odometer:
+				state.odometer = &number
+			case protos.Field_EnergyRemaining:
+				state.energyRemaining = &number
+			}
+		}
+	}
+
+	driving := gear != nil && (*gear == protos.ShiftState_ShiftStateD || *gear == protos.ShiftState_ShiftStateR) ||
+		gear == nil && speed > 0
+	parked := gear != nil && *gear == protos.ShiftState_ShiftStateP
+	charging := chargeState != nil && (*chargeState == protos.DetailedChargeStateValue_DetailedChargeStateStarting ||
+		*chargeState == protos.DetailedChargeStateValue_DetailedChargeStateCharging)
+	notCharging := chargeState != nil && !charging && *chargeState != protos.DetailedChargeStateValue_DetailedChargeStateUnknown
+
+	var closed *session
+	if current := state.current; current != nil {
+		if current.kind == Trip && (parked || charging) || current.kind == Charge && (notCharging || driving) {
+			closed = t.close(state)
+		}
+	}
+	if state.current == nil {
+		switch {
+		case driving:
+			t.open(vin, state, Trip, timestamp)
+		case charging:
+			t.open(vin, state, Charge, timestamp)
+		}
+	}
+	return closed
+}
+
+// applyConnectivity closes the session of a vehicle when it disconnects, or when it reconnects after the grace delay.
+// The disconnection of a connection other than the current one is ignored: a vehicle reconnecting can be reported
+// connected again before its previous connection is reported closed. It returns the session closed and the time it
+// ended.
+func (t *Tracker) applyConnectivity(state *vehicle, connectivity *protos.VehicleConnectivity) (*session, time.Time) {
+	timestamp := timeOf(connectivity.GetCreatedAt())
+	switch connectivity.GetStatus() {
+	case protos.ConnectivityEvent_DISCONNECTED:
+		if state.connectionID != "" && connectivity.GetConnectionId() != state.connectionID {
+			return nil, timestamp
+		}
+		state.connectionID = ""
+		if state.current == nil {
+			return nil, timestamp
+		}
+		if t.disconnectGrace == 0 {
+			return t.close(state), timestamp
+		}
+		state.disconnectedAt = timestamp
+	case protos.ConnectivityEvent_CONNECTED:
+		state.connectionID = connectivity.GetConnectionId()
+		disconnectedAt := state.disconnectedAt
+		state.disconnectedAt = time.Time{}
+		if state.current != nil && !disconnectedAt.IsZero() && timestamp.Sub(disconnectedAt) > t.disconnectGrace {
+			return t.close(state), disconnectedAt
+		}
+	}
+	return nil, timestamp
+}
+
+func (t *Tracker) open(vin string, state *vehicle, kind string, timestamp time.Time) {
+	state.current = &session{
+		id:                   fmt.Sprintf("%s-%s-%d", kind, vin, timestamp.UnixMilli()),
+		kind:                 kind,
+		startedAt:            timestamp,
+		startOdometer:        state.odometer,
+		startEnergyRemaining: state.energyRemaining,
+		startLocation:        state.location,
+	}
+	state.disconnectedAt = time.Time{}
+	metricsRegistry.sessionCount.Inc(map[string]string{"session": kind, "event": "open"})
+}
+
+func (t *Tracker) close(state *vehicle) *session {
+	closed := state.current
+	state.current = nil
+	metricsRegistry.sessionCount.Inc(map[string]string{"session": closed.kind, "event": "close"})
+	return closed
+}
+
+// summarize returns the summary of a closed session: its duration, the distance driven and the energy used or added,
+// in the units of the vehicle, and its start and end locations. Values not received are omitted.
+func (s *session) summarize(vin string, state *vehicle, endedAt time.Time) *protos.VehicleSession {
+	if s == nil {
+		return nil
+	}
+	summary := &protos.VehicleSession{
+		Vin:             vin,
+		SessionId:       s.id,
+		Kind:            protos.SessionKind_TRIP,
+		StartedAt:       timestamppb.New(s.startedAt),
+		EndedAt:         timestamppb.New(endedAt),
+		DurationSeconds: endedAt.Sub(s.startedAt).Seconds(),
+		StartLocation:   locationOf(s.startLocation),
+		EndLocation:     locationOf(state.location),
+	}
+	if s.kind == Charge {
+		summary.Kind = protos.SessionKind_CHARGE
+	}
+	if s.startOdometer != nil && state.odometer != nil {
+		distance := *state.odometer - *s.startOdometer
+		summary.Distance = &distance
+	}
+	if s.startEnergyRemaining != nil && state.energyRemaining != nil {
+		energy := *s.startEnergyRemaining - *state.energyRemaining
+		if s.kind == Charge {
+			energy = -energy
+		}
+		summary.Energy = &energy
+	}
+	return summary
+}
+
+// locationOf returns the session location of a location value, nil when there is none
+func locationOf(location *protos.LocationValue) *protos.SessionLocation {
+	if location == nil {
+		return nil
+	}
+	return &protos.SessionLocation{Latitude: location.GetLatitude(), Longitude: location.GetLongitude()}
+}
+
+// timeOf returns the time of a timestamp, now when there is none
+func timeOf(timestamp *timestamppb.Timestamp) time.Time {
+	if timestamp == nil {
+		return time.Now()
+	}
+	return timestamp.AsTime()
+}
+
+// numberOf returns the numeric value of a datum, including numbers sent as strings
+func numberOf(value *protos.Value) (float64, bool) {
+	switch v := value.GetValue().(type) {
+	case *protos.Value_DoubleValue:
+		return v.DoubleValue, true
+	case *protos.Value_FloatValue:
+		return float64(v.FloatValue), true
+	case *protos.Value_IntValue:
+		return float64(v.IntValue), true
+	case *protos.Value_LongValue:
+		return float64(v.LongValue), true
+	case *protos.Value_StringValue:
+		number, err := strconv.ParseFloat(v.StringValue, 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.sessionCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "sessions_total",
+		Help:   "The number of trip and charge sessions opened and closed.",
+		Labels: []string{"session", "event"},
+	})
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+var _ = Describe("Session eviction", func() {
+	var (
+		tracker *Tracker
+		clock   time.Time
+	)
+
+	drive := func() {
+		state := tracker.vehicle("42")
+		state.mutex.Lock()
+		defer state.mutex.Unlock()
+		state.seenAt = tracker.now()
+		gear := protos.ShiftState_ShiftStateD
+		tracker.applyPayload("42", state, &protos.Payload{Data: []*protos.Datum{
+			{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: gear}}},
+		}}, clock)
+	}
+
+	openSession := func() *session {
+		loaded, ok := tracker.vehicles.Load("42")
+		if !ok {
+			return nil
+		}
+		return loaded.(*vehicle).current
+	}
+
+	BeforeEach(func() {
+		logger, _ := logrus.NoOpLogger()
+		var err error
+		tracker, err = NewTracker(&Config{}, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(tracker.Close)
+		clock = time.Now()
+		tracker.now = func() time.Time { return clock }
+	})
+
+	It("forgets the vehicles without records for the vehicle TTL", func() {
+		drive()
+		Expect(openSession()).NotTo(BeNil())
+
+		clock = clock.Add(time.Minute)
+		tracker.evict(clock.Add(-tracker.vehicleTTL))
+		Expect(openSession()).NotTo(BeNil())
+
+		clock = clock.Add(tracker.vehicleTTL + time.Minute)
+		tracker.evict(clock.Add(-tracker.vehicleTTL))
+		Expect(openSession()).To(BeNil())
+	})
+
+	DescribeTable("rejects an invalid vehicle TTL",
+		func(config *Config, errMessage string) {
+			logger, _ := logrus.NoOpLogger()
+			_, err := NewTracker(config, metrics.NewCollector(nil, logger), logger)
+			Expect(err).To(MatchError(errMessage))
+		},
+		Entry("negative", &Config{VehicleTTLMinutes: -1}, "sessions vehicle_ttl_minutes must not be negative"),
+		Entry("within the disconnect grace", &Config{VehicleTTLMinutes: 1, DisconnectGraceSeconds: 60}, "sessions vehicle_ttl_minutes must exceed disconnect_grace_seconds"),
+	)
+})
@@ -0,0 +1,196 @@
+package session_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/session"
+)
+
+var _ = Describe("Session tracker", func() {
+	var (
+		logger *logrus.Logger
+		start  time.Time
+	)
+
+	gearDatum := func(gear protos.ShiftState) *protos.Datum {
+		return &protos.Datum{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: gear}}}
+	}
+
+	chargeStateDatum := func(state protos.DetailedChargeStateValue) *protos.Datum {
+		return &protos.Datum{Key: protos.Field_DetailedChargeState, Value: &protos.Value{Value: &protos.Value_DetailedChargeStateValue{DetailedChargeStateValue: state}}}
+	}
+
+	doubleDatum := func(field protos.Field, value float64) *protos.Datum {
+		return &protos.Datum{Key: field, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: value}}}
+	}
+
+	locationDatum := func(latitude, longitude float64) *protos.Datum {
+		return &protos.Datum{Key: protos.Field_Location, Value: &protos.Value{Value: &protos.Value_LocationValue{LocationValue: &protos.LocationValue{Latitude: latitude, Longitude: longitude}}}}
+	}
+
+	newRecord := func(vin string, txType string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		serializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+		record, err := telemetry.NewRecord(serializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newPayloadRecord := func(vin string, offset time.Duration, data ...*protos.Datum) *telemetry.Record {
+		return newRecord(vin, "V", &protos.Payload{Vin: vin, CreatedAt: timestamppb.New(start.Add(offset)), Data: data})
+	}
+
+	newConnectivityRecord := func(vin string, offset time.Duration, status protos.ConnectivityEvent, connectionID string) *telemetry.Record {
+		return newRecord(vin, "connectivity", &protos.VehicleConnectivity{Vin: vin, ConnectionId: connectionID, CreatedAt: timestamppb.New(start.Add(offset)), Status: status})
+	}
+
+	summaryOf := func(record *telemetry.Record) *protos.VehicleSession {
+		Expect(record).NotTo(BeNil())
+		Expect(record.TxType).To(Equal(session.RecordType))
+		message := &protos.VehicleSession{}
+		Expect(proto.Unmarshal(record.Payload(), message)).To(Succeed())
+		Expect(message.GetVin()).To(Equal(record.Vin))
+		return message
+	}
+
+	newTracker := func(config *session.Config) *session.Tracker {
+		tracker, err := session.NewTracker(config, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(tracker.Close)
+		return tracker
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		start = time.Now().Add(-time.Hour).Truncate(time.Second)
+	})
+
+	It("segments trips", func() {
+		tracker := newTracker(&session.Config{})
+		tripID := fmt.Sprintf("trip-42-%d", start.Add(time.Minute).UnixMilli())
+
+		parked := newPayloadRecord("42", 0, gearDatum(protos.ShiftState_ShiftStateP), doubleDatum(protos.Field_Odometer, 100), doubleDatum(protos.Field_EnergyRemaining, 60), locationDatum(37.41, -122.15))
+		Expect(tracker.Segment(parked)).To(BeNil())
+		Expect(parked.TripID).To(BeEmpty())
+
+		opening := newPayloadRecord("42", time.Minute, gearDatum(protos.ShiftState_ShiftStateD))
+		Expect(tracker.Segment(opening)).To(BeNil())
+		Expect(opening.TripID).To(Equal(tripID))
+
+		driving := newPayloadRecord("42", 10*time.Minute, doubleDatum(protos.Field_VehicleSpeed, 50), doubleDatum(protos.Field_Odometer, 112.5), doubleDatum(protos.Field_EnergyRemaining, 58))
+		Expect(tracker.Segment(driving)).To(BeNil())
+		Expect(driving.TripID).To(Equal(tripID))
+
+		closing := newPayloadRecord("42", 21*time.Minute, gearDatum(protos.ShiftState_ShiftStateP), locationDatum(37.42, -122.14))
+		summary := tracker.Segment(closing)
+		Expect(closing.TripID).To(Equal(tripID))
+		Expect(summary.TripID).To(Equal(tripID))
+
+		trip := summaryOf(summary)
+		Expect(trip.GetKind()).To(Equal(protos.SessionKind_TRIP))
+		Expect(trip.GetSessionId()).To(Equal(tripID))
+		Expect(trip.GetStartedAt().AsTime()).To(Equal(start.Add(time.Minute)))
+		Expect(trip.GetEndedAt().AsTime()).To(Equal(start.Add(21 * time.Minute)))
+		Expect(trip.GetDurationSeconds()).To(Equal(1200.0))
+		Expect(trip.Distance).To(HaveValue(Equal(12.5)))
+		Expect(trip.Energy).To(HaveValue(Equal(2.0)))
+		Expect(trip.GetStartLocation().GetLatitude()).To(Equal(37.41))
+		Expect(trip.GetStartLocation().GetLongitude()).To(Equal(-122.15))
+		Expect(trip.GetEndLocation().GetLatitude()).To(Equal(37.42))
+		Expect(trip.GetEndLocation().GetLongitude()).To(Equal(-122.14))
+
+		after := newPayloadRecord("42", 22*time.Minute, doubleDatum(protos.Field_Odometer, 112.5))
+		Expect(tracker.Segment(after)).To(BeNil())
+		Expect(after.TripID).To(BeEmpty())
+	})
+
+	It("segments charges", func() {
+		tracker := newTracker(&session.Config{})
+
+		opening := newPayloadRecord("42", 0, chargeStateDatum(protos.DetailedChargeStateValue_DetailedChargeStateCharging), doubleDatum(protos.Field_EnergyRemaining, 40))
+		Expect(tracker.Segment(opening)).To(BeNil())
+		Expect(opening.TripID).To(HavePrefix("charge-42-"))
+
+		closing := newPayloadRecord("42", time.Hour, chargeStateDatum(protos.DetailedChargeStateValue_DetailedChargeStateComplete), doubleDatum(protos.Field_EnergyRemaining, 70))
+		charge := summaryOf(tracker.Segment(closing))
+		Expect(charge.GetKind()).To(Equal(protos.SessionKind_CHARGE))
+		Expect(charge.GetDurationSeconds()).To(Equal(3600.0))
+		Expect(charge.Energy).To(HaveValue(Equal(30.0)))
+		Expect(charge.Distance).To(BeNil())
+		Expect(charge.GetStartLocation()).To(BeNil())
+	})
+
+	It("ends the charge when the vehicle is driven", func() {
+		tracker := newTracker(&session.Config{})
+		Expect(tracker.Segment(newPayloadRecord("42", 0, chargeStateDatum(protos.DetailedChargeStateValue_DetailedChargeStateCharging)))).To(BeNil())
+
+		driving := newPayloadRecord("42", time.Minute, gearDatum(protos.ShiftState_ShiftStateR))
+		Expect(summaryOf(tracker.Segment(driving)).GetKind()).To(Equal(protos.SessionKind_CHARGE))
+		Expect(driving.TripID).To(HavePrefix("trip-42-"))
+	})
+
+	It("ends sessions when the vehicle disconnects", func() {
+		tracker := newTracker(&session.Config{})
+		Expect(tracker.Segment(newPayloadRecord("42", 0, doubleDatum(protos.Field_VehicleSpeed, 20)))).To(BeNil())
+
+		disconnected := newConnectivityRecord("42", time.Minute, protos.ConnectivityEvent_DISCONNECTED, "")
+		Expect(summaryOf(tracker.Segment(disconnected)).GetDurationSeconds()).To(Equal(60.0))
+		Expect(disconnected.TripID).To(HavePrefix("trip-42-"))
+	})
+
+	It("ignores the disconnection of a previous connection", func() {
+		tracker := newTracker(&session.Config{})
+		Expect(tracker.Segment(newConnectivityRecord("42", 0, protos.ConnectivityEvent_CONNECTED, "first"))).To(BeNil())
+		Expect(tracker.Segment(newPayloadRecord("42", time.Minute, gearDatum(protos.ShiftState_ShiftStateD)))).To(BeNil())
+
+		Expect(tracker.Segment(newConnectivityRecord("42", 2*time.Minute, protos.ConnectivityEvent_CONNECTED, "second"))).To(BeNil())
+		stale := newConnectivityRecord("42", 3*time.Minute, protos.ConnectivityEvent_DISCONNECTED, "first")
+		Expect(tracker.Segment(stale)).To(BeNil())
+		Expect(stale.TripID).To(HavePrefix("trip-42-"))
+
+		disconnected := newConnectivityRecord("42", 4*time.Minute, protos.ConnectivityEvent_DISCONNECTED, "second")
+		Expect(summaryOf(tracker.Segment(disconnected)).GetDurationSeconds()).To(Equal(180.0))
+	})
+
+	It("keeps sessions open while the vehicle reconnects within the grace delay", func() {
+		tracker := newTracker(&session.Config{DisconnectGraceSeconds: 120})
+		Expect(tracker.Segment(newPayloadRecord("42", 0, gearDatum(protos.ShiftState_ShiftStateD)))).To(BeNil())
+
+		Expect(tracker.Segment(newConnectivityRecord("42", time.Minute, protos.ConnectivityEvent_DISCONNECTED, ""))).To(BeNil())
+		reconnected := newConnectivityRecord("42", 2*time.Minute, protos.ConnectivityEvent_CONNECTED, "")
+		Expect(tracker.Segment(reconnected)).To(BeNil())
+		Expect(reconnected.TripID).To(HavePrefix("trip-42-"))
+
+		Expect(tracker.Segment(newConnectivityRecord("42", 3*time.Minute, protos.ConnectivityEvent_DISCONNECTED, ""))).To(BeNil())
+		Expect(summaryOf(tracker.Segment(newConnectivityRecord("42", 10*time.Minute, protos.ConnectivityEvent_CONNECTED, ""))).GetDurationSeconds()).To(Equal(180.0))
+	})
+
+	It("ignores resent and duplicate records", func() {
+		tracker := newTracker(&session.Config{})
+
+		resent := newRecord("42", "V", &protos.Payload{Vin: "42", IsResend: true, Data: []*protos.Datum{gearDatum(protos.ShiftState_ShiftStateD)}})
+		Expect(tracker.Segment(resent)).To(BeNil())
+		Expect(resent.TripID).To(BeEmpty())
+
+		duplicate := newPayloadRecord("42", 0, gearDatum(protos.ShiftState_ShiftStateD))
+		duplicate.Duplicate = true
+		Expect(tracker.Segment(duplicate)).To(BeNil())
+		Expect(duplicate.TripID).To(BeEmpty())
+	})
+})
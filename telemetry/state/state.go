@@ -0,0 +1,350 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/metrics/adapter"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+)
+
+// DefaultSnapshotIntervalSeconds is the interval between two snapshots of the state to disk
+const DefaultSnapshotIntervalSeconds = 30
+
+// Config of the last known state of the vehicles
+type Config struct {
+	// SnapshotPath is the file the state is persisted to, so it survives restarts. Default: not persisted
+	SnapshotPath string `json:"snapshot_path,omitempty"`
+
+	// SnapshotIntervalSeconds is the interval between two snapshots. Default: 30
+	SnapshotIntervalSeconds int `json:"snapshot_interval_seconds,omitempty"`
+}
+
+// VehicleState is the last known state of a vehicle
+type VehicleState struct {
+	Vin          string                 `json:"vin"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	Fields       map[string]*FieldState `json:"fields"`
+	Alerts       map[string]*AlertState `json:"alerts"`
+	Connectivity *ConnectivityState     `json:"connectivity,omitempty"`
+}
+
+// FieldState is the latest value of a protos.Field, as the JSON encoding of its protos.Value
+type FieldState struct {
+	Value     json.RawMessage `json:"value"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// AlertState is an alert that has not ended yet
+type AlertState struct {
+	Audiences []string  `json:"audiences"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// ConnectivityState is the latest connectivity event of a vehicle
+type ConnectivityState struct {
+	Status           string    `json:"status"`
+	ConnectionID     string    `json:"connection_id"`
+	NetworkInterface string    `json:"network_interface"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// vehicleEntry guards the state of a vehicle
+type vehicleEntry struct {
+	mutex sync.Mutex
+	state *VehicleState
+}
+
+// Metrics stores metrics reported from this package
+type Metrics struct {
+	vehicleCount       adapter.Gauge
+	snapshotErrorCount adapter.Counter
+}
+
+var (
+	metricsRegistry Metrics
+	metricsOnce     sync.Once
+)
+
+// Store keeps the last known state of every vehicle, fed with the records dispatched by the server. Records are
+// observed from the read loops of the vehicles, so the store mutex only guards the vehicle map: each vehicle has its
+// own lock, and snapshots are encoded without holding any.
+type Store struct {
+	snapshotPath     string
+	snapshotInterval time.Duration
+	logger           *logrus.Logger
+
+	mutex    sync.RWMutex
+	vehicles map[string]*vehicleEntry
+	dirty    atomic.Bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// NewStore returns a store loaded from the snapshot of the config, if any, which it keeps up to date
+func NewStore(config *Config, metricsCollector metrics.MetricCollector, logger *logrus.Logger) (*Store, error) {
+	registerMetricsOnce(metricsCollector)
+
+	s := &Store{
+		snapshotPath:     config.SnapshotPath,
+		snapshotInterval: time.Duration(config.SnapshotIntervalSeconds) * time.Second,
+		logger:           logger,
+		vehicles:         make(map[string]*vehicleEntry),
+		done:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+	}
+	if s.snapshotInterval <= 0 {
+		s.snapshotInterval = DefaultSnapshotIntervalSeconds * time.Second
+	}
+	if s.snapshotPath == "" {
+		close(s.stopped)
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	metricsRegistry.vehicleCount.Set(int64(len(s.vehicles)), map[string]string{})
+	go s.snapshotLoop()
+	return s, nil
+}
+
+// Observe folds a dispatched record into the state of its vehicle
+func (s *Store) Observe(record *telemetry.Record) {
+	switch message := record.GetProtoMessage().(type) {
+	case *protos.Payload:
+		s.update(record.Vin, func(vehicle *VehicleState) { applyPayload(vehicle, message) })
+	case *protos.VehicleAlerts:
+		s.update(record.Vin, func(vehicle *VehicleState) { applyAlerts(vehicle, message) })
+	case *protos.VehicleConnectivity:
+		s.update(record.Vin, func(vehicle *VehicleState) { applyConnectivity(vehicle, message) })
+	}
+}
+
+// Get returns a copy of the state of a vehicle
+func (s *Store) Get(vin string) (*VehicleState, bool) {
+	s.mutex.RLock()
+	entry, ok := s.vehicles[vin]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	return entry.state.clone(), true
+}
+
+// Close stops the snapshots, writing a last one
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	<-s.stopped
+	if s.snapshotPath == "" {
+		return nil
+	}
+	return s.snapshot()
+}
+
+func (s *Store) update(vin string, apply func(*VehicleState)) {
+	entry := s.entry(vin)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	apply(entry.state)
+	entry.state.UpdatedAt = time.Now()
+	s.dirty.Store(true)
+}
+
+// entry returns the entry of a vehicle, added on its first record
+func (s *Store) entry(vin string) *vehicleEntry {
+	s.mutex.RLock()
+	entry, ok := s.vehicles[vin]
+	s.mutex.RUnlock()
+	if ok {
+		return entry
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry, ok = s.vehicles[vin]; ok {
+		return entry
+	}
+	entry = &vehicleEntry{state: &VehicleState{Vin: vin, Fields: make(map[string]*FieldState), Alerts: make(map[string]*AlertState)}}
+	s.vehicles[vin] = entry
+	metricsRegistry.vehicleCount.Set(int64(len(s.vehicles)), map[string]string{})
+	return entry
+}
+
+// applyPayload keeps the latest value of each field, values older than the stored one are ignored
+func applyPayload(vehicle *VehicleState, payload *protos.Payload) {
+	timestamp := asTime(payload.GetCreatedAt())
+	for _, datum := range payload.GetData() {
+		name := datum.GetKey().String()
+		if current, ok := vehicle.Fields[name]; ok && current.Timestamp.After(timestamp) {
+			continue
+		}
+		value, err := protojson.Marshal(datum.GetValue())
+		if err != nil {
+			continue
+		}
+		vehicle.Fields[name] = &FieldState{Value: value, Timestamp: timestamp}
+	}
+}
+
+// applyAlerts adds the alerts that started and removes the ones that ended
+func applyAlerts(vehicle *VehicleState, alerts *protos.VehicleAlerts) {
+	for _, alert := range alerts.GetAlerts() {
+		if alert.GetEndedAt() != nil {
+			delete(vehicle.Alerts, alert.GetName())
+			continue
+		}
+		audiences := make([]string, 0, len(alert.GetAudiences()))
+		for _, audience := range alert.GetAudiences() {
+			audiences = append(audiences, audience.String())
+		}
+		vehicle.Alerts[alert.GetName()] = &AlertState{Audiences: audiences, StartedAt: asTime(alert.GetStartedAt())}
+	}
+}
+
+func applyConnectivity(vehicle *VehicleState, connectivity *protos.VehicleConnectivity) {
+	timestamp := asTime(connectivity.GetCreatedAt())
+	if vehicle.Connectivity != nil && vehicle.Connectivity.Timestamp.After(timestamp) {
+		return
+	}
+	vehicle.Connectivity = &ConnectivityState{
+		Status:           connectivity.GetStatus().String(),
+		ConnectionID:     connectivity.GetConnectionId(),
+		NetworkInterface: connectivity.GetNetworkInterface(),
+		Timestamp:        timestamp,
+	}
+}
+
+func asTime(timestamp *timestamppb.Timestamp) time.Time {
+	if timestamp == nil {
+		return time.Now()
+	}
+	return timestamp.AsTime()
+}
+
+// clone copies the maps of the state, their values are replaced rather than modified on update
+func (v *VehicleState) clone() *VehicleState {
+	clone := *v
+	clone.Fields = make(map[string]*FieldState, len(v.Fields))
+	for name, field := range v.Fields {
+		clone.Fields[name] = field
+	}
+	clone.Alerts = make(map[string]*AlertState, len(v.Alerts))
+	for name, alert := range v.Alerts {
+		clone.Alerts[name] = alert
+	}
+	return &clone
+}
+
+func (s *Store) snapshotLoop() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.snapshot(); err != nil {
+				metricsRegistry.snapshotErrorCount.Inc(map[string]string{})
+				s.logger.ErrorLog("state_snapshot_error", err, logrus.LogInfo{"path": s.snapshotPath})
+			}
+		}
+	}
+}
+
+// snapshot atomically writes the state to disk if it changed since the last snapshot. The vehicles are copied one
+// at a time under their own lock, then encoded without blocking the updates.
+func (s *Store) snapshot() error {
+	if !s.dirty.Swap(false) {
+		return nil
+	}
+
+	s.mutex.RLock()
+	entries := make(map[string]*vehicleEntry, len(s.vehicles))
+	for vin, entry := range s.vehicles {
+		entries[vin] = entry
+	}
+	s.mutex.RUnlock()
+
+	vehicles := make(map[string]*VehicleState, len(entries))
+	for vin, entry := range entries {
+		entry.mutex.Lock()
+		vehicles[vin] = entry.state.clone()
+		entry.mutex.Unlock()
+	}
+
+	data, err := json.Marshal(vehicles)
+	if err == nil {
+		err = s.writeSnapshot(data)
+	}
+	if err != nil {
+		// keep the state dirty so the next snapshot retries
+		s.dirty.Store(true)
+	}
+	return err
+}
+
+func (s *Store) writeSnapshot(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.snapshotPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.snapshotPath+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(s.snapshotPath+".tmp", s.snapshotPath)
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var vehicles map[string]*VehicleState
+	if err := json.Unmarshal(data, &vehicles); err != nil {
+		return err
+	}
+	for vin, vehicle := range vehicles {
+		s.vehicles[vin] = &vehicleEntry{state: vehicle}
+	}
+	return nil
+}
+
+func registerMetricsOnce(metricsCollector metrics.MetricCollector) {
+	metricsOnce.Do(func() { registerMetrics(metricsCollector) })
+}
+
+func registerMetrics(metricsCollector metrics.MetricCollector) {
+	metricsRegistry.vehicleCount = metricsCollector.RegisterGauge(adapter.CollectorOptions{
+		Name:   "state_vehicles",
+		Help:   "The number of vehicles in the last known state store.",
+		Labels: []string{},
+	})
+
+	metricsRegistry.snapshotErrorCount = metricsCollector.RegisterCounter(adapter.CollectorOptions{
+		Name:   "state_snapshot_error_total",
+		Help:   "The number of failed snapshots of the last known state.",
+		Labels: []string{},
+	})
+}
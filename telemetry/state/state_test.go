@@ -0,0 +1,183 @@
+package state_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/metrics"
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/state"
+)
+
+var _ = Describe("State store", func() {
+	var (
+		logger     *logrus.Logger
+		serializer *telemetry.BinarySerializer
+		store      *state.Store
+		createdAt  time.Time
+	)
+
+	doubleDatum := func(field protos.Field, value float64) *protos.Datum {
+		return &protos.Datum{Key: field, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: value}}}
+	}
+
+	newVehicleRecord := func(vehicleSerializer *telemetry.BinarySerializer, vin string, txType string, message proto.Message) *telemetry.Record {
+		payload, err := proto.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		streamMessage := messages.StreamMessage{TXID: []byte("1234"), SenderID: []byte("vehicle_device." + vin), MessageTopic: []byte(txType), Payload: payload}
+		recordMsg, err := streamMessage.ToBytes()
+		Expect(err).NotTo(HaveOccurred())
+		record, err := telemetry.NewRecord(vehicleSerializer, recordMsg, "1", false)
+		Expect(err).NotTo(HaveOccurred())
+		return record
+	}
+
+	newRecord := func(txType string, message proto.Message) *telemetry.Record {
+		return newVehicleRecord(serializer, "42", txType, message)
+	}
+
+	newStore := func(config *state.Config) *state.Store {
+		s, err := state.NewStore(config, metrics.NewCollector(nil, logger), logger)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	BeforeEach(func() {
+		logger, _ = logrus.NoOpLogger()
+		serializer = telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: "42", SenderID: "vehicle_device.42"}, map[string][]telemetry.Producer{}, logger)
+		store = newStore(&state.Config{})
+		serializer.SetObserver(store)
+		createdAt = time.Now().Truncate(time.Second)
+	})
+
+	AfterEach(func() {
+		Expect(store.Close()).To(Succeed())
+	})
+
+	It("keeps the latest value of each field", func() {
+		serializer.Dispatch(newRecord("V", &protos.Payload{
+			CreatedAt: timestamppb.New(createdAt),
+			Data:      []*protos.Datum{doubleDatum(protos.Field_VehicleSpeed, 42), doubleDatum(protos.Field_Soc, 80)},
+		}))
+		serializer.Dispatch(newRecord("V", &protos.Payload{
+			CreatedAt: timestamppb.New(createdAt.Add(time.Second)),
+			Data:      []*protos.Datum{doubleDatum(protos.Field_VehicleSpeed, 50)},
+		}))
+		// delivered late, older than the stored value
+		serializer.Dispatch(newRecord("V", &protos.Payload{
+			CreatedAt: timestamppb.New(createdAt.Add(-time.Second)),
+			Data:      []*protos.Datum{doubleDatum(protos.Field_VehicleSpeed, 10)},
+		}))
+
+		vehicle, ok := store.Get("42")
+		Expect(ok).To(BeTrue())
+		Expect(vehicle.Vin).To(Equal("42"))
+		Expect(vehicle.Fields).To(HaveLen(2))
+		Expect(vehicle.Fields["VehicleSpeed"].Value).To(MatchJSON(`{"doubleValue": 50}`))
+		Expect(vehicle.Fields["VehicleSpeed"].Timestamp).To(BeTemporally("==", createdAt.Add(time.Second)))
+		Expect(vehicle.Fields["Soc"].Value).To(MatchJSON(`{"doubleValue": 80}`))
+	})
+
+	It("tracks active alerts", func() {
+		serializer.Dispatch(newRecord("alerts", &protos.VehicleAlerts{
+			CreatedAt: timestamppb.New(createdAt),
+			Alerts: []*protos.VehicleAlert{
+				{Name: "ChargePortFault", Audiences: []protos.Audience{protos.Audience_Customer}, StartedAt: timestamppb.New(createdAt)},
+				{Name: "TirePressureLow", Audiences: []protos.Audience{protos.Audience_Service}, StartedAt: timestamppb.New(createdAt)},
+			},
+		}))
+		serializer.Dispatch(newRecord("alerts", &protos.VehicleAlerts{
+			CreatedAt: timestamppb.New(createdAt.Add(time.Minute)),
+			Alerts: []*protos.VehicleAlert{
+				{Name: "ChargePortFault", StartedAt: timestamppb.New(createdAt), EndedAt: timestamppb.New(createdAt.Add(time.Minute))},
+			},
+		}))
+
+		vehicle, ok := store.Get("42")
+		Expect(ok).To(BeTrue())
+		Expect(vehicle.Alerts).To(HaveLen(1))
+		Expect(vehicle.Alerts["TirePressureLow"].Audiences).To(Equal([]string{"Service"}))
+	})
+
+	It("tracks the connectivity", func() {
+		serializer.Dispatch(newRecord("connectivity", &protos.VehicleConnectivity{
+			ConnectionId:     "connection-1",
+			Status:           protos.ConnectivityEvent_CONNECTED,
+			NetworkInterface: "wifi",
+			CreatedAt:        timestamppb.New(createdAt),
+		}))
+
+		vehicle, ok := store.Get("42")
+		Expect(ok).To(BeTrue())
+		Expect(vehicle.Connectivity.Status).To(Equal("CONNECTED"))
+		Expect(vehicle.Connectivity.ConnectionID).To(Equal("connection-1"))
+		Expect(vehicle.Connectivity.NetworkInterface).To(Equal("wifi"))
+		Expect(vehicle.Connectivity.Timestamp).To(BeTemporally("==", createdAt))
+	})
+
+	It("returns nothing for an unknown vin", func() {
+		_, ok := store.Get("43")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("survives a restart with a snapshot", func() {
+		snapshotPath := filepath.Join(GinkgoT().TempDir(), "state", "snapshot.json")
+		persisted := newStore(&state.Config{SnapshotPath: snapshotPath})
+		serializer.SetObserver(persisted)
+		serializer.Dispatch(newRecord("V", &protos.Payload{
+			CreatedAt: timestamppb.New(createdAt),
+			Data:      []*protos.Datum{doubleDatum(protos.Field_Odometer, 1234.5)},
+		}))
+		Expect(persisted.Close()).To(Succeed())
+
+		restarted := newStore(&state.Config{SnapshotPath: snapshotPath})
+		defer func() { Expect(restarted.Close()).To(Succeed()) }()
+		vehicle, ok := restarted.Get("42")
+		Expect(ok).To(BeTrue())
+		Expect(vehicle.Fields["Odometer"].Value).To(MatchJSON(`{"doubleValue": 1234.5}`))
+	})
+
+	It("observes the records of several vehicles concurrently", func() {
+		snapshotPath := filepath.Join(GinkgoT().TempDir(), "snapshot.json")
+		persisted := newStore(&state.Config{SnapshotPath: snapshotPath})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			vin := fmt.Sprintf("VIN%d", i)
+			vehicleSerializer := telemetry.NewBinarySerializer(&telemetry.RequestIdentity{DeviceID: vin, SenderID: "vehicle_device." + vin}, map[string][]telemetry.Producer{}, logger)
+			vehicleSerializer.SetObserver(persisted)
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for odometer := 1; odometer <= 100; odometer++ {
+					vehicleSerializer.Dispatch(newVehicleRecord(vehicleSerializer, vin, "V", &protos.Payload{
+						CreatedAt: timestamppb.New(createdAt.Add(time.Duration(odometer) * time.Second)),
+						Data:      []*protos.Datum{doubleDatum(protos.Field_Odometer, float64(odometer))},
+					}))
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(persisted.Close()).To(Succeed())
+
+		restarted := newStore(&state.Config{SnapshotPath: snapshotPath})
+		defer func() { Expect(restarted.Close()).To(Succeed()) }()
+		for i := 0; i < 10; i++ {
+			vehicle, ok := restarted.Get(fmt.Sprintf("VIN%d", i))
+			Expect(ok).To(BeTrue())
+			Expect(vehicle.Fields["Odometer"].Value).To(MatchJSON(`{"doubleValue": 100}`))
+		}
+	})
+})
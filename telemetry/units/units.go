@@ -0,0 +1,234 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+// Unit systems values can be normalized to
+const (
+	// SI converts values to metric units (km/h, km, °C, kPa)
+	SI = "si"
+	// Imperial converts values to imperial units (mph, mi, °F, psi)
+	Imperial = "imperial"
+)
+
+// Symbols of the units of the fields
+const (
+	MilesPerHour      = "mph"
+	KilometersPerHour = "km/h"
+	Miles             = "mi"
+	Kilometers        = "km"
+	Celsius           = "°C"
+	Fahrenheit        = "°F"
+	Bar               = "bar"
+	Kilopascals       = "kPa"
+	Psi               = "psi"
+	Kilowatts         = "kW"
+	KilowattHours     = "kWh"
+	Volts             = "V"
+	Amperes           = "A"
+	Percent           = "%"
+	Hours             = "h"
+	Minutes           = "min"
+)
+
+// Config of the unit normalization of V records
+type Config struct {
+	// System is the unit system values are converted to, "si" or "imperial". Default: the units the vehicles
+	// report, which are fixed per field whatever the unit settings of the vehicle
+	System string `json:"system,omitempty"`
+
+	// Annotate adds the unit of the values to the output of the logger dispatcher, which it also does when verbose
+	Annotate bool `json:"annotate,omitempty"`
+}
+
+// catalogue is the unit vehicles report each field in
+var catalogue = map[protos.Field]string{
+	protos.Field_VehicleSpeed:          MilesPerHour,
+	protos.Field_CurrentLimitMph:       MilesPerHour,
+	protos.Field_ChargeRateMilePerHour: MilesPerHour,
+
+	protos.Field_Odometer:                   Miles,
+	protos.Field_RatedRange:                 Miles,
+	protos.Field_EstBatteryRange:            Miles,
+	protos.Field_IdealBatteryRange:          Miles,
+	protos.Field_MilesToArrival:             Miles,
+	protos.Field_MilesSinceReset:            Miles,
+	protos.Field_SelfDrivingMilesSinceReset: Miles,
+
+	protos.Field_InsideTemp:                  Celsius,
+	protos.Field_OutsideTemp:                 Celsius,
+	protos.Field_ModuleTempMax:               Celsius,
+	protos.Field_ModuleTempMin:               Celsius,
+	protos.Field_HvacLeftTemperatureRequest:  Celsius,
+	protos.Field_HvacRightTemperatureRequest: Celsius,
+	protos.Field_DiHeatsinkTR:                Celsius,
+	protos.Field_DiHeatsinkTF:                Celsius,
+	protos.Field_DiHeatsinkTREL:              Celsius,
+	protos.Field_DiHeatsinkTRER:              Celsius,
+	protos.Field_DiStatorTempR:               Celsius,
+	protos.Field_DiStatorTempF:               Celsius,
+	protos.Field_DiStatorTempREL:             Celsius,
+	protos.Field_DiStatorTempRER:             Celsius,
+	protos.Field_DiInverterTR:                Celsius,
+	protos.Field_DiInverterTF:                Celsius,
+	protos.Field_DiInverterTREL:              Celsius,
+	protos.Field_DiInverterTRER:              Celsius,
+
+	protos.Field_TpmsPressureFl:             Bar,
+	protos.Field_TpmsPressureFr:             Bar,
+	protos.Field_TpmsPressureRl:             Bar,
+	protos.Field_TpmsPressureRr:             Bar,
+	protos.Field_SemitruckTpmsPressureRe1L0: Bar,
+	protos.Field_SemitruckTpmsPressureRe1L1: Bar,
+	protos.Field_SemitruckTpmsPressureRe1R0: Bar,
+	protos.Field_SemitruckTpmsPressureRe1R1: Bar,
+	protos.Field_SemitruckTpmsPressureRe2L0: Bar,
+	protos.Field_SemitruckTpmsPressureRe2L1: Bar,
+	protos.Field_SemitruckTpmsPressureRe2R0: Bar,
+	protos.Field_SemitruckTpmsPressureRe2R1: Bar,
+
+	protos.Field_DCChargingPower:                Kilowatts,
+	protos.Field_ACChargingPower:                Kilowatts,
+	protos.Field_PowershareInstantaneousPowerKW: Kilowatts,
+
+	protos.Field_DCChargingEnergyIn:        KilowattHours,
+	protos.Field_ACChargingEnergyIn:        KilowattHours,
+	protos.Field_EnergyRemaining:           KilowattHours,
+	protos.Field_LifetimeEnergyUsed:        KilowattHours,
+	protos.Field_LifetimeEnergyUsedDrive:   KilowattHours,
+	protos.Field_LifetimeEnergyGainedRegen: KilowattHours,
+
+	protos.Field_PackVoltage:     Volts,
+	protos.Field_BrickVoltageMax: Volts,
+	protos.Field_BrickVoltageMin: Volts,
+	protos.Field_ChargerVoltage:  Volts,
+	protos.Field_DiVBatR:         Volts,
+	protos.Field_DiVBatF:         Volts,
+	protos.Field_DiVBatREL:       Volts,
+	protos.Field_DiVBatRER:       Volts,
+
+	protos.Field_PackCurrent:             Amperes,
+	protos.Field_ChargeAmps:              Amperes,
+	protos.Field_ChargeCurrentRequest:    Amperes,
+	protos.Field_ChargeCurrentRequestMax: Amperes,
+	protos.Field_DiMotorCurrentR:         Amperes,
+	protos.Field_DiMotorCurrentF:         Amperes,
+	protos.Field_DiMotorCurrentREL:       Amperes,
+	protos.Field_DiMotorCurrentRER:       Amperes,
+
+	protos.Field_Soc:                                       Percent,
+	protos.Field_BatteryLevel:                              Percent,
+	protos.Field_ChargeLimitSoc:                            Percent,
+	protos.Field_ExpectedEnergyPercentAtTripArrival:        Percent,
+	protos.Field_SoftwareUpdateDownloadPercentComplete:     Percent,
+	protos.Field_SoftwareUpdateInstallationPercentComplete: Percent,
+	protos.Field_TonneauOpenPercent:                        Percent,
+
+	protos.Field_TimeToFullCharge:                  Hours,
+	protos.Field_EstimatedHoursToChargeTermination: Hours,
+	protos.Field_PowershareHoursLeft:               Hours,
+
+	protos.Field_MinutesToArrival:                      Minutes,
+	protos.Field_RouteTrafficMinutesDelay:              Minutes,
+	protos.Field_SoftwareUpdateExpectedDurationMinutes: Minutes,
+}
+
+// conversion converts a value from a unit reported by vehicles to the unit of a system
+type conversion struct {
+	unit    string
+	convert func(float64) float64
+}
+
+// conversions of the reported units which differ per system, the others are the same in every system
+var conversions = map[string]map[string]conversion{
+	SI: {
+		MilesPerHour: {unit: KilometersPerHour, convert: func(v float64) float64 { return v * 1.609344 }},
+		Miles:        {unit: Kilometers, convert: func(v float64) float64 { return v * 1.609344 }},
+		Bar:          {unit: Kilopascals, convert: func(v float64) float64 { return v * 100 }},
+	},
+	Imperial: {
+		Celsius: {unit: Fahrenheit, convert: func(v float64) float64 { return v*9/5 + 32 }},
+		Bar:     {unit: Psi, convert: func(v float64) float64 { return v * 14.5037738 }},
+	},
+}
+
+// Converter normalizes the values of V records to the units of a system
+type Converter struct {
+	conversions map[string]conversion
+	annotate    bool
+}
+
+// NewConverter validates the config
+func NewConverter(config *Config) (*Converter, error) {
+	converter := &Converter{annotate: config.Annotate}
+	switch config.System {
+	case "":
+	case SI, Imperial:
+		converter.conversions = conversions[config.System]
+	default:
+		return nil, fmt.Errorf("invalid units system: %s", config.System)
+	}
+	return converter, nil
+}
+
+// Annotate returns whether the units are added to the output of the logger dispatcher
+func (c *Converter) Annotate() bool {
+	return c.annotate
+}
+
+// Unit returns the unit of the values of a field once normalized, false for fields without unit
+func (c *Converter) Unit(field protos.Field) (string, bool) {
+	unit, ok := catalogue[field]
+	if !ok {
+		return "", false
+	}
+	if conversion, ok := c.conversions[unit]; ok {
+		return conversion.unit, true
+	}
+	return unit, true
+}
+
+// TransformPayload converts the numeric values of the payload in place, keeping their type, and returns whether
+// any value changed. Values of string fields are converted when they are numbers.
+func (c *Converter) TransformPayload(payload *protos.Payload) bool {
+	changed := false
+	for _, datum := range payload.GetData() {
+		conversion, ok := c.conversions[catalogue[datum.GetKey()]]
+		if !ok {
+			continue
+		}
+		if value, ok := convertValue(datum.GetValue(), conversion.convert); ok {
+			datum.Value = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+func convertValue(value *protos.Value, convert func(float64) float64) (*protos.Value, bool) {
+	switch v := value.GetValue().(type) {
+	case *protos.Value_DoubleValue:
+		return &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: convert(v.DoubleValue)}}, true
+	case *protos.Value_FloatValue:
+		return &protos.Value{Value: &protos.Value_FloatValue{FloatValue: float32(convert(float64(v.FloatValue)))}}, true
+	case *protos.Value_IntValue:
+		return &protos.Value{Value: &protos.Value_IntValue{IntValue: int32(math.Round(convert(float64(v.IntValue))))}}, true
+	case *protos.Value_LongValue:
+		return &protos.Value{Value: &protos.Value_LongValue{LongValue: int64(math.Round(convert(float64(v.LongValue))))}}, true
+	case *protos.Value_StringValue:
+		number, err := strconv.ParseFloat(v.StringValue, 64)
+		if err != nil {
+			return nil, false
+		}
+		// rounded like the values transformed from scientific notation
+		converted := math.Round(convert(number)*1e5) / 1e5
+		return &protos.Value{Value: &protos.Value_StringValue{StringValue: strconv.FormatFloat(converted, 'f', -1, 64)}}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,13 @@
+package units_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestUnits(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Units Suite")
+}
@@ -0,0 +1,86 @@
+package units_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/protos"
+	"github.com/teslamotors/fleet-telemetry/telemetry/units"
+)
+
+func datum(field protos.Field, value *protos.Value) *protos.Datum {
+	return &protos.Datum{Key: field, Value: value}
+}
+
+func stringValue(value string) *protos.Value {
+	return &protos.Value{Value: &protos.Value_StringValue{StringValue: value}}
+}
+
+var _ = Describe("Units", func() {
+	It("rejects an unknown system", func() {
+		_, err := units.NewConverter(&units.Config{System: "metric"})
+		Expect(err).To(MatchError("invalid units system: metric"))
+	})
+
+	It("keeps the reported values without system", func() {
+		converter, err := units.NewConverter(&units.Config{})
+		Expect(err).NotTo(HaveOccurred())
+
+		payload := &protos.Payload{Data: []*protos.Datum{datum(protos.Field_VehicleSpeed, stringValue("60"))}}
+		Expect(converter.TransformPayload(payload)).To(BeFalse())
+		Expect(payload.Data[0].GetValue().GetStringValue()).To(Equal("60"))
+
+		unit, ok := converter.Unit(protos.Field_VehicleSpeed)
+		Expect(ok).To(BeTrue())
+		Expect(unit).To(Equal(units.MilesPerHour))
+	})
+
+	It("converts to si", func() {
+		converter, err := units.NewConverter(&units.Config{System: units.SI})
+		Expect(err).NotTo(HaveOccurred())
+
+		payload := &protos.Payload{Data: []*protos.Datum{
+			datum(protos.Field_VehicleSpeed, stringValue("60")),
+			datum(protos.Field_Odometer, &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 100}}),
+			datum(protos.Field_TpmsPressureFl, &protos.Value{Value: &protos.Value_FloatValue{FloatValue: 2.9}}),
+			datum(protos.Field_InsideTemp, stringValue("21.5")),
+			datum(protos.Field_Gear, stringValue("D")),
+		}}
+		Expect(converter.TransformPayload(payload)).To(BeTrue())
+		Expect(payload.Data[0].GetValue().GetStringValue()).To(Equal("96.56064"))
+		Expect(payload.Data[1].GetValue().GetDoubleValue()).To(BeNumerically("~", 160.9344, 1e-9))
+		Expect(payload.Data[2].GetValue().GetFloatValue()).To(BeNumerically("~", 290, 1e-3))
+		Expect(payload.Data[3].GetValue().GetStringValue()).To(Equal("21.5"))
+		Expect(payload.Data[4].GetValue().GetStringValue()).To(Equal("D"))
+
+		unit, _ := converter.Unit(protos.Field_TpmsPressureFl)
+		Expect(unit).To(Equal(units.Kilopascals))
+		unit, _ = converter.Unit(protos.Field_InsideTemp)
+		Expect(unit).To(Equal(units.Celsius))
+	})
+
+	It("converts to imperial", func() {
+		converter, err := units.NewConverter(&units.Config{System: units.Imperial})
+		Expect(err).NotTo(HaveOccurred())
+
+		payload := &protos.Payload{Data: []*protos.Datum{
+			datum(protos.Field_OutsideTemp, &protos.Value{Value: &protos.Value_IntValue{IntValue: 20}}),
+			datum(protos.Field_TpmsPressureRr, stringValue("<invalid>")),
+			datum(protos.Field_VehicleSpeed, stringValue("60")),
+		}}
+		Expect(converter.TransformPayload(payload)).To(BeTrue())
+		Expect(payload.Data[0].GetValue().GetIntValue()).To(Equal(int32(68)))
+		Expect(payload.Data[1].GetValue().GetStringValue()).To(Equal("<invalid>"))
+		Expect(payload.Data[2].GetValue().GetStringValue()).To(Equal("60"))
+
+		unit, _ := converter.Unit(protos.Field_OutsideTemp)
+		Expect(unit).To(Equal(units.Fahrenheit))
+	})
+
+	It("has no unit for fields without unit", func() {
+		converter, err := units.NewConverter(&units.Config{System: units.SI})
+		Expect(err).NotTo(HaveOccurred())
+		_, ok := converter.Unit(protos.Field_Gear)
+		Expect(ok).To(BeFalse())
+	})
+})
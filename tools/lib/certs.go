@@ -3,9 +3,12 @@ package lib
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math/big"
@@ -37,6 +40,55 @@ func (t *TestCertAndKey) SaveCerts(directory, name string) {
 	}
 }
 
+// TLSCertificate returns the cert and its key for use in a tls.Config
+func (t *TestCertAndKey) TLSCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{t.Cert.Raw}, PrivateKey: t.privateKey, Leaf: t.Cert}
+}
+
+// RemoveFiles deletes the temporary cert and key files
+func (t *TestCertAndKey) RemoveFiles() {
+	_ = os.Remove(t.CertFile)
+	_ = os.Remove(t.KeyFile)
+}
+
+// LoadTestCertAndKey loads a cert/key pair from PEM files, ex.: a signing CA saved by SaveCerts
+func LoadTestCertAndKey(certFile, keyFile string) (*TestCertAndKey, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("no private key found in %s", keyFile)
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		key, pkcs8Err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if pkcs8Err != nil {
+			return nil, err
+		}
+		var ok bool
+		if privateKey, ok = key.(*rsa.PrivateKey); !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+	}
+
+	return &TestCertAndKey{CertFile: certFile, KeyFile: keyFile, Cert: cert, privateKey: privateKey}, nil
+}
+
 // GenerateServerTestKeyAndCert generates a test server cert/key given a signing CA.
 func GenerateServerTestKeyAndCert(commonName string, sanDomains []string, sanIPs []string, parent *TestCertAndKey) (*TestCertAndKey, error) {
 	return GenerateServerTestKeyAndCertWithDate(commonName, sanDomains, sanIPs, parent, time.Now())
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// VehicleDialer opens the websocket of a vehicle, authenticated by a client certificate signed by ClientCA
+// with the VIN as common name
+type VehicleDialer struct {
+	// ClientCA signs the client certificate of each vehicle
+	ClientCA *TestCertAndKey
+
+	// RootCAs verifies the server certificate, the system pool is used when nil
+	RootCAs *x509.CertPool
+
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool
+
+	// ClientVersion is sent in the Version header
+	ClientVersion string
+
+	// NetworkInterface is sent in the X-Network-Interface header
+	NetworkInterface string
+}
+
+// Dial opens the websocket of the vin
+func (d *VehicleDialer) Dial(ctx context.Context, url, vin string) (*websocket.Conn, error) {
+	clientCert, err := GenerateClientTestKeyAndCert(vin, d.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	clientCert.RemoveFiles()
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		TLSClientConfig: &tls.Config{
+			Certificates:       []tls.Certificate{clientCert.TLSCertificate()},
+			RootCAs:            d.RootCAs,
+			InsecureSkipVerify: d.InsecureSkipVerify,
+		},
+	}
+	headers := http.Header{}
+	headers.Add("Version", d.ClientVersion)
+	headers.Add("X-Network-Interface", d.NetworkInterface)
+
+	conn, _, err := dialer.DialContext(ctx, url, headers)
+	return conn, err
+}
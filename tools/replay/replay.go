@@ -0,0 +1,233 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+)
+
+// Sink receives the replayed messages
+type Sink interface {
+	// Send replays a captured message
+	Send(ctx context.Context, frame *capture.Frame) error
+
+	// Close waits for the outcome of the messages sent and releases the sink
+	Close() error
+}
+
+// Config of a replay
+type Config struct {
+	// Files are the capture files replayed, in order
+	Files []string
+
+	// Speed multiplies the pace of the capture: 1 replays at the original pace, 10 ten times faster
+	// and 0 as fast as possible
+	Speed float64
+}
+
+// Run replays the frames of the capture files into the sink, preserving the time between messages divided by
+// the speed. A message the sink fails to send is counted and the replay goes on.
+func Run(ctx context.Context, config *Config, sink Sink, stats *Stats) error {
+	if len(config.Files) == 0 {
+		return errors.New("no capture files to replay")
+	}
+	if config.Speed < 0 {
+		return errors.New("speed must not be negative")
+	}
+
+	pacer := &pacer{speed: config.Speed}
+	for _, path := range config.Files {
+		err := capture.ReadFiles([]string{path}, func(frame *capture.Frame) error {
+			if err := pacer.wait(ctx, frame.ReceivedAt); err != nil {
+				return err
+			}
+			stats.frameRead()
+			if err := sink.Send(ctx, frame); err != nil {
+				stats.sendFailed(err)
+				return nil
+			}
+			stats.frameReplayed()
+			return nil
+		})
+		switch {
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			return sink.Close()
+		case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, capture.ErrCorruptFrame):
+			// the remainder of a truncated or corrupt file is skipped
+			stats.fileSkipped(fmt.Errorf("%s: %w", path, err))
+		case err != nil:
+			_ = sink.Close()
+			return err
+		}
+	}
+	return sink.Close()
+}
+
+// pacer delays the frames to preserve the time elapsed between them in the capture
+type pacer struct {
+	speed      float64
+	startedAt  time.Time
+	capturedAt time.Time
+}
+
+func (p *pacer) wait(ctx context.Context, receivedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.speed == 0 {
+		return nil
+	}
+	if p.startedAt.IsZero() {
+		p.startedAt = time.Now()
+		p.capturedAt = receivedAt
+		return nil
+	}
+
+	delay := time.Until(p.startedAt.Add(time.Duration(float64(receivedAt.Sub(p.capturedAt)) / p.speed)))
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Stats accumulates the outcome of a replay
+type Stats struct {
+	mutex          sync.Mutex
+	read           int
+	replayed       int
+	failed         map[string]int
+	skippedFiles   []string
+	acks           int
+	errorResponses map[string]int
+}
+
+// Report is a snapshot of the stats
+type Report struct {
+	Elapsed        time.Duration
+	Read           int
+	Replayed       int
+	Failed         map[string]int
+	SkippedFiles   []string
+	Acks           int
+	ErrorResponses map[string]int
+}
+
+// NewStats returns empty stats
+func NewStats() *Stats {
+	return &Stats{
+		failed:         make(map[string]int),
+		errorResponses: make(map[string]int),
+	}
+}
+
+func (s *Stats) frameRead() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.read++
+}
+
+func (s *Stats) frameReplayed() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.replayed++
+}
+
+func (s *Stats) sendFailed(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failed[err.Error()]++
+}
+
+func (s *Stats) fileSkipped(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.skippedFiles = append(s.skippedFiles, err.Error())
+}
+
+func (s *Stats) ackReceived() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.acks++
+}
+
+func (s *Stats) errorReceived(message string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errorResponses[message]++
+}
+
+// Report returns a snapshot of the stats
+func (s *Stats) Report(elapsed time.Duration) Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return Report{
+		Elapsed:        elapsed,
+		Read:           s.read,
+		Replayed:       s.replayed,
+		Failed:         copyCounts(s.failed),
+		SkippedFiles:   append([]string{}, s.skippedFiles...),
+		Acks:           s.acks,
+		ErrorResponses: copyCounts(s.errorResponses),
+	}
+}
+
+// Print writes the report in a human readable form
+func (r Report) Print(w io.Writer) {
+	rate := 0.0
+	if r.Elapsed > 0 {
+		rate = float64(r.Replayed) / r.Elapsed.Seconds()
+	}
+
+	_, _ = fmt.Fprintf(w, "elapsed:         %s\n", r.Elapsed.Round(time.Millisecond))
+	_, _ = fmt.Fprintf(w, "messages read:   %d\n", r.Read)
+	_, _ = fmt.Fprintf(w, "replayed:        %d (%.1f/s)\n", r.Replayed, rate)
+	_, _ = fmt.Fprintf(w, "failed:          %d\n", sumCounts(r.Failed))
+	_, _ = fmt.Fprintf(w, "acks received:   %d\n", r.Acks)
+	_, _ = fmt.Fprintf(w, "error responses: %d\n", sumCounts(r.ErrorResponses))
+	printCounts(w, "failure", r.Failed)
+	printCounts(w, "error response", r.ErrorResponses)
+	for _, skipped := range r.SkippedFiles {
+		_, _ = fmt.Fprintf(w, "  skipped the end of %s\n", skipped)
+	}
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
+	}
+	return copied
+}
+
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+func printCounts(w io.Writer, label string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "  %s (%d): %s\n", label, counts[key], key)
+	}
+}
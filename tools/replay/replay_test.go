@@ -0,0 +1,151 @@
+package replay_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/tools/lib"
+	"github.com/teslamotors/fleet-telemetry/tools/replay"
+)
+
+type spyProducer struct {
+	produced []*telemetry.Record
+}
+
+func (p *spyProducer) Produce(entry *telemetry.Record)                 { p.produced = append(p.produced, entry) }
+func (p *spyProducer) Close() error                                    { return nil }
+func (p *spyProducer) ProcessReliableAck(_ *telemetry.Record)          {}
+func (p *spyProducer) ReportError(_ string, _ error, _ logrus.LogInfo) {}
+
+func streamMessage(vin, txid, topic string) []byte {
+	message := messages.StreamMessage{
+		TXID:         []byte(txid),
+		SenderID:     []byte("vehicle_device." + vin),
+		DeviceID:     []byte(vin),
+		DeviceType:   []byte("vehicle_device"),
+		MessageTopic: []byte(topic),
+		Payload:      []byte{},
+	}
+	data, err := message.ToBytes()
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
+
+var _ = Describe("Replay", func() {
+	var (
+		files      []string
+		receivedAt time.Time
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		writer, err := capture.NewWriter(&capture.Config{Dir: dir})
+		Expect(err).NotTo(HaveOccurred())
+		receivedAt = time.Now().Add(-time.Hour)
+		Expect(writer.Write("device-1", receivedAt, streamMessage("device-1", "txid-1", "V"))).To(Succeed())
+		Expect(writer.Write("device-2", receivedAt.Add(100*time.Millisecond), streamMessage("device-2", "txid-2", "alerts"))).To(Succeed())
+		Expect(writer.Write("device-1", receivedAt.Add(200*time.Millisecond), streamMessage("device-1", "txid-3", "V"))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		files, err = capture.ListFiles(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("ProducerSink", func() {
+		It("dispatches the records to the producers", func() {
+			logger, _ := logrus.NoOpLogger()
+			spy := &spyProducer{}
+			sink := replay.NewProducerSink(map[string][]telemetry.Producer{"V": {spy}}, false, logger)
+
+			stats := replay.NewStats()
+			Expect(replay.Run(context.Background(), &replay.Config{Files: files}, sink, stats)).To(Succeed())
+
+			Expect(spy.produced).To(HaveLen(2))
+			Expect(spy.produced[0].Txid).To(Equal("txid-1"))
+			Expect(spy.produced[0].Vin).To(Equal("device-1"))
+			Expect(spy.produced[1].Txid).To(Equal("txid-3"))
+
+			report := stats.Report(time.Second)
+			Expect(report.Read).To(Equal(3))
+			Expect(report.Replayed).To(Equal(3))
+			Expect(report.Failed).To(BeEmpty())
+		})
+
+		It("preserves the pace of the capture divided by the speed", func() {
+			logger, _ := logrus.NoOpLogger()
+			sink := replay.NewProducerSink(map[string][]telemetry.Producer{"V": {&spyProducer{}}}, false, logger)
+
+			start := time.Now()
+			Expect(replay.Run(context.Background(), &replay.Config{Files: files, Speed: 2}, sink, replay.NewStats())).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+		})
+
+		It("requires capture files", func() {
+			logger, _ := logrus.NoOpLogger()
+			sink := replay.NewProducerSink(nil, false, logger)
+			Expect(replay.Run(context.Background(), &replay.Config{}, sink, replay.NewStats())).To(MatchError("no capture files to replay"))
+		})
+	})
+
+	Describe("ServerSink", func() {
+		It("replays over a websocket per vehicle", func() {
+			clientCA, err := lib.GenerateRootSigningCert("Tesla Motors Products CA", nil)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(clientCA.RemoveFiles)
+
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(clientCA.Cert)
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer func() { _ = conn.Close() }()
+				for {
+					_, data, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					message, err := messages.StreamMessageFromBytes(data)
+					if err != nil {
+						return
+					}
+					response, _ := (&messages.StreamAckMessage{TXID: message.TXID, MessageTopic: message.MessageTopic}).ToBytes()
+					if err := conn.WriteMessage(websocket.BinaryMessage, response); err != nil {
+						return
+					}
+				}
+			}))
+			server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+			server.StartTLS()
+			defer server.Close()
+
+			rootCAs := x509.NewCertPool()
+			rootCAs.AddCert(server.Certificate())
+			stats := replay.NewStats()
+			dialer := &lib.VehicleDialer{ClientCA: clientCA, RootCAs: rootCAs, ClientVersion: "test", NetworkInterface: "wifi"}
+			sink := replay.NewServerSink("wss://"+strings.TrimPrefix(server.URL, "https://"), dialer, time.Second, stats)
+
+			Expect(replay.Run(context.Background(), &replay.Config{Files: files}, sink, stats)).To(Succeed())
+
+			report := stats.Report(time.Second)
+			Expect(report.Replayed).To(Equal(3))
+			Expect(report.Acks).To(Equal(3))
+			Expect(report.Failed).To(BeEmpty())
+		})
+	})
+})
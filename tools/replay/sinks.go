@@ -0,0 +1,179 @@
+package replay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	logrus "github.com/teslamotors/fleet-telemetry/logger"
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/messages/tesla"
+	"github.com/teslamotors/fleet-telemetry/telemetry"
+	"github.com/teslamotors/fleet-telemetry/telemetry/capture"
+	"github.com/teslamotors/fleet-telemetry/tools/lib"
+)
+
+const deviceType = "vehicle_device"
+
+// ServerSink re-injects the messages into a running server, over one websocket per vehicle
+type ServerSink struct {
+	url        string
+	dialer     *lib.VehicleDialer
+	ackTimeout time.Duration
+	stats      *Stats
+
+	connections map[string]*serverConnection
+}
+
+// serverConnection is the websocket of a vehicle and the number of messages waiting for a response
+type serverConnection struct {
+	conn       *websocket.Conn
+	mutex      sync.Mutex
+	pending    int
+	responses  chan struct{}
+	readerDone chan struct{}
+}
+
+// NewServerSink returns a sink replaying into the server at url. Close waits up to ackTimeout for the responses
+// of the messages sent.
+func NewServerSink(url string, dialer *lib.VehicleDialer, ackTimeout time.Duration, stats *Stats) *ServerSink {
+	return &ServerSink{
+		url:         url,
+		dialer:      dialer,
+		ackTimeout:  ackTimeout,
+		stats:       stats,
+		connections: make(map[string]*serverConnection),
+	}
+}
+
+// Send writes the message on the websocket of its vehicle, opening it on first use
+func (s *ServerSink) Send(ctx context.Context, frame *capture.Frame) error {
+	connection, ok := s.connections[frame.Vin]
+	if !ok {
+		conn, err := s.dialer.Dial(ctx, s.url, frame.Vin)
+		if err != nil {
+			return err
+		}
+		connection = &serverConnection{conn: conn, responses: make(chan struct{}, 1), readerDone: make(chan struct{})}
+		s.connections[frame.Vin] = connection
+		go connection.readResponses(s.stats)
+	}
+
+	connection.mutex.Lock()
+	connection.pending++
+	connection.mutex.Unlock()
+	if err := connection.conn.WriteMessage(websocket.BinaryMessage, frame.RawBytes); err != nil {
+		connection.mutex.Lock()
+		connection.pending--
+		connection.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close waits for the outstanding responses and closes the websockets
+func (s *ServerSink) Close() error {
+	deadline := time.Now().Add(s.ackTimeout)
+	for _, connection := range s.connections {
+		connection.awaitResponses(deadline)
+		_ = connection.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		_ = connection.conn.Close()
+	}
+	return nil
+}
+
+func (c *serverConnection) readResponses(stats *Stats) {
+	defer close(c.readerDone)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		envelope, _, err := tesla.FlatbuffersEnvelopeFromBytes(data)
+		if err != nil {
+			continue
+		}
+		switch envelope.MessageType() {
+		case tesla.MessageFlatbuffersStreamAck:
+			stats.ackReceived()
+		case tesla.MessageFlatbuffersStream:
+			if message, err := messages.StreamMessageFromBytes(data); err == nil {
+				stats.errorReceived(string(message.Payload))
+			}
+		default:
+			continue
+		}
+
+		c.mutex.Lock()
+		c.pending--
+		c.mutex.Unlock()
+		select {
+		case c.responses <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *serverConnection) awaitResponses(deadline time.Time) {
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+
+	for c.pendingCount() > 0 {
+		select {
+		case <-c.responses:
+		case <-c.readerDone:
+			return
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+func (c *serverConnection) pendingCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.pending
+}
+
+// ProducerSink parses the messages like the server does and dispatches the records to the producers directly
+type ProducerSink struct {
+	dispatchRules          map[string][]telemetry.Producer
+	transmitDecodedRecords bool
+	logger                 *logrus.Logger
+
+	serializers map[string]*telemetry.BinarySerializer
+}
+
+// NewProducerSink returns a sink dispatching to the producers of the dispatch rules
+func NewProducerSink(dispatchRules map[string][]telemetry.Producer, transmitDecodedRecords bool, logger *logrus.Logger) *ProducerSink {
+	return &ProducerSink{
+		dispatchRules:          dispatchRules,
+		transmitDecodedRecords: transmitDecodedRecords,
+		logger:                 logger,
+		serializers:            make(map[string]*telemetry.BinarySerializer),
+	}
+}
+
+// Send parses the message as a record of its vehicle and dispatches it
+func (s *ProducerSink) Send(_ context.Context, frame *capture.Frame) error {
+	serializer, ok := s.serializers[frame.Vin]
+	if !ok {
+		requestIdentity := &telemetry.RequestIdentity{DeviceID: frame.Vin, SenderID: messages.BuildClientID(deviceType, frame.Vin)}
+		serializer = telemetry.NewBinarySerializer(requestIdentity, s.dispatchRules, s.logger)
+		s.serializers[frame.Vin] = serializer
+	}
+
+	record, err := telemetry.NewRecord(serializer, frame.RawBytes, "replay", s.transmitDecodedRecords)
+	if err != nil {
+		return err
+	}
+	record.Dispatch()
+	return nil
+}
+
+// Close is a no-op, the producers are closed by their owner
+func (s *ProducerSink) Close() error {
+	return nil
+}
@@ -0,0 +1,171 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/messages/tesla"
+	"github.com/teslamotors/fleet-telemetry/protos"
+)
+
+const deviceType = "vehicle_device"
+
+// SupportedRecordTypes are the record types the simulator generates
+var SupportedRecordTypes = []string{"V", "alerts", "errors", "connectivity"}
+
+// ParseRecordWeights parses a comma separated list of record types with an optional weight, ex.: "V=8,alerts=1".
+// A record type without a weight has weight 1.
+func ParseRecordWeights(value string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		recordType, weight := entry, 1
+		if name, rawWeight, ok := strings.Cut(entry, "="); ok {
+			var err error
+			if weight, err = strconv.Atoi(rawWeight); err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight for record type %s: %s", name, rawWeight)
+			}
+			recordType = name
+		}
+		if !isSupportedRecordType(recordType) {
+			return nil, fmt.Errorf("unsupported record type: %s", recordType)
+		}
+		weights[recordType] = weight
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no record types in %q", value)
+	}
+	return weights, nil
+}
+
+func isSupportedRecordType(recordType string) bool {
+	for _, supported := range SupportedRecordTypes {
+		if supported == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// generator builds the messages of a vehicle, picking record types according to their weight
+type generator struct {
+	vin          string
+	senderID     string
+	connectionID string
+	recordTypes  []string
+	cumulative   []int
+	random       *rand.Rand
+	sequence     int
+}
+
+func newGenerator(vin string, weights map[string]int, seed int64) *generator {
+	recordTypes := make([]string, 0, len(weights))
+	for recordType := range weights {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+
+	cumulative := make([]int, len(recordTypes))
+	total := 0
+	for i, recordType := range recordTypes {
+		total += weights[recordType]
+		cumulative[i] = total
+	}
+
+	return &generator{
+		vin:          vin,
+		senderID:     messages.BuildClientID(deviceType, vin),
+		connectionID: fmt.Sprintf("simulator-%s-%d", vin, seed),
+		recordTypes:  recordTypes,
+		cumulative:   cumulative,
+		random:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// next returns the txid, the record type and the envelope of the next message
+func (g *generator) next() (string, string, []byte, error) {
+	g.sequence++
+	recordType := g.pickRecordType()
+	txid := fmt.Sprintf("%s-%d", g.vin, g.sequence)
+
+	payload, err := proto.Marshal(g.payload(recordType))
+	if err != nil {
+		return "", "", nil, err
+	}
+	now := time.Now()
+	envelope := tesla.FlatbuffersStreamToBytes([]byte(g.senderID), []byte(recordType), []byte(txid), payload, uint32(now.Unix()),
+		[]byte(txid), []byte(deviceType), []byte(g.vin), uint64(now.UnixMilli()))
+	return txid, recordType, envelope, nil
+}
+
+func (g *generator) pickRecordType() string {
+	pick := g.random.Intn(g.cumulative[len(g.cumulative)-1])
+	index := sort.SearchInts(g.cumulative, pick+1)
+	return g.recordTypes[index]
+}
+
+func (g *generator) payload(recordType string) proto.Message {
+	now := timestamppb.Now()
+	switch recordType {
+	case "alerts":
+		return &protos.VehicleAlerts{
+			Vin:       g.vin,
+			CreatedAt: now,
+			Alerts: []*protos.VehicleAlert{{
+				Name:      fmt.Sprintf("SIM_alert_%d", g.random.Intn(10)),
+				Audiences: []protos.Audience{protos.Audience_Customer},
+				StartedAt: now,
+			}},
+		}
+	case "errors":
+		return &protos.VehicleErrors{
+			Vin:       g.vin,
+			CreatedAt: now,
+			Errors: []*protos.VehicleError{{
+				Name:      fmt.Sprintf("SIM_error_%d", g.random.Intn(10)),
+				Body:      "simulated error",
+				Tags:      map[string]string{"source": "simulator"},
+				CreatedAt: now,
+			}},
+		}
+	case "connectivity":
+		status := protos.ConnectivityEvent_CONNECTED
+		if g.random.Intn(2) == 0 {
+			status = protos.ConnectivityEvent_DISCONNECTED
+		}
+		return &protos.VehicleConnectivity{
+			Vin:              g.vin,
+			ConnectionId:     g.connectionID,
+			Status:           status,
+			CreatedAt:        now,
+			NetworkInterface: "wifi",
+		}
+	default:
+		return &protos.Payload{
+			Vin:       g.vin,
+			CreatedAt: now,
+			Data: []*protos.Datum{
+				{Key: protos.Field_VehicleSpeed, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: g.random.Float64() * 120}}},
+				{Key: protos.Field_Soc, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 20 + g.random.Float64()*80}}},
+				{Key: protos.Field_Odometer, Value: &protos.Value{Value: &protos.Value_DoubleValue{DoubleValue: 10000 + float64(g.sequence)}}},
+				{Key: protos.Field_Gear, Value: &protos.Value{Value: &protos.Value_ShiftStateValue{ShiftStateValue: protos.ShiftState_ShiftStateD}}},
+				{Key: protos.Field_Location, Value: &protos.Value{Value: &protos.Value_LocationValue{LocationValue: &protos.LocationValue{
+					Latitude:  37.4 + g.random.Float64()/100,
+					Longitude: -122.1 - g.random.Float64()/100,
+				}}}},
+				{Key: protos.Field_VehicleName, Value: &protos.Value{Value: &protos.Value_StringValue{StringValue: "Simulated " + g.vin}}},
+			},
+		}
+	}
+}
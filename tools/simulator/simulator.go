@@ -0,0 +1,284 @@
+package simulator
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/messages/tesla"
+	"github.com/teslamotors/fleet-telemetry/tools/lib"
+)
+
+// Config of a simulation
+type Config struct {
+	// URL of the telemetry server, ex.: wss://localhost:4443
+	URL string
+
+	// Vehicles is the number of concurrent websockets
+	Vehicles int
+
+	// ConnectRate is the number of websockets opened per second while ramping up
+	ConnectRate float64
+
+	// MessageInterval is the time between two messages of a vehicle
+	MessageInterval time.Duration
+
+	// Duration of the simulation, from the first websocket opened
+	Duration time.Duration
+
+	// AckTimeout is how long a vehicle waits for the responses of its last messages before disconnecting
+	AckTimeout time.Duration
+
+	// RecordWeights maps the record types sent to their relative frequency
+	RecordWeights map[string]int
+
+	// VinPrefix prefixes the sequence number of each vehicle to build its VIN
+	VinPrefix string
+
+	// ClientCA signs the client certificate of each vehicle, its CN being the VIN
+	ClientCA *lib.TestCertAndKey
+
+	// RootCAs verifies the server certificate, the system pool is used when nil
+	RootCAs *x509.CertPool
+
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool
+
+	// ClientVersion is sent in the Version header
+	ClientVersion string
+
+	// NetworkInterface is sent in the X-Network-Interface header
+	NetworkInterface string
+}
+
+// Validate checks the config of a simulation
+func (c *Config) Validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("server url is required")
+	case c.Vehicles <= 0:
+		return errors.New("vehicles must be positive")
+	case c.ConnectRate <= 0:
+		return errors.New("connect rate must be positive")
+	case c.MessageInterval <= 0:
+		return errors.New("message interval must be positive")
+	case c.ClientCA == nil:
+		return errors.New("a client CA is required to sign vehicle certificates")
+	case len(c.RecordWeights) == 0:
+		return errors.New("at least one record type is required")
+	}
+	return nil
+}
+
+// Run opens the websockets of the vehicles at the configured rate and sends messages until the duration elapses
+// or the context is done, then waits for the outstanding responses. Stats are updated as responses arrive.
+func Run(ctx context.Context, config *Config, stats *Stats) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if config.Duration > 0 {
+		time.AfterFunc(config.Duration, cancel)
+	}
+
+	var wg sync.WaitGroup
+	connectTicker := time.NewTicker(time.Duration(float64(time.Second) / config.ConnectRate))
+	defer connectTicker.Stop()
+
+	for i := 0; i < config.Vehicles; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return nil
+			case <-connectTicker.C:
+			}
+		}
+
+		v := &vehicle{
+			vin:       fmt.Sprintf("%s%06d", config.VinPrefix, i),
+			config:    config,
+			stats:     stats,
+			pending:   make(map[string]pendingMessage),
+			responses: make(chan struct{}, 1),
+		}
+		v.generator = newGenerator(v.vin, config.RecordWeights, time.Now().UnixNano()+int64(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := v.run(ctx); err != nil {
+				stats.connectionFailed(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// pendingMessage is a message waiting for its response
+type pendingMessage struct {
+	recordType string
+	sentAt     time.Time
+}
+
+// vehicle is a simulated vehicle sending messages over its own websocket
+type vehicle struct {
+	vin       string
+	config    *Config
+	stats     *Stats
+	generator *generator
+
+	mutex     sync.Mutex
+	pending   map[string]pendingMessage
+	responses chan struct{}
+}
+
+func (v *vehicle) run(ctx context.Context) error {
+	conn, err := v.dial(ctx)
+	if err != nil {
+		return err
+	}
+	v.stats.connected()
+	defer func() { _ = conn.Close() }()
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		v.readResponses(conn)
+	}()
+
+	ticker := time.NewTicker(v.config.MessageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			v.awaitResponses(readerDone)
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			return nil
+		case <-readerDone:
+			v.stats.disconnected()
+			v.stats.responsesMissing(v.pendingCount())
+			return nil
+		case <-ticker.C:
+			if err := v.send(conn); err != nil {
+				v.stats.disconnected()
+				v.stats.responsesMissing(v.pendingCount())
+				return nil
+			}
+		}
+	}
+}
+
+func (v *vehicle) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := &lib.VehicleDialer{
+		ClientCA:           v.config.ClientCA,
+		RootCAs:            v.config.RootCAs,
+		InsecureSkipVerify: v.config.InsecureSkipVerify,
+		ClientVersion:      v.config.ClientVersion,
+		NetworkInterface:   v.config.NetworkInterface,
+	}
+	return dialer.Dial(ctx, v.config.URL, v.vin)
+}
+
+func (v *vehicle) send(conn *websocket.Conn) error {
+	txid, recordType, message, err := v.generator.next()
+	if err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	v.pending[txid] = pendingMessage{recordType: recordType, sentAt: time.Now()}
+	v.mutex.Unlock()
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+		v.mutex.Lock()
+		delete(v.pending, txid)
+		v.mutex.Unlock()
+		return err
+	}
+	v.stats.messageSent(recordType)
+	return nil
+}
+
+func (v *vehicle) readResponses(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		v.handleResponse(data)
+	}
+}
+
+// handleResponse matches an ack or an error response with the message it answers
+func (v *vehicle) handleResponse(data []byte) {
+	envelope, _, err := tesla.FlatbuffersEnvelopeFromBytes(data)
+	if err != nil {
+		v.stats.unexpectedResponse()
+		return
+	}
+	txid := string(envelope.TxidBytes())
+
+	v.mutex.Lock()
+	pending, ok := v.pending[txid]
+	delete(v.pending, txid)
+	v.mutex.Unlock()
+	if !ok {
+		v.stats.unexpectedResponse()
+		return
+	}
+	latency := time.Since(pending.sentAt)
+
+	switch envelope.MessageType() {
+	case tesla.MessageFlatbuffersStreamAck:
+		v.stats.ackReceived(pending.recordType, latency, string(envelope.TopicBytes()) == pending.recordType)
+	case tesla.MessageFlatbuffersStream:
+		message, err := messages.StreamMessageFromBytes(data)
+		if err != nil {
+			v.stats.unexpectedResponse()
+			return
+		}
+		v.stats.errorReceived(string(message.Payload), latency)
+	default:
+		v.stats.unexpectedResponse()
+	}
+
+	select {
+	case v.responses <- struct{}{}:
+	default:
+	}
+}
+
+// awaitResponses waits up to the ack timeout for the responses of the messages sent, the others are reported missing
+func (v *vehicle) awaitResponses(readerDone <-chan struct{}) {
+	timeout := time.NewTimer(v.config.AckTimeout)
+	defer timeout.Stop()
+
+	for v.pendingCount() > 0 {
+		select {
+		case <-v.responses:
+		case <-readerDone:
+			v.stats.disconnected()
+			v.stats.responsesMissing(v.pendingCount())
+			return
+		case <-timeout.C:
+			v.stats.responsesMissing(v.pendingCount())
+			return
+		}
+	}
+}
+
+func (v *vehicle) pendingCount() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.pending)
+}
@@ -0,0 +1,149 @@
+package simulator_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/teslamotors/fleet-telemetry/messages"
+	"github.com/teslamotors/fleet-telemetry/tools/lib"
+	"github.com/teslamotors/fleet-telemetry/tools/simulator"
+)
+
+var _ = Describe("Simulator", func() {
+	Describe("ParseRecordWeights", func() {
+		It("parses weights", func() {
+			weights, err := simulator.ParseRecordWeights("V=8, alerts")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(weights).To(Equal(map[string]int{"V": 8, "alerts": 1}))
+		})
+
+		It("rejects unsupported record types", func() {
+			_, err := simulator.ParseRecordWeights("V,metrics")
+			Expect(err).To(MatchError("unsupported record type: metrics"))
+		})
+
+		It("rejects invalid weights", func() {
+			_, err := simulator.ParseRecordWeights("V=0")
+			Expect(err).To(MatchError("invalid weight for record type V: 0"))
+		})
+	})
+
+	Describe("Report", func() {
+		It("is empty without messages", func() {
+			report := simulator.NewStats().Report(time.Second)
+			Expect(report.Latency).To(Equal(simulator.LatencyPercentiles{}))
+			Expect(report.Sent).To(BeEmpty())
+		})
+	})
+
+	Describe("Run", func() {
+		var (
+			clientCA *lib.TestCertAndKey
+			server   *httptest.Server
+			config   *simulator.Config
+		)
+
+		BeforeEach(func() {
+			var err error
+			clientCA, err = lib.GenerateRootSigningCert("Tesla Motors Products CA", nil)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(clientCA.RemoveFiles)
+
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(clientCA.Cert)
+			upgrader := websocket.Upgrader{}
+			server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				vin := r.TLS.PeerCertificates[0].Subject.CommonName
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer func() { _ = conn.Close() }()
+				for {
+					_, data, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					message, err := messages.StreamMessageFromBytes(data)
+					if err != nil || string(message.SenderID) != "vehicle_device."+vin {
+						return
+					}
+					var response []byte
+					if message.Topic() == "alerts" {
+						response, _ = (&messages.StreamMessage{TXID: message.TXID, Payload: []byte("alerts are rejected")}).ToBytes()
+					} else {
+						response, _ = (&messages.StreamAckMessage{TXID: message.TXID, MessageTopic: message.MessageTopic}).ToBytes()
+					}
+					if err := conn.WriteMessage(websocket.BinaryMessage, response); err != nil {
+						return
+					}
+				}
+			}))
+			server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+			server.StartTLS()
+
+			rootCAs := x509.NewCertPool()
+			rootCAs.AddCert(server.Certificate())
+			config = &simulator.Config{
+				URL:              "wss://" + strings.TrimPrefix(server.URL, "https://"),
+				Vehicles:         2,
+				ConnectRate:      100,
+				MessageInterval:  10 * time.Millisecond,
+				Duration:         300 * time.Millisecond,
+				AckTimeout:       time.Second,
+				RecordWeights:    map[string]int{"V": 1, "alerts": 1},
+				VinPrefix:        "TESTVIN",
+				ClientCA:         clientCA,
+				RootCAs:          rootCAs,
+				ClientVersion:    "test",
+				NetworkInterface: "wifi",
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("validates acks and error responses", func() {
+			stats := simulator.NewStats()
+			Expect(simulator.Run(context.Background(), config, stats)).To(Succeed())
+
+			report := stats.Report(time.Second)
+			Expect(report.Connections).To(Equal(2))
+			Expect(report.ConnectionErrors).To(BeEmpty())
+			Expect(report.Sent["V"]).To(BeNumerically(">", 0))
+			Expect(report.Sent["alerts"]).To(BeNumerically(">", 0))
+			Expect(report.Acked).To(Equal(map[string]int{"V": report.Sent["V"]}))
+			Expect(report.Errors).To(Equal(map[string]int{"alerts are rejected": report.Sent["alerts"]}))
+			Expect(report.MismatchedAcks).To(BeZero())
+			Expect(report.Unacked).To(BeZero())
+			Expect(report.Latency.P50).To(BeNumerically(">", 0))
+			Expect(report.Latency.Max).To(BeNumerically(">=", report.Latency.P99))
+		})
+
+		It("reports connection errors", func() {
+			config.RootCAs = nil
+			stats := simulator.NewStats()
+			Expect(simulator.Run(context.Background(), config, stats)).To(Succeed())
+
+			report := stats.Report(time.Second)
+			Expect(report.Connections).To(BeZero())
+			Expect(report.ConnectionErrors).To(HaveLen(1))
+			Expect(report.Sent).To(BeEmpty())
+		})
+
+		It("validates the config", func() {
+			config.ClientCA = nil
+			Expect(simulator.Run(context.Background(), config, simulator.NewStats())).To(MatchError("a client CA is required to sign vehicle certificates"))
+		})
+	})
+})
@@ -0,0 +1,209 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats accumulates the outcome of the messages sent by every simulated vehicle
+type Stats struct {
+	mutex            sync.Mutex
+	connections      int
+	connectionErrors map[string]int
+	disconnections   int
+	sent             map[string]int
+	acked            map[string]int
+	errors           map[string]int
+	mismatchedAcks   int
+	unexpected       int
+	unacked          int
+	latencies        []time.Duration
+}
+
+// Report is a snapshot of the stats
+type Report struct {
+	Elapsed          time.Duration
+	Connections      int
+	ConnectionErrors map[string]int
+	Disconnections   int
+	Sent             map[string]int
+	Acked            map[string]int
+	Errors           map[string]int
+	MismatchedAcks   int
+	Unexpected       int
+	Unacked          int
+	Latency          LatencyPercentiles
+}
+
+// LatencyPercentiles of the time between sending a message and receiving its response
+type LatencyPercentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// NewStats returns empty stats
+func NewStats() *Stats {
+	return &Stats{
+		connectionErrors: make(map[string]int),
+		sent:             make(map[string]int),
+		acked:            make(map[string]int),
+		errors:           make(map[string]int),
+	}
+}
+
+func (s *Stats) connected() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connections++
+}
+
+func (s *Stats) connectionFailed(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connectionErrors[err.Error()]++
+}
+
+func (s *Stats) disconnected() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.disconnections++
+}
+
+func (s *Stats) messageSent(recordType string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sent[recordType]++
+}
+
+func (s *Stats) ackReceived(recordType string, latency time.Duration, topicMatches bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.acked[recordType]++
+	s.latencies = append(s.latencies, latency)
+	if !topicMatches {
+		s.mismatchedAcks++
+	}
+}
+
+func (s *Stats) errorReceived(message string, latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errors[message]++
+	s.latencies = append(s.latencies, latency)
+}
+
+func (s *Stats) unexpectedResponse() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unexpected++
+}
+
+func (s *Stats) responsesMissing(count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unacked += count
+}
+
+// Report returns a snapshot of the stats
+func (s *Stats) Report(elapsed time.Duration) Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	latencies := append([]time.Duration{}, s.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	return Report{
+		Elapsed:          elapsed,
+		Connections:      s.connections,
+		ConnectionErrors: copyCounts(s.connectionErrors),
+		Disconnections:   s.disconnections,
+		Sent:             copyCounts(s.sent),
+		Acked:            copyCounts(s.acked),
+		Errors:           copyCounts(s.errors),
+		MismatchedAcks:   s.mismatchedAcks,
+		Unexpected:       s.unexpected,
+		Unacked:          s.unacked,
+		Latency: LatencyPercentiles{
+			P50: percentile(latencies, 50),
+			P90: percentile(latencies, 90),
+			P99: percentile(latencies, 99),
+			Max: percentile(latencies, 100),
+		},
+	}
+}
+
+// percentile returns the nearest rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
+	}
+	return copied
+}
+
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// Print writes the report in a human readable form
+func (r Report) Print(w io.Writer) {
+	sent := sumCounts(r.Sent)
+	rate := 0.0
+	if r.Elapsed > 0 {
+		rate = float64(sent) / r.Elapsed.Seconds()
+	}
+
+	_, _ = fmt.Fprintf(w, "elapsed:           %s\n", r.Elapsed.Round(time.Millisecond))
+	_, _ = fmt.Fprintf(w, "connections:       %d opened, %d failed, %d closed by server\n", r.Connections, sumCounts(r.ConnectionErrors), r.Disconnections)
+	_, _ = fmt.Fprintf(w, "messages sent:     %d (%.1f/s) %s\n", sent, rate, formatCounts(r.Sent))
+	_, _ = fmt.Fprintf(w, "acks received:     %d %s\n", sumCounts(r.Acked), formatCounts(r.Acked))
+	_, _ = fmt.Fprintf(w, "errors received:   %d\n", sumCounts(r.Errors))
+	_, _ = fmt.Fprintf(w, "mismatched acks:   %d\n", r.MismatchedAcks)
+	_, _ = fmt.Fprintf(w, "unexpected:        %d\n", r.Unexpected)
+	_, _ = fmt.Fprintf(w, "missing responses: %d\n", r.Unacked)
+	_, _ = fmt.Fprintf(w, "latency:           p50=%s p90=%s p99=%s max=%s\n", r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+	printCounts(w, "connection error", r.ConnectionErrors)
+	printCounts(w, "error response", r.Errors)
+}
+
+func formatCounts(counts map[string]int) string {
+	keys := sortedKeys(counts)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func printCounts(w io.Writer, label string, counts map[string]int) {
+	for _, key := range sortedKeys(counts) {
+		_, _ = fmt.Fprintf(w, "  %s (%d): %s\n", label, counts[key], key)
+	}
+}
+
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}